@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/doctor"
+)
+
+// doctor checks config and external dependencies before a deploy, exiting non-zero when anything is broken
+func main() {
+	configPath := flag.String("config", "config.yml", "path to the YAML config")
+	flag.Parse()
+
+	cfg := config.Load(*configPath)
+	os.Exit(doctor.Diagnose(context.Background(), cfg, os.Stdout))
+}
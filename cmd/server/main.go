@@ -1,16 +1,47 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/doctor"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/router"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	check := flag.Bool("check", false, "check config and dependencies like cmd/doctor, then exit")
+	renormalize := flag.Bool(
+		"renormalize-emails", false, "recompute users.email_normalized after changing auth.email_normalization, then exit",
+	)
+	flag.Parse()
+
 	cfg := config.Load("config.yml")
+	if *check {
+		os.Exit(doctor.Diagnose(context.Background(), cfg, os.Stdout))
+	}
+	if *renormalize {
+		os.Exit(renormalizeEmails(cfg))
+	}
+	if err := cfg.JWT.CheckSecret(); err != nil {
+		log.Fatalln("Invalid JWT config:", err)
+	}
+
+	log.Printf(
+		"🚀 Starting %s %s on :%d (production: %t), effective config:\n%s",
+		cfg.App.Name,
+		cfg.App.Version,
+		cfg.Project.AppPort,
+		cfg.Project.IsProduction,
+		cfg.Summary(),
+	)
 
 	mainRouter := router.SetupRouter(cfg)
 
@@ -24,3 +55,28 @@ func main() {
 		log.Fatalln("Server failed to start:", err)
 	}
 }
+
+// renormalizeEmails backfills users.email_normalized with the configured providers, see user.Service.RenormalizeEmails
+func renormalizeEmails(cfg *config.Config) int {
+	clock := utils.RealClock{}
+	db := database.Connect(&cfg.Database, clock)
+	userService := user.NewService(
+		user.NewRepository(db, cfg.Database.QueryTimeout),
+		database.NewTxManager(db),
+		cfg.Subreddit.AllowedLanguages,
+		cfg.Auth.EmailNormalization,
+		cfg.Retention,
+		clock,
+	)
+
+	report, err := userService.RenormalizeEmails(context.Background())
+	log.Printf(
+		"Renormalized emails: %d scanned, %d updated, %d kept as placeholders",
+		report.Scanned, report.Updated, report.Placeholders,
+	)
+	if err != nil {
+		log.Println("Renormalizing emails stopped, rerun to resume:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// registerEmailRoutes mounts the admin email tools. Only called with a configured transport,
+// without one the routes don't exist instead of answering every request with 503.
+func registerEmailRoutes(router *gin.Engine, h *Handler, requireAuth gin.HandlerFunc) {
+	emailRouter := router.Group(
+		"/admin/email",
+		requireAuth,
+		user.RequireAdmin(h.userService),
+	)
+	{
+		emailRouter.POST("/test", h.SendTestEmail)
+	}
+}
+
+// SendTestEmail mails the calling admin through the configured transport, to verify delivery end to end
+func (h *Handler) SendTestEmail(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	currentUser, err := h.userService.GetUserById(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	err = h.email.Send(
+		c.Request.Context(), email.Message{
+			To:      currentUser.Email,
+			Subject: h.config.App.Name + " test email",
+			Text:    "Email delivery works.",
+			Tags:    []string{"admin-test"},
+		},
+	)
+	switch {
+	case err == nil:
+		c.Status(http.StatusNoContent)
+	case errors.Is(err, email.ErrNotConfigured):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Email is not available"})
+	default:
+		log.Println("Test email failed:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send email"})
+	}
+}
@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func newEmailRouter(emailSender email.Sender) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(nil, nil, nil, emailSender, cfg), utils.RealClock{})
+	return router
+}
+
+func TestEmailRoutesNeedConfiguredEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		sender email.Sender
+		want   int
+	}{
+		{"unconfigured", nil, http.StatusNotFound},
+		{"configured", testutil.NewRecordingSender(), http.StatusUnauthorized}, // Registered, stopped by requireAuth
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				recorder := httptest.NewRecorder()
+				newEmailRouter(tt.sender).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/email/test", nil))
+				if recorder.Code != tt.want {
+					t.Errorf("POST /admin/email/test: status %d, want %d", recorder.Code, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,465 @@
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Handler struct {
+	service          *Service
+	userService      *user.Service
+	subredditService *subreddit.Service
+	email            email.Sender // nil while email is unconfigured, see RegisterRoutes
+	config           *config.Config
+}
+
+func NewHandler(
+	service *Service,
+	userService *user.Service,
+	subredditService *subreddit.Service,
+	emailSender email.Sender,
+	cfg *config.Config,
+) *Handler {
+	return &Handler{
+		service:          service,
+		userService:      userService,
+		subredditService: subredditService,
+		email:            emailSender,
+		config:           cfg,
+	}
+}
+
+func (h *Handler) GetReadOnly(c *gin.Context) {
+	c.JSON(
+		http.StatusOK, ReadOnlyResponse{
+			Enabled: h.service.IsReadOnly(c.Request.Context()),
+		},
+	)
+}
+
+func (h *Handler) SetReadOnly(c *gin.Context) {
+	var req ReadOnlyRequest
+	if err := utils.BindJSON(c, &req); err != nil || req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if err := h.service.SetReadOnly(c.Request.Context(), *req.Enabled); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update read-only mode"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ReadOnlyResponse{Enabled: *req.Enabled})
+}
+
+func (h *Handler) ListFlags(c *gin.Context) {
+	responses, err := h.service.ListFlags(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to load feature flags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, responses)
+}
+
+func (h *Handler) SetFlag(c *gin.Context) {
+	var req SetFlagRequest
+	if err := utils.BindJSON(c, &req); err != nil || len(req.Value) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	response, err := h.service.SetFlag(c.Request.Context(), c.Param("name"), req.Value)
+	if err != nil {
+		flagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) ResetFlag(c *gin.Context) {
+	response, err := h.service.ResetFlag(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		flagError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func flagError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, flags.ErrUnknownFlag):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, flags.ErrInvalidValue):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, flags.ErrUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feature flag"})
+	}
+}
+
+// PreviewUserRetention is the retention dry run, it reports how many deleted accounts are due without purging
+func (h *Handler) PreviewUserRetention(c *gin.Context) {
+	report, err := h.userService.PreviewRetention(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute retention report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *Handler) CreateTopic(c *gin.Context) {
+	var req subreddit.CreateTopicRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	topic, err := h.subredditService.CreateTopic(c.Request.Context(), req)
+	if err != nil {
+		var validationErrs subreddit.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": validationErrs,
+				},
+			)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create topic"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, subreddit.ToTopicResponse(topic))
+}
+
+func (h *Handler) UpdateTopic(c *gin.Context) {
+	var req subreddit.UpdateTopicRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	topic, err := h.subredditService.UpdateTopic(c.Request.Context(), c.Param("slug"), req)
+	if err != nil {
+		var validationErrs subreddit.ValidationErrors
+		if errors.As(err, &validationErrs) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": validationErrs,
+				},
+			)
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update topic"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subreddit.ToTopicResponse(topic))
+}
+
+func (h *Handler) ForceDeleteSubreddit(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	adminID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	var req ForceDeleteSubredditRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" || len(reason) > MaxAuditReasonLen {
+		c.JSON(
+			http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("A reason between 1 and %d characters is required", MaxAuditReasonLen),
+			},
+		)
+		return
+	}
+
+	err := h.service.ForceDeleteSubreddit(c.Request.Context(), adminID, subredditID, reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subreddit not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subreddit"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// StartReconcile kicks off a counter recount, poll GET /admin/reconcile/:id for its progress
+func (h *Handler) StartReconcile(c *gin.Context) {
+	var req StartReconcileRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	run, err := h.service.StartReconcile(c.Request.Context(), strings.TrimSpace(req.Scope))
+	if err != nil {
+		h.reconcileError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, run)
+}
+
+func (h *Handler) GetReconcile(c *gin.Context) {
+	h.reconcileAction(c, http.StatusOK, h.service.GetReconcile)
+}
+
+func (h *Handler) AbortReconcile(c *gin.Context) {
+	h.reconcileAction(c, http.StatusAccepted, h.service.AbortReconcile)
+}
+
+func (h *Handler) ResumeReconcile(c *gin.Context) {
+	h.reconcileAction(c, http.StatusAccepted, h.service.ResumeReconcile)
+}
+
+func (h *Handler) reconcileAction(
+	c *gin.Context,
+	status int,
+	action func(ctx context.Context, runID uuid.UUID) (*ReconcileRun, error),
+) {
+	runID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+
+	run, err := action(c.Request.Context(), runID)
+	if err != nil {
+		h.reconcileError(c, err)
+		return
+	}
+	c.JSON(status, run)
+}
+
+func (h *Handler) reconcileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrReconcileScopeInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrReconcileNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reconciliation run not found"})
+	case errors.Is(err, ErrReconcileRunning):
+		c.JSON(http.StatusConflict, utils.APIError{Error: err.Error(), Code: "reconcile_running"})
+	case errors.Is(err, ErrReconcileNotRunning), errors.Is(err, ErrReconcileNotResumable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrReconcileUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+	default:
+		log.Println("Reconciliation request failed:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process reconciliation"})
+	}
+}
+
+// GetModLog serves a subreddit's mod log as cursor-paginated JSON, or with ?format=csv as a streamed export
+func (h *Handler) GetModLog(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	filter, ok := h.modLogFilterFromQuery(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	switch c.Query("format") {
+	case "", "json":
+		h.listModLog(c, subredditID, userID, filter)
+	case "csv":
+		h.exportModLog(c, subredditID, userID, filter)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of: json, csv"})
+	}
+}
+
+// modLogFilterFromQuery reads the moderator/action/target_type/since/until filters and writes the 400 itself
+func (h *Handler) modLogFilterFromQuery(c *gin.Context) (ModLogFilter, bool) {
+	filter := ModLogFilter{
+		Action:     strings.TrimSpace(c.Query("action")),
+		TargetType: strings.TrimSpace(c.Query("target_type")),
+	}
+
+	if username := strings.TrimSpace(c.Query("moderator")); username != "" {
+		moderator, err := h.userService.GetByUsername(c.Request.Context(), username)
+		if err != nil && !errors.Is(err, user.ErrNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mod log"})
+			return ModLogFilter{}, false
+		}
+		actorID := uuid.Nil // Unknown moderator, matches no entries
+		if moderator != nil {
+			actorID = moderator.ID
+		}
+		filter.ActorID = &actorID
+	}
+
+	var err error
+	if filter.Since, err = timeQuery(c, "since"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return ModLogFilter{}, false
+	}
+	if filter.Until, err = timeQuery(c, "until"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return ModLogFilter{}, false
+	}
+
+	return filter, true
+}
+
+// timeQuery parses an optional RFC 3339 query value, nil when absent
+func timeQuery(c *gin.Context, param string) (*time.Time, error) {
+	raw := c.Query(param)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+	}
+	return &parsed, nil
+}
+
+func (h *Handler) listModLog(c *gin.Context, subredditID, userID uuid.UUID, filter ModLogFilter) {
+	limit := ModLogDefaultLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(parsed, ModLogMaxLimit)
+	}
+
+	var after *utils.Cursor
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := utils.DecodeCursor(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, utils.APIError{Error: err.Error(), Code: "invalid_cursor"})
+			return
+		}
+		after = &cursor
+	}
+
+	entries, next, err := h.service.GetModLog(c.Request.Context(), subredditID, userID, filter, after, limit)
+	if err != nil {
+		h.modLogError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToModLogResponse(entries, next))
+}
+
+// exportModLog writes rows as they come from the database, nothing is buffered beyond the CSV writer
+func (h *Handler) exportModLog(c *gin.Context, subredditID, userID uuid.UUID, filter ModLogFilter) {
+	writer := csv.NewWriter(c.Writer)
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="modlog-`+subredditID.String()+`.csv"`)
+		c.Status(http.StatusOK)
+		return writer.Write(
+			[]string{"id", "created_at", "moderator", "action", "target_type", "target_id", "reason"},
+		)
+	}
+
+	err := h.service.ExportModLog(
+		c.Request.Context(), subredditID, userID, filter, func(entry ModLogEntry) error {
+			if err := start(); err != nil {
+				return err
+			}
+			reason := ""
+			if entry.Reason != nil {
+				reason = *entry.Reason
+			}
+			return writer.Write(
+				[]string{
+					entry.ID.String(),
+					entry.CreatedAt.UTC().Format(time.RFC3339),
+					csvSafe(entry.ActorUsername),
+					csvSafe(entry.Action),
+					csvSafe(entry.TargetType),
+					entry.TargetID.String(),
+					csvSafe(reason),
+				},
+			)
+		},
+	)
+	if err != nil {
+		if started {
+			log.Println("Mod log export aborted:", err) // Headers are out, the truncated body is all we can do
+			return
+		}
+		h.modLogError(c, err)
+		return
+	}
+
+	if err := start(); err != nil { // Header row for an empty export
+		log.Println("Mod log export aborted:", err)
+		return
+	}
+	writer.Flush()
+}
+
+func (h *Handler) modLogError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subreddit not found"})
+		return
+	}
+	if errors.Is(err, ErrNotAuthorized) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mod log"})
+}
+
+// csvSafe defuses values a spreadsheet would evaluate as a formula; csv.Writer handles the quoting
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/outbox"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+type adminEnv struct {
+	router           *gin.Engine
+	cfg              *config.Config
+	repo             *Repository
+	outboxRepo       *outbox.Repository
+	userRepo         *user.Repository
+	subredditService *subreddit.Service
+}
+
+func newAdminEnv(t *testing.T) adminEnv {
+	t.Helper()
+	return newAdminEnvWithConfig(t, testConfig())
+}
+
+func testConfig() *config.Config {
+	return &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+}
+
+func newAdminEnvWithConfig(t *testing.T, cfg *config.Config) adminEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	clock := utils.RealClock{}
+	db := testutil.Postgres(t, clock)
+	txManager := database.NewTxManager(db)
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	repo := NewRepository(db, 0)
+	outboxRepo := outbox.NewRepository(db, 0)
+	userRepo := user.NewRepository(db, 0)
+	userService := user.NewService(
+		userRepo, txManager, nil, config.EmailNormalizationConfig{}, config.RetentionConfig{}, clock,
+	)
+	subredditService := subreddit.NewService(
+		subreddit.NewRepository(db, 0),
+		userService,
+		txManager,
+		outbox.NewPublisher(outboxRepo),
+		repo,
+		redisClient,
+		config.SubredditConfig{},
+		clock,
+	)
+	service := NewService(
+		repo, txManager, subredditService, userService, redisClient, flags.NewStore(redisClient, nil, clock),
+		config.AuditConfig{}, clock,
+	)
+
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(service, userService, subredditService, nil, cfg), clock)
+	return adminEnv{
+		router:           router,
+		cfg:              cfg,
+		repo:             repo,
+		outboxRepo:       outboxRepo,
+		userRepo:         userRepo,
+		subredditService: subredditService,
+	}
+}
+
+func (env adminEnv) createUser(t *testing.T, name string, role user.Role) uuid.UUID {
+	t.Helper()
+
+	u := &user.User{
+		ID:               utils.NewID(),
+		Username:         name,
+		Email:            name + "@example.com",
+		EmailNormalized:  name + "@example.com",
+		UsernameSkeleton: utils.Skeleton(name),
+		AuthProvider:     user.AuthProviderEmail,
+		Role:             role,
+	}
+	if err := env.userRepo.Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func (env adminEnv) forceDelete(t *testing.T, actorID, subredditID uuid.UUID) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveAs(
+		t, env.router, env.cfg, http.MethodDelete, "/admin/subreddits/"+subredditID.String(),
+		`{"reason":"spam ring"}`, actorID,
+	)
+}
+
+// serveAs sends body as JSON, signed in as actorID unless it is uuid.Nil
+func serveAs(
+	t *testing.T,
+	router *gin.Engine,
+	cfg *config.Config,
+	method, path, body string,
+	actorID uuid.UUID,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if actorID != uuid.Nil {
+		token, err := utils.GenerateJWT(utils.RealClock{}, cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, actorID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestForceDeleteSubredditAsAdmin(t *testing.T) {
+	env := newAdminEnv(t)
+	ctx := context.Background()
+	creator := env.createUser(t, "creator", user.RoleUser)
+	adminID := env.createUser(t, "site_admin", user.RoleAdmin)
+	sub, err := env.subredditService.CreateSubreddit(ctx, creator, "doomed", "Doomed", nil, nil, true, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Warm the lite cache, the deletion has to evict it
+	if lites, err := env.subredditService.GetLiteByIDs(ctx, []uuid.UUID{sub.ID}); err != nil || len(lites) != 1 {
+		t.Fatalf("GetLiteByIDs before = %v, %v", lites, err)
+	}
+
+	if rec := env.forceDelete(t, adminID, sub.ID); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE as admin: status %d, body %s", rec.Code, rec.Body)
+	}
+
+	if _, err := env.subredditService.GetSubredditForModeration(ctx, sub.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("subreddit after force delete: err = %v, want not found", err)
+	}
+	if lites, err := env.subredditService.GetLiteByIDs(ctx, []uuid.UUID{sub.ID}); err != nil || len(lites) != 0 {
+		t.Errorf("GetLiteByIDs after = %v, %v; want the cached entry gone", lites, err)
+	}
+
+	entries, err := env.repo.ListModLog(ctx, sub.ID, ModLogFilter{}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != AuditActionSubredditForceDelete || entries[0].ActorID != adminID ||
+		entries[0].Reason == nil || *entries[0].Reason != "spam ring" {
+		t.Errorf("mod log = %+v, want one force delete by the admin with the reason", entries)
+	}
+
+	events, err := env.outboxRepo.ClaimPending(ctx, 100, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := false
+	for _, event := range events {
+		if event.Type == outbox.EventSubredditDeleted && strings.Contains(event.Payload, sub.ID.String()) {
+			published = true
+		}
+	}
+	if !published {
+		t.Errorf("no %s event for the subreddit among %d pending", outbox.EventSubredditDeleted, len(events))
+	}
+}
+
+func TestForceDeleteSubredditAsNonAdmin(t *testing.T) {
+	env := newAdminEnv(t)
+	ctx := context.Background()
+	creator := env.createUser(t, "creator", user.RoleUser)
+	sub, err := env.subredditService.CreateSubreddit(ctx, creator, "spared", "Spared", nil, nil, true, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Not even the creator may use the admin route
+	if rec := env.forceDelete(t, creator, sub.ID); rec.Code != http.StatusForbidden {
+		t.Fatalf("DELETE as non-admin: status %d, want 403", rec.Code)
+	}
+
+	if _, err := env.subredditService.GetSubredditForModeration(ctx, sub.ID); err != nil {
+		t.Errorf("subreddit after a rejected force delete: %v", err)
+	}
+	entries, err := env.repo.ListModLog(ctx, sub.ID, ModLogFilter{}, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Action == AuditActionSubredditForceDelete {
+			t.Errorf("rejected force delete was audited: %+v", entry)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyAllowlist holds mutating routes that keep working in read-only mode: the auth routes that only
+// read users or touch Redis, so sessions survive the window, and the toggles so admins can switch it off
+var readOnlyAllowlist = map[string]struct{}{
+	"/auth/login":             {},
+	"/auth/logout":            {},
+	"/auth/refresh":           {},
+	"/auth/password-strength": {},
+	"/admin/read-only":        {},
+	"/admin/flags/:name":      {},
+}
+
+// maintenanceStats is published under "maintenance" on GET /admin/metrics: read_only is a 0/1 gauge of
+// the mode as this instance last saw it, read_only_rejected counts the requests turned away
+var (
+	maintenanceStats = expvar.NewMap("maintenance")
+	readOnlyGauge    = new(expvar.Int)
+)
+
+func init() {
+	maintenanceStats.Set("read_only", readOnlyGauge)
+}
+
+// ReadOnlyMiddleware rejects every mutating request with 503 before it reaches the database
+// while the API is in read-only mode (e.g. during a database failover)
+func ReadOnlyMiddleware(service *Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if _, ok := readOnlyAllowlist[c.FullPath()]; ok {
+			c.Next()
+			return
+		}
+
+		if service.IsReadOnly(c.Request.Context()) {
+			maintenanceStats.Add("read_only_rejected", 1)
+			c.AbortWithStatusJSON(
+				http.StatusServiceUnavailable, utils.APIError{
+					Error: "The API is temporarily in read-only mode",
+					Code:  "read_only",
+				},
+			)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newReadOnlyRouter(t *testing.T) (*gin.Engine, *Service) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	service := &Service{flags: flags.NewStore(client, nil, utils.RealClock{})}
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router := gin.New()
+	router.Use(ReadOnlyMiddleware(service))
+	router.GET("/subreddits", ok)
+	router.POST("/subreddits", ok)
+	router.POST("/auth/login", ok)
+	router.POST("/auth/refresh", ok)
+	router.PUT("/admin/read-only", ok)
+	return router, service
+}
+
+func serve(router *gin.Engine, method, path string) int {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder.Code
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	router, service := newReadOnlyRouter(t)
+	if err := service.SetReadOnly(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/subreddits", http.StatusOK},
+		{http.MethodPost, "/subreddits", http.StatusServiceUnavailable},
+		{http.MethodPost, "/auth/login", http.StatusOK},
+		{http.MethodPost, "/auth/refresh", http.StatusOK},
+		{http.MethodPut, "/admin/read-only", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := serve(router, tt.method, tt.path); got != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+		}
+	}
+
+	if err := service.SetReadOnly(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve(router, http.MethodPost, "/subreddits"); got != http.StatusOK {
+		t.Errorf("POST /subreddits after switching off = %d, want 200", got)
+	}
+}
+
+func TestReadOnlyGauge(t *testing.T) {
+	router, service := newReadOnlyRouter(t)
+	ctx := context.Background()
+
+	if err := service.SetReadOnly(ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOnlyGauge.Value(); got != 1 {
+		t.Errorf("gauge after enabling = %d, want 1", got)
+	}
+
+	rejected := maintenanceStats.Get("read_only_rejected")
+	before := int64(0)
+	if rejected != nil {
+		before = rejected.(*expvar.Int).Value()
+	}
+	serve(router, http.MethodPost, "/subreddits")
+	if got := maintenanceStats.Get("read_only_rejected").(*expvar.Int).Value(); got != before+1 {
+		t.Errorf("read_only_rejected = %d, want %d", got, before+1)
+	}
+
+	if err := service.SetReadOnly(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOnlyGauge.Value(); got != 0 {
+		t.Errorf("gauge after disabling = %d, want 0", got)
+	}
+}
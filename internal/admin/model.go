@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditActionSubredditForceDelete = "subreddit.force_delete"
+
+	AuditTargetSubreddit = "subreddit"
+
+	MaxAuditReasonLen = 500
+
+	ModLogDefaultLimit  = 50
+	ModLogMaxLimit      = 100
+	ModLogExportMaxRows = 50_000
+)
+
+// AuditLog records who performed a privileged action, on what, and why
+type AuditLog struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey"`
+	ActorID     uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Action      string     `gorm:"size:50;not null"`
+	TargetType  string     `gorm:"size:50;not null"`
+	TargetID    uuid.UUID  `gorm:"type:uuid;not null"`
+	SubredditID *uuid.UUID `gorm:"type:uuid"`
+	Reason      *string    `gorm:"size:500"`
+	CreatedAt   time.Time
+}
+
+// ModLogEntry is an audit log row joined with the actor's username
+type ModLogEntry struct {
+	ID            uuid.UUID
+	ActorID       uuid.UUID
+	ActorUsername string
+	Action        string
+	TargetType    string
+	TargetID      uuid.UUID
+	Reason        *string
+	CreatedAt     time.Time
+}
@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"net/http/pprof"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/gin-gonic/gin"
+)
+
+// registerPprofRoutes mounts net/http/pprof under /debug/pprof for admins only.
+// CPU profiles and traces run for `seconds`, keep it below server.write_timeout.
+func registerPprofRoutes(router *gin.Engine, h *Handler, requireAuth gin.HandlerFunc) {
+	pprofRouter := router.Group(
+		"/debug/pprof",
+		requireAuth,
+		user.RequireAdmin(h.userService),
+	)
+	{
+		pprofRouter.GET("/*profile", pprofHandler)
+		pprofRouter.POST("/symbol", gin.WrapF(pprof.Symbol))
+	}
+}
+
+// pprofHandler dispatches by hand, gin can't mix a catch-all with static routes at the same level.
+// Named profiles (heap, goroutine, allocs, ...) are resolved by pprof.Index from the URL path.
+func pprofHandler(c *gin.Context) {
+	switch c.Param("profile") {
+	case "/cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "/profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "/symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "/trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Index(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+var pprofPaths = []string{"/debug/pprof/", "/debug/pprof/heap?debug=1", "/debug/pprof/cmdline"}
+
+// Off by default the routes don't exist at all, the admin check never runs so no database is needed
+func TestPprofDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig()
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(nil, nil, nil, nil, cfg), utils.RealClock{})
+
+	for _, path := range pprofPaths {
+		for _, actorID := range []uuid.UUID{uuid.Nil, uuid.New()} {
+			if rec := serveAs(t, router, cfg, http.MethodGet, path, "", actorID); rec.Code != http.StatusNotFound {
+				t.Errorf("GET %s (signed in %t): status %d, want 404", path, actorID != uuid.Nil, rec.Code)
+			}
+		}
+	}
+}
+
+func TestPprofRequiresAdmin(t *testing.T) {
+	cfg := testConfig()
+	cfg.Debug.PprofEnabled = true
+	env := newAdminEnvWithConfig(t, cfg)
+	member := env.createUser(t, "member", user.RoleUser)
+	adminID := env.createUser(t, "site_admin", user.RoleAdmin)
+
+	for _, path := range pprofPaths {
+		tests := []struct {
+			name    string
+			actorID uuid.UUID
+			want    int
+		}{
+			{"anonymous", uuid.Nil, http.StatusUnauthorized},
+			{"member", member, http.StatusForbidden},
+			{"admin", adminID, http.StatusOK},
+		}
+		for _, tt := range tests {
+			if rec := serveAs(t, env.router, env.cfg, http.MethodGet, path, "", tt.actorID); rec.Code != tt.want {
+				t.Errorf("GET %s as %s: status %d, want %d", path, tt.name, rec.Code, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ReconcileScopeMemberCounts = "subreddit_member_counts"
+	ReconcileScopeAll          = "all"
+
+	ReconcileStatusRunning   = "running"
+	ReconcileStatusCompleted = "completed"
+	ReconcileStatusAborted   = "aborted"
+	ReconcileStatusFailed    = "failed"
+
+	ReconcileBatchSize = 500
+
+	reconcileRunPrefix   = "admin:reconcile:run:"
+	reconcileAbortPrefix = "admin:reconcile:abort:"
+	reconcileLockPrefix  = "admin:reconcile:lock:"
+	reconcileRunTTL      = 7 * 24 * time.Hour
+	// Refreshed every batch, so a crashed instance only blocks its scopes for this long
+	reconcileLockTTL = time.Minute
+)
+
+// reconcileScopes are the counters that can be recounted, in the order "all" runs them
+var reconcileScopes = []string{ReconcileScopeMemberCounts}
+
+var (
+	ErrReconcileScopeInvalid = errors.New(
+		"scope must be one of: " + strings.Join(append(slices.Clone(reconcileScopes), ReconcileScopeAll), ", "),
+	)
+	ErrReconcileUnavailable  = errors.New("reconciliation needs Redis")
+	ErrReconcileRunning      = errors.New("a reconciliation of this scope is already running")
+	ErrReconcileNotFound     = errors.New("reconciliation run not found")
+	ErrReconcileNotRunning   = errors.New("reconciliation run is not running")
+	ErrReconcileNotResumable = errors.New("reconciliation run already completed")
+)
+
+type recountFunc func(ctx context.Context, afterID uuid.UUID, limit int) (subreddit.RecountBatch, error)
+
+func (s *Service) recounters() map[string]recountFunc {
+	return map[string]recountFunc{
+		ReconcileScopeMemberCounts: s.subredditService.RecountMemberCounts,
+	}
+}
+
+// StartReconcile recounts the counters of scope in the background and returns the run to poll.
+// Runs keep their progress in Redis, one per scope at a time across instances.
+func (s *Service) StartReconcile(ctx context.Context, scope string) (*ReconcileRun, error) {
+	if s.redis == nil {
+		return nil, ErrReconcileUnavailable
+	}
+
+	scopes := []string{scope}
+	if scope == ReconcileScopeAll {
+		scopes = reconcileScopes
+	} else if !slices.Contains(reconcileScopes, scope) {
+		return nil, ErrReconcileScopeInvalid
+	}
+
+	now := s.clock.Now()
+	run := &ReconcileRun{
+		ID:        utils.NewID(),
+		Scope:     scope,
+		Status:    ReconcileStatusRunning,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	for _, stepScope := range scopes {
+		run.Steps = append(run.Steps, ReconcileStep{Scope: stepScope})
+	}
+
+	locks, err := s.lockReconcile(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.saveReconcile(ctx, run); err != nil {
+		s.releaseReconcile(locks)
+		return nil, err
+	}
+
+	started := run.clone() // run belongs to the worker from here on
+	go s.reconcile(run, locks)
+	return started, nil
+}
+
+// ResumeReconcile continues an aborted or failed run from its cursors. A run left "running" by a crashed
+// instance can be resumed as well once its locks expired.
+func (s *Service) ResumeReconcile(ctx context.Context, runID uuid.UUID) (*ReconcileRun, error) {
+	run, err := s.GetReconcile(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status == ReconcileStatusCompleted {
+		return nil, ErrReconcileNotResumable
+	}
+
+	locks, err := s.lockReconcile(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.Del(ctx, reconcileAbortPrefix+run.ID.String()).Err(); err != nil {
+		s.releaseReconcile(locks)
+		return nil, fmt.Errorf("failed to clear abort flag: %w", err)
+	}
+
+	run.Status = ReconcileStatusRunning
+	run.Error = ""
+	run.UpdatedAt = s.clock.Now()
+	if err := s.saveReconcile(ctx, run); err != nil {
+		s.releaseReconcile(locks)
+		return nil, err
+	}
+
+	resumed := run.clone()
+	go s.reconcile(run, locks)
+	return resumed, nil
+}
+
+// AbortReconcile asks the run to stop after its current batch, its cursors stay for ResumeReconcile
+func (s *Service) AbortReconcile(ctx context.Context, runID uuid.UUID) (*ReconcileRun, error) {
+	run, err := s.GetReconcile(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run.Status != ReconcileStatusRunning {
+		return nil, ErrReconcileNotRunning
+	}
+
+	if err := s.redis.Set(ctx, reconcileAbortPrefix+run.ID.String(), "1", reconcileRunTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to set abort flag: %w", err)
+	}
+	return run, nil
+}
+
+func (s *Service) GetReconcile(ctx context.Context, runID uuid.UUID) (*ReconcileRun, error) {
+	if s.redis == nil {
+		return nil, ErrReconcileUnavailable
+	}
+
+	payload, err := s.redis.Get(ctx, reconcileRunPrefix+runID.String()).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrReconcileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load reconciliation run: %w", err)
+	}
+
+	var run ReconcileRun
+	if err := json.Unmarshal(payload, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode reconciliation run: %w", err)
+	}
+	return &run, nil
+}
+
+// reconcile works through the run's steps batch by batch, saving progress after each one
+func (s *Service) reconcile(run *ReconcileRun, locks []*utils.RedisLock) {
+	ctx := context.Background()
+	defer s.releaseReconcile(locks)
+
+	finish := func(status string, err error) {
+		run.Status = status
+		run.UpdatedAt = s.clock.Now()
+		if err != nil {
+			run.Error = err.Error()
+			log.Printf("Reconciliation %s (%s) %s: %v", run.ID, run.Scope, status, err)
+		}
+		if status == ReconcileStatusCompleted {
+			finishedAt := run.UpdatedAt
+			run.FinishedAt = &finishedAt
+		}
+		if err := s.saveReconcile(ctx, run); err != nil {
+			log.Println("Failed to save reconciliation run:", err)
+		}
+	}
+
+	recounters := s.recounters()
+	for i := range run.Steps {
+		step := &run.Steps[i]
+		for !step.Done {
+			aborted, err := s.redis.Exists(ctx, reconcileAbortPrefix+run.ID.String()).Result()
+			if err != nil {
+				finish(ReconcileStatusFailed, fmt.Errorf("failed to check abort flag: %w", err))
+				return
+			}
+			if aborted > 0 {
+				finish(ReconcileStatusAborted, nil)
+				return
+			}
+			for _, lock := range locks {
+				if held, err := lock.Refresh(ctx); err != nil || !held {
+					finish(ReconcileStatusFailed, fmt.Errorf("lost the %s lock", step.Scope))
+					return
+				}
+			}
+
+			batch, err := recounters[step.Scope](ctx, step.Cursor, ReconcileBatchSize)
+			if err != nil {
+				finish(ReconcileStatusFailed, err)
+				return
+			}
+			if batch.Scanned == 0 {
+				step.Done = true
+			} else {
+				step.Cursor = batch.LastID
+				step.Scanned += batch.Scanned
+				step.Corrected += batch.Corrected
+				step.MaxDrift = max(step.MaxDrift, batch.MaxDrift)
+			}
+
+			run.UpdatedAt = s.clock.Now()
+			if err := s.saveReconcile(ctx, run); err != nil {
+				finish(ReconcileStatusFailed, err)
+				return
+			}
+		}
+	}
+
+	finish(ReconcileStatusCompleted, nil)
+}
+
+// lockReconcile takes the lock of every unfinished step, or none of them
+func (s *Service) lockReconcile(ctx context.Context, run *ReconcileRun) ([]*utils.RedisLock, error) {
+	var locks []*utils.RedisLock
+	for _, step := range run.Steps {
+		if step.Done {
+			continue
+		}
+		lock, err := utils.TryLock(ctx, s.redis, reconcileLockPrefix+step.Scope, run.ID.String(), reconcileLockTTL)
+		if err != nil || lock == nil {
+			s.releaseReconcile(locks)
+			if err != nil {
+				return nil, fmt.Errorf("failed to lock %s: %w", step.Scope, err)
+			}
+			return nil, ErrReconcileRunning
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+func (s *Service) releaseReconcile(locks []*utils.RedisLock) {
+	for _, lock := range locks {
+		if err := lock.Release(context.Background()); err != nil {
+			log.Println("Failed to release reconciliation lock:", err)
+		}
+	}
+}
+
+func (s *Service) saveReconcile(ctx context.Context, run *ReconcileRun) error {
+	payload, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := s.redis.Set(ctx, reconcileRunPrefix+run.ID.String(), payload, reconcileRunTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save reconciliation run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db           *database.DB
+	queryTimeout time.Duration // Applied to every repository call, see utils.WithQueryTimeout
+}
+
+func NewRepository(db *database.DB, queryTimeout time.Duration) *Repository {
+	return &Repository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// conn joins the transaction carried by ctx (see database.TxManager) or uses the repository handle
+func (repo *Repository) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, repo.db.WriteDB())
+}
+
+func (repo *Repository) CreateAuditLog(ctx context.Context, entry *AuditLog) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Create(entry).Error
+}
+
+// RecordSubredditAction audits a subreddit state change, see subreddit.Auditor
+func (repo *Repository) RecordSubredditAction(ctx context.Context, actorID, subredditID uuid.UUID, action string) error {
+	return repo.CreateAuditLog(
+		ctx, &AuditLog{
+			ID:          utils.NewID(),
+			ActorID:     actorID,
+			Action:      action,
+			TargetType:  AuditTargetSubreddit,
+			TargetID:    subredditID,
+			SubredditID: &subredditID,
+		},
+	)
+}
+
+// modLogQuery selects a subreddit's audit entries with the actor's username, newest first
+func (repo *Repository) modLogQuery(ctx context.Context, subredditID uuid.UUID, filter ModLogFilter) *gorm.DB {
+	query := repo.conn(ctx).
+		Table("audit_logs").
+		Select(
+			"audit_logs.id", "audit_logs.actor_id", "users.username AS actor_username", "audit_logs.action",
+			"audit_logs.target_type", "audit_logs.target_id", "audit_logs.reason", "audit_logs.created_at",
+		).
+		Joins("LEFT JOIN users ON users.id = audit_logs.actor_id").
+		Where("audit_logs.subreddit_id = ?", subredditID)
+
+	if filter.ActorID != nil {
+		query = query.Where("audit_logs.actor_id = ?", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("audit_logs.action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("audit_logs.target_type = ?", filter.TargetType)
+	}
+	if filter.Since != nil {
+		query = query.Where("audit_logs.created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("audit_logs.created_at < ?", *filter.Until)
+	}
+
+	return query.Order("audit_logs.created_at DESC").Order("audit_logs.id DESC")
+}
+
+// ListModLog returns up to limit entries positioned after the cursor (nil for the first page)
+func (repo *Repository) ListModLog(
+	ctx context.Context,
+	subredditID uuid.UUID,
+	filter ModLogFilter,
+	after *utils.Cursor,
+	limit int,
+) ([]ModLogEntry, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	query := repo.modLogQuery(ctx, subredditID, filter)
+	if after != nil {
+		query = query.Where("(audit_logs.created_at, audit_logs.id) < (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	var entries []ModLogEntry
+	err := query.Limit(limit).Scan(&entries).Error
+	return entries, err
+}
+
+// StreamModLog hands rows to fn one at a time instead of loading the result set.
+// It runs on the caller's context rather than queryTimeout: an export legitimately outlasts
+// a single-query budget, and limit bounds it.
+func (repo *Repository) StreamModLog(
+	ctx context.Context,
+	subredditID uuid.UUID,
+	filter ModLogFilter,
+	limit int,
+	fn func(entry ModLogEntry) error,
+) error {
+	db := repo.conn(ctx)
+	rows, err := repo.modLogQuery(ctx, subredditID, filter).Limit(limit).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry ModLogEntry
+		if err := db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DeleteAuditLogsBefore prunes entries created before cutoff and reports how many were removed
+func (repo *Repository) DeleteAuditLogsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).Where("created_at < ?", cutoff).Delete(&AuditLog{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,53 @@
+package admin
+
+import (
+	"expvar"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterRoutes(router *gin.Engine, h *Handler, clock utils.Clock) {
+	requireAuth := utils.JWTAuthMiddleware(&h.config.JWT, clock)
+
+	adminRouter := router.Group(
+		"/admin",
+		requireAuth,
+		user.RequireAdmin(h.userService),
+	)
+	{
+		adminRouter.GET("/read-only", h.GetReadOnly)
+		adminRouter.PUT("/read-only", h.SetReadOnly)
+
+		adminRouter.GET("/flags", h.ListFlags)
+		adminRouter.PUT("/flags/:name", h.SetFlag)
+		adminRouter.DELETE("/flags/:name", h.ResetFlag)
+
+		adminRouter.POST("/topics", h.CreateTopic)
+		adminRouter.PATCH("/topics/:slug", h.UpdateTopic)
+
+		adminRouter.DELETE("/subreddits/:id", h.ForceDeleteSubreddit)
+
+		adminRouter.GET("/retention/users", h.PreviewUserRetention)
+
+		adminRouter.POST("/reconcile", h.StartReconcile)
+		adminRouter.GET("/reconcile/:id", h.GetReconcile)
+		adminRouter.POST("/reconcile/:id/abort", h.AbortReconcile)
+		adminRouter.POST("/reconcile/:id/resume", h.ResumeReconcile)
+
+		adminRouter.GET("/metrics", utils.KeepJSONKeys, gin.WrapH(expvar.Handler())) // Cache and concurrency limiter counters among others
+	}
+
+	// Lives under /subreddits but is served here, the subreddit package can't depend on admin
+	// Its CSV export streams the whole filtered log, so it gets a small pool of its own
+	modLog := utils.NewConcurrencyLimiter("modlog", h.config.Server.ConcurrencyLimits["modlog"])
+	router.GET("/subreddits/:id/modlog", requireAuth, modLog.Limit(1), h.GetModLog)
+
+	if h.config.Debug.PprofEnabled {
+		registerPprofRoutes(router, h, requireAuth)
+	}
+	if h.email != nil {
+		registerEmailRoutes(router, h, requireAuth)
+	}
+}
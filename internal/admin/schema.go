@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+type ReadOnlyRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type ReadOnlyResponse struct {
+	Enabled bool `json:"enabled"`
+}
+
+type SetFlagRequest struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// FlagResponse renders values by kind: true/false for bool flags, numbers otherwise
+type FlagResponse struct {
+	Name        string     `json:"name"`
+	Kind        flags.Kind `json:"kind"`
+	Description string     `json:"description"`
+	Value       any        `json:"value"`
+	Default     any        `json:"default"`
+	Overridden  bool       `json:"overridden"`
+}
+
+func toFlagResponse(definition flags.Definition, defaultValue int64, overrides map[string]int64) FlagResponse {
+	value, overridden := overrides[definition.Name]
+	if !overridden {
+		value = defaultValue
+	}
+	return FlagResponse{
+		Name:        definition.Name,
+		Kind:        definition.Kind,
+		Description: definition.Description,
+		Value:       definition.Render(value),
+		Default:     definition.Render(defaultValue),
+		Overridden:  overridden,
+	}
+}
+
+type StartReconcileRequest struct {
+	Scope string `json:"scope"`
+}
+
+// ReconcileRun is a counter recount started from POST /admin/reconcile, with one step per counter scope.
+// Steps keep their cursor, so an aborted or failed run resumes where it stopped.
+type ReconcileRun struct {
+	ID         uuid.UUID       `json:"id"`
+	Scope      string          `json:"scope"`
+	Status     string          `json:"status"`
+	Steps      []ReconcileStep `json:"steps"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// ReconcileStep is the diff summary of one scope: rows looked at, rows corrected and the largest correction
+type ReconcileStep struct {
+	Scope     string    `json:"scope"`
+	Cursor    uuid.UUID `json:"cursor"`
+	Done      bool      `json:"done"`
+	Scanned   int       `json:"scanned"`
+	Corrected int       `json:"corrected"`
+	MaxDrift  int       `json:"max_drift"`
+}
+
+func (r *ReconcileRun) clone() *ReconcileRun {
+	clone := *r
+	clone.Steps = slices.Clone(r.Steps)
+	return &clone
+}
+
+type ForceDeleteSubredditRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ModLogFilter narrows a subreddit's mod log; nil and empty fields are not applied.
+// Since is inclusive, Until exclusive.
+type ModLogFilter struct {
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	Since      *time.Time
+	Until      *time.Time
+}
+
+type ModLogEntryResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Moderator  string    `json:"moderator"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   uuid.UUID `json:"target_id"`
+	Reason     *string   `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ModLogResponse struct {
+	Entries    []ModLogEntryResponse `json:"entries"`
+	NextCursor *string               `json:"next_cursor"` // Null on the last page
+}
+
+func ToModLogResponse(entries []ModLogEntry, next *utils.Cursor) ModLogResponse {
+	responses := make([]ModLogEntryResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = ModLogEntryResponse{
+			ID:         entry.ID,
+			Moderator:  entry.ActorUsername,
+			Action:     entry.Action,
+			TargetType: entry.TargetType,
+			TargetID:   entry.TargetID,
+			Reason:     entry.Reason,
+			CreatedAt:  entry.CreatedAt,
+		}
+	}
+
+	response := ModLogResponse{Entries: responses}
+	if next != nil {
+		encoded := next.Encode()
+		response.NextCursor = &encoded
+	}
+	return response
+}
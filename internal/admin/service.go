@@ -0,0 +1,249 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// LegacyReadOnlyKey held the read-only toggle before it became the flags.MaintenanceReadOnly feature flag
+const LegacyReadOnlyKey = "maintenance:read_only"
+
+var ErrNotAuthorized = errors.New("not authorized to perform this action")
+
+type Service struct {
+	repo             *Repository
+	txManager        *database.TxManager
+	subredditService *subreddit.Service
+	userService      *user.Service
+	redis            *redis.Client
+	flags            *flags.Store
+	auditCfg         config.AuditConfig
+	clock            utils.Clock
+}
+
+func NewService(
+	repo *Repository,
+	txManager *database.TxManager,
+	subredditService *subreddit.Service,
+	userService *user.Service,
+	redisClient *redis.Client,
+	flagStore *flags.Store,
+	auditCfg config.AuditConfig,
+	clock utils.Clock,
+) *Service {
+	return &Service{
+		repo:             repo,
+		txManager:        txManager,
+		subredditService: subredditService,
+		userService:      userService,
+		redis:            redisClient,
+		flags:            flagStore,
+		auditCfg:         auditCfg,
+		clock:            clock,
+	}
+}
+
+// IsReadOnly reads the flags.MaintenanceReadOnly flag, maintenance.read_only in config is its default
+func (s *Service) IsReadOnly(ctx context.Context) bool {
+	readOnly := s.flags.Bool(ctx, flags.MaintenanceReadOnly)
+	readOnlyGauge.Set(flags.BoolValue(readOnly))
+	return readOnly
+}
+
+func (s *Service) SetReadOnly(ctx context.Context, enabled bool) error {
+	if err := s.flags.Set(ctx, flags.MaintenanceReadOnly, flags.BoolValue(enabled)); err != nil {
+		return err
+	}
+	readOnlyGauge.Set(flags.BoolValue(enabled))
+	return nil
+}
+
+// MigrateReadOnlyFlag moves a read-only toggle stored under LegacyReadOnlyKey onto the feature flag
+func (s *Service) MigrateReadOnlyFlag(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+
+	value, err := s.redis.GetDel(ctx, LegacyReadOnlyKey).Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Println("Failed to migrate the read-only flag:", err)
+		}
+		return
+	}
+	if err := s.SetReadOnly(ctx, value == "1"); err != nil {
+		log.Println("Failed to migrate the read-only flag:", err)
+	}
+}
+
+// ListFlags returns every feature flag with its current value, straight from Redis
+func (s *Service) ListFlags(ctx context.Context) ([]FlagResponse, error) {
+	overrides, err := s.flags.Overridden(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+
+	responses := make([]FlagResponse, 0, len(flags.Definitions()))
+	for _, definition := range flags.Definitions() {
+		responses = append(responses, toFlagResponse(definition, s.flags.Default(definition.Name), overrides))
+	}
+	return responses, nil
+}
+
+// SetFlag overrides a flag on every instance, raw is true/false for bool flags and a number otherwise
+func (s *Service) SetFlag(ctx context.Context, name string, raw json.RawMessage) (*FlagResponse, error) {
+	definition, ok := flags.Lookup(name)
+	if !ok {
+		return nil, flags.ErrUnknownFlag
+	}
+	value, err := definition.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.flags.Set(ctx, name, value); err != nil {
+		return nil, err
+	}
+
+	response := toFlagResponse(definition, s.flags.Default(name), map[string]int64{name: value})
+	return &response, nil
+}
+
+// ResetFlag drops the override, the flag goes back to its default
+func (s *Service) ResetFlag(ctx context.Context, name string) (*FlagResponse, error) {
+	definition, ok := flags.Lookup(name)
+	if !ok {
+		return nil, flags.ErrUnknownFlag
+	}
+	if err := s.flags.Reset(ctx, name); err != nil {
+		return nil, err
+	}
+
+	response := toFlagResponse(definition, s.flags.Default(name), nil)
+	return &response, nil
+}
+
+// ForceDeleteSubreddit soft-deletes any subreddit regardless of ownership, audits it and publishes
+// EventSubredditDeleted in the same transaction. The name stays reserved because name availability checks
+// include soft-deleted rows.
+func (s *Service) ForceDeleteSubreddit(ctx context.Context, adminID, subredditID uuid.UUID, reason string) error {
+	return s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			if err := s.subredditService.ForceDeleteSubreddit(ctx, adminID, subredditID); err != nil {
+				return err
+			}
+
+			return s.repo.CreateAuditLog(
+				ctx, &AuditLog{
+					ID:          utils.NewID(),
+					ActorID:     adminID,
+					Action:      AuditActionSubredditForceDelete,
+					TargetType:  AuditTargetSubreddit,
+					TargetID:    subredditID,
+					SubredditID: &subredditID,
+					Reason:      &reason,
+				},
+			)
+		},
+	)
+}
+
+// authorizeModLog lets the subreddit creator (its only moderator for now) and site admins through
+func (s *Service) authorizeModLog(ctx context.Context, subredditID, userID uuid.UUID) error {
+	sub, err := s.subredditService.GetSubredditForModeration(ctx, subredditID)
+	if err != nil {
+		return err
+	}
+	if sub.CreatorID == userID {
+		return nil
+	}
+
+	viewer, err := s.userService.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !viewer.IsAdmin() {
+		return ErrNotAuthorized
+	}
+	return nil
+}
+
+// GetModLog returns one page of the subreddit's mod log and the cursor of the next one (nil on the last page)
+func (s *Service) GetModLog(
+	ctx context.Context,
+	subredditID, userID uuid.UUID,
+	filter ModLogFilter,
+	after *utils.Cursor,
+	limit int,
+) ([]ModLogEntry, *utils.Cursor, error) {
+	if err := s.authorizeModLog(ctx, subredditID, userID); err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := s.repo.ListModLog(ctx, subredditID, filter, after, limit+1) // One extra row tells if there's more
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(entries) <= limit {
+		return entries, nil, nil
+	}
+
+	entries = entries[:limit]
+	last := entries[limit-1]
+	return entries, &utils.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, nil
+}
+
+// ExportModLog streams up to ModLogExportMaxRows matching entries to fn.
+// Authorization fails before fn is first called, so callers can still answer with an error status.
+func (s *Service) ExportModLog(
+	ctx context.Context,
+	subredditID, userID uuid.UUID,
+	filter ModLogFilter,
+	fn func(entry ModLogEntry) error,
+) error {
+	if err := s.authorizeModLog(ctx, subredditID, userID); err != nil {
+		return err
+	}
+	return s.repo.StreamModLog(ctx, subredditID, filter, ModLogExportMaxRows, fn)
+}
+
+// RunAuditRetention prunes audit entries older than the configured retention every prune interval
+// until ctx is done. A zero retention keeps entries forever.
+func (s *Service) RunAuditRetention(ctx context.Context) {
+	if s.auditCfg.Retention <= 0 || s.auditCfg.PruneInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.auditCfg.PruneInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pruned, err := s.repo.DeleteAuditLogsBefore(ctx, s.clock.Now().Add(-s.auditCfg.Retention))
+				if err != nil {
+					log.Println("Failed to prune audit logs:", err)
+					continue
+				}
+				if pruned > 0 {
+					log.Printf("Pruned %d audit log entries past retention", pruned)
+				}
+			}
+		}
+	}()
+}
@@ -2,10 +2,13 @@ package auth
 
 import (
 	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
@@ -25,11 +28,18 @@ func NewHandler(service *Service, cfg *config.Config) *Handler {
 
 func (h *Handler) Register(c *gin.Context) {
 	var req RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.BindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
-	err := h.service.Register(c.Request.Context(), req.Email, req.Username, req.Password)
+	// Bots get the regular success response, so they have no reason to adapt
+	if req.Website != "" {
+		countHoneypot(c.ClientIP())
+		c.JSON(http.StatusCreated, gin.H{"message": "Registration successful"})
+		return
+	}
+
+	err := h.service.Register(c.Request.Context(), req.Email, req.Username, req.Password, req.FormToken)
 
 	if err != nil {
 		var validationErrs ValidationErrors
@@ -43,6 +53,11 @@ func (h *Handler) Register(c *gin.Context) {
 			return
 		}
 
+		if errors.Is(err, user.ErrUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+			return
+		}
+
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Registration failed"})
 		return
 	}
@@ -56,12 +71,18 @@ func (h *Handler) Register(c *gin.Context) {
 
 func (h *Handler) Login(c *gin.Context) {
 	var req BasicLoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.BindJSON(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
 
-	tokenPair, err := h.service.Login(c.Request.Context(), h.config.JWT, req.Email, req.Password)
+	tokenPair, err := h.service.Login(
+		c.Request.Context(),
+		h.config.JWT,
+		req.Email,
+		req.Password,
+		req.RememberMe,
+	)
 
 	if err != nil {
 		var validationErrs ValidationErrors
@@ -75,6 +96,11 @@ func (h *Handler) Login(c *gin.Context) {
 			return
 		}
 
+		if errors.Is(err, user.ErrUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+			return
+		}
+
 		if errors.Is(err, ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
@@ -102,6 +128,37 @@ func (h *Handler) Login(c *gin.Context) {
 	)
 }
 
+// RegisterToken issues the form token the registration form submits back, see Service.IssueRegisterToken
+func (h *Handler) RegisterToken(c *gin.Context) {
+	token, err := h.service.IssueRegisterToken(c.Request.Context())
+	if err != nil {
+		if !errors.Is(err, ErrRegisterTokensUnavailable) {
+			log.Println("Failed to issue register token:", err)
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+		return
+	}
+
+	c.JSON(
+		http.StatusOK, RegisterTokenResponse{
+			FormToken: token,
+			ExpiresIn: int(RegisterTokenTTL.Seconds()),
+		},
+	)
+}
+
+// PasswordStrength powers the registration strength meter, it only scores the password
+func (h *Handler) PasswordStrength(c *gin.Context) {
+	var req PasswordStrengthRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	strength := h.service.CheckPasswordStrength(req.Password, req.Email, req.Username)
+	c.JSON(http.StatusOK, ToPasswordStrengthResponse(strength))
+}
+
 func (h *Handler) Logout(c *gin.Context) {
 	refreshToken, err := c.Cookie(h.config.JWT.RefreshTokenCookieKey)
 	if err != nil {
@@ -144,8 +201,12 @@ func (h *Handler) Logout(c *gin.Context) {
 }
 
 func (h *Handler) RefreshToken(c *gin.Context) {
-	refreshToken, err := c.Cookie(h.config.JWT.RefreshTokenCookieKey)
+	refreshToken, err := h.refreshTokenFromRequest(c)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if refreshToken == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token required"})
 		return
 	}
@@ -163,13 +224,63 @@ func (h *Handler) RefreshToken(c *gin.Context) {
 
 	h.setTokenCookies(c, tokenPair)
 
-	c.JSON(http.StatusOK, gin.H{"message": "Token refreshed successfully"})
+	c.JSON(
+		http.StatusOK, TokenResponse{
+			Message:      "Token refreshed successfully",
+			AccessToken:  tokenPair.AccessToken,
+			RefreshToken: tokenPair.RefreshToken,
+		},
+	)
+}
+
+// refreshTokenFromRequest reads the refresh token from its cookie, falling back to the JSON body and then
+// to an "Authorization: Bearer" header for clients that don't keep cookies. Returns "" when none is sent.
+func (h *Handler) refreshTokenFromRequest(c *gin.Context) (string, error) {
+	if refreshToken, err := c.Cookie(h.config.JWT.RefreshTokenCookieKey); err == nil && refreshToken != "" {
+		return refreshToken, nil
+	}
+
+	if c.Request.ContentLength != 0 {
+		var req RefreshRequest
+		if err := utils.BindJSON(c, &req); err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		if req.RefreshToken != "" {
+			return req.RefreshToken, nil
+		}
+	}
+
+	if refreshToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return refreshToken, nil
+	}
+	return "", nil
 }
 
 func (h *Handler) GoogleURL(c *gin.Context) {
-	googleURL, err := h.service.CreateGoogleURL(h.config)
+	purpose := c.DefaultQuery("purpose", OAuthPurposeLogin)
+	userID := c.GetString("user_id") // Set by utils.OptionalJWTAuthMiddleware
+
+	switch purpose {
+	case OAuthPurposeLogin:
+		userID = ""
+	case OAuthPurposeLink:
+		if userID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login required to link a Google account"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid purpose, expected login or link"})
+		return
+	}
 
+	googleURL, nonce, err := h.service.CreateGoogleURL(
+		c.Request.Context(), h.config, purpose, c.Query("redirect_to"), userID,
+	)
 	if err != nil {
+		if errors.Is(err, ErrOAuthUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service temporarily unavailable, please retry"})
+			return
+		}
 		c.JSON(
 			http.StatusBadRequest, gin.H{
 				"error": "Problem generating google auth url",
@@ -177,6 +288,7 @@ func (h *Handler) GoogleURL(c *gin.Context) {
 		)
 		return
 	}
+	h.setOAuthStateCookie(c, nonce, int(OAuthStateTTL.Seconds()))
 
 	c.JSON(
 		http.StatusOK, gin.H{
@@ -193,29 +305,74 @@ func (h *Handler) HandleGoogleCallback(c *gin.Context) {
 		return
 	}
 
-	tokenPair, err := h.service.HandleGoogleCallback(
+	cookieNonce, _ := c.Cookie(OAuthStateCookie)
+	h.setOAuthStateCookie(c, "", -1) // Single use either way
+
+	result, err := h.service.HandleGoogleCallback(
 		c.Request.Context(),
 		&h.config.JWT,
 		googleAuthCode,
 		googleAuthState,
+		cookieNonce,
 	)
 	if err != nil {
+		if errors.Is(err, ErrOAuthStateMismatch) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error": "Sign-in link expired or was opened in another browser, please start again",
+				},
+			)
+			return
+		}
+		if errors.Is(err, user.ErrSignupNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Registration is restricted to approved email domains"})
+			return
+		}
+		if errors.Is(err, user.ErrGoogleAccountLinked) {
+			c.JSON(
+				http.StatusConflict, gin.H{
+					"error": "This Google account is already linked to another user",
+				},
+			)
+			return
+		}
 		c.JSON(
 			http.StatusUnauthorized, gin.H{
 				"error": "OAuth authentication failed",
 			},
 		)
+		return
 	}
 
-	h.setTokenCookies(c, tokenPair)
-	c.Redirect(http.StatusTemporaryRedirect, h.config.Project.FrontendURL)
+	if result.Tokens != nil {
+		h.setTokenCookies(c, result.Tokens)
+	}
+	// Redirect was sanitized to a relative path when the state was signed and again when it was read
+	c.Redirect(
+		http.StatusTemporaryRedirect,
+		strings.TrimRight(h.config.Project.FrontendURL, "/")+result.Redirect,
+	)
 }
 
+// setOAuthStateCookie is Lax so it comes along on the top-level redirect back from Google,
+// and scoped to the Google auth routes
+func (h *Handler) setOAuthStateCookie(c *gin.Context, nonce string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OAuthStateCookie, nonce, maxAge, "/auth/google", "", h.config.Project.IsProduction, true)
+}
+
+// setTokenCookies writes persistent cookies for "remember me" logins and session cookies (maxAge 0) otherwise
 func (h *Handler) setTokenCookies(c *gin.Context, tokenPair *utils.TokenPair) {
+	accessMaxAge, refreshMaxAge := 0, 0
+	if tokenPair.RememberMe {
+		accessMaxAge = int(h.config.JWT.AccessLifetime.Seconds())
+		refreshMaxAge = int(h.config.JWT.RememberMeLifetime.Seconds())
+	}
+
 	c.SetCookie(
 		h.config.JWT.AccessTokenCookieKey,
 		tokenPair.AccessToken,
-		int(h.config.JWT.AccessLifetime.Seconds()),
+		accessMaxAge,
 		"/",
 		"",
 		h.config.Project.IsProduction,
@@ -225,7 +382,7 @@ func (h *Handler) setTokenCookies(c *gin.Context, tokenPair *utils.TokenPair) {
 	c.SetCookie(
 		h.config.JWT.RefreshTokenCookieKey,
 		tokenPair.RefreshToken,
-		int(h.config.JWT.RefreshLifetime.Seconds()),
+		refreshMaxAge,
 		"/",
 		"",
 		h.config.Project.IsProduction,
@@ -0,0 +1,744 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type handlerTestEnv struct {
+	router      *gin.Engine
+	service     *Service
+	userService *user.Service
+	cfg         *config.Config
+	clock       *testutil.FakeClock
+}
+
+// Regression: signing in with Google on an email account used to replace the avatar the user uploaded
+func TestGoogleSignInKeepsUploadedAvatar(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Now())
+	db := testutil.Postgres(t, clock)
+	userRepo := user.NewRepository(db, 0)
+	userService := user.NewService(
+		userRepo, database.NewTxManager(db), nil,
+		config.EmailNormalizationConfig{}, config.RetentionConfig{}, clock,
+	)
+	env := newHandlerTestEnv(t, userService, clock)
+	ctx := context.Background()
+
+	u := createTestUser(t, env, "avatarist", testPassword)
+	uploaded := "https://cdn.agora.example/avatars/avatarist.png"
+	if err := userRepo.SetAvatar(ctx, u.ID, &uploaded); err != nil {
+		t.Fatal(err)
+	}
+
+	const googlePicture = "https://lh3.googleusercontent.com/a/avatarist"
+	google := http.NewServeMux()
+	google.HandleFunc(
+		"/token", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"access_token":"google-access","token_type":"Bearer","expires_in":3600}`)
+		},
+	)
+	google.HandleFunc(
+		"/userinfo", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer google-access" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(
+				GoogleUserInfo{ID: "google-avatarist", Email: u.Email, VerifiedEmail: true, AvatarURL: googlePicture},
+			)
+		},
+	)
+	googleServer := httptest.NewServer(google)
+	t.Cleanup(googleServer.Close)
+	env.service.oauthConfig.Endpoint.TokenURL = googleServer.URL + "/token"
+	env.service.userInfoURL = googleServer.URL + "/userinfo"
+
+	start := env.do(t, http.MethodGet, "/auth/google/url", "")
+	var resp struct{ URL string }
+	if err := json.Unmarshal(start.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := url.Parse(resp.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := "/auth/google/callback?code=ok&state=" + url.QueryEscape(authURL.Query().Get("state"))
+	rec := env.do(t, http.MethodGet, callback, "", responseCookie(start, OAuthStateCookie))
+	if rec.Code != http.StatusTemporaryRedirect || responseCookie(rec, "access_token") == nil {
+		t.Fatalf("callback: status %d, body %s; want a signed-in redirect", rec.Code, rec.Body)
+	}
+
+	signedIn, err := userService.GetUserById(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signedIn.GoogleID == nil || *signedIn.GoogleID != "google-avatarist" {
+		t.Errorf("google_id = %v, want the account linked by email", signedIn.GoogleID)
+	}
+	if signedIn.AvatarURL == nil || *signedIn.AvatarURL != uploaded {
+		t.Errorf("avatar_url = %v, want the uploaded %s", signedIn.AvatarURL, uploaded)
+	}
+	if signedIn.GoogleAvatarURL == nil || *signedIn.GoogleAvatarURL != googlePicture {
+		t.Errorf("google_avatar_url = %v, want %s kept for a sync", signedIn.GoogleAvatarURL, googlePicture)
+	}
+	if signedIn.AuthProvider != user.AuthProviderEmail {
+		t.Errorf("auth_provider = %s, want it left at %s", signedIn.AuthProvider, user.AuthProviderEmail)
+	}
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		JWT: config.JWTConfig{
+			Secret:                strings.Repeat("s", config.MinJWTSecretLength),
+			AccessLifetime:        15 * time.Minute,
+			RefreshLifetime:       24 * time.Hour,
+			RememberMeLifetime:    30 * 24 * time.Hour,
+			AccessTokenCookieKey:  "access_token",
+			RefreshTokenCookieKey: "refresh_token",
+		},
+		Project: config.ProjectConfig{FrontendURL: "https://agora.example"},
+	}
+}
+
+// newHandlerTestEnv mounts the auth routes on miniredis. userService may be nil for tests that never reach
+// the database, newDBHandlerTestEnv provides a real one.
+func newHandlerTestEnv(t *testing.T, userService *user.Service, clock *testutil.FakeClock) handlerTestEnv {
+	t.Helper()
+	return newHandlerTestEnvWithConfig(t, userService, clock, testConfig())
+}
+
+func newHandlerTestEnvWithConfig(
+	t *testing.T,
+	userService *user.Service,
+	clock *testutil.FakeClock,
+	cfg *config.Config,
+) handlerTestEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	service := NewService(userService, config.GoogleConfig{}, client, cfg.Auth, clock)
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(service, cfg), client, clock)
+
+	return handlerTestEnv{router: router, service: service, userService: userService, cfg: cfg, clock: clock}
+}
+
+func newDBHandlerTestEnv(t *testing.T) handlerTestEnv {
+	t.Helper()
+	return newDBHandlerTestEnvWithConfig(t, testConfig())
+}
+
+func newDBHandlerTestEnvWithConfig(t *testing.T, cfg *config.Config) handlerTestEnv {
+	t.Helper()
+
+	clock := testutil.NewFakeClock(time.Now())
+	db := testutil.Postgres(t, clock)
+	userService := user.NewService(
+		user.NewRepository(db, 0), database.NewTxManager(db), nil,
+		config.EmailNormalizationConfig{}, config.RetentionConfig{}, clock,
+	)
+	return newHandlerTestEnvWithConfig(t, userService, clock, cfg)
+}
+
+// do sends a JSON request, cookies are attached as given
+func (env handlerTestEnv) do(
+	t *testing.T,
+	method, path, body string,
+	cookies ...*http.Cookie,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	env.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (env handlerTestEnv) refreshToken(t *testing.T, userID uuid.UUID, lifetime time.Duration) string {
+	t.Helper()
+
+	token, err := utils.GenerateJWT(env.clock, env.cfg.JWT.Secret, utils.TokenTypeRefresh, lifetime, userID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func createTestUser(t *testing.T, env handlerTestEnv, name, password string) *user.User {
+	t.Helper()
+
+	u, err := env.userService.CreateUser(context.Background(), name+"@example.com", name, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRefreshRejectsMissingAndInvalidTokens(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+	expired := env.refreshToken(t, uuid.New(), -time.Minute)
+	access, err := utils.GenerateJWT(env.clock, env.cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, uuid.NewString())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		body    string
+		cookies []*http.Cookie
+		want    int
+	}{
+		{"nothing sent", "", nil, http.StatusUnauthorized},
+		{"empty body token", `{"refresh_token":""}`, nil, http.StatusUnauthorized},
+		{"malformed body", `{"refresh_token":`, nil, http.StatusBadRequest},
+		{"garbage cookie", "", []*http.Cookie{{Name: "refresh_token", Value: "garbage"}}, http.StatusUnauthorized},
+		{"expired body token", `{"refresh_token":"` + expired + `"}`, nil, http.StatusUnauthorized},
+		{"access token as refresh", `{"refresh_token":"` + access + `"}`, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rec := env.do(t, http.MethodPost, "/auth/refresh", tt.body, tt.cookies...)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+		if responseCookie(rec, "access_token") != nil {
+			t.Errorf("%s: set an access cookie", tt.name)
+		}
+	}
+}
+
+func TestRefreshRejectsBlacklistedToken(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+	token := env.refreshToken(t, uuid.New(), time.Hour)
+	if err := env.service.blacklistToken(context.Background(), &env.cfg.JWT, token, utils.TokenTypeRefresh); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.do(t, http.MethodPost, "/auth/refresh", "", &http.Cookie{Name: "refresh_token", Value: token})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", rec.Code)
+	}
+	rec = env.do(t, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+token+`"}`)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("body token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRefreshTokenFromRequestPrecedence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewHandler(nil, testConfig())
+
+	tests := []struct {
+		name, cookie, body, header, want string
+	}{
+		{"cookie", "from-cookie", "", "", "from-cookie"},
+		{"body", "", `{"refresh_token":"from-body"}`, "", "from-body"},
+		{"header", "", "", "Bearer from-header", "from-header"},
+		{"cookie wins over body and header", "from-cookie", `{"refresh_token":"from-body"}`, "Bearer from-header", "from-cookie"},
+		{"body wins over header", "", `{"refresh_token":"from-body"}`, "Bearer from-header", "from-body"},
+		{"empty body falls through to header", "", `{}`, "Bearer from-header", "from-header"},
+		{"non-bearer header", "", "", "Basic abc", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodPost, "/auth/refresh", strings.NewReader(tt.body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		if tt.cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: "refresh_token", Value: tt.cookie})
+		}
+		if tt.header != "" {
+			c.Request.Header.Set("Authorization", tt.header)
+		}
+
+		got, err := h.refreshTokenFromRequest(c)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRefreshRotatesCookieToken(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	u := createTestUser(t, env, "rotator", "Sup3r-secret-pass")
+	token := env.refreshToken(t, u.ID, time.Hour)
+
+	rec := env.do(t, http.MethodPost, "/auth/refresh", "", &http.Cookie{Name: "refresh_token", Value: token})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	refreshCookie, accessCookie := responseCookie(rec, "refresh_token"), responseCookie(rec, "access_token")
+	if refreshCookie == nil || accessCookie == nil || !refreshCookie.HttpOnly {
+		t.Fatalf("cookies not set: %v", rec.Result().Cookies())
+	}
+	if refreshCookie.Value == token {
+		t.Fatal("refresh token wasn't rotated")
+	}
+
+	// The old token is spent, the rotated one works
+	if rec := env.do(t, http.MethodPost, "/auth/refresh", "", &http.Cookie{Name: "refresh_token", Value: token}); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed token: status %d, want 401", rec.Code)
+	}
+	env.clock.Advance(time.Second) // A new iat, so the next pair differs from this one
+	if rec := env.do(t, http.MethodPost, "/auth/refresh", "", refreshCookie); rec.Code != http.StatusOK {
+		t.Fatalf("rotated token: status %d (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestRefreshRotatesBodyToken(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	u := createTestUser(t, env, "mobile", "Sup3r-secret-pass")
+	token := env.refreshToken(t, u.ID, time.Hour)
+
+	rec := env.do(t, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+token+`"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	var resp TokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" || resp.RefreshToken == token {
+		t.Fatalf("response doesn't carry a rotated pair: %+v", resp)
+	}
+	if userID, _, err := utils.DecryptJWT(env.clock, resp.AccessToken, env.cfg.JWT.Secret, utils.TokenTypeAccess); err != nil || userID != u.ID.String() {
+		t.Fatalf("access token for %q (%v), want %s", userID, err, u.ID)
+	}
+
+	if rec := env.do(t, http.MethodPost, "/auth/refresh", `{"refresh_token":"`+token+`"}`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("replayed token: status %d, want 401", rec.Code)
+	}
+}
+
+func TestRefreshRejectsDeletedUser(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	token := env.refreshToken(t, uuid.New(), time.Hour) // Signed correctly, but nobody has this ID
+
+	rec := env.do(t, http.MethodPost, "/auth/refresh", "", &http.Cookie{Name: "refresh_token", Value: token})
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d, want 401", rec.Code)
+	}
+}
+
+const testPassword = "Sup3r-secret-pass"
+
+// registerFormToken fetches a form token and waits out RegisterTokenMinAge on the fake clock
+func (env handlerTestEnv) registerFormToken(t *testing.T) string {
+	t.Helper()
+
+	rec := env.do(t, http.MethodGet, "/auth/register-token", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("register-token: status %d (%s)", rec.Code, rec.Body)
+	}
+	var resp RegisterTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	env.clock.Advance(RegisterTokenMinAge)
+	return resp.FormToken
+}
+
+func TestRegister(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	body := func(email, username, formToken string) string {
+		return `{"email":"` + email + `","username":"` + username + `","password":"` + testPassword +
+			`","form_token":"` + formToken + `"}`
+	}
+
+	formToken := env.registerFormToken(t)
+	rec := env.do(t, http.MethodPost, "/auth/register", body("new@example.com", "newcomer", formToken))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	if _, err := env.userService.GetByEmail(context.Background(), "new@example.com"); err != nil {
+		t.Fatalf("registered user not stored: %v", err)
+	}
+
+	tests := []struct {
+		name, body string
+		field      string
+	}{
+		{"taken email", body("new@example.com", "someoneelse", env.registerFormToken(t)), "email"},
+		{"taken username", body("other@example.com", "newcomer", env.registerFormToken(t)), "username"},
+		{"spent form token", body("third@example.com", "third", formToken), "form_token"},
+	}
+	for _, tt := range tests {
+		rec := env.do(t, http.MethodPost, "/auth/register", tt.body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400 (%s)", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		if tt.field != "" && !strings.Contains(rec.Body.String(), `"field":"`+tt.field+`"`) {
+			t.Errorf("%s: no %s error in %s", tt.name, tt.field, rec.Body)
+		}
+	}
+}
+
+func TestRegisterEmailDomainAllowlist(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.AllowedEmailDomains = []string{"agora.example"}
+	env := newDBHandlerTestEnvWithConfig(t, cfg)
+	register := func(email, username string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(
+			RegisterRequest{
+				Email: email, Username: username, Password: testPassword, FormToken: env.registerFormToken(t),
+			},
+		)
+		return env.do(t, http.MethodPost, "/auth/register", string(body))
+	}
+
+	if rec := register("dev@Agora.example", "insider"); rec.Code != http.StatusCreated {
+		t.Fatalf("allowed domain: status %d (%s)", rec.Code, rec.Body)
+	}
+
+	rec := register("dev@gmail.com", "outsider")
+	if rec.Code != http.StatusBadRequest ||
+		!strings.Contains(rec.Body.String(), `{"field":"email","message":"`+ErrEmailDomainNotAllowed+`"}`) {
+		t.Fatalf("other domain: status %d (%s), want 400 with the email field error", rec.Code, rec.Body)
+	}
+	if _, err := env.userService.GetByEmail(context.Background(), "dev@gmail.com"); !errors.Is(err, user.ErrNotFound) {
+		t.Errorf("rejected registration stored a user: %v", err)
+	}
+}
+
+// A failed availability lookup is an outage, not a free email or an unknown account
+func TestRegisterAndLoginDuringOutage(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Now())
+	db := testutil.UnreachablePostgres(t)
+	userService := user.NewService(
+		user.NewRepository(db, 0), database.NewTxManager(db), nil,
+		config.EmailNormalizationConfig{}, config.RetentionConfig{}, clock,
+	)
+	env := newHandlerTestEnv(t, userService, clock)
+
+	register := `{"email":"new@example.com","username":"newcomer","password":"` + testPassword +
+		`","form_token":"` + env.registerFormToken(t) + `"}`
+	if rec := env.do(t, http.MethodPost, "/auth/register", register); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("register: status %d, want 503 (%s)", rec.Code, rec.Body)
+	}
+
+	body, _ := json.Marshal(BasicLoginRequest{Email: "member@example.com", Password: testPassword})
+	if rec := env.do(t, http.MethodPost, "/auth/login", string(body)); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("login: status %d, want 503 (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestRegisterRejectsBeforeTouchingUsers(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now())) // A nil user service panics if reached
+
+	tests := []struct {
+		name, body string
+		want       int
+	}{
+		{"invalid JSON", `{"email":`, http.StatusBadRequest},
+		{"invalid fields", `{"email":"nope","username":"x","password":"short"}`, http.StatusBadRequest},
+		{"unknown form token", `{"email":"a@example.com","username":"someone","password":"` + testPassword + `","form_token":"made-up"}`, http.StatusBadRequest},
+		// Bots filling the honeypot are told they succeeded
+		{"honeypot", `{"email":"bot@example.com","username":"bot","password":"` + testPassword + `","website":"spam.example"}`, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		if rec := env.do(t, http.MethodPost, "/auth/register", tt.body); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestLogin(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	u := createTestUser(t, env, "member", testPassword)
+	if _, err := env.userService.FindOrCreateByGoogle(context.Background(), "googler@example.com", "google-1", "", true); err != nil {
+		t.Fatal(err)
+	}
+	login := func(email, password string, rememberMe bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BasicLoginRequest{Email: email, Password: password, RememberMe: rememberMe})
+		return env.do(t, http.MethodPost, "/auth/login", string(body))
+	}
+
+	rec := login(u.Email, testPassword, false)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	access, refresh := responseCookie(rec, "access_token"), responseCookie(rec, "refresh_token")
+	if access == nil || refresh == nil || !access.HttpOnly || !refresh.HttpOnly {
+		t.Fatalf("token cookies missing or readable by scripts: %v", rec.Result().Cookies())
+	}
+	if access.MaxAge != 0 || refresh.MaxAge != 0 {
+		t.Errorf("login without remember me set persistent cookies: %d/%d", access.MaxAge, refresh.MaxAge)
+	}
+	if userID, _, err := utils.DecryptJWT(env.clock, access.Value, env.cfg.JWT.Secret, utils.TokenTypeAccess); err != nil || userID != u.ID.String() {
+		t.Errorf("access cookie for %q (%v), want %s", userID, err, u.ID)
+	}
+
+	rec = login(u.Email, testPassword, true)
+	if refresh := responseCookie(rec, "refresh_token"); rec.Code != http.StatusOK || refresh == nil ||
+		refresh.MaxAge != int(env.cfg.JWT.RememberMeLifetime.Seconds()) {
+		t.Errorf("remember me: status %d, refresh cookie %v", rec.Code, refresh)
+	}
+
+	failures := []struct {
+		name, email, password string
+		want                  int
+	}{
+		{"wrong password", u.Email, testPassword + "x", http.StatusUnauthorized},
+		{"Google-only account", "googler@example.com", testPassword, http.StatusBadRequest},
+		{"malformed email", "not-an-email", testPassword, http.StatusBadRequest},
+	}
+	for _, tt := range failures {
+		rec := login(tt.email, tt.password, false)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body)
+		}
+		if responseCookie(rec, "access_token") != nil {
+			t.Errorf("%s: set an access cookie", tt.name)
+		}
+	}
+	if rec := login("nobody@example.com", testPassword, false); rec.Code == http.StatusOK {
+		t.Error("unknown email logged in")
+	}
+}
+
+// Remembered logins get persistent cookies and the long refresh lifetime, others session cookies and
+// the short one; a refresh keeps whichever class the login picked
+func TestLoginRememberMeCookieLifetimes(t *testing.T) {
+	env := newDBHandlerTestEnv(t)
+	u := createTestUser(t, env, "rememberer", testPassword)
+	jwtCfg := env.cfg.JWT
+
+	tests := []struct {
+		rememberMe                  bool
+		accessMaxAge, refreshMaxAge int
+		refreshLifetime             time.Duration
+	}{
+		{false, 0, 0, jwtCfg.RefreshLifetime},
+		{true, int(jwtCfg.AccessLifetime.Seconds()), int(jwtCfg.RememberMeLifetime.Seconds()), jwtCfg.RememberMeLifetime},
+	}
+	for _, tt := range tests {
+		body, _ := json.Marshal(BasicLoginRequest{Email: u.Email, Password: testPassword, RememberMe: tt.rememberMe})
+		rec := env.do(t, http.MethodPost, "/auth/login", string(body))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("remember_me=%t: status %d (%s)", tt.rememberMe, rec.Code, rec.Body)
+		}
+
+		for round := range 2 { // The login, then a rotation through /auth/refresh
+			access, refresh := responseCookie(rec, "access_token"), responseCookie(rec, "refresh_token")
+			if access == nil || refresh == nil {
+				t.Fatalf("remember_me=%t round %d: cookies %v", tt.rememberMe, round, rec.Result().Cookies())
+			}
+			if access.MaxAge != tt.accessMaxAge || refresh.MaxAge != tt.refreshMaxAge {
+				t.Errorf(
+					"remember_me=%t round %d: cookie max-age %d/%d, want %d/%d",
+					tt.rememberMe, round, access.MaxAge, refresh.MaxAge, tt.accessMaxAge, tt.refreshMaxAge,
+				)
+			}
+			_, claims, err := utils.DecryptJWT(env.clock, refresh.Value, jwtCfg.Secret, utils.TokenTypeRefresh)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expiresAt, _ := claims.GetExpirationTime()
+			issuedAt, _ := claims.GetIssuedAt()
+			if expiresAt == nil || issuedAt == nil || expiresAt.Sub(issuedAt.Time) != tt.refreshLifetime {
+				t.Errorf("remember_me=%t round %d: refresh token exp %v iat %v, want %s apart",
+					tt.rememberMe, round, expiresAt, issuedAt, tt.refreshLifetime)
+			}
+
+			env.clock.Advance(time.Second) // A distinct iat so the rotated token isn't the blacklisted one
+			rec = env.do(t, http.MethodPost, "/auth/refresh", "", refresh)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("remember_me=%t refresh: status %d (%s)", tt.rememberMe, rec.Code, rec.Body)
+			}
+		}
+	}
+}
+
+// Login agrees with registration's duplicate check: any alias of the mailbox finds the account
+func TestLoginByEmailAlias(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Now())
+	db := testutil.Postgres(t, clock)
+	userService := user.NewService(
+		user.NewRepository(db, 0), database.NewTxManager(db), nil,
+		config.EmailNormalizationConfig{
+			Enabled: true,
+			Providers: []config.EmailProviderConfig{
+				{Domain: "gmail.com", Aliases: []string{"googlemail.com"}, IgnoreDots: true, TagSeparator: "+"},
+			},
+		},
+		config.RetentionConfig{}, clock,
+	)
+	env := newHandlerTestEnv(t, userService, clock)
+	u, err := userService.CreateUser(context.Background(), "A.B@gmail.com", "aliased", testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(email, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(BasicLoginRequest{Email: email, Password: password})
+		return env.do(t, http.MethodPost, "/auth/login", string(body))
+	}
+
+	for _, email := range []string{"A.B@gmail.com", "a.b@gmail.com", "ab+news@GoogleMail.com", "ab@gmail.com"} {
+		rec := login(email, testPassword)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%q: status %d (%s), want 200", email, rec.Code, rec.Body)
+			continue
+		}
+		access := responseCookie(rec, "access_token")
+		if access == nil {
+			t.Errorf("%q: no access cookie", email)
+			continue
+		}
+		if userID, _, err := utils.DecryptJWT(env.clock, access.Value, env.cfg.JWT.Secret, utils.TokenTypeAccess); err != nil || userID != u.ID.String() {
+			t.Errorf("%q: signed in as %q (%v), want %s", email, userID, err, u.ID)
+		}
+	}
+	if rec := login("ab+news@gmail.com", testPassword+"x"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("alias with a wrong password: status %d, want 401", rec.Code)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+	userID := uuid.New()
+	access, err := utils.GenerateJWT(env.clock, env.cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, userID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessCookie := &http.Cookie{Name: "access_token", Value: access}
+	refreshCookie := &http.Cookie{Name: "refresh_token", Value: env.refreshToken(t, userID, time.Hour)}
+
+	if rec := env.do(t, http.MethodPost, "/auth/logout", "", refreshCookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without an access token: status %d, want 401", rec.Code)
+	}
+	if rec := env.do(t, http.MethodPost, "/auth/logout", "", accessCookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without a refresh token: status %d, want 401", rec.Code)
+	}
+
+	rec := env.do(t, http.MethodPost, "/auth/logout", "", accessCookie, refreshCookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	for _, name := range []string{"access_token", "refresh_token"} {
+		if cookie := responseCookie(rec, name); cookie == nil || cookie.MaxAge >= 0 || cookie.Value != "" {
+			t.Errorf("%s cookie not cleared: %v", name, cookie)
+		}
+	}
+
+	// The refresh token is revoked, not only dropped from the browser
+	if rec := env.do(t, http.MethodPost, "/auth/refresh", "", refreshCookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("refresh after logout: status %d, want 401", rec.Code)
+	}
+}
+
+func TestGoogleURL(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+
+	if rec := env.do(t, http.MethodGet, "/auth/google/url?purpose=link", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous link: status %d, want 401", rec.Code)
+	}
+	if rec := env.do(t, http.MethodGet, "/auth/google/url?purpose=admin", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown purpose: status %d, want 400", rec.Code)
+	}
+
+	rec := env.do(t, http.MethodGet, "/auth/google/url?redirect_to=//evil.example", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	cookie := responseCookie(rec, OAuthStateCookie)
+	if cookie == nil || cookie.Value == "" || !cookie.HttpOnly || cookie.Path != "/auth/google" ||
+		cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("state cookie = %+v", cookie)
+	}
+
+	var resp struct{ URL string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := http.NewRequest(http.MethodGet, resp.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := ValidateState(parsed.URL.Query().Get("state"), env.cfg.JWT.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Nonce != cookie.Value || state.Redirect != "/" {
+		t.Errorf("state = %+v, want the cookie's nonce and a sanitized redirect", state)
+	}
+}
+
+func TestGoogleCallback(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+	tokenEndpoint := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			},
+		),
+	)
+	t.Cleanup(tokenEndpoint.Close)
+	env.service.oauthConfig.Endpoint.TokenURL = tokenEndpoint.URL
+
+	if rec := env.do(t, http.MethodGet, "/auth/google/callback?state=x", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing code: status %d, want 400", rec.Code)
+	}
+	if rec := env.do(t, http.MethodGet, "/auth/google/callback?code=x", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("missing state: status %d, want 400", rec.Code)
+	}
+	if rec := env.do(t, http.MethodGet, "/auth/google/callback?code=x&state=forged", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("forged state: status %d, want 401", rec.Code)
+	}
+
+	start := env.do(t, http.MethodGet, "/auth/google/url", "")
+	nonceCookie := responseCookie(start, OAuthStateCookie)
+	var resp struct{ URL string }
+	if err := json.Unmarshal(start.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	startURL, _ := http.NewRequest(http.MethodGet, resp.URL, nil)
+	callback := "/auth/google/callback?code=rejected&state=" + startURL.URL.Query().Get("state")
+
+	// Matching browser, but Google refuses the code: no session, and the state can't be retried
+	rec := env.do(t, http.MethodGet, callback, "", nonceCookie)
+	if rec.Code != http.StatusUnauthorized || responseCookie(rec, "access_token") != nil {
+		t.Fatalf("refused code: status %d, cookies %v", rec.Code, rec.Result().Cookies())
+	}
+	if cleared := responseCookie(rec, OAuthStateCookie); cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("state cookie not cleared: %v", cleared)
+	}
+	if rec := env.do(t, http.MethodGet, callback, "", nonceCookie); rec.Code != http.StatusBadRequest {
+		t.Errorf("replayed state: status %d, want 400", rec.Code)
+	}
+}
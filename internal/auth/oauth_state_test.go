@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+var hostileRedirects = []string{
+	"https://evil.example",
+	"//evil.example",
+	"///evil.example",
+	"/\\evil.example",
+	"\\\\evil.example",
+	"/%2F/evil.example",
+	"/%5Cevil.example",
+	"javascript:alert(1)",
+	"/r/golang\r\nLocation: https://evil.example",
+	"http:/evil.example",
+	"evil.example",
+	" /r/golang",
+}
+
+func TestSanitizeRedirectPathRejectsHostileValues(t *testing.T) {
+	for _, raw := range hostileRedirects {
+		if got := SanitizeRedirectPath(raw); got != "/" {
+			t.Errorf("SanitizeRedirectPath(%q) = %q, want /", raw, got)
+		}
+	}
+
+	for _, raw := range []string{"/", "/r/golang", "/r/golang?tab=new", "/settings#email"} {
+		if got := SanitizeRedirectPath(raw); got != raw {
+			t.Errorf("SanitizeRedirectPath(%q) = %q, want it unchanged", raw, got)
+		}
+	}
+}
+
+func TestResolveRedirectPathStaysInAllowlist(t *testing.T) {
+	allowlist := []string{"/settings", "/r/*"}
+	tests := []struct {
+		raw, want string
+	}{
+		{"/settings", "/settings"},
+		{"/r/golang?tab=new", "/r/golang?tab=new"},
+		{"/r", "/"},
+		{"/r/../admin", "/"},
+		{"/r/%2E%2E/admin", "/"},
+		{"/settings/../admin", "/"},
+		{"/admin", "/"},
+	}
+	for _, tt := range tests {
+		if got := ResolveRedirectPath(tt.raw, allowlist); got != tt.want {
+			t.Errorf("ResolveRedirectPath(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+	for _, raw := range hostileRedirects {
+		if got := ResolveRedirectPath(raw, allowlist); got != "/" {
+			t.Errorf("ResolveRedirectPath(%q) = %q, want /", raw, got)
+		}
+	}
+}
+
+func TestValidateStateRejectsTampering(t *testing.T) {
+	state, nonce, err := GenerateState("secret", OAuthState{Purpose: OAuthPurposeLink, UserID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := ValidateState(state, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Nonce != nonce || payload.UserID != "u1" {
+		t.Errorf("ValidateState = %+v, want nonce %q for u1", payload, nonce)
+	}
+
+	if _, err := ValidateState(state, "other-secret"); err == nil {
+		t.Error("state signed with another secret was accepted")
+	}
+	body, signature, _ := strings.Cut(state, ".")
+	forged := strings.ToUpper(body[:1]) + body[1:] + "." + signature
+	if forged != state {
+		if _, err := ValidateState(forged, "secret"); err == nil {
+			t.Error("state with a modified payload was accepted")
+		}
+	}
+}
+
+func TestValidateStateSanitizesSignedRedirect(t *testing.T) {
+	// A state signed before the redirect was checked still can't send the user off-site
+	state, _, err := GenerateState("secret", OAuthState{Purpose: OAuthPurposeLogin, Redirect: "//evil.example"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := ValidateState(state, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Redirect != "/" {
+		t.Errorf("Redirect = %q, want /", payload.Redirect)
+	}
+}
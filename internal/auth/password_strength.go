@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	PasswordScoreMax = 4
+
+	passwordStrongLen   = 12
+	passwordVeryLongLen = 16
+	passwordMinRunLen   = 3 // "aaa", "abc", "321" and longer count as runs
+)
+
+const (
+	FeedbackPasswordLonger    = "Use at least 12 characters, a few unrelated words work well"
+	FeedbackPasswordVariety   = "Mix uppercase and lowercase letters, numbers and symbols"
+	FeedbackPasswordRepeats   = "Avoid repeated characters like \"aaa\""
+	FeedbackPasswordSequences = "Avoid sequences like \"abc\", \"123\" or \"qwerty\""
+	FeedbackPasswordCommon    = "This is a commonly used password"
+	FeedbackPasswordPersonal  = "Avoid using your username or email in the password"
+)
+
+// commonPasswords holds frequently breached passwords and their usual stems, matched case-insensitively
+var commonPasswords = []string{
+	"password", "passw0rd", "qwerty", "letmein", "welcome", "admin", "iloveyou", "monkey", "dragon",
+	"football", "baseball", "sunshine", "princess", "trustno1", "master", "shadow", "superman", "agora",
+}
+
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890"}
+
+// PasswordStrength is the live strength meter result. Score goes from 0 (trivially guessable) to
+// PasswordScoreMax and is only guidance, PolicyError is what registration would actually reject.
+type PasswordStrength struct {
+	Score       int
+	Feedback    []string
+	PolicyError string // Empty when the password passes ValidatePasswordFormat
+}
+
+// CheckPasswordStrength scores password without storing or logging it. personal holds the username/email
+// being registered, passwords built around them are easy to guess for anyone who knows the account.
+func (v *Validator) CheckPasswordStrength(password string, personal ...string) PasswordStrength {
+	var strength PasswordStrength
+	if err := v.ValidatePasswordFormat(password); err != nil {
+		strength.PolicyError = err.Error()
+	}
+
+	length := len([]rune(password))
+	switch {
+	case length >= passwordVeryLongLen:
+		strength.Score = 3
+	case length >= passwordStrongLen:
+		strength.Score = 2
+	case length >= PasswordMinLen:
+		strength.Score = 1
+	}
+	if length < passwordStrongLen {
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordLonger)
+	}
+
+	if classes := characterClasses(password); classes >= 3 {
+		strength.Score++
+	} else if length > 0 {
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordVariety)
+	}
+
+	lowered := strings.ToLower(password)
+	if hasRepeatedRun(lowered) {
+		strength.Score--
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordRepeats)
+	}
+	if hasSequenceRun(lowered) {
+		strength.Score--
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordSequences)
+	}
+
+	// These are guessed early by any cracking dictionary, however long the rest is
+	if containsAny(lowered, commonPasswords) {
+		strength.Score = min(strength.Score, 0)
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordCommon)
+	}
+	if containsAny(lowered, personalTokens(personal)) {
+		strength.Score = min(strength.Score, 1)
+		strength.Feedback = append(strength.Feedback, FeedbackPasswordPersonal)
+	}
+
+	strength.Score = max(0, min(strength.Score, PasswordScoreMax))
+	return strength
+}
+
+func characterClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+func hasRepeatedRun(s string) bool {
+	runes := []rune(s)
+	run := 1
+	for i := 1; i < len(runes); i++ {
+		if runes[i] != runes[i-1] {
+			run = 1
+			continue
+		}
+		if run++; run >= passwordMinRunLen {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSequenceRun spots alphabetical/numeric runs in either direction and keyboard row runs like "qwe" or "asdf"
+func hasSequenceRun(s string) bool {
+	runes := []rune(s)
+	for i := 0; i+passwordMinRunLen <= len(runes); i++ {
+		step := runes[i+1] - runes[i]
+		if step != 1 && step != -1 {
+			continue
+		}
+		if runes[i+2]-runes[i+1] == step {
+			return true
+		}
+	}
+
+	for i := 0; i+passwordMinRunLen <= len(runes); i++ {
+		window := string(runes[i : i+passwordMinRunLen])
+		for _, row := range keyboardRows {
+			if strings.Contains(row, window) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// personalTokens splits emails at "@" and drops parts too short to be meaningful in a password
+func personalTokens(personal []string) []string {
+	var tokens []string
+	for _, value := range personal {
+		local, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(value)), "@")
+		if len([]rune(local)) >= UsernameMinLen {
+			tokens = append(tokens, local)
+		}
+	}
+	return tokens
+}
+
+func containsAny(s string, needles []string) bool {
+	for _, needle := range needles {
+		if strings.Contains(s, needle) {
+			return true
+		}
+	}
+	return false
+}
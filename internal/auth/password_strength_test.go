@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	validator := NewValidator(nil, false, nil, 0)
+
+	tests := []struct {
+		name       string
+		password   string
+		personal   []string
+		wantScore  int
+		wantPolicy string
+		want       []string
+	}{
+		{"empty", "", nil, 0, ErrPasswordRequired, []string{FeedbackPasswordLonger}},
+		{
+			"common password", "password", nil, 0, ErrPasswordWeak,
+			[]string{FeedbackPasswordLonger, FeedbackPasswordVariety, FeedbackPasswordCommon},
+		},
+		// Passing the policy doesn't make a password strong
+		{
+			"policy-compliant but guessable", "Qwerty123!", nil, 0, "",
+			[]string{FeedbackPasswordLonger, FeedbackPasswordSequences, FeedbackPasswordCommon},
+		},
+		{"repeated characters", "Zk7mmmmW", nil, 1, "", []string{FeedbackPasswordLonger, FeedbackPasswordRepeats}},
+		{
+			"built around the username", "Johnsmith#2024x", []string{"johnsmith@example.com", "johnsmith"}, 1, "",
+			[]string{FeedbackPasswordPersonal},
+		},
+		{"unrelated words", "Tr4ce-Lantern-Moss9", nil, PasswordScoreMax, "", nil},
+		{"unrelated words for another user", "Tr4ce-Lantern-Moss9", []string{"jo@example.com", "johnsmith"}, PasswordScoreMax, "", nil},
+		{
+			"too long for the policy", strings.Repeat("Tr4ce-Lantern-Moss9", 2), nil, PasswordScoreMax,
+			fmt.Sprintf(ErrPasswordTooLong, PasswordMaxLen), nil,
+		},
+	}
+	for _, tt := range tests {
+		got := validator.CheckPasswordStrength(tt.password, tt.personal...)
+		if got.Score != tt.wantScore || got.PolicyError != tt.wantPolicy || !slices.Equal(got.Feedback, tt.want) {
+			t.Errorf(
+				"%s: score %d, policy %q, feedback %q; want %d, %q, %q",
+				tt.name, got.Score, got.PolicyError, got.Feedback, tt.wantScore, tt.wantPolicy, tt.want,
+			)
+		}
+	}
+}
+
+func TestPasswordStrengthEndpoint(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	tests := []struct {
+		body string
+		want PasswordStrengthResponse
+	}{
+		{
+			`{"password":"password"}`,
+			PasswordStrengthResponse{
+				Score:       0,
+				MaxScore:    PasswordScoreMax,
+				Feedback:    []string{FeedbackPasswordLonger, FeedbackPasswordVariety, FeedbackPasswordCommon},
+				PolicyError: ErrPasswordWeak,
+			},
+		},
+		{
+			`{"password":"Johnsmith#2024x","username":"johnsmith","email":"js@example.com"}`,
+			PasswordStrengthResponse{
+				Score:        1,
+				MaxScore:     PasswordScoreMax,
+				Feedback:     []string{FeedbackPasswordPersonal},
+				PassesPolicy: true,
+			},
+		},
+		{
+			`{"password":"Tr4ce-Lantern-Moss9"}`,
+			PasswordStrengthResponse{Score: PasswordScoreMax, MaxScore: PasswordScoreMax, Feedback: []string{}, PassesPolicy: true},
+		},
+	}
+	for _, tt := range tests {
+		rec := env.do(t, http.MethodPost, "/auth/password-strength", tt.body)
+		var got PasswordStrengthResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d, %v (%s)", tt.body, rec.Code, err, rec.Body)
+		}
+		if got.Score != tt.want.Score || got.MaxScore != tt.want.MaxScore || got.PassesPolicy != tt.want.PassesPolicy ||
+			got.PolicyError != tt.want.PolicyError || !slices.Equal(got.Feedback, tt.want.Feedback) {
+			t.Errorf("%s: got %+v, want %+v", tt.body, got, tt.want)
+		}
+		// An empty list, not null, so the meter can render it as is
+		if !strings.Contains(rec.Body.String(), `"feedback":[`) {
+			t.Errorf("%s: feedback isn't a JSON array in %s", tt.body, rec.Body)
+		}
+	}
+
+	if rec := env.do(t, http.MethodPost, "/auth/password-strength", `{"password":`); rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body: status %d, want 400", rec.Code)
+	}
+	for _, secret := range []string{"Johnsmith#2024x", "Tr4ce-Lantern-Moss9"} {
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("password %q was logged: %s", secret, logs.String())
+		}
+	}
+}
+
+func TestPasswordStrengthRateLimited(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.PasswordStrengthRateLimit = config.RateLimitConfig{Limit: 3, Window: time.Minute}
+	env := newHandlerTestEnvWithConfig(t, nil, testutil.NewFakeClock(time.Now()), cfg)
+
+	for i := range 3 {
+		if rec := env.do(t, http.MethodPost, "/auth/password-strength", `{"password":"x"}`); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+	rec := env.do(t, http.MethodPost, "/auth/password-strength", `{"password":"x"}`)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over the limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	env.clock.Advance(time.Minute)
+	if rec := env.do(t, http.MethodPost, "/auth/password-strength", `{"password":"x"}`); rec.Code != http.StatusOK {
+		t.Fatalf("next window: status %d", rec.Code)
+	}
+}
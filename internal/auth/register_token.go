@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RegisterTokenPrefix = "auth:register_token:"
+	RegisterTokenTTL    = time.Hour
+	// Humans take longer than this to fill the form, bots submitting right after fetching the token don't
+	RegisterTokenMinAge = 2 * time.Second
+)
+
+const (
+	ErrRegisterTokenRequired = "form token is required"
+	ErrRegisterTokenInvalid  = "form token is invalid or expired"
+	ErrRegisterTokenTooFast  = "form was submitted too quickly, please try again"
+)
+
+var ErrRegisterTokensUnavailable = errors.New("registration form tokens are unavailable")
+
+// registrationStats is published under "registration" on GET /admin/metrics to tune the bot checks
+var registrationStats = expvar.NewMap("registration")
+
+// IssueRegisterToken hands out a form token remembering when it was issued. Needs Redis.
+// It's single use: the first submission carrying it consumes it, so the form fetches a new one after a failure.
+func (s *Service) IssueRegisterToken(ctx context.Context) (string, error) {
+	if s.redis == nil {
+		return "", ErrRegisterTokensUnavailable
+	}
+
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(randomBytes)
+
+	issuedAt := strconv.FormatInt(s.clock.Now().UnixMilli(), 10)
+	if err := s.redis.Set(ctx, RegisterTokenPrefix+token, issuedAt, RegisterTokenTTL).Err(); err != nil {
+		return "", fmt.Errorf("failed to store register token: %w", err)
+	}
+	return token, nil
+}
+
+// checkRegisterToken returns a validation message when the submission looks automated.
+// A sent token is always checked and consumed (GETDEL), so concurrent submissions can't share one; a missing one
+// only fails in strict mode. Without Redis, or when it fails, the check is skipped: it's a cheap bot filter and
+// not worth blocking sign-ups over.
+func (s *Service) checkRegisterToken(ctx context.Context, token string) string {
+	if s.redis == nil {
+		return ""
+	}
+	if token == "" {
+		if !s.authCfg.RegisterFormTokenRequired {
+			return ""
+		}
+		registrationStats.Add("form_token_missing", 1)
+		return ErrRegisterTokenRequired
+	}
+
+	issuedAt, err := s.redis.GetDel(ctx, RegisterTokenPrefix+token).Int64()
+	if errors.Is(err, redis.Nil) {
+		registrationStats.Add("form_token_invalid", 1)
+		return ErrRegisterTokenInvalid
+	}
+	if err != nil {
+		log.Printf("Failed to check register token, letting the request through: %v", err)
+		return ""
+	}
+
+	if s.clock.Now().Sub(time.UnixMilli(issuedAt)) < RegisterTokenMinAge {
+		registrationStats.Add("form_token_too_fast", 1)
+		return ErrRegisterTokenTooFast
+	}
+	return ""
+}
+
+// countHoneypot records a registration dropped because the hidden honeypot field was filled in
+func countHoneypot(clientIP string) {
+	registrationStats.Add("honeypot", 1)
+	log.Printf("Registration honeypot triggered from %s", clientIP)
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// registerBody carries a weak password: a submission whose form token passes is then rejected on the password,
+// before anything reaches the user service
+func registerBody(formToken string) string {
+	return `{"email":"a@example.com","username":"someone","password":"weak","form_token":"` + formToken + `"}`
+}
+
+// formTokenError returns the form_token validation message of a register response, "" when there is none
+func formTokenError(t *testing.T, body []byte) string {
+	t.Helper()
+
+	var resp struct {
+		Details []ValidationError `json:"details"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("unexpected response %s", body)
+	}
+	for _, detail := range resp.Details {
+		if detail.Field == "form_token" {
+			return detail.Message
+		}
+	}
+	return ""
+}
+
+func TestRegisterTokenIssued(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+
+	rec := env.do(t, http.MethodGet, "/auth/register-token", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	var resp RegisterTokenResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.FormToken == "" || resp.ExpiresIn != int(RegisterTokenTTL.Seconds()) {
+		t.Fatalf("response = %+v", resp)
+	}
+	ttl := env.service.redis.TTL(context.Background(), RegisterTokenPrefix+resp.FormToken).Val()
+	if ttl <= 0 || ttl > RegisterTokenTTL {
+		t.Errorf("stored token TTL = %v, want up to %v", ttl, RegisterTokenTTL)
+	}
+}
+
+func TestRegisterTokenRateLimited(t *testing.T) {
+	cfg := testConfig()
+	cfg.Auth.RegisterTokenRateLimit = config.RateLimitConfig{Limit: 2, Window: time.Minute}
+	env := newHandlerTestEnvWithConfig(t, nil, testutil.NewFakeClock(time.Now()), cfg)
+
+	for i := range 2 {
+		if rec := env.do(t, http.MethodGet, "/auth/register-token", ""); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+	}
+	rec := env.do(t, http.MethodGet, "/auth/register-token", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over the limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	env.clock.Advance(time.Minute)
+	if rec := env.do(t, http.MethodGet, "/auth/register-token", ""); rec.Code != http.StatusOK {
+		t.Fatalf("next window: status %d", rec.Code)
+	}
+}
+
+func TestRegisterTokenUnavailable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := testConfig()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr(), MaxRetries: -1})
+	clock := testutil.NewFakeClock(time.Now())
+	env := handlerTestEnv{router: gin.New(), cfg: cfg, clock: clock}
+	env.service = NewService(nil, config.GoogleConfig{}, client, cfg.Auth, clock)
+	RegisterRoutes(env.router, NewHandler(env.service, cfg), client, clock)
+	server.Close()
+
+	if rec := env.do(t, http.MethodGet, "/auth/register-token", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Redis down: status %d, want 503", rec.Code)
+	}
+
+	withoutRedis := handlerTestEnv{router: gin.New(), cfg: cfg, clock: clock}
+	RegisterRoutes(withoutRedis.router, NewHandler(NewService(nil, config.GoogleConfig{}, nil, cfg.Auth, clock), cfg), nil, clock)
+	if rec := withoutRedis.do(t, http.MethodGet, "/auth/register-token", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("no Redis: status %d, want 503", rec.Code)
+	}
+}
+
+func TestRegisterFormTokenChecks(t *testing.T) {
+	strict := testConfig()
+	strict.Auth.RegisterFormTokenRequired = true
+
+	tests := []struct {
+		name   string
+		cfg    *config.Config
+		token  func(env handlerTestEnv) string
+		expect string
+	}{
+		{"missing, lenient", testConfig(), func(handlerTestEnv) string { return "" }, ""},
+		{"missing, strict", strict, func(handlerTestEnv) string { return "" }, ErrRegisterTokenRequired},
+		{"unknown", testConfig(), func(handlerTestEnv) string { return "made-up" }, ErrRegisterTokenInvalid},
+		{
+			"too fast", testConfig(), func(env handlerTestEnv) string {
+				token, _ := env.service.IssueRegisterToken(context.Background())
+				return token
+			}, ErrRegisterTokenTooFast,
+		},
+		{
+			"expired", testConfig(), func(env handlerTestEnv) string {
+				token := env.registerFormToken(t)
+				env.service.redis.Del(context.Background(), RegisterTokenPrefix+token) // What the TTL does
+				return token
+			}, ErrRegisterTokenInvalid,
+		},
+		{"valid", testConfig(), func(env handlerTestEnv) string { return env.registerFormToken(t) }, ""},
+	}
+	for _, tt := range tests {
+		env := newHandlerTestEnvWithConfig(t, nil, testutil.NewFakeClock(time.Now()), tt.cfg)
+		rec := env.do(t, http.MethodPost, "/auth/register", registerBody(tt.token(env)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400 (%s)", tt.name, rec.Code, rec.Body)
+			continue
+		}
+		if got := formTokenError(t, rec.Body.Bytes()); got != tt.expect {
+			t.Errorf("%s: form_token error %q, want %q", tt.name, got, tt.expect)
+		}
+	}
+}
+
+// A token is spent by the first submission, even a rejected one, so a bot can't retry with it
+// or fan it out over concurrent requests
+func TestRegisterFormTokenSingleUse(t *testing.T) {
+	env := newHandlerTestEnv(t, nil, testutil.NewFakeClock(time.Now()))
+
+	token, err := env.service.IssueRegisterToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := env.do(t, http.MethodPost, "/auth/register", registerBody(token))
+	if got := formTokenError(t, rec.Body.Bytes()); got != ErrRegisterTokenTooFast {
+		t.Fatalf("first submission: form_token error %q", got)
+	}
+	env.clock.Advance(RegisterTokenMinAge)
+	rec = env.do(t, http.MethodPost, "/auth/register", registerBody(token))
+	if got := formTokenError(t, rec.Body.Bytes()); got != ErrRegisterTokenInvalid {
+		t.Fatalf("retry with the same token: form_token error %q, want %q", got, ErrRegisterTokenInvalid)
+	}
+
+	token = env.registerFormToken(t)
+	const submissions = 20
+	accepted := make([]bool, submissions)
+	var wg sync.WaitGroup
+	for i := range submissions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := env.do(t, http.MethodPost, "/auth/register", registerBody(token))
+			accepted[i] = !strings.Contains(rec.Body.String(), `"field":"form_token"`)
+		}()
+	}
+	wg.Wait()
+
+	passed := 0
+	for _, ok := range accepted {
+		if ok {
+			passed++
+		}
+	}
+	if passed != 1 {
+		t.Fatalf("%d concurrent submissions got past the form token, want 1", passed)
+	}
+}
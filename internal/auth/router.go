@@ -3,24 +3,41 @@ package auth
 import (
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
-func RegisterRoutes(router *gin.Engine, h *Handler) {
+// RegisterRoutes needs Redis for the rate limits shared across instances
+func RegisterRoutes(router *gin.Engine, h *Handler, redisClient *redis.Client, clock utils.Clock) {
+	requireAuth := utils.JWTAuthMiddleware(&h.config.JWT, clock)
+	optionalAuth := utils.OptionalJWTAuthMiddleware(&h.config.JWT, clock)
+
 	authRouter := router.Group("/auth")
 	{
 		authRouter.POST("/register", h.Register)
+		// Each token is a Redis key for an hour, so handing them out must not be free
+		authRouter.GET(
+			"/register-token",
+			utils.RateLimit(redisClient, "register_token", h.config.Auth.RegisterTokenRateLimit, clock),
+			h.RegisterToken,
+		)
+		// Called on every keystroke by the registration form, hence the generous but finite budget
+		authRouter.POST(
+			"/password-strength",
+			utils.RateLimit(redisClient, "password_strength", h.config.Auth.PasswordStrengthRateLimit, clock),
+			h.PasswordStrength,
+		)
 		authRouter.POST("/login", h.Login)
-		authRouter.POST("/logout", utils.JWTAuthMiddleware(&h.config.JWT), h.Logout)
+		authRouter.POST("/logout", requireAuth, h.Logout)
 		authRouter.POST("/refresh", h.RefreshToken)
 	}
 
-	registerGoogleAuthRoutes(authRouter, h)
+	registerGoogleAuthRoutes(authRouter, h, optionalAuth)
 }
 
-func registerGoogleAuthRoutes(baseRouter *gin.RouterGroup, h *Handler) {
+func registerGoogleAuthRoutes(baseRouter *gin.RouterGroup, h *Handler, optionalAuth gin.HandlerFunc) {
 	googleAuthRouter := baseRouter.Group("/google")
 	{
-		googleAuthRouter.GET("/url", h.GoogleURL)
+		googleAuthRouter.GET("/url", optionalAuth, h.GoogleURL)
 		googleAuthRouter.GET("/callback", h.HandleGoogleCallback)
 	}
 }
@@ -6,11 +6,62 @@ type RegisterRequest struct {
 	Email    string `json:"email"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Bot defenses, both optional: Website is a honeypot the form hides from humans,
+	// FormToken comes from GET /auth/register-token
+	Website   string `json:"website"`
+	FormToken string `json:"form_token"`
+}
+
+type RegisterTokenResponse struct {
+	FormToken string `json:"form_token"`
+	ExpiresIn int    `json:"expires_in"` // Seconds
 }
 
 type BasicLoginRequest struct {
-	Email    string `json:"email"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+// PasswordStrengthRequest takes the username/email being registered too, so the meter can flag passwords built
+// around them. Nothing in it is stored or logged.
+type PasswordStrengthRequest struct {
 	Password string `json:"password"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
+}
+
+type PasswordStrengthResponse struct {
+	Score        int      `json:"score"`
+	MaxScore     int      `json:"max_score"`
+	Feedback     []string `json:"feedback"`
+	PassesPolicy bool     `json:"passes_policy"`
+	PolicyError  string   `json:"policy_error,omitempty"`
+}
+
+func ToPasswordStrengthResponse(strength PasswordStrength) PasswordStrengthResponse {
+	feedback := strength.Feedback
+	if feedback == nil {
+		feedback = []string{}
+	}
+	return PasswordStrengthResponse{
+		Score:        strength.Score,
+		MaxScore:     PasswordScoreMax,
+		Feedback:     feedback,
+		PassesPolicy: strength.PolicyError == "",
+		PolicyError:  strength.PolicyError,
+	}
+}
+
+// RefreshRequest lets clients without cookies (mobile, CLI) send the refresh token in the body
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type TokenResponse struct {
+	Message      string `json:"message"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type GoogleUserInfo struct {
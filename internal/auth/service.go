@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,23 +12,32 @@ import (
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+// googleUserInfoURL serves the profile of the account behind a Google access token
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
 type Service struct {
 	userService *user.Service
 	validator   *Validator
 	oauthConfig *oauth2.Config
+	userInfoURL string // googleUserInfoURL, tests point it at a fake
 	redis       *redis.Client
+	authCfg     config.AuthConfig
+	clock       utils.Clock
 }
 
 func NewService(
 	userService *user.Service,
 	googleCfg config.GoogleConfig,
 	redisClient *redis.Client,
+	authCfg config.AuthConfig,
+	clock utils.Clock,
 ) *Service {
 	oauthConfig := &oauth2.Config{
 		ClientID:     googleCfg.ClientID,
@@ -39,42 +49,74 @@ func NewService(
 
 	return &Service{
 		userService: userService,
-		validator:   NewValidator(userService),
+		validator: NewValidator(
+			userService,
+			authCfg.ConfusableUsernameCheck,
+			authCfg.AllowedEmailDomains,
+			authCfg.UsernameMaxLen,
+		),
 		oauthConfig: oauthConfig,
+		userInfoURL: googleUserInfoURL,
 		redis:       redisClient,
+		authCfg:     authCfg,
+		clock:       clock,
 	}
 }
 
 var (
 	ErrInvalidCredentials     = errors.New("invalid email or password")
 	ErrOAuthAccountNoPassword = errors.New("account uses OAuth, no password set")
+	ErrOAuthStateMismatch     = errors.New("OAuth state not started in this browser, expired or already used")
+	ErrOAuthUnavailable       = errors.New("OAuth state storage unavailable")
 )
 
-func (s *Service) Register(ctx context.Context, email, username, password string) error {
-	if errs := s.validator.ValidateRegistrationInput(
+// Register checks the form token before anything else, so bots don't get to probe taken emails/usernames
+func (s *Service) Register(ctx context.Context, email, username, password, formToken string) error {
+	if message := s.checkRegisterToken(ctx, formToken); message != "" {
+		return ValidationErrors{NewValidationError("form_token", message)}
+	}
+
+	username = utils.NormalizeText(username)
+	errs, err := s.validator.ValidateRegistrationInput(
 		ctx,
 		email,
 		username,
 		password,
-	); len(errs) > 0 {
+	)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
 		return errs
 	}
 
-	_, err := s.userService.CreateUser(ctx, email, username, password)
+	_, err = s.userService.CreateUser(ctx, email, username, password)
 	return err
 }
 
+func (s *Service) CheckPasswordStrength(password, email, username string) PasswordStrength {
+	return s.validator.CheckPasswordStrength(password, email, username)
+}
+
 func (s *Service) Login(
 	ctx context.Context,
 	cfg config.JWTConfig,
 	email, password string,
+	rememberMe bool,
 ) (*utils.TokenPair, error) {
-	if errs := s.validator.ValidateLoginInput(ctx, email, password); len(errs) > 0 {
+	errs, err := s.validator.ValidateLoginInput(ctx, email, password)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
 		return nil, errs
 	}
 	userObj, err := s.userService.GetByEmail(ctx, email)
 	if err != nil {
-		return nil, ErrInvalidCredentials
+		if errors.Is(err, user.ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
 	}
 
 	if userObj.Password == nil {
@@ -85,30 +127,80 @@ func (s *Service) Login(
 		return nil, ErrInvalidCredentials
 	}
 
-	return s.generateTokenPair(&cfg, userObj.ID.String())
+	return s.generateTokenPair(&cfg, userObj.ID.String(), rememberMe)
 }
 
-func (s *Service) CreateGoogleURL(cfg *config.Config) (string, error) {
-	state, err := GenerateState(cfg.JWT.Secret)
+// CreateGoogleURL starts the OAuth flow; purpose, redirect path and (for linking) the user are bound into the signed state.
+// The returned nonce goes into the OAuthStateCookie, it's also stored in Redis until the callback consumes it.
+func (s *Service) CreateGoogleURL(
+	ctx context.Context,
+	cfg *config.Config,
+	purpose, redirectPath, userID string,
+) (authURL, nonce string, err error) {
+	if s.redis == nil {
+		return "", "", ErrOAuthUnavailable
+	}
+
+	state, nonce, err := GenerateState(
+		cfg.JWT.Secret, OAuthState{
+			Purpose:  purpose,
+			Redirect: ResolveRedirectPath(redirectPath, s.authCfg.RedirectAllowlist),
+			UserID:   userID,
+		},
+	)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate state token: %w", err)
+		return "", "", fmt.Errorf("failed to generate state token: %w", err)
+	}
+	if err := s.redis.Set(ctx, OAuthStatePrefix+nonce, purpose, OAuthStateTTL).Err(); err != nil {
+		return "", "", fmt.Errorf("%w: %w", ErrOAuthUnavailable, err)
 	}
-	authURL := s.oauthConfig.AuthCodeURL(
+
+	authURL = s.oauthConfig.AuthCodeURL(
 		state,
 		oauth2.AccessTypeOnline,
 		oauth2.SetAuthURLParam("prompt", "select_account"),
 	)
-	return authURL, nil
+	return authURL, nonce, nil
+}
+
+// consumeState accepts a state only in the browser that started the flow (cookieNonce) and only once.
+// Without it a victim lured to an attacker's callback URL would link the attacker's Google account.
+func (s *Service) consumeState(ctx context.Context, oauthState *OAuthState, cookieNonce string) error {
+	if cookieNonce == "" || subtle.ConstantTimeCompare([]byte(cookieNonce), []byte(oauthState.Nonce)) != 1 {
+		return ErrOAuthStateMismatch
+	}
+	if s.redis == nil {
+		return ErrOAuthUnavailable
+	}
+
+	if err := s.redis.GetDel(ctx, OAuthStatePrefix+oauthState.Nonce).Err(); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrOAuthStateMismatch // Expired or already used
+		}
+		return fmt.Errorf("%w: %w", ErrOAuthUnavailable, err)
+	}
+	return nil
+}
+
+// GoogleCallbackResult tells the handler where to send the user; Tokens is only set for login flows
+type GoogleCallbackResult struct {
+	Purpose  string
+	Redirect string
+	Tokens   *utils.TokenPair
 }
 
 func (s *Service) HandleGoogleCallback(
 	ctx context.Context,
 	jwtCfg *config.JWTConfig,
-	code, state string,
-) (*utils.TokenPair, error) {
-	if err := ValidateState(state, jwtCfg.Secret); err != nil {
+	code, state, cookieNonce string,
+) (*GoogleCallbackResult, error) {
+	oauthState, err := ValidateState(state, jwtCfg.Secret)
+	if err != nil {
 		return nil, fmt.Errorf("invalid state: %w", err)
 	}
+	if err := s.consumeState(ctx, oauthState, cookieNonce); err != nil {
+		return nil, err
+	}
 
 	token, err := s.oauthConfig.Exchange(ctx, code)
 	if err != nil {
@@ -120,30 +212,47 @@ func (s *Service) HandleGoogleCallback(
 		return nil, fmt.Errorf("failed to fetch user info: %w", err)
 	}
 
+	result := &GoogleCallbackResult{
+		Purpose: oauthState.Purpose,
+		// Checked again in case the allowlist shrank while the flow was in progress
+		Redirect: ResolveRedirectPath(oauthState.Redirect, s.authCfg.RedirectAllowlist),
+	}
+
+	if oauthState.Purpose == OAuthPurposeLink {
+		userID, err := uuid.Parse(oauthState.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state: %w", err)
+		}
+		if err := s.userService.LinkGoogleAccount(ctx, userID, userInfo.ID, userInfo.AvatarURL); err != nil {
+			return nil, fmt.Errorf("failed to link account: %w", err)
+		}
+		return result, nil
+	}
+
+	// Existing accounts may still sign in with Google, the domain allowlist only gates new ones
 	userObj, err := s.userService.FindOrCreateByGoogle(
 		ctx,
 		userInfo.Email,
 		userInfo.ID,
 		userInfo.AvatarURL,
+		s.validator.ValidateEmailDomain(userInfo.Email) == nil,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
 	}
 
-	return s.generateTokenPair(jwtCfg, userObj.ID.String())
+	// Google sign-in has no "remember me" checkbox, so it gets a session-scoped login
+	if result.Tokens, err = s.generateTokenPair(jwtCfg, userObj.ID.String(), false); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func (s *Service) fetchGoogleUserInfo(ctx context.Context, accessToken string) (
 	*GoogleUserInfo,
 	error,
 ) {
-	// TODO: replace hardcoded values
-	req, _ := http.NewRequestWithContext(
-		ctx,
-		"GET",
-		"https://www.googleapis.com/oauth2/v2/userinfo",
-		nil,
-	)
+	req, _ := http.NewRequestWithContext(ctx, "GET", s.userInfoURL, nil)
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
 	resp, err := http.DefaultClient.Do(req)
@@ -164,7 +273,7 @@ func (s *Service) refreshTokens(
 	refreshToken string,
 	cfg *config.JWTConfig,
 ) (*utils.TokenPair, error) {
-	userID, _, err := utils.DecryptJWT(refreshToken, cfg.Secret, utils.TokenTypeRefresh)
+	userID, claims, err := utils.DecryptJWT(s.clock, refreshToken, cfg.Secret, utils.TokenTypeRefresh)
 	if err != nil {
 		return nil, utils.ErrInvalidRefreshToken
 	}
@@ -187,15 +296,18 @@ func (s *Service) refreshTokens(
 		return nil, err
 	}
 
-	return s.generateTokenPair(cfg, userID)
+	// Rotated tokens keep the lifetime class the user picked at login
+	rememberMe, _ := claims[utils.ClaimRememberMe].(bool)
+	return s.generateTokenPair(cfg, userID, rememberMe)
 
 }
 
-func (s *Service) generateTokenPair(cfg *config.JWTConfig, userID string) (
+func (s *Service) generateTokenPair(cfg *config.JWTConfig, userID string, rememberMe bool) (
 	*utils.TokenPair,
 	error,
 ) {
 	accessToken, err := utils.GenerateJWT(
+		s.clock,
 		cfg.Secret,
 		utils.TokenTypeAccess,
 		cfg.AccessLifetime,
@@ -205,11 +317,18 @@ func (s *Service) generateTokenPair(cfg *config.JWTConfig, userID string) (
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
 
-	refreshToken, err := utils.GenerateJWT(
+	refreshLifetime := cfg.RefreshLifetime
+	if rememberMe {
+		refreshLifetime = cfg.RememberMeLifetime
+	}
+
+	refreshToken, err := utils.GenerateJWTWithClaims(
+		s.clock,
 		cfg.Secret,
 		utils.TokenTypeRefresh,
-		cfg.RefreshLifetime,
+		refreshLifetime,
 		userID,
+		jwt.MapClaims{utils.ClaimRememberMe: rememberMe},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
@@ -218,6 +337,7 @@ func (s *Service) generateTokenPair(cfg *config.JWTConfig, userID string) (
 	return &utils.TokenPair{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
+		RememberMe:   rememberMe,
 	}, nil
 }
 
@@ -241,7 +361,7 @@ func (s *Service) blacklistToken(
 		return nil
 	}
 
-	_, tokenClaims, err := utils.DecryptJWT(token, cfg.Secret, expectedTokenType)
+	_, tokenClaims, err := utils.DecryptJWT(s.clock, token, cfg.Secret, expectedTokenType)
 	if err != nil {
 		return err
 	}
@@ -250,7 +370,7 @@ func (s *Service) blacklistToken(
 		return fmt.Errorf("token missing expiry claim")
 	}
 
-	ttl := time.Until(time.Unix(int64(exp), 0))
+	ttl := time.Unix(int64(exp), 0).Sub(s.clock.Now())
 	if ttl <= 0 {
 		return fmt.Errorf("token already expired")
 	}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newOAuthTestService(t *testing.T) (*Service, *config.Config) {
+	t.Helper()
+
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: "test-secret"}}
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	return NewService(nil, config.GoogleConfig{}, client, config.AuthConfig{}, utils.RealClock{}), cfg
+}
+
+// startLink runs CreateGoogleURL for a link flow and returns the state param and the cookie nonce
+func startLink(t *testing.T, service *Service, cfg *config.Config, userID string) (string, string) {
+	t.Helper()
+
+	authURL, nonce, err := service.CreateGoogleURL(context.Background(), cfg, OAuthPurposeLink, "/", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Query().Get("state"), nonce
+}
+
+func TestConsumeStateBindsFlowToBrowser(t *testing.T) {
+	service, cfg := newOAuthTestService(t)
+	ctx := context.Background()
+
+	victimState, victimNonce := startLink(t, service, cfg, "victim")
+	attackerState, attackerNonce := startLink(t, service, cfg, "attacker")
+	attacker, err := ValidateState(attackerState, cfg.JWT.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The victim's browser holds its own nonce, or none at all, never the attacker's
+	for _, cookie := range []string{"", victimNonce} {
+		if err := service.consumeState(ctx, attacker, cookie); !errors.Is(err, ErrOAuthStateMismatch) {
+			t.Errorf("attacker state with cookie %q: err = %v, want ErrOAuthStateMismatch", cookie, err)
+		}
+	}
+
+	if err := service.consumeState(ctx, attacker, attackerNonce); err != nil {
+		t.Fatalf("state in its own browser: %v", err)
+	}
+	if err := service.consumeState(ctx, attacker, attackerNonce); !errors.Is(err, ErrOAuthStateMismatch) {
+		t.Errorf("replayed state: err = %v, want ErrOAuthStateMismatch", err)
+	}
+
+	victim, err := ValidateState(victimState, cfg.JWT.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.consumeState(ctx, victim, victimNonce); err != nil {
+		t.Errorf("victim's own flow: %v", err)
+	}
+}
+
+func TestGoogleCallbackRejectsUnboundState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, cfg := newOAuthTestService(t)
+	state, _ := startLink(t, service, cfg, "attacker")
+
+	router := gin.New()
+	router.GET("/auth/google/callback", NewHandler(service, cfg).HandleGoogleCallback)
+
+	// What a victim's browser sends after following the attacker's callback link: no state cookie.
+	// It must fail before the code is exchanged, the exchange would reach Google.
+	request := httptest.NewRequest(
+		http.MethodGet, "/auth/google/callback?code=attacker-code&state="+url.QueryEscape(state), nil,
+	)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 (%s)", recorder.Code, recorder.Body)
+	}
+	if location := recorder.Header().Get("Location"); location != "" {
+		t.Errorf("redirected to %q", location)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -19,24 +20,24 @@ const (
 	ErrEmailRequired         = "email is required"
 	ErrEmailInvalid          = "invalid email format"
 	ErrEmailNoWhitespaces    = "email cannot have leading or trailing whitespace"
-	ErrEmailTaken            = "email already registered"
 	ErrEmailDoesNotExist     = "user with given email doesn't exist"
+	ErrEmailDomainNotAllowed = "registration is restricted to approved email domains"
 	ErrUsernameRequired      = "username is required"
 	ErrUsernameNoWhitespaces = "username cannot have leading or trailing whitespace"
 	ErrUsernameTooShort      = "username must be at least %d characters"
 	ErrUsernameTooLong       = "username must be at most %d characters"
 	ErrUsernameInvalid       = "username can only contain letters, numbers, and underscores"
-	ErrUsernameTaken         = "username already taken"
 	ErrPasswordRequired      = "password is required"
 	ErrPasswordNoWhitespaces = "password cannot have leading or trailing whitespace"
 	ErrPasswordTooShort      = "password must be at least %d characters"
-	ErrPasswordTooLong
-	ErrPasswordWeak = "password must contain uppercase, lowercase, and number"
+	ErrPasswordTooLong       = "password must be at most %d characters"
+	ErrPasswordWeak          = "password must contain uppercase, lowercase, and number"
 
-	UsernameMinLen = 3
-	UsernameMaxLen = 50
-	PasswordMinLen = 8
-	PasswordMaxLen = 30
+	UsernameMinLen  = 3
+	UsernameMaxLen  = 50  // Default, auth.username_max_len may change it up to UsernameHardCap
+	UsernameHardCap = 255 // Matches the column size
+	PasswordMinLen  = 8
+	PasswordMaxLen  = 30 // In bytes unlike the other limits, bcrypt only takes 72 of them
 )
 
 type Validator struct {
@@ -44,6 +45,11 @@ type Validator struct {
 	emailRegex    *regexp.Regexp
 	usernameRegex *regexp.Regexp
 	passwordRegex *regexp.Regexp
+	// Also reject usernames that only look like an existing one
+	confusableCheck bool
+	// Lowercased domains new accounts must be at, nil when sign-up is open to every domain
+	allowedEmailDomains map[string]struct{}
+	usernameMaxLen      int // In runes
 }
 
 type ValidationError struct {
@@ -53,12 +59,35 @@ type ValidationError struct {
 
 type ValidationErrors []ValidationError
 
-func NewValidator(userService *user.Service) *Validator {
+// NewValidator falls back to UsernameMaxLen for a usernameMaxLen outside UsernameMinLen..UsernameHardCap
+func NewValidator(
+	userService *user.Service,
+	confusableCheck bool,
+	allowedEmailDomains []string,
+	usernameMaxLen int,
+) *Validator {
+	var domains map[string]struct{}
+	for _, domain := range allowedEmailDomains {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			if domains == nil {
+				domains = make(map[string]struct{}, len(allowedEmailDomains))
+			}
+			domains[domain] = struct{}{}
+		}
+	}
+
+	if usernameMaxLen < UsernameMinLen || usernameMaxLen > UsernameHardCap {
+		usernameMaxLen = UsernameMaxLen
+	}
+
 	return &Validator{
-		userService:   userService,
-		emailRegex:    EmailRegex,
-		usernameRegex: UsernameRegex,
-		passwordRegex: PasswordRegex,
+		userService:         userService,
+		emailRegex:          EmailRegex,
+		usernameRegex:       UsernameRegex,
+		passwordRegex:       PasswordRegex,
+		confusableCheck:     confusableCheck,
+		allowedEmailDomains: domains,
+		usernameMaxLen:      usernameMaxLen,
 	}
 }
 
@@ -88,6 +117,22 @@ func (v *Validator) ValidateEmailFormat(email string) error {
 	return nil
 }
 
+// ValidateEmailDomain enforces the sign-up domain allowlist. Only exact domains match, subdomains have to be
+// listed on their own.
+func (v *Validator) ValidateEmailDomain(email string) error {
+	if v.allowedEmailDomains == nil {
+		return nil
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return errors.New(ErrEmailDomainNotAllowed)
+	}
+	if _, ok := v.allowedEmailDomains[strings.ToLower(email[at+1:])]; !ok {
+		return errors.New(ErrEmailDomainNotAllowed)
+	}
+	return nil
+}
+
 func (v *Validator) ValidateUsernameFormat(username string) error {
 	if username == "" {
 		return errors.New(ErrUsernameRequired)
@@ -95,11 +140,12 @@ func (v *Validator) ValidateUsernameFormat(username string) error {
 	if username != strings.TrimSpace(username) {
 		return errors.New(ErrUsernameNoWhitespaces)
 	}
-	if len(username) < UsernameMinLen {
+	length := utf8.RuneCountInString(username)
+	if length < UsernameMinLen {
 		return errors.New(fmt.Sprintf(ErrUsernameTooShort, UsernameMinLen))
 	}
-	if len(username) > UsernameMaxLen {
-		return errors.New(fmt.Sprintf(ErrUsernameTooLong, UsernameMaxLen))
+	if length > v.usernameMaxLen {
+		return errors.New(fmt.Sprintf(ErrUsernameTooLong, v.usernameMaxLen))
 	}
 	if !v.usernameRegex.MatchString(username) {
 		return errors.New(ErrUsernameInvalid)
@@ -127,16 +173,18 @@ func (v *Validator) ValidatePasswordFormat(password string) error {
 	return nil
 }
 
+// The DB-backed checks below return user.ErrUnavailable-wrapped errors when the lookup itself failed;
+// those are not validation failures and must be returned to the caller as-is
+
 func (v *Validator) ValidateEmailExists(ctx context.Context, email string) error {
-	emailExists, _ := v.userService.ExistsByEmail(ctx, email)
-	if emailExists {
-		return errors.New(ErrEmailTaken)
-	}
-	return nil
+	return v.userService.CheckEmailAvailable(ctx, email)
 }
 
 func (v *Validator) ValidateEmailNotTaken(ctx context.Context, email string) error {
-	alreadyExists, _ := v.userService.ExistsByEmail(ctx, email)
+	alreadyExists, err := v.userService.ExistsByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
 	if !alreadyExists {
 		return errors.New(ErrEmailDoesNotExist)
 	}
@@ -144,18 +192,25 @@ func (v *Validator) ValidateEmailNotTaken(ctx context.Context, email string) err
 }
 
 func (v *Validator) ValidateUsernameExists(ctx context.Context, username string) error {
-	alreadyExists, _ := v.userService.ExistsByUsername(ctx, username)
-	if alreadyExists {
-		return errors.New(ErrUsernameTaken)
+	if err := v.userService.CheckUsernameAvailable(ctx, username); err != nil {
+		return err
+	}
+	if v.confusableCheck {
+		return v.userService.CheckUsernameNotConfusable(ctx, username)
 	}
 	return nil
 }
 
-func (v *Validator) ValidateRegistrationInput(ctx context.Context, email, username, password string) ValidationErrors {
+func (v *Validator) ValidateRegistrationInput(ctx context.Context, email, username, password string) (
+	ValidationErrors,
+	error,
+) {
 	var errs ValidationErrors
 	// Format validation
 	if err := v.ValidateEmailFormat(email); err != nil {
 		errs = append(errs, NewValidationError("email", err.Error()))
+	} else if err := v.ValidateEmailDomain(email); err != nil {
+		errs = append(errs, NewValidationError("email", err.Error()))
 	}
 	if err := v.ValidateUsernameFormat(username); err != nil {
 		errs = append(errs, NewValidationError("username", err.Error()))
@@ -167,17 +222,23 @@ func (v *Validator) ValidateRegistrationInput(ctx context.Context, email, userna
 	// Business validation(expensive by DB hits, performed only if formatting validation succeeds)
 	if len(errs) == 0 {
 		if err := v.ValidateEmailExists(ctx, email); err != nil {
+			if errors.Is(err, user.ErrUnavailable) {
+				return nil, err
+			}
 			errs = append(errs, NewValidationError("email", err.Error()))
 		}
 		if err := v.ValidateUsernameExists(ctx, username); err != nil {
+			if errors.Is(err, user.ErrUnavailable) {
+				return nil, err
+			}
 			errs = append(errs, NewValidationError("username", err.Error()))
 		}
 	}
 
-	return errs
+	return errs, nil
 }
 
-func (v *Validator) ValidateLoginInput(ctx context.Context, email, password string) ValidationErrors {
+func (v *Validator) ValidateLoginInput(ctx context.Context, email, password string) (ValidationErrors, error) {
 	var errs ValidationErrors
 	if err := v.ValidateEmailFormat(email); err != nil {
 		errs = append(errs, NewValidationError("email", err.Error()))
@@ -188,8 +249,11 @@ func (v *Validator) ValidateLoginInput(ctx context.Context, email, password stri
 
 	if len(errs) == 0 {
 		if err := v.ValidateEmailNotTaken(ctx, email); err != nil {
+			if errors.Is(err, user.ErrUnavailable) {
+				return nil, err
+			}
 			errs = append(errs, NewValidationError("email", err.Error()))
 		}
 	}
-	return errs
+	return errs, nil
 }
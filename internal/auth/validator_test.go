@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func errText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Lengths count runes, so a CJK username (3 bytes a rune) reaches the length checks with its real size.
+// Non-ASCII usernames are still rejected afterwards by UsernameRegex.
+func TestValidateUsernameFormatCountsRunes(t *testing.T) {
+	validator := NewValidator(nil, false, nil, 0)
+
+	tests := []struct {
+		name     string
+		username string
+		want     string
+	}{
+		{"two CJK runes are too short", strings.Repeat("名", 2), fmt.Sprintf(ErrUsernameTooShort, UsernameMinLen)},
+		{"three CJK runes pass the length checks", strings.Repeat("名", 3), ErrUsernameInvalid},
+		{"max CJK runes pass the length checks", strings.Repeat("名", UsernameMaxLen), ErrUsernameInvalid},
+		{"one CJK rune over the max", strings.Repeat("名", UsernameMaxLen+1), fmt.Sprintf(ErrUsernameTooLong, UsernameMaxLen)},
+		{"max ASCII runes", strings.Repeat("a", UsernameMaxLen), ""},
+	}
+	for _, tt := range tests {
+		if got := errText(validator.ValidateUsernameFormat(tt.username)); got != tt.want {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewValidatorUsernameMaxLen(t *testing.T) {
+	tests := []struct {
+		configured, want int
+	}{
+		{0, UsernameMaxLen},
+		{UsernameMinLen - 1, UsernameMaxLen}, // A limit below the minimum would reject every username
+		{UsernameMinLen, UsernameMinLen},
+		{UsernameHardCap, UsernameHardCap},
+		{UsernameHardCap + 1, UsernameMaxLen},
+	}
+	for _, tt := range tests {
+		if got := NewValidator(nil, false, nil, tt.configured).usernameMaxLen; got != tt.want {
+			t.Errorf("NewValidator(usernameMaxLen %d) uses %d, want %d", tt.configured, got, tt.want)
+		}
+	}
+
+	validator := NewValidator(nil, false, nil, 20)
+	if err := validator.ValidateUsernameFormat(strings.Repeat("é", 20)); errText(err) != ErrUsernameInvalid {
+		t.Errorf("20 two-byte runes with a limit of 20: error %v, want only the charset check to fail", err)
+	}
+	if err := validator.ValidateUsernameFormat(strings.Repeat("é", 21)); errText(err) != fmt.Sprintf(ErrUsernameTooLong, 20) {
+		t.Errorf("21 two-byte runes with a limit of 20: error %v, want too long", err)
+	}
+}
+
+// Credentials are rejected with surrounding whitespace rather than trimmed, a password can't be silently changed
+func TestValidatorRejectsSurroundingWhitespace(t *testing.T) {
+	validator := NewValidator(nil, false, nil, 0)
+
+	if got := errText(validator.ValidateEmailFormat(" gopher@example.com")); got != ErrEmailNoWhitespaces {
+		t.Errorf("padded email: error %q, want %q", got, ErrEmailNoWhitespaces)
+	}
+	if got := errText(validator.ValidateUsernameFormat("gopher ")); got != ErrUsernameNoWhitespaces {
+		t.Errorf("padded username: error %q, want %q", got, ErrUsernameNoWhitespaces)
+	}
+	if got := errText(validator.ValidatePasswordFormat(" Secret123")); got != ErrPasswordNoWhitespaces {
+		t.Errorf("padded password: error %q, want %q", got, ErrPasswordNoWhitespaces)
+	}
+}
+
+func TestValidateEmailDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		email   string
+		want    string
+	}{
+		{"empty policy", nil, "anyone@gmail.com", ""},
+		{"blank entries only", []string{" ", ""}, "anyone@gmail.com", ""},
+		{"allowed domain", []string{" Agora.example ", "corp.example"}, "dev@agora.example", ""},
+		{"allowed domain in another case", []string{"agora.example"}, "dev@AGORA.Example", ""},
+		{"other domain", []string{"agora.example"}, "dev@gmail.com", ErrEmailDomainNotAllowed},
+		{"subdomain isn't covered", []string{"agora.example"}, "dev@mail.agora.example", ErrEmailDomainNotAllowed},
+		{"lookalike suffix", []string{"agora.example"}, "dev@evilagora.example", ErrEmailDomainNotAllowed},
+		{"domain in the local part", []string{"agora.example"}, `"x@agora.example"@gmail.com`, ErrEmailDomainNotAllowed},
+		{"no domain", []string{"agora.example"}, "agora.example", ErrEmailDomainNotAllowed},
+	}
+	for _, tt := range tests {
+		validator := NewValidator(nil, false, tt.allowed, 0)
+		if got := errText(validator.ValidateEmailDomain(tt.email)); got != tt.want {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"log"
 	"os"
@@ -8,14 +9,22 @@ import (
 )
 
 type Config struct {
-	App      AppConfig     `yaml:"app"`
-	Server   ServerConfig  `yaml:"server"`
-	Logging  LoggingConfig `yaml:"logging"`
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Project  ProjectConfig
-	Google   GoogleConfig
+	App         AppConfig         `yaml:"app"`
+	Server      ServerConfig      `yaml:"server"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	Subreddit   SubredditConfig   `yaml:"subreddit"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Debug       DebugConfig       `yaml:"debug"`
+	Outbox      OutboxConfig      `yaml:"outbox"`
+	Audit       AuditConfig       `yaml:"audit"`
+	Retention   RetentionConfig   `yaml:"retention"`
+	Database    DatabaseConfig
+	Redis       RedisConfig
+	JWT         JWTConfig
+	Project     ProjectConfig
+	Google      GoogleConfig
+	Email       EmailConfig
 }
 type AppConfig struct {
 	Name    string `yaml:"name"`
@@ -27,6 +36,25 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `yaml:"write_timeout"`
 	IdleTimeout  time.Duration `yaml:"idle_timeout"`
 	Cors         CorsConfig
+
+	// Handlers still running after it get cut off with 503, keep it below WriteTimeout
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	// Request body guards applied before JSON reaches handlers
+	MaxBodyBytes              int64 `yaml:"max_body_bytes"`
+	MaxJSONDepth              int   `yaml:"max_json_depth"`
+	DisallowUnknownJSONFields bool  `yaml:"disallow_unknown_json_fields"`
+
+	// Caps on in-flight requests to expensive route groups, keyed by group name (see utils.ConcurrencyLimiter)
+	ConcurrencyLimits map[string]ConcurrencyLimitConfig `yaml:"concurrency_limits"`
+}
+
+// ConcurrencyLimitConfig sizes one route group's semaphore. Routes take a weight out of Capacity,
+// requests wait up to MaxWait for room and are then turned away. A group without Capacity isn't limited.
+type ConcurrencyLimitConfig struct {
+	Capacity   int64         `yaml:"capacity"`
+	MaxWait    time.Duration `yaml:"max_wait"`
+	RetryAfter time.Duration `yaml:"retry_after"`
 }
 
 type LoggingConfig struct {
@@ -34,10 +62,146 @@ type LoggingConfig struct {
 	Format string `yaml:"format"`
 }
 
+// MaintenanceConfig holds defaults used when the feature flag isn't overridden, see flags.MaintenanceReadOnly
+type MaintenanceConfig struct {
+	ReadOnly bool `yaml:"read_only"`
+}
+
+type SubredditConfig struct {
+	MaxMembershipsPerUser int `yaml:"max_memberships_per_user"`
+	// ISO 639-1 codes accepted as a subreddit's content language
+	AllowedLanguages []string `yaml:"allowed_languages"`
+	// How long a creator can still cancel a requested deletion, and how often due ones are purged
+	DeletionGracePeriod   time.Duration `yaml:"deletion_grace_period"`
+	DeletionPurgeInterval time.Duration `yaml:"deletion_purge_interval"`
+
+	TopicPageCache CacheTTLConfig `yaml:"topic_page_cache"` // First page of GET /subreddits/by-topic/:slug
+
+	DisplayNameMaxLen int `yaml:"display_name_max_len"` // In runes (code points), 0 keeps subreddit.DisplayNameMaxLen
+}
+
+// CacheTTLConfig configures a stale-while-revalidate cache (see utils.SWRCache).
+// Entries are fresh for SoftTTL, then served stale while refreshing until HardTTL.
+type CacheTTLConfig struct {
+	SoftTTL time.Duration `yaml:"soft_ttl"`
+	HardTTL time.Duration `yaml:"hard_ttl"`
+}
+
+type AuthConfig struct {
+	// Frontend paths OAuth flows may return to: exact paths, or prefixes written as "/r/*"
+	RedirectAllowlist []string `yaml:"redirect_allowlist"`
+	// Reject registrations whose username is a lookalike of an existing one (see utils.Skeleton)
+	ConfusableUsernameCheck bool `yaml:"confusable_username_check"`
+
+	PasswordStrengthRateLimit RateLimitConfig `yaml:"password_strength_rate_limit"` // Per client IP
+	RegisterTokenRateLimit    RateLimitConfig `yaml:"register_token_rate_limit"`    // Per client IP
+
+	// Invite-only deployments: only emails at these domains may sign up (password or Google). Empty allows all.
+	AllowedEmailDomains []string `yaml:"allowed_email_domains"`
+
+	UsernameMaxLen int `yaml:"username_max_len"` // In runes (code points), 0 keeps auth.UsernameMaxLen
+
+	// Strict mode: registrations must carry a form token from GET /auth/register-token (needs Redis).
+	// Sent tokens are always checked.
+	RegisterFormTokenRequired bool `yaml:"register_form_token_required"`
+
+	EmailNormalization EmailNormalizationConfig `yaml:"email_normalization"`
+}
+
+// EmailNormalizationConfig drives duplicate detection for aliases of one mailbox (see utils.EmailNormalizer).
+// Disabled, addresses are only compared case-insensitively.
+type EmailNormalizationConfig struct {
+	Enabled   bool                  `yaml:"enabled"`
+	Providers []EmailProviderConfig `yaml:"providers"`
+}
+
+// EmailProviderConfig describes how a provider routes aliases. Migration 0022 backfilled users.email_normalized
+// with the defaults, after changing them run `server -renormalize-emails` (see user.Service.RenormalizeEmails).
+type EmailProviderConfig struct {
+	Domain       string   `yaml:"domain"`
+	Aliases      []string `yaml:"aliases"`       // Other domains delivering to the same mailboxes, e.g. googlemail.com
+	IgnoreDots   bool     `yaml:"ignore_dots"`   // "a.b" and "ab" are the same mailbox
+	TagSeparator string   `yaml:"tag_separator"` // "ab+tag" goes to "ab", empty when there's no subaddressing
+}
+
+// RateLimitConfig allows Limit requests per Window, see utils.RateLimit. A zero Limit disables it.
+type RateLimitConfig struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// OutboxConfig drives the relay that moves outbox events to a Redis stream
+type OutboxConfig struct {
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
+	Stream       string        `yaml:"stream"`
+	StreamMaxLen int64         `yaml:"stream_max_len"` // Approximate trim, consumers must keep up within it
+
+	// A failed event is retried after RetryBackoff, doubling per attempt up to MaxRetryBackoff,
+	// and dead-lettered after MaxAttempts (0 retries forever)
+	MaxAttempts     int           `yaml:"max_attempts"`
+	RetryBackoff    time.Duration `yaml:"retry_backoff"`
+	MaxRetryBackoff time.Duration `yaml:"max_retry_backoff"`
+
+	// Dispatched events older than Retention are deleted every PruneInterval, 0 keeps them forever
+	Retention     time.Duration `yaml:"retention"`
+	PruneInterval time.Duration `yaml:"prune_interval"`
+}
+
+type AuditConfig struct {
+	Retention     time.Duration `yaml:"retention"` // Older audit/mod log entries are pruned, 0 keeps them forever
+	PruneInterval time.Duration `yaml:"prune_interval"`
+}
+
+// RetentionConfig limits how long data about deleted accounts is kept. Soft-deleted users are scrubbed
+// of email, password, Google ID and avatar after ScrubAfter and removed after HardDeleteAfter (0 keeps the rows).
+type RetentionConfig struct {
+	ScrubAfter      time.Duration `yaml:"scrub_after"`
+	HardDeleteAfter time.Duration `yaml:"hard_delete_after"`
+	Interval        time.Duration `yaml:"interval"`
+	BatchSize       int           `yaml:"batch_size"`
+}
+
+type DebugConfig struct {
+	PprofEnabled bool `yaml:"pprof_enabled"` // Mounts /debug/pprof (admin only)
+}
+
+// RedactedValue replaces secrets in Config.Redacted, unset secrets stay empty so they still show as missing
+const RedactedValue = "****"
+
+// Redacted returns a copy safe to log: passwords, keys and signing secrets are replaced with RedactedValue.
+// New secret fields must be added here.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redact := func(secret *string) {
+		if *secret != "" {
+			*secret = RedactedValue
+		}
+	}
+
+	redact(&redacted.JWT.Secret)
+	redact(&redacted.Database.DBPassword)
+	redact(&redacted.Database.Replica.DBPassword)
+	redact(&redacted.Redis.Password)
+	redact(&redacted.Google.ClientSecret)
+	redact(&redacted.Email.SMTP.Password)
+	redact(&redacted.Email.API.Key)
+	return &redacted
+}
+
+// Summary renders the redacted effective config as YAML for the startup log
+func (c *Config) Summary() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("<failed to render config: %v>", err)
+	}
+	return string(out)
+}
+
 func Load(path string) *Config {
 	cfg := new(Config)
 
-	corsCfg, dbCfg, redisCfg, jwtCfg, projectCfg, googleCfg := loadEnv()
+	corsCfg, dbCfg, redisCfg, jwtCfg, projectCfg, googleCfg, emailCfg := loadEnv()
 
 	yamlFile, err := os.ReadFile(path)
 	if err != nil {
@@ -56,6 +220,7 @@ func Load(path string) *Config {
 	cfg.JWT = jwtCfg
 	cfg.Project = projectCfg
 	cfg.Google = googleCfg
+	cfg.Email = emailCfg
 
 	return cfg
 }
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// secretField matches fields holding credentials, CookieKey fields only name a cookie
+var secretField = regexp.MustCompile(`Password$|Secret$|^Key$`)
+
+// fillStrings sets every string field reachable through nested structs to its own dotted path
+func fillStrings(v reflect.Value, path string) {
+	for i := range v.NumField() {
+		field, name := v.Field(i), path+v.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(name)
+		case reflect.Struct:
+			fillStrings(field, name+".")
+		}
+	}
+}
+
+// stringFields lists the string fields reachable through nested structs by dotted path
+func stringFields(v reflect.Value, path string, fields map[string]string) {
+	for i := range v.NumField() {
+		field, name := v.Field(i), path+v.Type().Field(i).Name
+		switch field.Kind() {
+		case reflect.String:
+			fields[name] = field.String()
+		case reflect.Struct:
+			stringFields(field, name+".", fields)
+		}
+	}
+}
+
+func TestRedactedHidesSecrets(t *testing.T) {
+	cfg := new(Config)
+	fillStrings(reflect.ValueOf(cfg).Elem(), "")
+
+	summary := cfg.Summary()
+	redacted := make(map[string]string)
+	stringFields(reflect.ValueOf(cfg.Redacted()).Elem(), "", redacted)
+	secrets := 0
+	for name, value := range redacted {
+		leaf := name[strings.LastIndex(name, ".")+1:]
+		if !secretField.MatchString(leaf) {
+			if value != name {
+				t.Errorf("%s = %q, only secrets may change", name, value)
+			}
+			continue
+		}
+		secrets++
+		if value != RedactedValue {
+			t.Errorf("%s = %q, want %s; add it to Config.Redacted", name, value, RedactedValue)
+		}
+		// Every value is its own path, so finding a secret's path in the summary means it was logged
+		if strings.Contains(summary, name) {
+			t.Errorf("summary contains the value of %s:\n%s", name, summary)
+		}
+	}
+	if secrets < 7 {
+		t.Errorf("found %d secret fields, the pattern no longer matches the config", secrets)
+	}
+
+	for _, visible := range []string{"Database.DBHost", "Redis.Host", "JWT.AccessTokenCookieKey", RedactedValue} {
+		if !strings.Contains(summary, visible) {
+			t.Errorf("summary lacks %q:\n%s", visible, summary)
+		}
+	}
+	if cfg.JWT.Secret != "JWT.Secret" || cfg.Database.DBPassword != "Database.DBPassword" {
+		t.Error("Redacted modified the original config")
+	}
+}
+
+// An unset secret stays empty, so a missing one is still visible in the log
+func TestRedactedKeepsUnsetSecretsEmpty(t *testing.T) {
+	cfg := &Config{JWT: JWTConfig{Secret: "a-very-secret-signing-key"}}
+
+	redacted := cfg.Redacted()
+	if redacted.JWT.Secret != RedactedValue || redacted.Redis.Password != "" || redacted.Email.API.Key != "" {
+		t.Errorf(
+			"JWT secret %q, Redis password %q, email API key %q; want only the set secret redacted",
+			redacted.JWT.Secret, redacted.Redis.Password, redacted.Email.API.Key,
+		)
+	}
+	if strings.Contains(cfg.Summary(), "a-very-secret-signing-key") {
+		t.Errorf("summary contains the JWT secret:\n%s", cfg.Summary())
+	}
+}
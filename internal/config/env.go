@@ -2,8 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"log"
+	"net/mail"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +20,30 @@ type DatabaseConfig struct {
 	DBUser     string
 	DBName     string
 	DBPassword string
+
+	QueryTimeout time.Duration
+
+	// GORM logger: silent, error, warn or info. Queries slower than SlowQueryThreshold are logged at warn
+	LogLevel           string
+	SlowQueryThreshold time.Duration
+
+	Replica ReplicaConfig
+}
+
+// ReplicaConfig points read-only queries at a streaming replica, it's disabled while DBHost is empty
+type ReplicaConfig struct {
+	DBHost     string
+	DBPort     int
+	DBUser     string
+	DBName     string
+	DBPassword string
+
+	// How long after a write the author's reads keep going to the primary, should exceed the usual replica lag
+	StickyWindow time.Duration
+}
+
+func (c ReplicaConfig) Enabled() bool {
+	return c.DBHost != ""
 }
 
 type RedisConfig struct {
@@ -35,34 +62,109 @@ type ProjectConfig struct {
 type JWTConfig struct {
 	Secret                string
 	AccessLifetime        time.Duration
-	RefreshLifetime       time.Duration
+	RefreshLifetime       time.Duration // Session-scoped refresh lifetime, used when "remember me" is off
+	RememberMeLifetime    time.Duration // Refresh lifetime for "remember me" logins, cookies become persistent
 	AccessTokenCookieKey  string
 	RefreshTokenCookieKey string
 }
 
-type GoogleConfig struct {
-	SMTPHost     string
-	SMTPPort     int
-	SMTPUsername string
-	SMTPPassword string
-	SMTPUseTLS   bool
+const (
+	MinJWTSecretLength   = 32
+	placeholderJWTSecret = "supadupasecret"
+)
+
+// CheckSecret enforces the secret policy: long enough to resist brute force and not the env fallback
+func (c *JWTConfig) CheckSecret() error {
+	if c.Secret == placeholderJWTSecret {
+		return errors.New("JWT_SECRET_KEY is the placeholder default, set a random secret")
+	}
+	if len(c.Secret) < MinJWTSecretLength {
+		return fmt.Errorf("JWT_SECRET_KEY must be at least %d characters, got %d", MinJWTSecretLength, len(c.Secret))
+	}
+	return nil
+}
 
+type GoogleConfig struct {
 	ClientID          string
 	ClientSecret      string
 	ClientRedirectURL string
 }
 
+const (
+	EmailProviderSMTP = "smtp"
+	EmailProviderHTTP = "http"
+)
+
+// Placeholder email credentials: the env fallbacks first, then the .env.example values
+var (
+	placeholderSMTPUsernames = []string{"email@gmail.com", "email@email.com"}
+	placeholderSMTPPasswords = []string{"somepassword", "password"}
+)
+
+const placeholderEmailAPIKey = "key"
+
+// EmailConfig picks the transport and holds the sender identity shown to recipients, whatever the transport
+type EmailConfig struct {
+	Provider string // EmailProviderSMTP or EmailProviderHTTP
+
+	FromAddress string // Bare address, falls back to the SMTP username (only works for mailbox logins like Gmail)
+	FromName    string // Display name, RFC 2047 encoded when non-ASCII
+	ReplyTo     string // Optional mailbox, normalized at startup
+
+	SMTP SMTPConfig
+	API  EmailAPIConfig
+
+	StartupCheck bool // Log a warning at startup when the SMTP server can't be reached or refuses the login
+}
+
+// Configured reports whether the selected transport has real credentials, the env fallbacks and
+// .env.example values are placeholders
+func (c *EmailConfig) Configured() bool {
+	switch c.Provider {
+	case EmailProviderSMTP:
+		return c.SMTP.Host != "" &&
+			c.SMTP.Username != "" && !slices.Contains(placeholderSMTPUsernames, c.SMTP.Username) &&
+			c.SMTP.Password != "" && !slices.Contains(placeholderSMTPPasswords, c.SMTP.Password)
+	case EmailProviderHTTP:
+		return c.API.Endpoint != "" && c.API.Key != "" && c.API.Key != placeholderEmailAPIKey
+	default:
+		return false
+	}
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	UseTLS   bool
+}
+
+// EmailAPIConfig configures a provider's JSON send endpoint (see email.HTTPSender)
+type EmailAPIConfig struct {
+	Endpoint     string
+	Key          string
+	AuthHeader   string // e.g. "Authorization" or "X-Api-Key"
+	AuthTemplate string // Header value with {key} replaced by Key, e.g. "Bearer {key}"
+}
+
 type CorsConfig struct {
 	AllowedOrigins []string
 }
 
-func loadEnv() (CorsConfig, DatabaseConfig, RedisConfig, JWTConfig, ProjectConfig, GoogleConfig) {
+func loadEnv() (CorsConfig, DatabaseConfig, RedisConfig, JWTConfig, ProjectConfig, GoogleConfig, EmailConfig) {
 	if _, ok := os.LookupEnv("IS_DOCKER"); !ok {
 		if err := godotenv.Load(); err != nil {
 			log.Fatalln("⚠️ No .env file found, falling back to OS envs. Details:", err.Error())
 		}
 	}
 
+	isProduction := getEnv("IS_PRODUCTION", false, parseBool)
+	defaultDBLogLevel := "info"
+	if isProduction {
+		defaultDBLogLevel = "warn" // Every statement at info is too noisy and slow for production
+	}
+
 	corsCfg := CorsConfig{
 		AllowedOrigins: getEnv("CORS_ALLOWED_ORIGINS", []string{"*"}, parseStringSlice),
 	}
@@ -72,6 +174,26 @@ func loadEnv() (CorsConfig, DatabaseConfig, RedisConfig, JWTConfig, ProjectConfi
 		DBUser:     getEnv("POSTGRES_USER", "postgres", parseString),
 		DBName:     getEnv("POSTGRES_DB", "agora_db", parseString),
 		DBPassword: getEnv("POSTGRES_PASSWORD", "password", parseString),
+		QueryTimeout: getEnv(
+			"POSTGRES_QUERY_TIMEOUT",
+			3*time.Second,
+			parseDuration,
+		),
+		LogLevel: getEnv("POSTGRES_LOG_LEVEL", defaultDBLogLevel, parseString),
+		SlowQueryThreshold: getEnv(
+			"POSTGRES_SLOW_QUERY_THRESHOLD",
+			200*time.Millisecond,
+			parseDuration,
+		),
+	}
+	// Credentials default to the primary's, replicas usually share them
+	dbCfg.Replica = ReplicaConfig{
+		DBHost:       getEnv("POSTGRES_REPLICA_HOST", "", parseString),
+		DBPort:       getEnv("POSTGRES_REPLICA_PORT", dbCfg.DBPort, parseInt),
+		DBUser:       getEnv("POSTGRES_REPLICA_USER", dbCfg.DBUser, parseString),
+		DBName:       getEnv("POSTGRES_REPLICA_DB", dbCfg.DBName, parseString),
+		DBPassword:   getEnv("POSTGRES_REPLICA_PASSWORD", dbCfg.DBPassword, parseString),
+		StickyWindow: getEnv("POSTGRES_REPLICA_STICKY_WINDOW", 5*time.Second, parseDuration),
 	}
 	redisCfg := RedisConfig{
 		Host:     getEnv("REDIS_HOST", "localhost", parseString),
@@ -80,7 +202,7 @@ func loadEnv() (CorsConfig, DatabaseConfig, RedisConfig, JWTConfig, ProjectConfi
 		DB:       getEnv("REDIS_DB_NUM", 0, parseInt),
 	}
 	jwtCfg := JWTConfig{
-		Secret: getEnv("JWT_SECRET_KEY", "supadupasecret", parseString),
+		Secret: getEnv("JWT_SECRET_KEY", placeholderJWTSecret, parseString),
 		AccessLifetime: getEnv(
 			"JWT_ACCESS_TOKEN_LIFETIME_SECONDS",
 			15*time.Minute,
@@ -91,26 +213,47 @@ func loadEnv() (CorsConfig, DatabaseConfig, RedisConfig, JWTConfig, ProjectConfi
 			24*time.Hour,
 			parseDuration,
 		),
+		RememberMeLifetime: getEnv(
+			"JWT_REMEMBER_ME_TOKEN_LIFETIME_SECONDS",
+			30*24*time.Hour,
+			parseDuration,
+		),
 		AccessTokenCookieKey:  getEnv("JWT_ACCESS_TOKEN_COOKIE_KEY", "access", parseString),
 		RefreshTokenCookieKey: getEnv("JWT_REFRESH_TOKEN_COOKIE_KEY", "refresh", parseString),
 	}
 	projectCfg := ProjectConfig{
-		IsProduction: getEnv("IS_PRODUCTION", false, parseBool),
+		IsProduction: isProduction,
 		AppPort:      getEnv("APP_PORT", 8080, parseInt),
 		FrontendURL:  getEnv("FRONTEND_URL", "http://localhost:3000", parseString),
 	}
 	googleCfg := GoogleConfig{
-		SMTPHost:          getEnv("GOOGLE_SMTP_HOST", "smtp.gmail.com", parseString),
-		SMTPPort:          getEnv("GOOGLE_SMTP_PORT", 587, parseInt),
-		SMTPUsername:      getEnv("GOOGLE_SMTP_USERNAME", "email@gmail.com", parseString),
-		SMTPPassword:      getEnv("GOOGLE_SMTP_PASSWORD", "somepassword", parseString),
-		SMTPUseTLS:        getEnv("GOOGLE_SMTP_USE_TLS", true, parseBool),
 		ClientID:          getEnv("GOOGLE_CLIENT_ID", "google_client_id", parseString),
 		ClientSecret:      getEnv("GOOGLE_CLIENT_SECRET", "supadupasecret", parseString),
 		ClientRedirectURL: getEnv("GOOGLE_REDIRECT_URL", "someurl.com", parseString),
 	}
+	smtpCfg := SMTPConfig{
+		Host:     getEnv("GOOGLE_SMTP_HOST", "smtp.gmail.com", parseString),
+		Port:     getEnv("GOOGLE_SMTP_PORT", 587, parseInt),
+		Username: getEnv("GOOGLE_SMTP_USERNAME", placeholderSMTPUsernames[0], parseString),
+		Password: getEnv("GOOGLE_SMTP_PASSWORD", placeholderSMTPPasswords[0], parseString),
+		UseTLS:   getEnv("GOOGLE_SMTP_USE_TLS", true, parseBool),
+	}
+	emailCfg := EmailConfig{
+		Provider:    getEnv("EMAIL_PROVIDER", EmailProviderSMTP, parseEmailProvider),
+		FromAddress: getEnv("EMAIL_FROM_ADDRESS", smtpCfg.Username, parseAddress),
+		FromName:    getEnv("EMAIL_FROM_NAME", "Agora", parseHeaderText),
+		ReplyTo:     getEnv("EMAIL_REPLY_TO", "", parseMailbox),
+		SMTP:        smtpCfg,
+		API: EmailAPIConfig{
+			Endpoint:     getEnv("EMAIL_API_ENDPOINT", "", parseString),
+			Key:          getEnv("EMAIL_API_KEY", "", parseString),
+			AuthHeader:   getEnv("EMAIL_API_AUTH_HEADER", "Authorization", parseString),
+			AuthTemplate: getEnv("EMAIL_API_AUTH_TEMPLATE", "Bearer {key}", parseString),
+		},
+		StartupCheck: getEnv("EMAIL_STARTUP_CHECK", false, parseBool),
+	}
 
-	return corsCfg, dbCfg, redisCfg, jwtCfg, projectCfg, googleCfg
+	return corsCfg, dbCfg, redisCfg, jwtCfg, projectCfg, googleCfg, emailCfg
 }
 
 type parseFunc[T any] func(string) (T, error)
@@ -147,6 +290,44 @@ func parseDuration(val string) (time.Duration, error) {
 	return time.ParseDuration(val)
 }
 
+// parseMailbox accepts an RFC 5322 mailbox ("support@x.com" or "Support <support@x.com>")
+// and returns it in canonical form
+func parseMailbox(val string) (string, error) {
+	addr, err := mail.ParseAddress(val)
+	if err != nil {
+		return "", err
+	}
+	return addr.String(), nil
+}
+
+// parseAddress accepts a bare address only ("no-reply@x.com"), the display name is configured separately
+func parseAddress(val string) (string, error) {
+	addr, err := mail.ParseAddress(val)
+	if err != nil {
+		return "", err
+	}
+	if addr.Name != "" || addr.Address != val {
+		return "", errors.New("expected a bare address without a display name")
+	}
+	return addr.Address, nil
+}
+
+func parseEmailProvider(val string) (string, error) {
+	switch val = strings.ToLower(val); val {
+	case EmailProviderSMTP, EmailProviderHTTP:
+		return val, nil
+	default:
+		return "", errors.New("expected smtp or http")
+	}
+}
+
+func parseHeaderText(val string) (string, error) {
+	if strings.ContainsAny(val, "\r\n") {
+		return "", errors.New("value contains a line break")
+	}
+	return val, nil
+}
+
 func parseStringSlice(val string) ([]string, error) {
 	if val == "" {
 		return nil, errors.New("value is empty")
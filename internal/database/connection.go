@@ -2,21 +2,25 @@ package database
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"net/url"
+	"os"
+	"strings"
 
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-func buildDBDSN(cfg *config.DatabaseConfig) string {
+func buildDBDSN(host string, port int, user, password, name string) string {
 	u := &url.URL{
 		Scheme: "postgres",
-		User:   url.UserPassword(cfg.DBUser, cfg.DBPassword),
-		Host:   fmt.Sprintf("%s:%d", cfg.DBHost, cfg.DBPort),
-		Path:   cfg.DBName,
+		User:   url.UserPassword(user, password),
+		Host:   fmt.Sprintf("%s:%d", host, port),
+		Path:   name,
 	}
 	q := u.Query()
 	q.Add("sslmode", "disable")
@@ -24,12 +28,85 @@ func buildDBDSN(cfg *config.DatabaseConfig) string {
 	return u.String()
 }
 
-func Connect(cfg *config.DatabaseConfig) *gorm.DB {
-	dsn := buildDBDSN(cfg)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Info)})
+var logLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// newLogger builds the GORM logger writing to out from config; an unknown level falls back to warn
+func newLogger(cfg *config.DatabaseConfig, out io.Writer) logger.Interface {
+	level, ok := logLevels[strings.ToLower(strings.TrimSpace(cfg.LogLevel))]
+	if !ok {
+		log.Printf("⚠️ Unknown database log level %q, using warn", cfg.LogLevel)
+		level = logger.Warn
+	}
+
+	return logger.New(
+		log.New(out, "\r\n", log.LstdFlags),
+		logger.Config{
+			SlowThreshold: cfg.SlowQueryThreshold,
+			LogLevel:      level,
+			Colorful:      true,
+		},
+	)
+}
+
+// Connect is Open for the server, which can't run without its database
+func Connect(cfg *config.DatabaseConfig, clock utils.Clock) *DB {
+	db, err := Open(cfg, clock)
 	if err != nil {
-		log.Fatalln("failed to connect to database:", err)
+		log.Fatalln(err)
 		return nil
 	}
+	if cfg.Replica.Enabled() {
+		log.Println("✅ Database replica connected, read-only queries are routed to it")
+	}
 	return db
 }
+
+// GormConfig is shared by every pool; clock drives the CreatedAt/UpdatedAt values gorm fills in.
+// Errors are translated, so unique violations surface as gorm.ErrDuplicatedKey.
+func GormConfig(cfg *config.DatabaseConfig, clock utils.Clock) *gorm.Config {
+	return &gorm.Config{
+		Logger:         newLogger(cfg, os.Stdout),
+		NowFunc:        clock.Now,
+		TranslateError: true,
+	}
+}
+
+// Open connects the primary pool and, when configured, the replica one
+func Open(cfg *config.DatabaseConfig, clock utils.Clock) (*DB, error) {
+	gormCfg := GormConfig(cfg, clock)
+
+	primary, err := gorm.Open(
+		postgres.Open(buildDBDSN(cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)),
+		gormCfg,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if !cfg.Replica.Enabled() {
+		return NewDB(primary, nil), nil
+	}
+
+	replicaCfg := cfg.Replica
+	replica, err := gorm.Open(
+		postgres.Open(
+			buildDBDSN(
+				replicaCfg.DBHost,
+				replicaCfg.DBPort,
+				replicaCfg.DBUser,
+				replicaCfg.DBPassword,
+				replicaCfg.DBName,
+			),
+		),
+		gormCfg,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database replica: %w", err)
+	}
+	return NewDB(primary, replica), nil
+}
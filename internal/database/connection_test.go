@@ -0,0 +1,62 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+func TestLoggerFollowsConfig(t *testing.T) {
+	const threshold = 100 * time.Millisecond
+	fast := time.Now().Add(-time.Millisecond)
+	slow := time.Now().Add(-2 * threshold)
+	sql := func() (string, int64) { return "SELECT 1", 1 }
+
+	tests := []struct {
+		level                         string
+		logsFast, logsSlow, logsError bool
+	}{
+		{"silent", false, false, false},
+		{"error", false, false, true},
+		{"warn", false, true, true},
+		{"info", true, true, true},
+		{" INFO ", true, true, true},
+		{"verbose", false, true, true}, // Unknown levels fall back to warn
+	}
+	for _, tt := range tests {
+		traces := []struct {
+			name  string
+			begin time.Time
+			err   error
+			want  bool
+		}{
+			{"fast query", fast, nil, tt.logsFast},
+			{"slow query", slow, nil, tt.logsSlow},
+			{"failed query", fast, errors.New("boom"), tt.logsError},
+		}
+		for _, trace := range traces {
+			var out strings.Builder
+			newLogger(&config.DatabaseConfig{LogLevel: tt.level, SlowQueryThreshold: threshold}, &out).
+				Trace(context.Background(), trace.begin, sql, trace.err)
+
+			if logged := strings.Contains(out.String(), "SELECT 1"); logged != trace.want {
+				t.Errorf("level %q, %s: logged %t, want %t (%q)", tt.level, trace.name, logged, trace.want, out.String())
+			}
+		}
+	}
+}
+
+func TestLoggerMarksSlowQueries(t *testing.T) {
+	var out strings.Builder
+	newLogger(&config.DatabaseConfig{LogLevel: "warn", SlowQueryThreshold: 50 * time.Millisecond}, &out).Trace(
+		context.Background(), time.Now().Add(-time.Second), func() (string, int64) { return "SELECT pg_sleep(1)", 1 }, nil,
+	)
+
+	if !strings.Contains(out.String(), "SLOW SQL >= 50ms") {
+		t.Errorf("slow query log %q does not name the threshold", out.String())
+	}
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// migrationFiles are the goose migrations compiled in, so a binary can tell whether its schema is applied
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationFiles are the goose migrations by file name, for setting up test databases
+func MigrationFiles() fs.FS {
+	files, _ := fs.Sub(migrationFiles, "migrations")
+	return files
+}
+
+// PendingMigrations lists the migration files not applied to the primary yet, according to goose's version table
+func PendingMigrations(ctx context.Context, db *DB) ([]string, error) {
+	// goose appends a row per up/down, the latest one per version tells whether it's applied
+	var applied []int64
+	err := db.WriteDB().WithContext(ctx).
+		Raw(
+			"SELECT version_id FROM (" +
+				"SELECT DISTINCT ON (version_id) version_id, is_applied FROM goose_db_version " +
+				"ORDER BY version_id, id DESC" +
+				") latest WHERE is_applied",
+		).
+		Scan(&applied).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read goose_db_version: %w", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "migrations/")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no numeric version", name)
+		}
+		if !slices.Contains(applied, version) {
+			pending = append(pending, name)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Add role to users table (regular users vs site admins)
+
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,41 @@
+-- +goose Up
+-- Create topics and the subreddit <-> topic join table
+
+CREATE TABLE topics (
+                        id UUID PRIMARY KEY,
+                        slug VARCHAR(50) NOT NULL UNIQUE,
+                        name VARCHAR(100) NOT NULL,
+                        is_sensitive BOOLEAN NOT NULL DEFAULT false,
+
+                        created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                        updated_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL
+);
+
+-- Primary key doubles as the (topic_id, subreddit_id) reverse index used by topic listings
+CREATE TABLE subreddit_topics (
+                                  topic_id UUID NOT NULL,
+                                  subreddit_id UUID NOT NULL,
+
+                                  PRIMARY KEY (topic_id, subreddit_id),
+
+                                  CONSTRAINT fk_subreddit_topics_topic
+                                      FOREIGN KEY (topic_id)
+                                          REFERENCES topics(id)
+                                          ON DELETE CASCADE,
+
+                                  CONSTRAINT fk_subreddit_topics_subreddit
+                                      FOREIGN KEY (subreddit_id)
+                                          REFERENCES subreddits(id)
+                                          ON DELETE CASCADE
+);
+
+-- Index for loading a subreddit's topics
+CREATE INDEX idx_subreddit_topics_subreddit_id ON subreddit_topics(subreddit_id);
+
+-- Index for ordering topic listings by popularity
+CREATE INDEX idx_subreddits_member_count ON subreddits(member_count DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS subreddit_topics;
+DROP TABLE IF EXISTS topics;
+DROP INDEX IF EXISTS idx_subreddits_member_count;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Create audit_logs table for privileged (admin) actions
+
+CREATE TABLE audit_logs (
+                            id UUID PRIMARY KEY,
+                            actor_id UUID NOT NULL,
+                            action VARCHAR(50) NOT NULL,
+                            target_type VARCHAR(50) NOT NULL,
+                            target_id UUID NOT NULL,
+                            subreddit_id UUID,
+                            reason VARCHAR(500),
+
+                            created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+
+                            CONSTRAINT fk_audit_logs_actor
+                                FOREIGN KEY (actor_id)
+                                    REFERENCES users(id)
+                                    ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX idx_audit_logs_target ON audit_logs(target_type, target_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_logs;
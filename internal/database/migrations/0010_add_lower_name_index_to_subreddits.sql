@@ -0,0 +1,7 @@
+-- +goose Up
+-- Case-insensitive name lookups (GET /subreddits/by-name/:name, ExistsByName)
+
+CREATE INDEX idx_subreddits_name_lower ON subreddits(LOWER(name));
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subreddits_name_lower;
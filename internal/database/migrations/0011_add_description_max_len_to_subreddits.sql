@@ -0,0 +1,10 @@
+-- +goose Up
+-- Per-subreddit description length override, bounded by the global hard cap
+
+ALTER TABLE subreddits ALTER COLUMN description TYPE VARCHAR(2000);
+ALTER TABLE subreddits ADD COLUMN description_max_len SMALLINT
+    CONSTRAINT chk_subreddits_description_max_len CHECK (description_max_len BETWEEN 1 AND 2000);
+
+-- +goose Down
+ALTER TABLE subreddits DROP COLUMN IF EXISTS description_max_len;
+ALTER TABLE subreddits ALTER COLUMN description TYPE VARCHAR(500) USING LEFT(description, 500);
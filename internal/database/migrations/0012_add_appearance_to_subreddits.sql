@@ -0,0 +1,11 @@
+-- +goose Up
+-- Community branding: banner image and #RRGGBB colors
+
+ALTER TABLE subreddits ADD COLUMN banner_url VARCHAR(500);
+ALTER TABLE subreddits ADD COLUMN primary_color CHAR(7);
+ALTER TABLE subreddits ADD COLUMN banner_color CHAR(7);
+
+-- +goose Down
+ALTER TABLE subreddits DROP COLUMN IF EXISTS banner_color;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS primary_color;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS banner_url;
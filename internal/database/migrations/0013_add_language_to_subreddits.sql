@@ -0,0 +1,9 @@
+-- +goose Up
+-- Optional ISO 639-1 content language, filterable in subreddit lists
+
+ALTER TABLE subreddits ADD COLUMN language CHAR(2);
+CREATE INDEX idx_subreddits_language ON subreddits(language) WHERE language IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subreddits_language;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS language;
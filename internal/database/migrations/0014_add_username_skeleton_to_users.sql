@@ -0,0 +1,15 @@
+-- +goose Up
+-- Confusable skeleton of the username (utils.Skeleton), registrations are checked against it.
+-- Usernames are ASCII-only, so the backfill only needs the ASCII part of the mapping.
+
+ALTER TABLE users ADD COLUMN username_skeleton VARCHAR(255);
+
+UPDATE users
+SET username_skeleton = REPLACE(REPLACE(LOWER(TRANSLATE(username, 'I1|0', 'lllo')), 'rn', 'm'), 'vv', 'w');
+
+ALTER TABLE users ALTER COLUMN username_skeleton SET NOT NULL;
+CREATE INDEX idx_users_username_skeleton ON users(username_skeleton);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_username_skeleton;
+ALTER TABLE users DROP COLUMN IF EXISTS username_skeleton;
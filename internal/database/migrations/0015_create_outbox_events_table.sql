@@ -0,0 +1,17 @@
+-- +goose Up
+-- Transactional outbox: rows are written together with the domain change and relayed to a Redis stream
+
+CREATE TABLE outbox_events (
+                               id UUID PRIMARY KEY,
+                               type VARCHAR(100) NOT NULL,
+                               payload JSONB NOT NULL,
+                               attempts INTEGER DEFAULT 0 NOT NULL,
+
+                               created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                               dispatched_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events(created_at) WHERE dispatched_at IS NULL;
+
+-- +goose Down
+DROP TABLE IF EXISTS outbox_events;
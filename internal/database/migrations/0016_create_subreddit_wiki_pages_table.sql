@@ -0,0 +1,28 @@
+-- +goose Up
+-- Create subreddit wiki pages (rules, FAQs), one row per (subreddit, slug)
+
+CREATE TABLE subreddit_wiki_pages (
+                                      id UUID PRIMARY KEY,
+                                      subreddit_id UUID NOT NULL,
+                                      slug VARCHAR(50) NOT NULL,
+                                      content TEXT NOT NULL,
+                                      updated_by_id UUID NOT NULL,
+
+                                      created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+                                      updated_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+
+                                      CONSTRAINT uq_subreddit_wiki_pages_slug UNIQUE (subreddit_id, slug),
+
+                                      CONSTRAINT fk_subreddit_wiki_pages_subreddit
+                                          FOREIGN KEY (subreddit_id)
+                                              REFERENCES subreddits(id)
+                                              ON DELETE CASCADE,
+
+                                      CONSTRAINT fk_subreddit_wiki_pages_updated_by
+                                          FOREIGN KEY (updated_by_id)
+                                              REFERENCES users(id)
+                                              ON DELETE RESTRICT
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS subreddit_wiki_pages;
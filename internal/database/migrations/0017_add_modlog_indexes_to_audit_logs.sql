@@ -0,0 +1,9 @@
+-- +goose Up
+-- Indexes for the per-subreddit mod log: newest first, optionally narrowed to one moderator
+
+CREATE INDEX idx_audit_logs_subreddit_created ON audit_logs(subreddit_id, created_at DESC);
+CREATE INDEX idx_audit_logs_subreddit_actor_created ON audit_logs(subreddit_id, actor_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_logs_subreddit_actor_created;
+DROP INDEX IF EXISTS idx_audit_logs_subreddit_created;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- Self-serve deletion grace period: set while deletion is pending, the purge job soft-deletes the row after it
+
+ALTER TABLE subreddits ADD COLUMN purge_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_subreddits_purge_at ON subreddits(purge_at) WHERE purge_at IS NOT NULL AND deleted_at IS NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subreddits_purge_at;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS purge_at;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Per-user feed and display preferences, users without a row get the defaults below
+
+CREATE TABLE user_settings (
+                               user_id UUID PRIMARY KEY,
+                               default_feed_sort VARCHAR(10) DEFAULT 'hot' NOT NULL,
+                               show_nsfw BOOLEAN DEFAULT false NOT NULL,
+                               email_on_reply BOOLEAN DEFAULT true NOT NULL,
+                               email_on_mention BOOLEAN DEFAULT true NOT NULL,
+                               content_language CHAR(2),
+                               theme VARCHAR(10) DEFAULT 'system' NOT NULL,
+
+                               updated_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+
+                               CONSTRAINT fk_user_settings_user
+                                   FOREIGN KEY (user_id)
+                                       REFERENCES users(id)
+                                       ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS user_settings;
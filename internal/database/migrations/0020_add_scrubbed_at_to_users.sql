@@ -0,0 +1,9 @@
+-- +goose Up
+-- Retention: personal data of soft-deleted accounts is wiped after a while, scrubbed_at marks the wiped ones
+
+ALTER TABLE users ADD COLUMN scrubbed_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_users_soft_deleted ON users(id) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_soft_deleted;
+ALTER TABLE users DROP COLUMN IF EXISTS scrubbed_at;
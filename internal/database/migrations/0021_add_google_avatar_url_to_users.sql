@@ -0,0 +1,8 @@
+-- +goose Up
+-- The Google picture is kept apart from avatar_url so signing in with Google doesn't overwrite a chosen avatar
+
+ALTER TABLE users ADD COLUMN google_avatar_url VARCHAR(500);
+UPDATE users SET google_avatar_url = avatar_url WHERE google_id IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS google_avatar_url;
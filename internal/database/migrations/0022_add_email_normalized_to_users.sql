@@ -0,0 +1,29 @@
+-- +goose Up
+-- Duplicate detection for aliases of one mailbox, see utils.EmailNormalizer. The backfill mirrors the
+-- providers in config.yml; accounts that already are aliases of an older one keep a unique placeholder.
+
+ALTER TABLE users ADD COLUMN email_normalized VARCHAR(255);
+
+UPDATE users SET email_normalized = CASE
+    WHEN split_part(lower(email), '@', 2) IN ('gmail.com', 'googlemail.com')
+        THEN replace(split_part(split_part(lower(email), '@', 1), '+', 1), '.', '') || '@gmail.com'
+    WHEN split_part(lower(email), '@', 2) IN (
+        'outlook.com', 'hotmail.com', 'live.com', 'icloud.com', 'fastmail.com', 'proton.me', 'protonmail.com'
+    )
+        THEN split_part(split_part(lower(email), '@', 1), '+', 1) || '@' || split_part(lower(email), '@', 2)
+    ELSE lower(email)
+END;
+
+UPDATE users SET email_normalized = left(email_normalized, 218) || '#' || id
+WHERE EXISTS (
+    SELECT 1 FROM users older
+    WHERE older.email_normalized = users.email_normalized
+      AND (older.created_at, older.id) < (users.created_at, users.id)
+);
+
+ALTER TABLE users ALTER COLUMN email_normalized SET NOT NULL;
+CREATE UNIQUE INDEX idx_users_email_normalized ON users(email_normalized);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_email_normalized;
+ALTER TABLE users DROP COLUMN IF EXISTS email_normalized;
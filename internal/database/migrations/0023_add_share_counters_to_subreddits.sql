@@ -0,0 +1,13 @@
+-- +goose Up
+-- Share links: share_code is assigned on first share and resolved by GET /s/:code, counters only ever grow
+
+ALTER TABLE subreddits ADD COLUMN share_code VARCHAR(11);
+ALTER TABLE subreddits ADD COLUMN share_count BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE subreddits ADD COLUMN click_count BIGINT NOT NULL DEFAULT 0;
+CREATE UNIQUE INDEX idx_subreddits_share_code ON subreddits(share_code) WHERE share_code IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_subreddits_share_code;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS click_count;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS share_count;
+ALTER TABLE subreddits DROP COLUMN IF EXISTS share_code;
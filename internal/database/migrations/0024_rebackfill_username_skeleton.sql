@@ -0,0 +1,10 @@
+-- +goose Up
+-- utils.Skeleton now lowercases before mapping confusables ("I" reads as "i", not "l").
+-- 0014 translated first, recompute every skeleton the new way. Usernames are ASCII-only.
+
+UPDATE users
+SET username_skeleton = REPLACE(REPLACE(TRANSLATE(LOWER(username), '1|0', 'llo'), 'rn', 'm'), 'vv', 'w');
+
+-- +goose Down
+UPDATE users
+SET username_skeleton = REPLACE(REPLACE(LOWER(TRANSLATE(username, 'I1|0', 'lllo')), 'rn', 'm'), 'vv', 'w');
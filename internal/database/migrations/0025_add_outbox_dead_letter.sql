@@ -0,0 +1,22 @@
+-- +goose Up
+-- Failed events back off individually and are parked after too many attempts instead of blocking the relay
+
+ALTER TABLE outbox_events
+    ADD COLUMN next_attempt_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN dead_lettered_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN last_error TEXT;
+
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events(created_at)
+    WHERE dispatched_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX idx_outbox_events_dispatched ON outbox_events(dispatched_at) WHERE dispatched_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_outbox_events_dispatched;
+DROP INDEX IF EXISTS idx_outbox_events_pending;
+CREATE INDEX idx_outbox_events_pending ON outbox_events(created_at) WHERE dispatched_at IS NULL;
+
+ALTER TABLE outbox_events
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS dead_lettered_at,
+    DROP COLUMN IF EXISTS next_attempt_at;
@@ -15,7 +15,20 @@ func ConnectRedisClient(cfg *config.RedisConfig) *redis.Client {
 	if redisClient != nil {
 		return redisClient
 	}
-	redisClient = redis.NewClient(&redis.Options{
+	client, err := OpenRedisClient(cfg)
+	if err != nil {
+		log.Fatalln(err)
+		return nil
+	}
+	redisClient = client
+
+	log.Println("✅ Redis connected successfully")
+	return redisClient
+}
+
+// OpenRedisClient returns a client that answered a ping, unlike ConnectRedisClient it's not shared
+func OpenRedisClient(cfg *config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password: cfg.Password,
 		DB:       cfg.DB,
@@ -24,13 +37,11 @@ func ConnectRedisClient(cfg *config.RedisConfig) *redis.Client {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalln("failed to connect to Redis:", err)
-		return nil
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
-
-	log.Println("✅ Redis connected successfully")
-	return redisClient
+	return client, nil
 }
 
 func GetRedisClient() *redis.Client {
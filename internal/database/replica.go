@@ -0,0 +1,40 @@
+package database
+
+import (
+	"context"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// DB pairs the primary with an optional read replica. Writes, transactions and reads that must see
+// the latest state use WriteDB, read-only queries that tolerate replica lag use ReadDB.
+type DB struct {
+	primary *gorm.DB
+	replica *gorm.DB // nil without a configured replica, ReadDB then falls back to primary
+}
+
+func NewDB(primary, replica *gorm.DB) *DB {
+	return &DB{
+		primary: primary,
+		replica: replica,
+	}
+}
+
+func (d *DB) WriteDB() *gorm.DB {
+	return d.primary
+}
+
+// ReadDB returns the replica unless ctx was pinned to the primary (see utils.WithPrimaryReads)
+func (d *DB) ReadDB(ctx context.Context) *gorm.DB {
+	if d.replica == nil || utils.PrimaryReads(ctx) {
+		return d.primary
+	}
+	return d.replica
+}
+
+// ReadConn is Conn for read-only queries: it still joins the transaction carried by ctx,
+// otherwise it reads from db.ReadDB
+func ReadConn(ctx context.Context, db *DB) *gorm.DB {
+	return Conn(ctx, db.ReadDB(ctx))
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txContextKey struct{}
+
+type afterCommitKey struct{}
+
+// TxManager lets services compose operations across several repositories atomically
+// without depending on gorm: the transaction travels in the context instead of in a repository copy
+type TxManager struct {
+	db *gorm.DB
+}
+
+// NewTxManager runs transactions on the primary, replicas are read-only
+func NewTxManager(db *DB) *TxManager {
+	return &TxManager{
+		db: db.WriteDB(),
+	}
+}
+
+// RunInTx executes fn inside a transaction stored in the passed context.
+// Nested calls join the outer transaction, so any error returned by fn rolls back the whole unit of work.
+func (m *TxManager) RunInTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	var hooks []func()
+	err := m.db.WithContext(ctx).Transaction(
+		func(tx *gorm.DB) error {
+			ctx := context.WithValue(ctx, txContextKey{}, tx)
+			return fn(context.WithValue(ctx, afterCommitKey{}, &hooks))
+		},
+	)
+	if err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+	return nil
+}
+
+// AfterCommit defers fn until the transaction in ctx has committed, it's dropped on rollback.
+// Outside a transaction fn runs right away. Meant for side effects other readers could observe
+// too early, such as cache invalidation.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(afterCommitKey{}).(*[]func()); ok {
+		*hooks = append(*hooks, fn)
+		return
+	}
+	fn()
+}
+
+// Conn returns the transaction bound to ctx when there is one, falling back to the repository's own handle
+func Conn(ctx context.Context, fallback *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txContextKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return fallback.WithContext(ctx)
+}
@@ -0,0 +1,143 @@
+package database_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+func TestRunInTxRollsBackAcrossRepositories(t *testing.T) {
+	db := testutil.Postgres(t, utils.RealClock{})
+	txManager := database.NewTxManager(db)
+	userRepo := user.NewRepository(db, 0)
+	subredditRepo := subreddit.NewRepository(db, 0)
+	ctx := context.Background()
+
+	created := &user.User{
+		ID:               utils.NewID(),
+		Username:         "rollback",
+		UsernameSkeleton: utils.Skeleton("rollback"),
+		Email:            "rollback@example.com",
+		EmailNormalized:  "rollback@example.com",
+		AuthProvider:     user.AuthProviderEmail,
+		Role:             user.RoleUser,
+	}
+	err := txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			if err := userRepo.Create(ctx, created); err != nil {
+				return err
+			}
+			// The creator doesn't exist, so the second repository fails on the foreign key
+			return subredditRepo.Create(
+				ctx, &subreddit.Subreddit{
+					ID:          utils.NewID(),
+					Name:        "rollback",
+					DisplayName: "Rollback",
+					CreatorID:   utils.NewID(),
+				},
+			)
+		},
+	)
+	if !errors.Is(err, gorm.ErrForeignKeyViolated) {
+		t.Fatalf("RunInTx error = %v, want a foreign key violation", err)
+	}
+
+	if _, err := userRepo.GetByID(ctx, created.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("user from the rolled back transaction: err = %v, want not found", err)
+	}
+}
+
+func TestRunInTxNestedCallsJoinOuter(t *testing.T) {
+	db := testutil.Postgres(t, utils.RealClock{})
+	txManager := database.NewTxManager(db)
+	userRepo := user.NewRepository(db, 0)
+	ctx := context.Background()
+
+	created := &user.User{
+		ID:               utils.NewID(),
+		Username:         "nested",
+		UsernameSkeleton: utils.Skeleton("nested"),
+		Email:            "nested@example.com",
+		EmailNormalized:  "nested@example.com",
+		AuthProvider:     user.AuthProviderEmail,
+		Role:             user.RoleUser,
+	}
+	errAbort := errors.New("abort")
+	err := txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			inner := txManager.RunInTx(
+				ctx, func(ctx context.Context) error {
+					return userRepo.Create(ctx, created)
+				},
+			)
+			if inner != nil {
+				return inner
+			}
+			return errAbort
+		},
+	)
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("RunInTx error = %v, want %v", err, errAbort)
+	}
+
+	if _, err := userRepo.GetByID(ctx, created.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("user committed by the nested call: err = %v, want not found", err)
+	}
+}
+
+func TestAfterCommitRunsOnlyOnceCommitted(t *testing.T) {
+	db := testutil.Postgres(t, utils.RealClock{})
+	txManager := database.NewTxManager(db)
+	ctx := context.Background()
+
+	var ran []string
+	err := txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			database.AfterCommit(ctx, func() { ran = append(ran, "outer") })
+			// Nested calls hand their hooks to the outer transaction
+			err := txManager.RunInTx(
+				ctx, func(ctx context.Context) error {
+					database.AfterCommit(ctx, func() { ran = append(ran, "nested") })
+					return nil
+				},
+			)
+			if len(ran) != 0 {
+				t.Errorf("hooks ran before the commit: %v", ran)
+			}
+			return err
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0] != "outer" || ran[1] != "nested" {
+		t.Errorf("hooks after commit = %v, want [outer nested]", ran)
+	}
+
+	ran = nil
+	errAbort := errors.New("abort")
+	err = txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			database.AfterCommit(ctx, func() { ran = append(ran, "rolled back") })
+			return errAbort
+		},
+	)
+	if !errors.Is(err, errAbort) || len(ran) != 0 {
+		t.Errorf("rolled back transaction: err = %v, hooks ran %v; want no hooks", err, ran)
+	}
+}
+
+func TestAfterCommitOutsideTransactionRunsImmediately(t *testing.T) {
+	ran := false
+	database.AfterCommit(context.Background(), func() { ran = true })
+	if !ran {
+		t.Error("AfterCommit without a transaction didn't run the hook")
+	}
+}
@@ -0,0 +1,95 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// checks is everything the doctor verifies, in order. A new dependency registers its check here.
+func checks(deps *dependencies) []Check {
+	return []Check{
+		NewCheck("jwt secret", deps.checkJWTSecret),
+		NewCheck("postgres", deps.checkDatabase),
+		NewCheck("migrations", deps.checkMigrations),
+		NewCheck("redis", deps.checkRedis),
+		NewCheck("email", deps.checkEmail),
+	}
+}
+
+// dependencies holds the clients opened by the checks, so later checks can build on earlier ones
+type dependencies struct {
+	cfg   *config.Config
+	db    *database.DB
+	redis *redis.Client
+}
+
+func (d *dependencies) checkJWTSecret(context.Context) error {
+	return d.cfg.JWT.CheckSecret()
+}
+
+// checkDatabase relies on gorm pinging the primary and replica when opening them
+func (d *dependencies) checkDatabase(context.Context) error {
+	dbCfg := d.cfg.Database
+	dbCfg.LogLevel = "silent" // The table reports failures, statement logs would only clutter it
+
+	db, err := database.Open(&dbCfg, utils.RealClock{})
+	if err != nil {
+		return fmt.Errorf("%w (check POSTGRES_HOST, POSTGRES_PORT and credentials)", err)
+	}
+	d.db = db
+	return nil
+}
+
+func (d *dependencies) checkMigrations(ctx context.Context) error {
+	if d.db == nil {
+		return skip("needs postgres")
+	}
+
+	pending, err := database.PendingMigrations(ctx, d.db)
+	if err != nil {
+		return fmt.Errorf("%w (has goose ever run against this database?)", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d pending, run goose up: %s", len(pending), strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+func (d *dependencies) checkRedis(context.Context) error {
+	client, err := database.OpenRedisClient(&d.cfg.Redis)
+	if err != nil {
+		return fmt.Errorf("%w (check REDIS_HOST, REDIS_PORT and REDIS_PASSWORD)", err)
+	}
+	d.redis = client
+	return nil
+}
+
+func (d *dependencies) checkEmail(ctx context.Context) error {
+	err := email.Check(ctx, &d.cfg.Email)
+	if errors.Is(err, email.ErrNotConfigured) {
+		return skip("%s provider has no credentials, email features are disabled", d.cfg.Email.Provider)
+	}
+	return err
+}
+
+func (d *dependencies) close() {
+	if d.db != nil {
+		for _, conn := range []*gorm.DB{d.db.WriteDB(), d.db.ReadDB(context.Background())} {
+			if sqlDB, err := conn.DB(); err == nil {
+				sqlDB.Close() // Closing the primary twice without a replica is harmless
+			}
+		}
+	}
+	if d.redis != nil {
+		d.redis.Close()
+	}
+}
@@ -0,0 +1,88 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+const checkTimeout = 10 * time.Second
+
+// Check is one probe of a dependency or a config policy, it returns what's wrong in a way an operator can act on
+type Check interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+type checkFunc struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// NewCheck wraps a function as a Check
+func NewCheck(name string, run func(ctx context.Context) error) Check {
+	return checkFunc{name: name, run: run}
+}
+
+func (c checkFunc) Name() string {
+	return c.name
+}
+
+func (c checkFunc) Run(ctx context.Context) error {
+	return c.run(ctx)
+}
+
+// skipError marks a check that doesn't apply to this deployment, it doesn't fail the run
+type skipError struct {
+	reason string
+}
+
+func (e skipError) Error() string {
+	return e.reason
+}
+
+func skip(format string, args ...any) error {
+	return skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// Diagnose runs every registered check against cfg with the production clients, prints a table to w
+// and returns the process exit code: 1 when any check failed
+func Diagnose(ctx context.Context, cfg *config.Config, w io.Writer) int {
+	deps := &dependencies{cfg: cfg}
+	defer deps.close()
+
+	failed := false
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CHECK\tSTATUS\tTIME\tDETAILS")
+	for _, check := range checks(deps) {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		started := time.Now()
+		err := check.Run(checkCtx)
+		elapsed := time.Since(started).Round(time.Millisecond)
+		cancel()
+
+		status, details := "✅ ok", ""
+		var skipped skipError
+		switch {
+		case errors.As(err, &skipped):
+			status, details = "⏭ skip", skipped.reason
+		case err != nil:
+			status, details = "❌ fail", err.Error()
+			failed = true
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", check.Name(), status, elapsed, details)
+	}
+	table.Flush()
+
+	if failed {
+		fmt.Fprintln(w, "\n❌ Some checks failed, fix them before deploying")
+		return 1
+	}
+	fmt.Fprintln(w, "\n✅ All checks passed")
+	return 0
+}
@@ -0,0 +1,54 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+const startupCheckTimeout = 10 * time.Second
+
+// Check verifies the configured transport is usable: SMTP servers must accept the login and a NOOP.
+// The HTTP transport is only checked for credentials, providers have no common no-op call.
+func Check(ctx context.Context, cfg *config.EmailConfig) error {
+	if !cfg.Configured() {
+		return ErrNotConfigured
+	}
+	if cfg.Provider != config.EmailProviderSMTP {
+		return nil
+	}
+
+	client, err := dialSMTP(ctx, &cfg.SMTP)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Noop(); err != nil {
+		return fmt.Errorf("SMTP NOOP failed: %w", err)
+	}
+	return client.Quit()
+}
+
+// RunStartupCheck warns about unusable email without holding up startup, the connectivity part only runs
+// when cfg.StartupCheck is set
+func RunStartupCheck(cfg *config.EmailConfig) {
+	if !cfg.Configured() {
+		log.Printf("⚠️ Email (%s) is not configured, email features are disabled", cfg.Provider)
+		return
+	}
+	if !cfg.StartupCheck {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+		defer cancel()
+		if err := Check(ctx, cfg); err != nil {
+			log.Printf("⚠️ Email (%s) check failed, sending will fail until this is fixed: %v", cfg.Provider, err)
+		}
+	}()
+}
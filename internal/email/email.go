@@ -0,0 +1,83 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+)
+
+// Message is one outgoing email. Text is required, HTML is an optional alternative part.
+// Tags are forwarded to providers that support them for analytics, SMTP drops them.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Tags    []string
+}
+
+// Sender delivers a message through one transport, callers depend on this instead of a concrete one
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// ErrNotConfigured is returned by Send while email is unconfigured, handlers answer it with 503
+var ErrNotConfigured = errors.New("email is not configured")
+
+// NewSender builds the transport selected by cfg.Provider. Without credentials (see config.EmailConfig.Configured)
+// it's a sender failing every message with ErrNotConfigured, so the rest of the app still starts.
+func NewSender(cfg *config.EmailConfig, clock utils.Clock) (Sender, error) {
+	if !cfg.Configured() {
+		return unconfiguredSender{}, nil
+	}
+
+	switch cfg.Provider {
+	case config.EmailProviderSMTP:
+		return NewSMTPSender(cfg, clock), nil
+	case config.EmailProviderHTTP:
+		return NewHTTPSender(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown email provider %q", cfg.Provider)
+	}
+}
+
+type unconfiguredSender struct{}
+
+func (unconfiguredSender) Send(context.Context, Message) error {
+	return ErrNotConfigured
+}
+
+// Validate checks what every Sender refuses before sending: an unparsable recipient and header values
+// carrying line breaks (header injection)
+func Validate(msg Message) error {
+	_, err := parseMessage(msg)
+	return err
+}
+
+func parseMessage(msg Message) (*mail.Address, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, ErrInvalidHeader
+	}
+	return to, nil
+}
+
+// prepare validates the parts every transport needs, see Validate
+func prepare(cfg *config.EmailConfig, msg Message) (from, to *mail.Address, err error) {
+	to, err = parseMessage(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.ContainsAny(cfg.ReplyTo, "\r\n") {
+		return nil, nil, ErrInvalidHeader
+	}
+	return &mail.Address{Name: cfg.FromName, Address: cfg.FromAddress}, to, nil
+}
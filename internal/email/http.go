@@ -0,0 +1,88 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+const httpSendTimeout = 10 * time.Second
+
+// HTTPSender posts messages as JSON to a transactional provider's send endpoint.
+// The auth header is built from cfg.API.AuthTemplate, so most providers fit without code changes.
+type HTTPSender struct {
+	cfg    *config.EmailConfig
+	client *http.Client
+}
+
+func NewHTTPSender(cfg *config.EmailConfig) *HTTPSender {
+	return &HTTPSender{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpSendTimeout},
+	}
+}
+
+type apiAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type apiMessage struct {
+	From    apiAddress   `json:"from"`
+	ReplyTo string       `json:"reply_to,omitempty"`
+	To      []apiAddress `json:"to"`
+	Subject string       `json:"subject"`
+	Text    string       `json:"text"`
+	HTML    string       `json:"html,omitempty"`
+	Tags    []string     `json:"tags,omitempty"`
+}
+
+func (s *HTTPSender) Send(ctx context.Context, msg Message) error {
+	from, to, err := prepare(s.cfg, msg)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(
+		apiMessage{
+			From:    apiAddress{Email: from.Address, Name: from.Name},
+			ReplyTo: s.cfg.ReplyTo,
+			To:      []apiAddress{{Email: to.Address, Name: to.Name}},
+			Subject: msg.Subject,
+			Text:    msg.Text,
+			HTML:    msg.HTML,
+			Tags:    msg.Tags,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to encode email: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.API.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.API.AuthHeader != "" {
+		req.Header.Set(s.cfg.API.AuthHeader, strings.ReplaceAll(s.cfg.API.AuthTemplate, "{key}", s.cfg.API.Key))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach email provider: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("email provider answered %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package email_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+)
+
+// delivery is what a transport handed over, reduced to the fields every transport carries
+type delivery struct {
+	to, subject, text string
+}
+
+// senderUnderTest wires a Sender to a fake backend: delivered lists what reached it, breakBackend makes
+// it refuse everything afterward
+type senderUnderTest struct {
+	sender       email.Sender
+	delivered    func() []delivery
+	breakBackend func()
+}
+
+// Every Sender, the test fake included, must pass these, so handler tests on the fake hold for production
+var senders = map[string]func(t *testing.T) senderUnderTest{
+	"smtp": newSMTPUnderTest,
+	"http": newHTTPUnderTest,
+	"recording": func(t *testing.T) senderUnderTest {
+		recorder := testutil.NewRecordingSender()
+		return senderUnderTest{
+			sender: recorder,
+			delivered: func() []delivery {
+				var out []delivery
+				for _, msg := range recorder.Messages() {
+					to, _ := mail.ParseAddress(msg.To)
+					out = append(out, delivery{to: to.Address, subject: msg.Subject, text: msg.Text})
+				}
+				return out
+			},
+			breakBackend: func() { recorder.FailWith(errors.New("backend down")) },
+		}
+	},
+}
+
+func TestSenderContract(t *testing.T) {
+	valid := email.Message{
+		To:      "Олена <olena@example.com>",
+		Subject: "Confirm your email ✓",
+		Text:    "Your code is 123456",
+		HTML:    "<p>Your code is <b>123456</b></p>",
+		Tags:    []string{"verification"},
+	}
+
+	for name, setup := range senders {
+		t.Run(
+			name+"/delivers", func(t *testing.T) {
+				s := setup(t)
+				if err := s.sender.Send(context.Background(), valid); err != nil {
+					t.Fatal(err)
+				}
+				got := s.delivered()
+				want := delivery{to: "olena@example.com", subject: valid.Subject, text: valid.Text}
+				if len(got) != 1 || got[0] != want {
+					t.Fatalf("delivered %+v, want [%+v]", got, want)
+				}
+			},
+		)
+
+		t.Run(
+			name+"/rejects invalid recipient", func(t *testing.T) {
+				s := setup(t)
+				msg := valid
+				msg.To = "not an address"
+				if err := s.sender.Send(context.Background(), msg); err == nil {
+					t.Fatal("invalid recipient was accepted")
+				}
+				if got := s.delivered(); len(got) != 0 {
+					t.Fatalf("delivered %+v", got)
+				}
+			},
+		)
+
+		t.Run(
+			name+"/rejects header injection", func(t *testing.T) {
+				s := setup(t)
+				msg := valid
+				msg.Subject = "hi\r\nBcc: victim@example.com"
+				if err := s.sender.Send(context.Background(), msg); !errors.Is(err, email.ErrInvalidHeader) {
+					t.Fatalf("err = %v, want ErrInvalidHeader", err)
+				}
+				if got := s.delivered(); len(got) != 0 {
+					t.Fatalf("delivered %+v", got)
+				}
+			},
+		)
+
+		t.Run(
+			name+"/honors cancelled context", func(t *testing.T) {
+				s := setup(t)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				if err := s.sender.Send(ctx, valid); err == nil {
+					t.Fatal("sent on a cancelled context")
+				}
+				if got := s.delivered(); len(got) != 0 {
+					t.Fatalf("delivered %+v", got)
+				}
+			},
+		)
+
+		t.Run(
+			name+"/reports backend failure", func(t *testing.T) {
+				s := setup(t)
+				s.breakBackend()
+				if err := s.sender.Send(context.Background(), valid); err == nil {
+					t.Fatal("backend failure wasn't reported")
+				}
+			},
+		)
+	}
+}
+
+func TestUnconfiguredSender(t *testing.T) {
+	sender, err := email.NewSender(&config.EmailConfig{Provider: config.EmailProviderSMTP}, utils.RealClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sender.Send(context.Background(), email.Message{To: "a@example.com"}); !errors.Is(err, email.ErrNotConfigured) {
+		t.Fatalf("err = %v, want ErrNotConfigured", err)
+	}
+}
+
+func newHTTPUnderTest(t *testing.T) senderUnderTest {
+	var mu sync.Mutex
+	var got []delivery
+	broken := false
+
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if broken || r.Header.Get("X-Api-Key") != "Key secret" {
+					http.Error(w, "refused", http.StatusInternalServerError)
+					return
+				}
+				var body struct {
+					To      []struct{ Email string } `json:"to"`
+					Subject string                   `json:"subject"`
+					Text    string                   `json:"text"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.To) != 1 {
+					http.Error(w, "bad payload", http.StatusBadRequest)
+					return
+				}
+				got = append(got, delivery{to: body.To[0].Email, subject: body.Subject, text: body.Text})
+			},
+		),
+	)
+	t.Cleanup(server.Close)
+
+	cfg := &config.EmailConfig{
+		Provider:    config.EmailProviderHTTP,
+		FromAddress: "noreply@agora.example",
+		API: config.EmailAPIConfig{
+			Endpoint: server.URL, Key: "secret", AuthHeader: "X-Api-Key", AuthTemplate: "Key {key}",
+		},
+	}
+	sender, err := email.NewSender(cfg, utils.RealClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return senderUnderTest{
+		sender: sender,
+		delivered: func() []delivery {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]delivery(nil), got...)
+		},
+		breakBackend: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			broken = true
+		},
+	}
+}
+
+func newSMTPUnderTest(t *testing.T) senderUnderTest {
+	server := startSMTPServer(t)
+	cfg := &config.EmailConfig{
+		Provider:    config.EmailProviderSMTP,
+		FromAddress: "noreply@agora.example",
+		SMTP: config.SMTPConfig{
+			Host: "127.0.0.1", Port: server.port, Username: "agora", Password: "smtp-secret",
+		},
+	}
+	sender, err := email.NewSender(cfg, utils.RealClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return senderUnderTest{sender: sender, delivered: server.delivered, breakBackend: server.refuseRecipients}
+}
+
+// smtpServer speaks just enough SMTP for net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT, DATA, NOOP and QUIT
+type smtpServer struct {
+	port int
+
+	mu      sync.Mutex
+	got     []delivery
+	refuse  bool
+	failure error
+}
+
+func startSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+
+	server := &smtpServer{port: listener.Addr().(*net.TCPAddr).Port}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(
+		func() {
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if server.failure != nil {
+				t.Errorf("fake SMTP server: %v", server.failure)
+			}
+		},
+	)
+	return server
+}
+
+func (s *smtpServer) delivered() []delivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]delivery(nil), s.got...)
+}
+
+func (s *smtpServer) refuseRecipients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refuse = true
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) { _ = text.PrintfLine("%s", line) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.ToUpper(line), " ")
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL", "NOOP", "RSET":
+			reply("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			refuse := s.refuse
+			s.mu.Unlock()
+			if refuse {
+				reply("550 5.1.1 Mailbox unavailable")
+				continue
+			}
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			raw, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.record(raw)
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) record(raw []byte) {
+	got, err := parseDelivery(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.failure = err
+		return
+	}
+	s.got = append(s.got, got)
+}
+
+// parseDelivery reads the recipient, subject and the text/plain part back out of a rendered message
+func parseDelivery(raw []byte) (delivery, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return delivery{}, err
+	}
+	to, err := msg.Header.AddressList("To")
+	if err != nil {
+		return delivery{}, err
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return delivery{}, err
+	}
+
+	body := io.Reader(msg.Body)
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return delivery{}, err
+	}
+	if boundary := params["boundary"]; boundary != "" {
+		part, err := multipart.NewReader(msg.Body, boundary).NextRawPart() // text/plain comes first
+		if err != nil {
+			return delivery{}, err
+		}
+		body = part
+	}
+	text, err := io.ReadAll(quotedprintable.NewReader(body))
+	if err != nil {
+		return delivery{}, err
+	}
+	return delivery{to: to[0].Address, subject: subject, text: string(text)}, nil
+}
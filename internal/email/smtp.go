@@ -0,0 +1,190 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+)
+
+var ErrInvalidHeader = errors.New("email header contains a line break")
+
+// SMTPSender delivers through an SMTP server with STARTTLS, one connection per message
+type SMTPSender struct {
+	cfg   *config.EmailConfig
+	clock utils.Clock
+}
+
+func NewSMTPSender(cfg *config.EmailConfig, clock utils.Clock) *SMTPSender {
+	return &SMTPSender{
+		cfg:   cfg,
+		clock: clock,
+	}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	from, to, err := prepare(s.cfg, msg)
+	if err != nil {
+		return err
+	}
+
+	raw, err := buildMessage(from, s.cfg.ReplyTo, to, msg, s.clock.Now())
+	if err != nil {
+		return err
+	}
+
+	client, err := dialSMTP(ctx, &s.cfg.SMTP)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return err
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(raw); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// dialSMTP opens an authenticated session, upgraded to TLS when configured
+func dialSMTP(ctx context.Context, smtpCfg *config.SMTPConfig) (*smtp.Client, error) {
+	addr := net.JoinHostPort(smtpCfg.Host, strconv.Itoa(smtpCfg.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, smtpCfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+
+	if smtpCfg.UseTLS {
+		if err := client.StartTLS(&tls.Config{ServerName: smtpCfg.Host}); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	auth := smtp.PlainAuth("", smtpCfg.Username, smtpCfg.Password, smtpCfg.Host)
+	if err := client.Auth(auth); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SMTP authentication failed: %w", err)
+	}
+	return client, nil
+}
+
+// buildMessage renders RFC 5322 headers and the body, multipart/alternative when there is an HTML part.
+// Display names and a non-ASCII subject are RFC 2047 encoded. Header values are checked by prepare.
+func buildMessage(from *mail.Address, replyTo string, to *mail.Address, msg Message, now time.Time) ([]byte, error) {
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHeader := func(name, value string) {
+		buf.WriteString(name + ": " + value + "\r\n")
+	}
+
+	writeHeader("From", from.String())
+	writeHeader("To", to.String())
+	if replyTo != "" {
+		writeHeader("Reply-To", replyTo)
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", now.Format(time.RFC1123Z))
+	writeHeader("Message-ID", messageID)
+	writeHeader("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		writeHeader("Content-Type", "text/plain; charset=UTF-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Text); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(&buf)
+	writeHeader("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text}, // Least preferred first, as RFC 2046 wants
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		writer, err := parts.CreatePart(
+			textproto.MIMEHeader{
+				"Content-Type":              {part.contentType},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(writer, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeQuotedPrintable keeps lines under the SMTP length limit whatever the body looks like
+func writeQuotedPrintable(w io.Writer, body string) error {
+	encoder := quotedprintable.NewWriter(w)
+	if _, err := encoder.Write([]byte(body)); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// newMessageID is a globally unique "<random@domain>", the domain taken from the sender address
+func newMessageID(fromAddress string) (string, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", fmt.Errorf("failed to generate message ID: %w", err)
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(fromAddress, "@"); at >= 0 {
+		domain = fromAddress[at+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(randomBytes), domain), nil
+}
@@ -0,0 +1,190 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+var testEmailConfig = &config.EmailConfig{
+	FromName:    "Агора Team",
+	FromAddress: "noreply@agora.example",
+	ReplyTo:     "support@agora.example",
+}
+
+func buildTestMessage(t *testing.T, msg Message, now time.Time) *mail.Message {
+	t.Helper()
+
+	from, to, err := prepare(testEmailConfig, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := buildMessage(from, testEmailConfig.ReplyTo, to, msg, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(raw), "\r\n") {
+		if len(line) > 998 {
+			t.Fatalf("line %d is %d bytes, over the RFC 5322 limit", i, len(line))
+		}
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("message doesn't parse: %v\n%s", err, raw)
+	}
+	return parsed
+}
+
+func TestBuildMessageHeaders(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 9, 26, 0, time.FixedZone("EET", 2*60*60))
+	parsed := buildTestMessage(
+		t, Message{To: "Łukasz <lukasz@example.com>", Subject: "Вітаємо в Agora — confirm ✓", Text: "hi"}, now,
+	)
+
+	date, err := parsed.Header.Date()
+	if err != nil {
+		t.Fatalf("Date: %v", err)
+	}
+	if !date.Equal(now) {
+		t.Errorf("Date = %v, want %v", date, now)
+	}
+
+	messageID := parsed.Header.Get("Message-ID")
+	if !regexp.MustCompile(`^<[0-9a-f]{32}@agora\.example>$`).MatchString(messageID) {
+		t.Errorf("Message-ID = %q, want <hex@sender domain>", messageID)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("Subject: %v", err)
+	}
+	if subject != "Вітаємо в Agora — confirm ✓" {
+		t.Errorf("Subject decodes to %q", subject)
+	}
+	if raw := parsed.Header.Get("Subject"); !isASCII(raw) {
+		t.Errorf("raw Subject %q isn't RFC 2047 encoded", raw)
+	}
+
+	from, err := parsed.Header.AddressList("From")
+	if err != nil {
+		t.Fatalf("From: %v", err)
+	}
+	if from[0].Name != "Агора Team" || from[0].Address != "noreply@agora.example" {
+		t.Errorf("From = %+v", from[0])
+	}
+	to, err := parsed.Header.AddressList("To")
+	if err != nil {
+		t.Fatalf("To: %v", err)
+	}
+	if to[0].Name != "Łukasz" || to[0].Address != "lukasz@example.com" {
+		t.Errorf("To = %+v", to[0])
+	}
+	if got := parsed.Header.Get("Reply-To"); got != "support@agora.example" {
+		t.Errorf("Reply-To = %q", got)
+	}
+	if got := parsed.Header.Get("MIME-Version"); got != "1.0" {
+		t.Errorf("MIME-Version = %q", got)
+	}
+}
+
+func TestBuildMessageIDsAreUnique(t *testing.T) {
+	now := time.Now()
+	first := buildTestMessage(t, Message{To: "a@example.com", Text: "x"}, now).Header.Get("Message-ID")
+	second := buildTestMessage(t, Message{To: "a@example.com", Text: "x"}, now).Header.Get("Message-ID")
+	if first == second {
+		t.Fatalf("two messages share the Message-ID %s", first)
+	}
+}
+
+func TestBuildMessagePlainText(t *testing.T) {
+	text := "Привіт!\r\n" + strings.Repeat("long line ", 200)
+	parsed := buildTestMessage(t, Message{To: "a@example.com", Subject: "plain", Text: text}, time.Now())
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/plain" || params["charset"] != "UTF-8" {
+		t.Fatalf("Content-Type = %q (%v)", parsed.Header.Get("Content-Type"), err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != text {
+		t.Errorf("body decodes to %q", body)
+	}
+}
+
+func TestBuildMessageAlternativeParts(t *testing.T) {
+	msg := Message{To: "a@example.com", Subject: "both", Text: "Plain ✓", HTML: "<p>HTML ✓</p>"}
+	parsed := buildTestMessage(t, msg, time.Now())
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v)", parsed.Header.Get("Content-Type"), err)
+	}
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	want := []struct{ mediaType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	}
+	for _, w := range want {
+		part, err := reader.NextRawPart()
+		if err != nil {
+			t.Fatalf("reading the %s part: %v", w.mediaType, err)
+		}
+		if got, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); got != w.mediaType {
+			t.Errorf("part Content-Type = %q, want %s", got, w.mediaType)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != w.body {
+			t.Errorf("%s part decodes to %q, want %q", w.mediaType, body, w.body)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected exactly two parts, got err %v", err)
+	}
+}
+
+func TestPrepareRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.EmailConfig
+		msg  Message
+	}{
+		{"subject", testEmailConfig, Message{To: "a@example.com", Subject: "hi\r\nBcc: victim@example.com"}},
+		{"bare LF", testEmailConfig, Message{To: "a@example.com", Subject: "hi\nBcc: victim@example.com"}},
+		{"reply-to", &config.EmailConfig{ReplyTo: "x@example.com\r\nBcc: victim@example.com"}, Message{To: "a@example.com"}},
+	}
+	for _, tt := range tests {
+		if _, _, err := prepare(tt.cfg, tt.msg); !errors.Is(err, ErrInvalidHeader) {
+			t.Errorf("%s: err = %v, want ErrInvalidHeader", tt.name, err)
+		}
+	}
+
+	if _, _, err := prepare(testEmailConfig, Message{To: "a@example.com\r\nBcc: victim@example.com"}); err == nil {
+		t.Error("recipient with a line break was accepted")
+	}
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
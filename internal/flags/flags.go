@@ -0,0 +1,126 @@
+// Package flags holds runtime toggles that can be changed without a restart. Definitions live in code, values
+// are overridden in Redis and cached in-process on every instance, see Store.
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/google/uuid"
+)
+
+type Kind string
+
+const (
+	KindBool Kind = "bool"
+	KindInt  Kind = "int"
+	// KindPercentage rolls a feature out to a stable share (0-100) of users, see Bucket
+	KindPercentage Kind = "percentage"
+)
+
+const (
+	MaintenanceReadOnly = "maintenance_read_only"
+	// TopicPageCacheSoftTTL is how long (in seconds) the first topic page is served without a refresh
+	TopicPageCacheSoftTTL = "topic_page_cache_soft_ttl"
+)
+
+var (
+	ErrUnknownFlag  = errors.New("unknown feature flag")
+	ErrInvalidValue = errors.New("invalid feature flag value")
+	ErrUnavailable  = errors.New("feature flags need Redis to be changed")
+)
+
+// Definition describes a flag. Every value is stored as an int64: 0/1 for bools, 0-100 for percentages.
+type Definition struct {
+	Name        string
+	Kind        Kind
+	Description string
+	Default     int64
+	Max         int64 // Upper bound for KindInt, values are never negative
+}
+
+// definitions are all known flags, their defaults can be overridden from config in NewStore
+var definitions = []Definition{
+	{
+		Name:        MaintenanceReadOnly,
+		Kind:        KindBool,
+		Description: "Reject mutating requests with 503, e.g. during a database failover",
+	},
+	{
+		Name:        TopicPageCacheSoftTTL,
+		Kind:        KindInt,
+		Description: "Seconds the first page of GET /subreddits/by-topic/:slug is served without a refresh",
+		Default:     60,
+		Max:         3600,
+	},
+}
+
+func Definitions() []Definition {
+	return definitions
+}
+
+func Lookup(name string) (Definition, bool) {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Parse reads a JSON value of the flag's kind: true/false for bools, a number otherwise
+func (d Definition) Parse(raw json.RawMessage) (int64, error) {
+	if d.Kind == KindBool {
+		var enabled bool
+		if err := json.Unmarshal(raw, &enabled); err != nil {
+			return 0, fmt.Errorf("%w: %s expects true or false", ErrInvalidValue, d.Name)
+		}
+		return BoolValue(enabled), nil
+	}
+
+	var value int64
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return 0, fmt.Errorf("%w: %s expects a whole number", ErrInvalidValue, d.Name)
+	}
+	return value, d.Validate(value)
+}
+
+func (d Definition) Validate(value int64) error {
+	upper := d.Max
+	switch d.Kind {
+	case KindBool:
+		upper = 1
+	case KindPercentage:
+		upper = 100
+	}
+	if value < 0 || value > upper {
+		return fmt.Errorf("%w: %s must be between 0 and %d", ErrInvalidValue, d.Name, upper)
+	}
+	return nil
+}
+
+// Render is the JSON form of value, the counterpart of Parse
+func (d Definition) Render(value int64) any {
+	if d.Kind == KindBool {
+		return value == 1
+	}
+	return value
+}
+
+func BoolValue(enabled bool) int64 {
+	if enabled {
+		return 1
+	}
+	return 0
+}
+
+// Bucket places a user in 0-99 for a percentage rollout. It's salted with the flag name so rollouts of different
+// flags don't hit the same users, and stable, so raising the percentage only ever adds users.
+func Bucket(name string, userID uuid.UUID) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	hash.Write(userID[:])
+	return int64(hash.Sum64() % 100)
+}
@@ -0,0 +1,93 @@
+package flags
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// testUsers returns n deterministic user IDs, so a failure reproduces
+func testUsers(n int) []uuid.UUID {
+	random := rand.New(rand.NewPCG(1, 2))
+	users := make([]uuid.UUID, n)
+	for i := range users {
+		for j := range users[i] {
+			users[i][j] = byte(random.Uint32())
+		}
+		users[i][6] = users[i][6]&0x0f | 0x70 // Version 7 like utils.NewID
+		users[i][8] = users[i][8]&0x3f | 0x80
+	}
+	return users
+}
+
+func TestBucketDistribution(t *testing.T) {
+	const users = 100_000
+	var counts [100]int
+	for _, userID := range testUsers(users) {
+		bucket := Bucket("new_feed_ranking", userID)
+		if bucket < 0 || bucket >= 100 {
+			t.Fatalf("Bucket = %d, want 0-99", bucket)
+		}
+		counts[bucket]++
+	}
+
+	// Chi-square with 99 degrees of freedom stays below 148 for a uniform hash with p > 0.999
+	expected := float64(users) / 100
+	chiSquare := 0.0
+	for _, count := range counts {
+		chiSquare += math.Pow(float64(count)-expected, 2) / expected
+	}
+	if chiSquare > 148 {
+		t.Errorf("chi-square = %.1f over %v, buckets aren't uniform", chiSquare, counts)
+	}
+}
+
+func TestBucketRolloutShares(t *testing.T) {
+	users := testUsers(50_000)
+	for _, percentage := range []int64{1, 10, 25, 50, 90} {
+		enabled := 0
+		for _, userID := range users {
+			if Bucket("new_feed_ranking", userID) < percentage {
+				enabled++
+			}
+		}
+		share := float64(enabled) / float64(len(users)) * 100
+		if math.Abs(share-float64(percentage)) > 1 {
+			t.Errorf("rollout %d%% reached %.2f%% of users", percentage, share)
+		}
+	}
+}
+
+// Buckets are pinned: a hash change would reshuffle who is in every running rollout across a deploy
+func TestBucketStable(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		want   int64
+	}{
+		{"new_feed_ranking", "00000000-0000-0000-0000-000000000001", 76},
+		{"new_feed_ranking", "0190f5a2-7c3e-7b1a-9d2e-4f6a8b0c1d2e", 4},
+		{"maintenance_read_only", "0190f5a2-7c3e-7b1a-9d2e-4f6a8b0c1d2e", 11},
+	}
+	for _, tt := range tests {
+		if got := Bucket(tt.name, uuid.MustParse(tt.userID)); got != tt.want {
+			t.Errorf("Bucket(%s, %s) = %d, want %d", tt.name, tt.userID, got, tt.want)
+		}
+	}
+}
+
+// Flags hash the name in, so a 50% rollout of two flags doesn't hand both to the same half of the users
+func TestBucketIndependentAcrossFlags(t *testing.T) {
+	users := testUsers(50_000)
+	both := 0
+	for _, userID := range users {
+		if Bucket("flag_a", userID) < 50 && Bucket("flag_b", userID) < 50 {
+			both++
+		}
+	}
+	if share := float64(both) / float64(len(users)) * 100; math.Abs(share-25) > 1 {
+		t.Errorf("%.2f%% of users got both 50%% rollouts, want about 25%%", share)
+	}
+}
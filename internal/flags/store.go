@@ -0,0 +1,266 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	ValuesKey = "flags:values" // Hash of flag name to overridden value
+	// Every instance subscribes here and drops its cached values when a flag changes
+	InvalidateChannel = "flags:invalidate"
+	// Cached values are reloaded after this even without an invalidation, in case one was missed
+	LocalTTL = 30 * time.Second
+)
+
+// Store resolves flags from the overrides in Redis, falling back to the defaults. Overrides are cached
+// in-process, so reading a flag on every request costs no round trip. When Redis is down the last loaded
+// overrides stay in use, or the defaults when none were loaded yet.
+type Store struct {
+	redis    *redis.Client
+	defaults map[string]int64
+	clock    utils.Clock
+
+	mu        sync.Mutex
+	overrides map[string]int64
+	loadedAt  time.Time
+	expiries  uint64 // Bumped by expire, a load that overlapped one isn't trusted as fresh
+
+	loads singleflight.Group // One Redis round trip per instance while the cache is stale
+}
+
+// NewStore takes defaults for some flags (from config), the rest keep the default of their Definition
+func NewStore(redisClient *redis.Client, defaults map[string]int64, clock utils.Clock) *Store {
+	resolved := make(map[string]int64, len(definitions))
+	for _, definition := range definitions {
+		resolved[definition.Name] = definition.Default
+		if value, ok := defaults[definition.Name]; ok && definition.Validate(value) == nil {
+			resolved[definition.Name] = value
+		}
+	}
+	return &Store{
+		redis:    redisClient,
+		defaults: resolved,
+		clock:    clock,
+	}
+}
+
+// Value returns the flag's current value, 0 for flags that aren't defined
+func (s *Store) Value(ctx context.Context, name string) int64 {
+	if s == nil {
+		definition, _ := Lookup(name)
+		return definition.Default
+	}
+
+	if value, ok := s.cachedOverrides(ctx)[name]; ok {
+		return value
+	}
+	return s.defaults[name]
+}
+
+func (s *Store) Bool(ctx context.Context, name string) bool {
+	return s.Value(ctx, name) == 1
+}
+
+func (s *Store) Int(ctx context.Context, name string) int64 {
+	return s.Value(ctx, name)
+}
+
+// Enabled tells whether the feature is on for userID: the bool itself, or the user's bucket being within
+// the rollout percentage. Anonymous users (uuid.Nil) only see percentage rollouts once they reach 100.
+func (s *Store) Enabled(ctx context.Context, name string, userID uuid.UUID) bool {
+	definition, ok := Lookup(name)
+	if !ok {
+		return false
+	}
+
+	value := s.Value(ctx, name)
+	if definition.Kind != KindPercentage {
+		return value > 0
+	}
+	if userID == uuid.Nil {
+		return value >= 100
+	}
+	return Bucket(name, userID) < value
+}
+
+// Overridden reports the flags currently overridden in Redis, read from Redis rather than the cache
+func (s *Store) Overridden(ctx context.Context) (map[string]int64, error) {
+	if s.redis == nil {
+		return map[string]int64{}, nil
+	}
+	return s.load(ctx)
+}
+
+// Default is the value the flag has without an override
+func (s *Store) Default(name string) int64 {
+	return s.defaults[name]
+}
+
+// Set overrides the flag on every instance
+func (s *Store) Set(ctx context.Context, name string, value int64) error {
+	definition, ok := Lookup(name)
+	if !ok {
+		return ErrUnknownFlag
+	}
+	if err := definition.Validate(value); err != nil {
+		return err
+	}
+	if s.redis == nil {
+		return ErrUnavailable
+	}
+
+	if err := s.redis.HSet(ctx, ValuesKey, name, value).Err(); err != nil {
+		return fmt.Errorf("failed to set feature flag: %w", err)
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// Reset drops the override, the flag goes back to its default on every instance
+func (s *Store) Reset(ctx context.Context, name string) error {
+	if _, ok := Lookup(name); !ok {
+		return ErrUnknownFlag
+	}
+	if s.redis == nil {
+		return nil
+	}
+
+	if err := s.redis.HDel(ctx, ValuesKey, name).Err(); err != nil {
+		return fmt.Errorf("failed to reset feature flag: %w", err)
+	}
+	s.invalidate(ctx)
+	return nil
+}
+
+// Listen drops the cached overrides whenever another instance changes a flag, until ctx is done
+func (s *Store) Listen(ctx context.Context) {
+	if s.redis == nil {
+		return
+	}
+
+	pubsub := s.redis.Subscribe(ctx, InvalidateChannel)
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-messages:
+				if !ok {
+					return
+				}
+				s.expire()
+			}
+		}
+	}()
+}
+
+func (s *Store) cachedOverrides(ctx context.Context) map[string]int64 {
+	if s.redis == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	overrides, fresh := s.overrides, !s.loadedAt.IsZero() && s.clock.Now().Sub(s.loadedAt) < LocalTTL
+	s.mu.Unlock()
+	if fresh {
+		return overrides
+	}
+
+	// mu isn't held over the round trip, readers wait on the shared load instead and expire never blocks.
+	// The load outlives the caller that started it, the others waiting on it would get its cancellation.
+	reloaded, _, _ := s.loads.Do(
+		"overrides", func() (any, error) {
+			return s.reload(context.WithoutCancel(ctx)), nil
+		},
+	)
+	return reloaded.(map[string]int64)
+}
+
+// reload loads the overrides into the cache and returns what the cache holds afterwards
+func (s *Store) reload(ctx context.Context) map[string]int64 {
+	s.mu.Lock()
+	expiries := s.expiries
+	s.mu.Unlock()
+
+	overrides, err := s.load(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A failed load is retried after LocalTTL as well, so requests don't pile up on a Redis that is down
+	s.loadedAt = s.clock.Now()
+	if err != nil {
+		log.Println("Failed to load feature flags, keeping the previous values:", err)
+		return s.overrides
+	}
+	s.overrides = overrides
+	if s.expiries != expiries {
+		s.loadedAt = time.Time{} // May have been read before the change, the next read loads again
+	}
+	return overrides
+}
+
+func (s *Store) load(ctx context.Context) (map[string]int64, error) {
+	values, err := s.redis.HGetAll(ctx, ValuesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]int64, len(values))
+	for name, raw := range values {
+		value, err := strconv.ParseInt(raw, 10, 64)
+		definition, ok := Lookup(name)
+		if err != nil || !ok || definition.Validate(value) != nil {
+			continue // Left behind by a removed flag or written by hand
+		}
+		overrides[name] = value
+	}
+	return overrides, nil
+}
+
+func (s *Store) expire() {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.expiries++
+	s.mu.Unlock()
+}
+
+func (s *Store) invalidate(ctx context.Context) {
+	s.expire()
+	if err := s.redis.Publish(ctx, InvalidateChannel, "").Err(); err != nil {
+		log.Println("Failed to publish feature flag invalidation:", err)
+	}
+}
+
+// defaultStore backs the package-level helpers, so services can check a flag without having the Store injected
+var defaultStore atomic.Pointer[Store]
+
+// SetDefault makes store the one the package-level helpers read, until then they return Definition defaults
+func SetDefault(store *Store) {
+	defaultStore.Store(store)
+}
+
+func Enabled(ctx context.Context, name string, userID uuid.UUID) bool {
+	return defaultStore.Load().Enabled(ctx, name, userID)
+}
+
+func Bool(ctx context.Context, name string) bool {
+	return defaultStore.Load().Bool(ctx, name)
+}
+
+func Int(ctx context.Context, name string) int64 {
+	return defaultStore.Load().Int(ctx, name)
+}
@@ -0,0 +1,128 @@
+package flags
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// slowLoadHook counts HGETALLs and holds each one until release is closed
+type slowLoadHook struct {
+	loads   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *slowLoadHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h *slowLoadHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "hgetall" {
+			if h.loads.Add(1) == 1 {
+				close(h.started)
+			}
+			<-h.release
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *slowLoadHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func newSlowStore(t *testing.T) (*Store, *slowLoadHook) {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	if err := client.HSet(context.Background(), ValuesKey, TopicPageCacheSoftTTL, 42).Err(); err != nil {
+		t.Fatal(err)
+	}
+	hook := &slowLoadHook{started: make(chan struct{}), release: make(chan struct{})}
+	client.AddHook(hook)
+	return NewStore(client, nil, utils.RealClock{}), hook
+}
+
+// Readers arriving while the cache is stale share one load instead of each taking a Redis round trip
+func TestStoreLoadsOncePerStaleCache(t *testing.T) {
+	store, hook := newSlowStore(t)
+
+	const readers = 50
+	values := make([]int64, readers)
+	var wg sync.WaitGroup
+	for i := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			values[i] = store.Int(context.Background(), TopicPageCacheSoftTTL)
+		}()
+	}
+	<-hook.started
+	time.Sleep(10 * time.Millisecond) // Let the other readers reach the load
+	close(hook.release)
+	wg.Wait()
+
+	if loads := hook.loads.Load(); loads != 1 {
+		t.Errorf("%d HGETALLs for %d readers, want 1", loads, readers)
+	}
+	for i, value := range values {
+		if value != 42 {
+			t.Fatalf("reader %d got %d, want the override 42", i, value)
+		}
+	}
+}
+
+// An invalidation arriving mid-load must not wait for Redis, and the load it raced isn't trusted as fresh
+func TestStoreExpireDuringLoad(t *testing.T) {
+	store, hook := newSlowStore(t)
+
+	loaded := make(chan struct{})
+	go func() {
+		store.Int(context.Background(), TopicPageCacheSoftTTL)
+		close(loaded)
+	}()
+	<-hook.started
+
+	expired := make(chan struct{})
+	go func() {
+		store.expire()
+		close(expired)
+	}()
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("expire blocked behind the Redis load")
+	}
+
+	close(hook.release)
+	<-loaded
+	if value := store.Int(context.Background(), TopicPageCacheSoftTTL); value != 42 {
+		t.Fatalf("value = %d, want 42", value)
+	}
+	if loads := hook.loads.Load(); loads != 2 {
+		t.Errorf("%d HGETALLs, want the raced load to be followed by a fresh one", loads)
+	}
+}
+
+// The load is shared, so the cancelled request that happened to start it must not fail it for everyone
+func TestStoreLoadOutlivesCancelledCaller(t *testing.T) {
+	store, hook := newSlowStore(t)
+	close(hook.release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if value := store.Int(ctx, TopicPageCacheSoftTTL); value != 42 {
+		t.Fatalf("value = %d, want the override 42 despite the cancelled caller", value)
+	}
+}
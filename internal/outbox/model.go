@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventSubredditCreated = "subreddit.created"
+	EventMemberJoined     = "subreddit.member_joined"
+	EventMemberLeft       = "subreddit.member_left"
+
+	// Notification consumers fan these out to the subreddit's members
+	EventSubredditDeletionScheduled = "subreddit.deletion_scheduled"
+	EventSubredditDeletionCancelled = "subreddit.deletion_cancelled"
+	EventSubredditDeleted           = "subreddit.deleted"
+)
+
+// Event is a domain change waiting to be relayed to the Redis stream.
+// It's written in the same transaction as the change itself, so a crash after commit can't lose it.
+type Event struct {
+	ID           uuid.UUID `gorm:"type:uuid;primaryKey"` // Consumers use it as the idempotency key
+	Type         string    `gorm:"size:100;not null"`
+	Payload      string    `gorm:"type:jsonb;not null"`
+	Attempts     int       `gorm:"not null;default:0"`
+	CreatedAt    time.Time
+	DispatchedAt *time.Time
+
+	NextAttemptAt  *time.Time // Set after a failed relay, the event isn't claimed before it
+	DeadLetteredAt *time.Time // Set once Attempts reaches OutboxConfig.MaxAttempts, the relay stops picking it up
+	LastError      *string
+}
+
+func (Event) TableName() string {
+	return "outbox_events"
+}
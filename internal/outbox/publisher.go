@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+)
+
+// Publisher is what services call instead of firing side effects (notifications, webhooks, indexing) directly
+type Publisher struct {
+	repo *Repository
+}
+
+func NewPublisher(repo *Repository) *Publisher {
+	return &Publisher{
+		repo: repo,
+	}
+}
+
+// Publish stores the event in the outbox. Call it inside the txManager.RunInTx of the domain change:
+// the event is then committed or rolled back together with it, and the Relay delivers it afterward.
+func (p *Publisher) Publish(ctx context.Context, eventType string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	return p.repo.Create(
+		ctx, &Event{
+			ID:      utils.NewID(),
+			Type:    eventType,
+			Payload: string(raw),
+		},
+	)
+}
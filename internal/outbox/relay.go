@@ -0,0 +1,204 @@
+package outbox
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"math"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// outboxStats is published on GET /admin/metrics: events that failed a relay attempt and those given up on
+var outboxStats = expvar.NewMap("outbox")
+
+// Relay moves committed outbox events to a Redis stream. Delivery is at-least-once: a crash between
+// XADD and marking the rows dispatched re-sends them, so consumers dedupe on the event ID.
+type Relay struct {
+	repo      *Repository
+	txManager *database.TxManager
+	redis     *redis.Client
+	cfg       config.OutboxConfig
+	clock     utils.Clock
+}
+
+func NewRelay(
+	repo *Repository,
+	txManager *database.TxManager,
+	redisClient *redis.Client,
+	cfg config.OutboxConfig,
+	clock utils.Clock,
+) *Relay {
+	return &Relay{
+		repo:      repo,
+		txManager: txManager,
+		redis:     redisClient,
+		cfg:       cfg,
+		clock:     clock,
+	}
+}
+
+// Run polls the outbox in the background until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	if r.redis == nil || r.cfg.PollInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.cfg.PollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.drain(ctx)
+			}
+		}
+	}()
+}
+
+// drain relays batches until the outbox is empty or an event fails. Failed events wait out their
+// backoff, so the next tick picks up where this one stopped instead of hammering a struggling Redis.
+func (r *Relay) drain(ctx context.Context) {
+	for {
+		claimed, failed, err := r.relayBatch(ctx)
+		if err != nil {
+			log.Println("Failed to relay outbox events:", err)
+			return
+		}
+		if failed > 0 || claimed < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// relayBatch returns how many events it claimed and how many of them couldn't be relayed.
+// Each XADD is checked on its own: a failing event is retried later or dead-lettered, it doesn't hold
+// back the rest of the batch.
+func (r *Relay) relayBatch(ctx context.Context) (int, int, error) {
+	var claimed, failed, deadLettered int
+
+	err := r.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			claimed, failed, deadLettered = 0, 0, 0
+			now := r.clock.Now()
+
+			events, err := r.repo.ClaimPending(ctx, r.cfg.BatchSize, now)
+			if err != nil || len(events) == 0 {
+				return err
+			}
+			claimed = len(events)
+
+			pipe := r.redis.Pipeline()
+			cmds := make([]*redis.StringCmd, len(events))
+			for i, event := range events {
+				cmds[i] = pipe.XAdd(
+					ctx, &redis.XAddArgs{
+						Stream: r.cfg.Stream,
+						MaxLen: r.cfg.StreamMaxLen,
+						Approx: true,
+						Values: map[string]interface{}{
+							"id":      event.ID.String(),
+							"type":    event.Type,
+							"payload": event.Payload,
+						},
+					},
+				)
+			}
+			_, _ = pipe.Exec(ctx) // Only reports the first failure, each command is checked below
+
+			dispatched := make([]uuid.UUID, 0, len(events))
+			for i, event := range events {
+				xaddErr := cmds[i].Err()
+				if xaddErr == nil {
+					dispatched = append(dispatched, event.ID)
+					continue
+				}
+
+				failed++
+				dead, err := r.recordFailure(ctx, event, xaddErr, now)
+				if err != nil {
+					return err
+				}
+				if dead {
+					deadLettered++
+				}
+			}
+			if len(dispatched) == 0 {
+				return nil
+			}
+			return r.repo.MarkDispatched(ctx, dispatched, now)
+		},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if failed > 0 {
+		outboxStats.Add("failed", int64(failed))
+	}
+	if deadLettered > 0 {
+		outboxStats.Add("dead_lettered", int64(deadLettered))
+	}
+	return claimed, failed, nil
+}
+
+// recordFailure schedules the event's next attempt, or dead-letters it once it has used up MaxAttempts
+func (r *Relay) recordFailure(ctx context.Context, event Event, cause error, now time.Time) (bool, error) {
+	attempts := event.Attempts + 1
+	if r.cfg.MaxAttempts > 0 && attempts >= r.cfg.MaxAttempts {
+		log.Printf("Dead-lettering outbox event %s (%s) after %d attempts: %v", event.ID, event.Type, attempts, cause)
+		return true, r.repo.RecordFailure(ctx, event.ID, cause.Error(), nil, &now)
+	}
+
+	nextAttemptAt := now.Add(retryBackoff(r.cfg, attempts))
+	return false, r.repo.RecordFailure(ctx, event.ID, cause.Error(), &nextAttemptAt, nil)
+}
+
+// retryBackoff doubles RetryBackoff for every attempt after the first, capped at MaxRetryBackoff
+func retryBackoff(cfg config.OutboxConfig, attempts int) time.Duration {
+	backoff := cfg.RetryBackoff
+	for i := 1; i < attempts && backoff > 0 && backoff < math.MaxInt64/2; i++ {
+		backoff *= 2
+	}
+	if cfg.MaxRetryBackoff > 0 && backoff > cfg.MaxRetryBackoff {
+		return cfg.MaxRetryBackoff
+	}
+	return backoff
+}
+
+// RunRetention deletes dispatched events older than the configured retention every prune interval
+// until ctx is done. A zero retention keeps them forever.
+func (r *Relay) RunRetention(ctx context.Context) {
+	if r.cfg.Retention <= 0 || r.cfg.PruneInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(r.cfg.PruneInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pruned, err := r.repo.DeleteDispatchedBefore(ctx, r.clock.Now().Add(-r.cfg.Retention))
+				if err != nil {
+					log.Println("Failed to prune outbox events:", err)
+					continue
+				}
+				if pruned > 0 {
+					log.Printf("Pruned %d dispatched outbox events past retention", pruned)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,197 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := config.OutboxConfig{RetryBackoff: time.Second, MaxRetryBackoff: time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{1000, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryBackoff(cfg, tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+
+	if got := retryBackoff(config.OutboxConfig{RetryBackoff: time.Second}, 1000); got <= 0 {
+		t.Errorf("uncapped backoff overflowed to %v", got)
+	}
+}
+
+// poisonHook fails the XADD of every event whose payload contains marker
+type poisonHook struct {
+	marker string
+}
+
+func (h poisonHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h poisonHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return next
+}
+
+func (h poisonHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		var healthy []redis.Cmder
+		for _, cmd := range cmds {
+			if strings.Contains(cmd.String(), h.marker) {
+				cmd.SetErr(errors.New("ERR poisoned"))
+				continue
+			}
+			healthy = append(healthy, cmd)
+		}
+		if len(healthy) == 0 {
+			return nil
+		}
+		return next(ctx, healthy)
+	}
+}
+
+type relayEnv struct {
+	relay     *Relay
+	publisher *Publisher
+	repo      *Repository
+	db        *database.DB
+	redis     *redis.Client
+	clock     *testutil.FakeClock
+}
+
+func newRelayEnv(t *testing.T, cfg config.OutboxConfig) relayEnv {
+	t.Helper()
+
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	db := testutil.Postgres(t, clock)
+	repo := NewRepository(db, 0)
+	redisClient := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	redisClient.AddHook(poisonHook{marker: "poison"})
+
+	return relayEnv{
+		relay:     NewRelay(repo, database.NewTxManager(db), redisClient, cfg, clock),
+		publisher: NewPublisher(repo),
+		repo:      repo,
+		db:        db,
+		redis:     redisClient,
+		clock:     clock,
+	}
+}
+
+func (env relayEnv) event(t *testing.T, payload string) Event {
+	t.Helper()
+
+	var event Event
+	err := env.db.WriteDB().Where("payload->>'name' = ?", payload).First(&event).Error
+	if err != nil {
+		t.Fatalf("load %s: %v", payload, err)
+	}
+	return event
+}
+
+func TestRelayFailingEventDoesNotBlockTheBatch(t *testing.T) {
+	cfg := config.OutboxConfig{
+		BatchSize: 10, Stream: "events", MaxAttempts: 3, RetryBackoff: time.Second, MaxRetryBackoff: time.Minute,
+	}
+	env := newRelayEnv(t, cfg)
+	ctx := context.Background()
+
+	for _, name := range []string{"poison", "first", "second"} {
+		if err := env.publisher.Publish(ctx, EventSubredditCreated, map[string]string{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+		env.clock.Advance(time.Millisecond) // Keeps the poisoned event at the head of the outbox
+	}
+
+	claimed, failed, err := env.relay.relayBatch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed != 3 || failed != 1 {
+		t.Fatalf("claimed %d, failed %d, want 3 and 1", claimed, failed)
+	}
+	if n := env.redis.XLen(ctx, "events").Val(); n != 2 {
+		t.Errorf("stream has %d entries, want the 2 healthy events", n)
+	}
+	for _, name := range []string{"first", "second"} {
+		if env.event(t, name).DispatchedAt == nil {
+			t.Errorf("%s wasn't marked dispatched", name)
+		}
+	}
+
+	poison := env.event(t, "poison")
+	if poison.Attempts != 1 || poison.NextAttemptAt == nil || poison.LastError == nil {
+		t.Fatalf("poisoned event after one failure: %+v", poison)
+	}
+
+	// Still backing off, so the next batch doesn't claim it
+	if claimed, _, _ := env.relay.relayBatch(ctx); claimed != 0 {
+		t.Fatalf("claimed %d events during the backoff", claimed)
+	}
+
+	for range cfg.MaxAttempts - 1 {
+		env.clock.Advance(cfg.MaxRetryBackoff)
+		if _, _, err := env.relay.relayBatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	poison = env.event(t, "poison")
+	if poison.DeadLetteredAt == nil || poison.Attempts != cfg.MaxAttempts {
+		t.Fatalf("poisoned event wasn't dead-lettered after %d attempts: %+v", cfg.MaxAttempts, poison)
+	}
+
+	env.clock.Advance(cfg.MaxRetryBackoff)
+	if claimed, _, _ := env.relay.relayBatch(ctx); claimed != 0 {
+		t.Fatalf("dead-lettered event was claimed again")
+	}
+}
+
+func TestDeleteDispatchedBeforeKeepsUndelivered(t *testing.T) {
+	env := newRelayEnv(t, config.OutboxConfig{BatchSize: 10, Stream: "events", MaxAttempts: 1})
+	ctx := context.Background()
+
+	for _, name := range []string{"delivered", "poison"} {
+		if err := env.publisher.Publish(ctx, EventSubredditCreated, map[string]string{"name": name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := env.relay.relayBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.publisher.Publish(ctx, EventSubredditCreated, map[string]string{"name": "pending"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env.clock.Advance(time.Hour)
+	pruned, err := env.repo.DeleteDispatchedBefore(ctx, env.clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Fatalf("pruned %d events, want only the delivered one", pruned)
+	}
+	if env.event(t, "poison").DeadLetteredAt == nil {
+		t.Error("dead-lettered event should be kept")
+	}
+	env.event(t, "pending")
+}
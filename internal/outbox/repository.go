@@ -0,0 +1,103 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	db           *database.DB
+	queryTimeout time.Duration // Applied to every repository call, see utils.WithQueryTimeout
+}
+
+func NewRepository(db *database.DB, queryTimeout time.Duration) *Repository {
+	return &Repository{
+		db:           db,
+		queryTimeout: queryTimeout,
+	}
+}
+
+// conn joins the transaction carried by ctx (see database.TxManager) or uses the repository handle
+func (repo *Repository) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, repo.db.WriteDB())
+}
+
+func (repo *Repository) Create(ctx context.Context, event *Event) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Create(event).Error
+}
+
+// ClaimPending locks the oldest undispatched events that are due at now until the surrounding
+// transaction ends. SKIP LOCKED lets several relay instances work through the outbox without handing
+// out the same rows. Dead-lettered events and ones still backing off are left out.
+func (repo *Repository) ClaimPending(ctx context.Context, limit int, now time.Time) ([]Event, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var events []Event
+	err := repo.conn(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("dispatched_at IS NULL AND dead_lettered_at IS NULL").
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("created_at").
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
+
+func (repo *Repository) MarkDispatched(ctx context.Context, ids []uuid.UUID, dispatchedAt time.Time) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Model(&Event{}).
+		Where("id IN ?", ids).
+		Updates(
+			map[string]interface{}{
+				"dispatched_at": dispatchedAt,
+				"attempts":      gorm.Expr("attempts + 1"),
+			},
+		).Error
+}
+
+// RecordFailure counts a failed relay attempt and either schedules the retry or dead-letters the event
+func (repo *Repository) RecordFailure(
+	ctx context.Context,
+	id uuid.UUID,
+	lastError string,
+	nextAttemptAt *time.Time,
+	deadLetteredAt *time.Time,
+) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Model(&Event{}).
+		Where("id = ?", id).
+		Updates(
+			map[string]interface{}{
+				"attempts":         gorm.Expr("attempts + 1"),
+				"last_error":       lastError,
+				"next_attempt_at":  nextAttemptAt,
+				"dead_lettered_at": deadLetteredAt,
+			},
+		).Error
+}
+
+// DeleteDispatchedBefore prunes events relayed before cutoff. Dead-lettered events are kept for inspection.
+func (repo *Repository) DeleteDispatchedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).Where("dispatched_at < ?", cutoff).Delete(&Event{})
+	return result.RowsAffected, result.Error
+}
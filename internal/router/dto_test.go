@@ -0,0 +1,388 @@
+package router_test
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/admin"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/auth"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// internalTypes have JSON tags but never reach API clients, so they have no golden
+var internalTypes = map[string]string{
+	"auth.OAuthState":          "signed into the OAuth state param",
+	"auth.GoogleUserInfo":      "decoded from Google's userinfo endpoint",
+	"subreddit.SubredditEvent": "outbox payload",
+	"subreddit.DeletionEvent":  "outbox payload",
+}
+
+var (
+	sampleTime = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	sampleID   = uuid.MustParse("0190f3a4-7b1c-7d2e-9f10-2b3c4d5e6f70")
+)
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func set[T any](value T) utils.Optional[T] {
+	return utils.Optional[T]{Value: &value, Set: true}
+}
+
+// dtoSamples has one value of every DTO with all optional fields filled in, so each key shows up in the goldens
+func dtoSamples() []any {
+	subredditResponse := subreddit.SubredditResponse{
+		ID:                sampleID,
+		Name:              "golang",
+		DisplayName:       "Go Programming",
+		Description:       ptr("All things Go"),
+		DescriptionMaxLen: 500,
+		IconURL:           ptr("https://cdn.example/icons/golang.png"),
+		BannerURL:         ptr("https://cdn.example/banners/golang.png"),
+		PrimaryColor:      ptr("#00ADD8"),
+		BannerColor:       ptr("#5DC9E2"),
+		Creator:           user.PublicUserResponse{Email: "gopher@example.com", Username: "gopher_42"},
+		MemberCount:       12873,
+		PostCount:         410,
+		IsPublic:          true,
+		IsNSFW:            false,
+		Language:          ptr("en"),
+		PurgeAt:           &sampleTime,
+		Topics:            []subreddit.TopicResponse{{Slug: "programming", Name: "Programming", IsSensitive: false}},
+		MemberSince:       &sampleTime,
+		ShareCount:        ptr[int64](3),
+		ClickCount:        ptr[int64](41),
+		CreatedAt:         sampleTime,
+		UpdatedAt:         sampleTime,
+	}
+	pagination := utils.PaginationMeta{Page: 2, PageSize: 20, Total: 45}
+	reconcileStep := admin.ReconcileStep{
+		Scope: "subreddit_members", Cursor: sampleID, Done: true, Scanned: 1000, Corrected: 2, MaxDrift: 5,
+	}
+	modLogEntry := admin.ModLogEntryResponse{
+		ID:         sampleID,
+		Moderator:  "gopher_42",
+		Action:     "force_delete",
+		TargetType: "subreddit",
+		TargetID:   sampleID,
+		Reason:     ptr("spam"),
+		CreatedAt:  sampleTime,
+	}
+	batchResult := subreddit.BatchMembershipResult{
+		SubredditID: sampleID, Success: false, Code: "not_found", Error: "Subreddit not found",
+	}
+
+	return []any{
+		admin.ReadOnlyRequest{Enabled: ptr(true)},
+		admin.ReadOnlyResponse{Enabled: true},
+		admin.SetFlagRequest{Value: json.RawMessage(`50`)},
+		admin.FlagResponse{
+			Name:        "new_feed_ranking",
+			Kind:        flags.KindPercentage,
+			Description: "Share of users on the new feed ranking",
+			Value:       int64(50),
+			Default:     int64(0),
+			Overridden:  true,
+		},
+		admin.StartReconcileRequest{Scope: "all"},
+		admin.ReconcileRun{
+			ID:         sampleID,
+			Scope:      "all",
+			Status:     "failed",
+			Steps:      []admin.ReconcileStep{reconcileStep},
+			Error:      "statement timeout",
+			StartedAt:  sampleTime,
+			UpdatedAt:  sampleTime,
+			FinishedAt: &sampleTime,
+		},
+		reconcileStep,
+		admin.ForceDeleteSubredditRequest{Reason: "illegal content"},
+		modLogEntry,
+		admin.ModLogResponse{Entries: []admin.ModLogEntryResponse{modLogEntry}, NextCursor: ptr("c2Vjb25kX3BhZ2U")},
+
+		auth.RegisterRequest{
+			Email: "gopher@example.com", Username: "gopher_42", Password: "correct horse", Website: "", FormToken: "token",
+		},
+		auth.RegisterTokenResponse{FormToken: "token", ExpiresIn: 600},
+		auth.BasicLoginRequest{Email: "gopher@example.com", Password: "correct horse", RememberMe: true},
+		auth.PasswordStrengthRequest{Password: "correct horse", Email: "gopher@example.com", Username: "gopher_42"},
+		auth.PasswordStrengthResponse{
+			Score: 2, MaxScore: auth.PasswordScoreMax, Feedback: []string{"Add another word"}, PassesPolicy: false,
+			PolicyError: "password is too weak",
+		},
+		auth.RefreshRequest{RefreshToken: "refresh"},
+		auth.TokenResponse{Message: "Login successful", AccessToken: "access", RefreshToken: "refresh"},
+		auth.ValidationError{Field: "form_token", Message: "form token is required"},
+
+		subreddit.SubredditLite{
+			ID: sampleID, Name: "golang", IconURL: ptr("https://cdn.example/icons/golang.png"), IsNSFW: false,
+			IsPublic: true,
+		},
+		subreddit.RecountSummary{Scanned: 120, Corrected: 3, MaxDrift: 7},
+		subredditResponse,
+		subreddit.JoinedSubredditResponse{SubredditResponse: subredditResponse, JoinedAt: sampleTime},
+		subreddit.JoinedSubredditListResponse{
+			Subreddits: []subreddit.JoinedSubredditResponse{{SubredditResponse: subredditResponse, JoinedAt: sampleTime}},
+			Pagination: pagination,
+		},
+		subreddit.ShareResponse{Code: "aB3dE9", URL: "https://agora.example/s/aB3dE9"},
+		subreddit.MemberResponse{Username: "gopher_42", JoinedAt: sampleTime},
+		subreddit.MemberListResponse{
+			Members:    []subreddit.MemberResponse{{Username: "gopher_42", JoinedAt: sampleTime}},
+			Pagination: pagination,
+		},
+		subreddit.PutWikiPageRequest{Content: "# Rules"},
+		subreddit.WikiPageResponse{
+			Slug: "rules", Content: "# Rules", ContentHTML: "<h1>Rules</h1>", UpdatedBy: "gopher_42", UpdatedAt: sampleTime,
+		},
+		subreddit.MembershipResponse{IsMember: true, JoinedAt: &sampleTime},
+		subreddit.TopicResponse{Slug: "programming", Name: "Programming", IsSensitive: false},
+		subreddit.SubredditListResponse{Subreddits: []subreddit.SubredditResponse{subredditResponse}, Pagination: pagination},
+		subreddit.CreateSubredditRequest{
+			Name:        "golang",
+			DisplayName: "Go Programming",
+			Description: ptr("All things Go"),
+			IconURL:     ptr("https://cdn.example/icons/golang.png"),
+			IsPublic:    ptr(true),
+			IsNSFW:      ptr(false),
+			Topics:      []string{"programming"},
+			Language:    ptr("en"),
+		},
+		subreddit.UpdateSubredditRequest{
+			DisplayName:       ptr("Go"),
+			Description:       set("Gophers welcome"),
+			IconURL:           set("https://cdn.example/icons/go.png"),
+			IsPublic:          ptr(false),
+			IsNSFW:            ptr(false),
+			Topics:            &[]string{"programming"},
+			DescriptionMaxLen: set(1000),
+			BannerURL:         set("https://cdn.example/banners/go.png"),
+			PrimaryColor:      set("#00ADD8"),
+			BannerColor:       set("#5DC9E2"),
+			Language:          set("en"),
+		},
+		subreddit.BatchMembershipRequest{SubredditIDs: []uuid.UUID{sampleID}},
+		batchResult,
+		subreddit.BatchMembershipResponse{Results: []subreddit.BatchMembershipResult{batchResult}},
+		subreddit.CreateTopicRequest{Slug: "programming", Name: "Programming", IsSensitive: ptr(false)},
+		subreddit.UpdateTopicRequest{Name: ptr("Programming"), IsSensitive: ptr(true)},
+		subreddit.ValidationError{Field: "display_name", Message: "display_name must be at most 100 characters"},
+
+		user.PublicUserResponse{Email: "gopher@example.com", Username: "gopher_42"},
+		user.MeResponse{
+			Email:           "gopher@example.com",
+			Username:        "gopher_42",
+			AvatarURL:       ptr("https://cdn.example/avatars/gopher_42.png"),
+			AuthProvider:    user.AuthProviderEmail,
+			LinkedProviders: []user.AuthProvider{user.AuthProviderEmail, user.AuthProviderGoogle},
+		},
+		user.SettingsResponse{
+			DefaultFeedSort: "hot", ShowNSFW: false, EmailOnReply: true, EmailOnMention: true,
+			ContentLanguage: ptr("en"), Theme: "dark",
+		},
+		user.UpdateSettingsRequest{
+			DefaultFeedSort: ptr("new"), ShowNSFW: ptr(true), EmailOnReply: ptr(false), EmailOnMention: ptr(false),
+			ContentLanguage: set("uk"), Theme: ptr("light"),
+		},
+		user.RetentionReport{ScrubCutoff: sampleTime, ScrubDue: 4, HardDeleteCutoff: &sampleTime, HardDeleteDue: 1},
+		user.ValidationError{Field: "theme", Message: "theme must be one of light, dark, system"},
+
+		pagination,
+		utils.APIError{Error: "Subreddit not found", Code: "not_found"},
+	}
+}
+
+// dtoName is "<package>.<Type>", matching the golden file names
+func dtoName(t reflect.Type) string {
+	return path.Base(t.PkgPath()) + "." + t.Name()
+}
+
+func TestDTOGoldens(t *testing.T) {
+	for _, sample := range dtoSamples() {
+		name := dtoName(reflect.TypeOf(sample))
+		snake, err := json.Marshal(sample)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		camel, err := utils.ConvertJSONKeys(snake, utils.SnakeToCamel)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		checkGolden(t, filepath.Join("testdata", "dto", name+".snake.json"), snake)
+		checkGolden(t, filepath.Join("testdata", "dto", name+".camel.json"), camel)
+
+		// Request bodies are bound from either convention, see utils.BindJSON
+		back, err := utils.ConvertJSONKeys(camel, utils.CamelToSnake)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(back, snake) {
+			t.Errorf("%s camelCase doesn't map back:\n got %s\nwant %s", name, back, snake)
+		}
+	}
+}
+
+func checkGolden(t *testing.T, goldenPath string, got []byte) {
+	t.Helper()
+
+	if *updateGolden {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, got, "", "  "); err != nil {
+			t.Fatal(err)
+		}
+		pretty.WriteByte('\n')
+		if err := os.WriteFile(goldenPath, pretty.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	var want bytes.Buffer
+	if err := json.Compact(&want, golden); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("%s:\n got %s\nwant %s", goldenPath, got, want.Bytes())
+	}
+}
+
+// A new DTO must get a sample (and so goldens) or be listed as internal, a removed one must be dropped
+func TestDTOSamplesCoverEveryDTO(t *testing.T) {
+	sampled := make(map[string]bool)
+	for _, sample := range dtoSamples() {
+		sampled[dtoName(reflect.TypeOf(sample))] = true
+	}
+
+	files, err := filepath.Glob(filepath.Join("..", "*", "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	declared := make(map[string]bool)
+	for _, file := range files {
+		if strings.HasSuffix(file, "_test.go") || strings.Contains(file, "testutil") {
+			continue
+		}
+		parsed, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, decl := range parsed.Decls {
+			general, ok := decl.(*ast.GenDecl)
+			if !ok || general.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range general.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				structType, ok := typeSpec.Type.(*ast.StructType)
+				if ok && typeSpec.Name.IsExported() && hasJSONTag(structType) {
+					declared[parsed.Name.Name+"."+typeSpec.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	for name := range declared {
+		if _, internal := internalTypes[name]; !sampled[name] && !internal {
+			t.Errorf("%s has no sample in dtoSamples (or entry in internalTypes), add one and run with -update", name)
+		}
+	}
+	for name := range sampled {
+		if !declared[name] {
+			t.Errorf("%s is sampled but no longer declared", name)
+		}
+	}
+	for name := range internalTypes {
+		if !declared[name] {
+			t.Errorf("internalTypes lists %s, which no longer exists", name)
+		}
+	}
+}
+
+func hasJSONTag(structType *ast.StructType) bool {
+	for _, field := range structType.Fields.List {
+		if field.Tag != nil && strings.Contains(field.Tag.Value, `json:"`) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Keys inside maps are data, the camelCase rewrite may only skip them if the field is in utils.VerbatimFields.
+// A listed name must not be used for a DTO-valued field either, its keys would stay snake_case.
+func TestVerbatimFieldsMatchDTOs(t *testing.T) {
+	used := make(map[string]bool)
+	for _, sample := range dtoSamples() {
+		sampleType := reflect.TypeOf(sample)
+		checkVerbatimFields(t, dtoName(sampleType), sampleType, used)
+	}
+	for name := range utils.VerbatimFields {
+		if !used[name] {
+			t.Errorf("utils.VerbatimFields lists %q, which no DTO field uses", name)
+		}
+	}
+}
+
+func checkVerbatimFields(t *testing.T, dto string, structType reflect.Type, used map[string]bool) {
+	t.Helper()
+
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		if field.Anonymous {
+			checkVerbatimFields(t, dto, field.Type, used)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		_, listed := utils.VerbatimFields[name]
+
+		valueType := field.Type
+		for valueType.Kind() == reflect.Pointer || valueType.Kind() == reflect.Slice && valueType != rawMessageType {
+			valueType = valueType.Elem()
+		}
+		switch {
+		case valueType == rawMessageType || valueType.Kind() == reflect.Map || valueType.Kind() == reflect.Interface:
+			used[name] = true
+			if !listed {
+				t.Errorf("%s.%s holds keys that aren't field names, add %q to utils.VerbatimFields", dto, field.Name, name)
+			}
+		case valueType.Kind() == reflect.Struct && !valueType.Implements(jsonMarshalerType) &&
+			!valueType.Implements(textMarshalerType):
+			if listed {
+				t.Errorf("%s.%s is a DTO but %q is in utils.VerbatimFields, its keys wouldn't be converted", dto, field.Name, name)
+			}
+			checkVerbatimFields(t, dto, valueType, used)
+		}
+	}
+}
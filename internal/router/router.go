@@ -1,9 +1,17 @@
 package router
 
 import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/admin"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/auth"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/outbox"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/subreddit"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
@@ -11,33 +19,119 @@ import (
 )
 
 func SetupRouter(cfg *config.Config) *gin.Engine {
+	clock := utils.RealClock{}
+
 	// Infrastructure layer - Database
-	db := database.Connect(&cfg.Database)
+	db := database.Connect(&cfg.Database, clock)
 	// Infrastructure layer - Redis (singleton)
 	redisClient := database.ConnectRedisClient(&cfg.Redis)
+	// Infrastructure layer - Email, optional: without a configured transport email routes aren't registered
+	emailSender := newEmailSender(&cfg.Email, clock)
+	email.RunStartupCheck(&cfg.Email)
+
+	txManager := database.NewTxManager(db)
 
 	// Data layer - Repositories
-	userRepo := user.NewRepository(db)
-	subredditRepo := subreddit.NewRepository(db)
+	userRepo := user.NewRepository(db, cfg.Database.QueryTimeout)
+	subredditRepo := subreddit.NewRepository(db, cfg.Database.QueryTimeout)
+	adminRepo := admin.NewRepository(db, cfg.Database.QueryTimeout)
+	outboxRepo := outbox.NewRepository(db, cfg.Database.QueryTimeout)
 
 	// Domain layer - Services
-	userService := user.NewService(userRepo)
-	authService := auth.NewService(userService, cfg.Google, redisClient)
-	subredditService := subreddit.NewService(subredditRepo)
+	flagStore := flags.NewStore(
+		redisClient,
+		map[string]int64{
+			flags.MaintenanceReadOnly:   flags.BoolValue(cfg.Maintenance.ReadOnly),
+			flags.TopicPageCacheSoftTTL: int64(cfg.Subreddit.TopicPageCache.SoftTTL / time.Second),
+		},
+		clock,
+	)
+	flags.SetDefault(flagStore)
+	flagStore.Listen(context.Background())
+	outboxPublisher := outbox.NewPublisher(outboxRepo)
+	outboxRelay := outbox.NewRelay(outboxRepo, txManager, redisClient, cfg.Outbox, clock)
+	outboxRelay.Run(context.Background())
+	outboxRelay.RunRetention(context.Background())
+	userService := user.NewService(
+		userRepo,
+		txManager,
+		cfg.Subreddit.AllowedLanguages,
+		cfg.Auth.EmailNormalization,
+		cfg.Retention,
+		clock,
+	)
+	userService.RunRetention(context.Background())
+	authService := auth.NewService(userService, cfg.Google, redisClient, cfg.Auth, clock)
+	subredditService := subreddit.NewService(
+		subredditRepo,
+		userService,
+		txManager,
+		outboxPublisher,
+		adminRepo,
+		redisClient,
+		cfg.Subreddit,
+		clock,
+	)
+	subredditService.ListenForCacheInvalidation(context.Background())
+	subredditService.RunDeletionPurge(context.Background())
+	adminService := admin.NewService(
+		adminRepo,
+		txManager,
+		subredditService,
+		userService,
+		redisClient,
+		flagStore,
+		cfg.Audit,
+		clock,
+	)
+	adminService.MigrateReadOnlyFlag(context.Background())
+	adminService.RunAuditRetention(context.Background())
 
 	// Presentation layer - Handlers
 	userHandler := user.NewHandler(userService, cfg)
 	authHandler := auth.NewHandler(authService, cfg)
 	subredditHandler := subreddit.NewHandler(subredditService, cfg)
+	adminHandler := admin.NewHandler(adminService, userService, subredditService, emailSender, cfg)
 
 	// Router setup
-	router := gin.Default()
+	router := newEngine()
 	router.Use(utils.CORS(&cfg.Server.Cors))
+	router.Use(utils.RequestTimeout(cfg.Server.RequestTimeout))
+	router.Use(utils.JSONGuardMiddleware(&cfg.Server))
+	router.Use(utils.JSONConvention()) // Global, so it rewrites what coalesced or cached handlers wrote as well
+	router.Use(admin.ReadOnlyMiddleware(adminService))
+	if cfg.Database.Replica.Enabled() {
+		router.Use(utils.ReadYourWrites(redisClient, &cfg.JWT, clock, cfg.Database.Replica.StickyWindow))
+	}
 
 	// Register domain routes
-	user.RegisterRoutes(router, userHandler)
-	auth.RegisterRoutes(router, authHandler)
-	subreddit.RegisterRoutes(router, subredditHandler)
+	user.RegisterRoutes(router, userHandler, clock)
+	auth.RegisterRoutes(router, authHandler, redisClient, clock)
+	subreddit.RegisterRoutes(router, subredditHandler, clock)
+	admin.RegisterRoutes(router, adminHandler, clock)
+
+	return router
+}
 
+// newEngine answers unknown paths and wrong methods with the same JSON errors as the rest of the API,
+// and marks every response no-store unless a route group declares its own cache policy
+func newEngine() *gin.Engine {
+	router := gin.Default()
+	router.HandleMethodNotAllowed = true
+	router.NoRoute(utils.NotFoundHandler)
+	router.NoMethod(utils.MethodNotAllowedHandler)
+	router.Use(utils.CacheControl(utils.CachePolicyNoStore)) // Groups serving public static objects override it
 	return router
 }
+
+// newEmailSender returns nil while email is unconfigured, handlers register their email routes only with a sender
+func newEmailSender(cfg *config.EmailConfig, clock utils.Clock) email.Sender {
+	if !cfg.Configured() {
+		return nil
+	}
+	sender, err := email.NewSender(cfg, clock)
+	if err != nil {
+		log.Fatalln("Invalid email config:", err)
+	}
+	return sender
+}
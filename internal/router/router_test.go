@@ -0,0 +1,90 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestUnmatchedRoutesAnswerJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newEngine()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/subreddits/:id", ok)
+	router.PATCH("/subreddits/:id", ok)
+	router.DELETE("/subreddits/:id", ok)
+
+	tests := []struct {
+		method, path string
+		want         int
+		code         string
+		allow        []string
+	}{
+		{http.MethodGet, "/nowhere", http.StatusNotFound, "not_found", nil},
+		{http.MethodPost, "/subreddits/x/nowhere", http.StatusNotFound, "not_found", nil},
+		{
+			http.MethodPost, "/subreddits/x", http.StatusMethodNotAllowed, "method_not_allowed",
+			[]string{http.MethodGet, http.MethodPatch, http.MethodDelete},
+		},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+		if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			t.Errorf("%s %s: Content-Type %q, want JSON", tt.method, tt.path, contentType)
+		}
+		var body utils.APIError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != tt.code || body.Error == "" {
+			t.Errorf("%s %s: body %s (%v), want an APIError with code %s", tt.method, tt.path, rec.Body, err, tt.code)
+		}
+
+		allow := rec.Header().Get("Allow")
+		for _, method := range tt.allow {
+			if !strings.Contains(allow, method) {
+				t.Errorf("%s %s: Allow %q is missing %s", tt.method, tt.path, allow, method)
+			}
+		}
+		if tt.allow == nil && allow != "" {
+			t.Errorf("%s %s: Allow %q on a 404", tt.method, tt.path, allow)
+		}
+	}
+}
+
+func TestCachePolicyPerGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newEngine()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) }
+	router.GET("/me", ok)
+	router.GET("/subreddits/:id", ok)
+	router.GET("/feed", ok)
+	static := router.Group("/static", utils.CacheControl(utils.CachePolicyImmutable))
+	static.GET("/icons/:hash", ok)
+
+	tests := []struct {
+		method, path, want string
+	}{
+		{http.MethodGet, "/me", "no-store"},
+		{http.MethodGet, "/feed", "no-store"},
+		{http.MethodGet, "/subreddits/x", "no-store"},
+		{http.MethodGet, "/static/icons/3f2a9c", "public, max-age=31536000, immutable"},
+		// Fallbacks go through the engine-wide default too, a group's policy doesn't leak out of it
+		{http.MethodGet, "/static/nowhere", "no-store"},
+		{http.MethodPost, "/me", "no-store"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if got := rec.Header().Values("Cache-Control"); len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%s %s: Cache-Control %q, want exactly %q", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
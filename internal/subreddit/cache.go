@@ -0,0 +1,139 @@
+package subreddit
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	LiteCacheLocalSize   = 1000
+	LiteCacheLocalTTL    = 30 * time.Second
+	LiteCacheRedisTTL    = 5 * time.Minute
+	LiteCacheRedisPrefix = "subreddit:lite:"
+	// Every instance subscribes here and drops its local entry for the published subreddit ID
+	LiteCacheInvalidateChannel = "subreddit:lite:invalidate"
+)
+
+// liteCache is a read-through cache for SubredditLite: process-local LRU, then Redis, then Postgres
+type liteCache struct {
+	local *utils.LRU[uuid.UUID, SubredditLite]
+	redis *redis.Client
+}
+
+func newLiteCache(redisClient *redis.Client, clock utils.Clock) *liteCache {
+	return &liteCache{
+		local: utils.NewLRU[uuid.UUID, SubredditLite](LiteCacheLocalSize, LiteCacheLocalTTL, clock),
+		redis: redisClient,
+	}
+}
+
+// get resolves ids in tiers and returns the hits plus the IDs that have to come from the DB
+func (c *liteCache) get(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]SubredditLite, []uuid.UUID) {
+	found := make(map[uuid.UUID]SubredditLite, len(ids))
+	var remote []uuid.UUID
+	for _, id := range ids {
+		if lite, ok := c.local.Get(id); ok {
+			found[id] = lite
+			continue
+		}
+		remote = append(remote, id)
+	}
+
+	if len(remote) == 0 || c.redis == nil {
+		return found, remote
+	}
+
+	keys := make([]string, len(remote))
+	for i, id := range remote {
+		keys[i] = LiteCacheRedisPrefix + id.String()
+	}
+	values, err := c.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Println("Failed to read subreddit cache:", err)
+		return found, remote
+	}
+
+	var missing []uuid.UUID
+	for i, value := range values {
+		raw, ok := value.(string)
+		var lite SubredditLite
+		if !ok || json.Unmarshal([]byte(raw), &lite) != nil {
+			missing = append(missing, remote[i])
+			continue
+		}
+		found[lite.ID] = lite
+		c.local.Set(lite.ID, lite)
+	}
+
+	return found, missing
+}
+
+func (c *liteCache) set(ctx context.Context, lites []SubredditLite) {
+	for _, lite := range lites {
+		c.local.Set(lite.ID, lite)
+	}
+
+	if len(lites) == 0 || c.redis == nil {
+		return
+	}
+
+	pipe := c.redis.Pipeline()
+	for _, lite := range lites {
+		payload, err := json.Marshal(lite)
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, LiteCacheRedisPrefix+lite.ID.String(), payload, LiteCacheRedisTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Println("Failed to write subreddit cache:", err)
+	}
+}
+
+// invalidate drops the entry everywhere: locally, in Redis and (via pub/sub) on other instances
+func (c *liteCache) invalidate(ctx context.Context, id uuid.UUID) {
+	c.local.Delete(id)
+
+	if c.redis == nil {
+		return
+	}
+	if err := c.redis.Del(ctx, LiteCacheRedisPrefix+id.String()).Err(); err != nil {
+		log.Println("Failed to invalidate subreddit cache:", err)
+	}
+	if err := c.redis.Publish(ctx, LiteCacheInvalidateChannel, id.String()).Err(); err != nil {
+		log.Println("Failed to publish subreddit cache invalidation:", err)
+	}
+}
+
+// listen evicts local entries invalidated by other instances until ctx is done
+func (c *liteCache) listen(ctx context.Context) {
+	if c.redis == nil {
+		return
+	}
+
+	pubsub := c.redis.Subscribe(ctx, LiteCacheInvalidateChannel)
+	go func() {
+		defer pubsub.Close()
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				if id, err := uuid.Parse(message.Payload); err == nil {
+					c.local.Delete(id)
+				}
+			}
+		}
+	}()
+}
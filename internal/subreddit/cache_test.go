@@ -0,0 +1,88 @@
+package subreddit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const feedPageSize = 25 // Distinct subreddits on a busy feed page
+
+func feedLites(n int) ([]SubredditLite, []uuid.UUID) {
+	lites := make([]SubredditLite, n)
+	ids := make([]uuid.UUID, n)
+	for i := range lites {
+		ids[i] = uuid.New()
+		lites[i] = SubredditLite{ID: ids[i], Name: "community", IsPublic: true}
+	}
+	return lites, ids
+}
+
+func newBenchLiteCache(tb testing.TB) *liteCache {
+	tb.Helper()
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(tb).Addr()})
+	return newLiteCache(client, utils.RealClock{})
+}
+
+// A Service without a repository panics if the cached path ever reaches Postgres
+func TestGetLiteByIDsCachedPathSkipsDatabase(t *testing.T) {
+	cache := newBenchLiteCache(t)
+	lites, ids := feedLites(feedPageSize)
+	cache.set(context.Background(), lites)
+	service := &Service{liteCache: cache}
+
+	found, err := service.GetLiteByIDs(context.Background(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(ids) {
+		t.Fatalf("found %d of %d", len(found), len(ids))
+	}
+
+	// Only the result map (4 allocations today), nothing per ID and nothing from gorm
+	allocs := testing.AllocsPerRun(
+		100, func() {
+			_, _ = service.GetLiteByIDs(context.Background(), ids)
+		},
+	)
+	if allocs > 8 {
+		t.Errorf("local hit path allocates %.0f times per call, want a handful", allocs)
+	}
+}
+
+func BenchmarkGetLiteByIDsLocalHit(b *testing.B) {
+	cache := newBenchLiteCache(b)
+	lites, ids := feedLites(feedPageSize)
+	cache.set(context.Background(), lites)
+	service := &Service{liteCache: cache}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := service.GetLiteByIDs(ctx, ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGetLiteByIDsRedisHit(b *testing.B) {
+	cache := newBenchLiteCache(b)
+	lites, ids := feedLites(feedPageSize)
+	ctx := context.Background()
+	cache.set(ctx, lites)
+	service := &Service{liteCache: cache}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, id := range ids {
+			cache.local.Delete(id) // Another instance's view: only Redis is warm
+		}
+		if _, err := service.GetLiteByIDs(ctx, ids); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package subreddit
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
@@ -25,7 +28,45 @@ func NewHandler(service *Service, cfg *config.Config) *Handler {
 }
 
 func (h *Handler) GetSubredditList(c *gin.Context) {
-	subreddits, err := h.service.GetSubredditList(c.Request.Context())
+	sort, err := utils.ParseSort(c.Query("sort"), c.Query("order"), ListSortFields, DefaultListSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, ok := utils.PaginationFromQuery(c)
+	if !ok {
+		return // Error response already sent
+	}
+	filter, err := h.service.ParseListFilter(c.Query("lang"), c.Query("since"), c.Query("until"))
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err,
+			},
+		)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var subreddits []Subreddit
+	var total int64
+
+	// `mine` is ignored for anonymous requests, they always get the public list
+	userID, authenticated := h.optionalUserID(c)
+	mine := c.Query("mine")
+	switch {
+	case !authenticated || mine == "":
+		subreddits, total, err = h.service.GetSubredditList(ctx, sort, page, filter)
+	case mine == MineFilterCreated:
+		subreddits, total, err = h.service.GetCreatedSubreddits(ctx, userID, sort, page, filter)
+	case mine == MineFilterJoined || mine == MineFilterAll:
+		subreddits, total, err = h.service.GetJoinedSubreddits(ctx, userID, sort, page, filter)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mine must be one of: created, joined, all"})
+		return
+	}
+
 	if err != nil {
 		c.JSON(
 			http.StatusInternalServerError, gin.H{
@@ -34,23 +75,209 @@ func (h *Handler) GetSubredditList(c *gin.Context) {
 		)
 		return
 	}
-	response := ToSubredditListResponse(subreddits)
+	response := ToSubredditListResponse(subreddits, page.Meta(total))
 	c.JSON(http.StatusOK, response)
 }
 
+// optionalUserID reads the user set by utils.OptionalJWTAuthMiddleware without writing an error response
+func (h *Handler) optionalUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
 func (h *Handler) GetSubreddit(c *gin.Context) {
-	subredditIDString := c.Param("id")
-	subredditID, err := uuid.Parse(subredditIDString)
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	subreddit, err := h.service.GetSubredditById(c.Request.Context(), subredditID, viewerID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
 		c.JSON(
-			http.StatusBadRequest, gin.H{
-				"error": "Invalid subreddit ID",
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch subreddit",
+			},
+		)
+		return
+	}
+
+	response := ToSubredditResponse(subreddit)
+	response.MemberSince = h.memberSince(c, subreddit.ID)
+	h.addShareStats(c, subreddit, &response)
+	c.JSON(http.StatusOK, response)
+}
+
+// HeadSubreddit answers existence checks with 200 or 404 and no body
+func (h *Handler) HeadSubreddit(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	exists, err := h.service.SubredditExists(c.Request.Context(), subredditID, viewerID)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// memberSince is the authenticated viewer's join date, nil for anonymous viewers and non-members
+func (h *Handler) memberSince(c *gin.Context, subredditID uuid.UUID) *time.Time {
+	userID, authenticated := h.optionalUserID(c)
+	if !authenticated {
+		return nil
+	}
+
+	member, err := h.service.GetMembership(c.Request.Context(), subredditID, userID)
+	if err != nil {
+		return nil // Best effort, the subreddit itself is still served
+	}
+	return &member.CreatedAt
+}
+
+// addShareStats fills the share counters for the community's moderators (its creator for now)
+func (h *Handler) addShareStats(c *gin.Context, subreddit *Subreddit, response *SubredditResponse) {
+	userID, authenticated := h.optionalUserID(c)
+	if !authenticated || userID != subreddit.CreatorID {
+		return
+	}
+	response.ShareCount = &subreddit.ShareCount
+	response.ClickCount = &subreddit.ClickCount
+}
+
+func (h *Handler) GetMySubreddits(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	sort, err := utils.ParseSort(c.Query("sort"), c.Query("order"), ListSortFields, DefaultListSort)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, ok := utils.PaginationFromQuery(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	memberships, total, err := h.service.GetMemberships(c.Request.Context(), userID, sort, page)
+	if err != nil {
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch subreddits",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToJoinedSubredditListResponse(memberships, page.Meta(total)))
+}
+
+// RecountMySubreddits recounts the member counts of the caller's subreddits and reports what it corrected
+func (h *Handler) RecountMySubreddits(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	summary, err := h.service.RecountJoinedMemberCounts(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to recount member counts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *Handler) GetMembership(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	member, err := h.service.GetMembership(c.Request.Context(), subredditID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusOK, MembershipResponse{IsMember: false})
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch membership",
 			},
 		)
 		return
 	}
 
-	subreddit, err := h.service.GetSubredditById(c.Request.Context(), subredditID)
+	c.JSON(http.StatusOK, MembershipResponse{IsMember: true, JoinedAt: &member.CreatedAt})
+}
+
+func (h *Handler) GetMembers(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	page, ok := utils.PaginationFromQuery(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	members, total, err := h.service.GetMembers(c.Request.Context(), subredditID, viewerID, page)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch members",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToMemberListResponse(members, page.Meta(total)))
+}
+
+// GetSubredditByName serves name-based lookups. The ID-based URL is the canonical one and is
+// advertised via Content-Location; a name in the wrong casing is redirected to the stored one.
+func (h *Handler) GetSubredditByName(c *gin.Context) {
+	name := c.Param("name")
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	subreddit, err := h.service.GetSubredditByName(c.Request.Context(), name, viewerID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(
@@ -68,13 +295,148 @@ func (h *Handler) GetSubreddit(c *gin.Context) {
 		return
 	}
 
+	if subreddit.Name != name {
+		location := "/subreddits/by-name/" + url.PathEscape(subreddit.Name)
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, location)
+		return
+	}
+
 	response := ToSubredditResponse(subreddit)
+	response.MemberSince = h.memberSince(c, subreddit.ID)
+	h.addShareStats(c, subreddit, &response)
+
+	c.Header("Content-Location", "/subreddits/"+subreddit.ID.String())
 	c.JSON(http.StatusOK, response)
 }
 
+func (h *Handler) GetWikiPage(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	page, err := h.service.GetWikiPage(c.Request.Context(), subredditID, c.Param("slug"), viewerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Wiki page not found",
+				},
+			)
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch wiki page",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToWikiPageResponse(page))
+}
+
+func (h *Handler) PutWikiPage(c *gin.Context) {
+	var req PutWikiPageRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(
+			http.StatusBadRequest, gin.H{
+				"error": "Invalid request body",
+			},
+		)
+		return
+	}
+
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	page, err := h.service.PutWikiPage(c.Request.Context(), subredditID, userID, c.Param("slug"), req.Content)
+	if err != nil {
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": validationErrs,
+				},
+			)
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
+		if errors.Is(err, ErrNotAuthorized) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to save wiki page",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToWikiPageResponse(page))
+}
+
+func (h *Handler) GetSubredditsByTopic(c *gin.Context) {
+	page, ok := utils.PaginationFromQuery(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	subreddits, total, err := h.service.GetSubredditsByTopic(
+		c.Request.Context(),
+		c.Param("slug"),
+		page,
+		viewerID,
+	)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Topic not found"})
+			return
+		}
+		if errors.Is(err, ErrSensitiveTopic) {
+			c.JSON(
+				http.StatusForbidden, utils.APIError{
+					Error: "Enable NSFW content to browse this topic",
+					Code:  "nsfw_required",
+				},
+			)
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to fetch subreddits",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, ToSubredditListResponse(subreddits, page.Meta(total)))
+}
+
 func (h *Handler) CreateSubreddit(c *gin.Context) {
 	var req CreateSubredditRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.BindJSON(c, &req); err != nil {
 		c.JSON(
 			http.StatusBadRequest, gin.H{
 				"error": "Invalid request body",
@@ -108,6 +470,8 @@ func (h *Handler) CreateSubreddit(c *gin.Context) {
 		req.IconURL,
 		isPublic,
 		isNSFW,
+		req.Topics,
+		req.Language,
 	)
 
 	if err != nil {
@@ -122,6 +486,19 @@ func (h *Handler) CreateSubreddit(c *gin.Context) {
 			return
 		}
 
+		if errors.Is(err, ErrUnavailable) {
+			c.JSON(
+				http.StatusServiceUnavailable, gin.H{
+					"error": "Service temporarily unavailable, please retry",
+				},
+			)
+			return
+		}
+		if errors.Is(err, ErrMembershipLimitReached) {
+			c.JSON(http.StatusForbidden, h.membershipLimitError()) // The creator becomes a member
+			return
+		}
+
 		c.JSON(
 			http.StatusInternalServerError, gin.H{
 				"error": "Failed to create subreddit",
@@ -136,7 +513,7 @@ func (h *Handler) CreateSubreddit(c *gin.Context) {
 
 func (h *Handler) UpdateSubreddit(c *gin.Context) {
 	var req UpdateSubredditRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := utils.BindJSON(c, &req); err != nil {
 		c.JSON(
 			http.StatusBadRequest, gin.H{
 				"error": "Invalid request body",
@@ -145,15 +522,9 @@ func (h *Handler) UpdateSubreddit(c *gin.Context) {
 		return
 	}
 
-	subredditIDString := c.Param("id")
-	subredditID, err := uuid.Parse(subredditIDString)
-	if err != nil {
-		c.JSON(
-			http.StatusBadRequest, gin.H{
-				"error": "Invalid subreddit ID",
-			},
-		)
-		return
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
 	}
 	userID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
@@ -197,22 +568,61 @@ func (h *Handler) UpdateSubreddit(c *gin.Context) {
 }
 
 func (h *Handler) DeleteSubreddit(c *gin.Context) {
-	subredditIDString := c.Param("id")
-	subredditID, err := uuid.Parse(subredditIDString)
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	purgeAt, err := h.service.DeleteSubreddit(c.Request.Context(), subredditID, userID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
+		if errors.Is(err, ErrNotAuthorized) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
+			return
+		}
+		if errors.Is(err, ErrDeletionScheduled) {
+			c.JSON(
+				http.StatusConflict, utils.APIError{
+					Error: "Deletion of this community is already scheduled",
+					Code:  "deletion_already_scheduled",
+				},
+			)
+			return
+		}
 		c.JSON(
-			http.StatusBadRequest, gin.H{
-				"error": "Invalid subreddit ID",
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete subreddit",
 			},
 		)
 		return
 	}
+
+	c.JSON(http.StatusAccepted, gin.H{"purge_at": purgeAt})
+}
+
+// CancelDeletion restores a subreddit whose deletion grace period hasn't ended yet
+func (h *Handler) CancelDeletion(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
 	userID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
 		return // Error response already sent
 	}
 
-	err = h.service.DeleteSubreddit(c.Request.Context(), subredditID, userID)
+	err := h.service.CancelDeletion(c.Request.Context(), subredditID, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(
@@ -226,9 +636,18 @@ func (h *Handler) DeleteSubreddit(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
 			return
 		}
+		if errors.Is(err, ErrDeletionNotScheduled) {
+			c.JSON(
+				http.StatusConflict, utils.APIError{
+					Error: "Deletion of this community is not scheduled",
+					Code:  "deletion_not_scheduled",
+				},
+			)
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError, gin.H{
-				"error": "Failed to fetch subreddits",
+				"error": "Failed to cancel subreddit deletion",
 			},
 		)
 		return
@@ -237,23 +656,141 @@ func (h *Handler) DeleteSubreddit(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
-func (h *Handler) JoinSubreddit(c *gin.Context) {
-	subredditIDString := c.Param("id")
-	subredditID, err := uuid.Parse(subredditIDString)
+func (h *Handler) DeleteIcon(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	err := h.service.RemoveIcon(c.Request.Context(), subredditID, userID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
+		if errors.Is(err, ErrNotAuthorized) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to remove subreddit icon",
+			},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) DeleteBanner(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	err := h.service.RemoveBanner(c.Request.Context(), subredditID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(
+				http.StatusNotFound, gin.H{
+					"error": "Subreddit not found",
+				},
+			)
+			return
+		}
+		if errors.Is(err, ErrNotAuthorized) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You cannot perform this action"})
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to remove subreddit banner",
+			},
+		)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) BatchJoinSubreddits(c *gin.Context) {
+	h.batchMembership(c, h.service.BatchJoin)
+}
+
+func (h *Handler) BatchLeaveSubreddits(c *gin.Context) {
+	h.batchMembership(c, h.service.BatchLeave)
+}
+
+// batchMembership always answers 200 once the batch itself is valid, per-ID failures are in the results
+func (h *Handler) batchMembership(
+	c *gin.Context,
+	operation func(ctx context.Context, subredditIDs []uuid.UUID, userID uuid.UUID) (
+		[]BatchMembershipResult,
+		error,
+	),
+) {
+	var req BatchMembershipRequest
+	if err := utils.BindJSON(c, &req); err != nil {
 		c.JSON(
 			http.StatusBadRequest, gin.H{
-				"error": "Invalid subreddit ID",
+				"error": "Invalid request body",
 			},
 		)
 		return
 	}
+
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	results, err := operation(c.Request.Context(), req.SubredditIDs, userID)
+	if err != nil {
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": validationErrs,
+				},
+			)
+			return
+		}
+		c.JSON(
+			http.StatusInternalServerError, gin.H{
+				"error": "Failed to process batch",
+			},
+		)
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchMembershipResponse{Results: results})
+}
+
+func (h *Handler) JoinSubreddit(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
 	userID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	err = h.service.JoinSubreddit(c.Request.Context(), subredditID, userID)
+	err := h.service.JoinSubreddit(c.Request.Context(), subredditID, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(
@@ -263,6 +800,14 @@ func (h *Handler) JoinSubreddit(c *gin.Context) {
 			)
 			return
 		}
+		if errors.Is(err, ErrMembershipLimitReached) {
+			c.JSON(http.StatusForbidden, h.membershipLimitError())
+			return
+		}
+		if errors.Is(err, ErrSubredditPrivate) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Private subreddits can't be joined"})
+			return
+		}
 		c.JSON(
 			http.StatusInternalServerError, gin.H{
 				"error": fmt.Sprintf("Failed to fetch subreddits: %s", err.Error()),
@@ -273,23 +818,69 @@ func (h *Handler) JoinSubreddit(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Joined subreddit successfully"})
 }
 
-func (h *Handler) LeaveSubreddit(c *gin.Context) {
-	subredditIDString := c.Param("id")
-	subredditID, err := uuid.Parse(subredditIDString)
+func (h *Handler) membershipLimitError() utils.APIError {
+	return utils.APIError{
+		Error: fmt.Sprintf(
+			"You can be a member of at most %d communities. Leave one to join another.",
+			h.config.Subreddit.MaxMembershipsPerUser,
+		),
+		Code: "membership_limit_reached",
+	}
+}
+
+func (h *Handler) ShareSubreddit(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return
+	}
+
+	code, err := h.service.ShareSubreddit(c.Request.Context(), subredditID, userID)
 	if err != nil {
-		c.JSON(
-			http.StatusBadRequest, gin.H{
-				"error": "Invalid subreddit ID",
-			},
-		)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subreddit not found"})
+			return
+		}
+		if errors.Is(err, ErrSharingUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Sharing is temporarily unavailable"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share subreddit"})
 		return
 	}
+	c.JSON(http.StatusOK, ShareResponse{Code: code, URL: "/s/" + code})
+}
+
+// ResolveShareLink redirects a short link to the community page on the frontend
+func (h *Handler) ResolveShareLink(c *gin.Context) {
+	viewerID, _ := h.optionalUserID(c) // uuid.Nil for anonymous viewers
+
+	subreddit, err := h.service.ResolveShareCode(c.Request.Context(), c.Param("code"), viewerID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Link not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve link"})
+		return
+	}
+	c.Redirect(http.StatusFound, ShareRedirectURL(h.config.Project.FrontendURL, subreddit.Name))
+}
+
+func (h *Handler) LeaveSubreddit(c *gin.Context) {
+	subredditID, ok := utils.ParseUUIDParam(c, "id")
+	if !ok {
+		return // Error response already sent
+	}
 	userID, ok := utils.GetUserIDFromContext(c)
 	if !ok {
 		return
 	}
 
-	err = h.service.LeaveSubreddit(c.Request.Context(), subredditID, userID)
+	err := h.service.LeaveSubreddit(c.Request.Context(), subredditID, userID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(
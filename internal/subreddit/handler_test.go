@@ -0,0 +1,738 @@
+package subreddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// handlerEnv serves the subreddit routes on top of a testEnv
+type handlerEnv struct {
+	testEnv
+	engine *gin.Engine
+	cfg    *config.Config
+}
+
+func newHandlerEnv(t *testing.T, subredditCfg config.SubredditConfig) handlerEnv {
+	t.Helper()
+	return newHandlerEnvOn(t, newTestEnv(t, subredditCfg), subredditCfg)
+}
+
+func newHandlerEnvOn(t *testing.T, env testEnv, subredditCfg config.SubredditConfig) handlerEnv {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{
+		JWT:       config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)},
+		Subreddit: subredditCfg,
+	}
+	engine := gin.New()
+	RegisterRoutes(engine, NewHandler(env.service, cfg), utils.RealClock{})
+	return handlerEnv{testEnv: env, engine: engine, cfg: cfg}
+}
+
+// do sends body as JSON, signed in as userID unless it is uuid.Nil
+func (env handlerEnv) do(t *testing.T, method, path, body string, userID uuid.UUID) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if userID != uuid.Nil {
+		token, err := utils.GenerateJWT(
+			utils.RealClock{}, env.cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, userID.String(),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	env.engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDeleteIcon(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	member := env.createUser(t, "member")
+	iconURL := "https://cdn.example/icons/iconic.png"
+	sub, err := env.service.CreateSubreddit(ctx, creator, "iconic", "Iconic", nil, &iconURL, true, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.JoinSubreddit(ctx, sub.ID, member); err != nil {
+		t.Fatal(err)
+	}
+	// Cached before the removal, the lite projection must not keep serving the old icon
+	if lites, err := env.service.GetLiteByIDs(ctx, []uuid.UUID{sub.ID}); err != nil || lites[sub.ID].IconURL == nil {
+		t.Fatalf("GetLiteByIDs before = %v, %v", lites, err)
+	}
+
+	path := "/subreddits/" + sub.ID.String() + "/icon"
+	if rec := env.do(t, http.MethodDelete, path, "", member); rec.Code != http.StatusForbidden {
+		t.Errorf("DELETE icon as a member: status %d, want 403", rec.Code)
+	}
+	if rec := env.do(t, http.MethodDelete, path, "", uuid.Nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("DELETE icon anonymously: status %d, want 401", rec.Code)
+	}
+	if stored, err := env.service.repo.GetByID(ctx, sub.ID); err != nil || stored.IconURL == nil {
+		t.Fatalf("icon after rejected removals = %v, %v; want it kept", stored, err)
+	}
+
+	for range 2 { // Removing an already removed icon is a no-op
+		if rec := env.do(t, http.MethodDelete, path, "", creator); rec.Code != http.StatusNoContent {
+			t.Fatalf("DELETE icon as the creator: status %d (%s)", rec.Code, rec.Body)
+		}
+	}
+	stored, err := env.service.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.IconURL != nil {
+		t.Errorf("icon_url = %q, want NULL", *stored.IconURL)
+	}
+	if lites, err := env.service.GetLiteByIDs(ctx, []uuid.UUID{sub.ID}); err != nil || lites[sub.ID].IconURL != nil {
+		t.Errorf("GetLiteByIDs after = %v, %v; want the icon gone", lites, err)
+	}
+
+	if rec := env.do(t, http.MethodDelete, "/subreddits/"+uuid.NewString()+"/icon", "", creator); rec.Code != http.StatusNotFound {
+		t.Errorf("DELETE icon of an unknown subreddit: status %d, want 404", rec.Code)
+	}
+}
+
+// listNames returns the subreddit names of a GET /subreddits response, failing on a non-200 status
+func listNames(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	var response SubredditListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Pagination.Total != int64(len(response.Subreddits)) {
+		t.Errorf("pagination total %d for %d subreddits on a single page", response.Pagination.Total, len(response.Subreddits))
+	}
+	names := make([]string, len(response.Subreddits))
+	for i, subreddit := range response.Subreddits {
+		names[i] = subreddit.Name
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestSubredditListMineFilter(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	viewer := env.createUser(t, "viewer")
+	other := env.createUser(t, "other")
+	env.createSubreddit(t, viewer, "mine_public")
+	if _, err := env.service.CreateSubreddit(ctx, viewer, "mine_private", "Private", nil, nil, false, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	joined := env.createSubreddit(t, other, "joined")
+	env.createSubreddit(t, other, "unrelated")
+	if _, err := env.service.CreateSubreddit(ctx, other, "other_private", "Private", nil, nil, false, false, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.service.JoinSubreddit(ctx, joined, viewer); err != nil {
+		t.Fatal(err)
+	}
+
+	public := []string{"joined", "mine_public", "unrelated"}
+	tests := []struct {
+		query  string
+		viewer uuid.UUID
+		want   []string
+	}{
+		{"", viewer, public},
+		{"?mine=created", viewer, []string{"mine_private", "mine_public"}},
+		{"?mine=joined", viewer, []string{"joined", "mine_private", "mine_public"}},
+		{"?mine=all", viewer, []string{"joined", "mine_private", "mine_public"}},
+		// Anonymous requests ignore the flag, invalid values included
+		{"?mine=created", uuid.Nil, public},
+		{"?mine=joined", uuid.Nil, public},
+		{"?mine=bogus", uuid.Nil, public},
+	}
+	for _, tt := range tests {
+		got := listNames(t, env.do(t, http.MethodGet, "/subreddits"+tt.query, "", tt.viewer))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GET /subreddits%s (signed in %t) = %v, want %v", tt.query, tt.viewer != uuid.Nil, got, tt.want)
+		}
+	}
+
+	if rec := env.do(t, http.MethodGet, "/subreddits?mine=bogus", "", viewer); rec.Code != http.StatusBadRequest {
+		t.Errorf("mine=bogus signed in: status %d, want 400", rec.Code)
+	}
+
+	// Paginated like the public list
+	rec := env.do(t, http.MethodGet, "/subreddits?mine=joined&page=2&page_size=2", "", viewer)
+	var response SubredditListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Subreddits) != 1 || response.Pagination != (utils.PaginationMeta{Page: 2, PageSize: 2, Total: 3}) {
+		t.Errorf("page 2 of mine=joined = %d subreddits, %+v; want 1 of 3", len(response.Subreddits), response.Pagination)
+	}
+}
+
+func TestGetSubredditByNameCanonicalURL(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	creator := env.createUser(t, "creator")
+	subID := env.createSubreddit(t, creator, "GoLang")
+
+	rec := env.do(t, http.MethodGet, "/subreddits/by-name/GoLang", "", uuid.Nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("stored casing: status %d (%s)", rec.Code, rec.Body)
+	}
+	if got, want := rec.Header().Get("Content-Location"), "/subreddits/"+subID.String(); got != want {
+		t.Errorf("Content-Location = %q, want %q", got, want)
+	}
+	var response SubredditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID != subID || response.Name != "GoLang" {
+		t.Errorf("response = %s %q, want %s GoLang", response.ID, response.Name, subID)
+	}
+
+	redirects := []struct{ path, want string }{
+		{"/subreddits/by-name/golang", "/subreddits/by-name/GoLang"},
+		{"/subreddits/by-name/GOLANG", "/subreddits/by-name/GoLang"},
+		{"/subreddits/by-name/golang?utm_source=feed", "/subreddits/by-name/GoLang?utm_source=feed"},
+	}
+	for _, tt := range redirects {
+		rec := env.do(t, http.MethodGet, tt.path, "", uuid.Nil)
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.want {
+			t.Errorf("GET %s: status %d, Location %q; want 301 to %s", tt.path, rec.Code, rec.Header().Get("Location"), tt.want)
+		}
+		if rec.Header().Get("Content-Location") != "" {
+			t.Errorf("GET %s: redirect carries Content-Location", tt.path)
+		}
+	}
+
+	if rec := env.do(t, http.MethodGet, "/subreddits/by-name/gopher", "", uuid.Nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown name: status %d, want 404", rec.Code)
+	}
+}
+
+func TestUpdateDescriptionMaxLen(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	creator := env.createUser(t, "creator")
+	path := "/subreddits/" + env.createSubreddit(t, creator, "terse").String()
+	patch := func(body string) (int, SubredditResponse) {
+		t.Helper()
+		rec := env.do(t, http.MethodPatch, path, body, creator)
+		var response SubredditResponse
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec.Code, response
+	}
+	description := func(n int) string { return `{"description":"` + strings.Repeat("d", n) + `"}` }
+
+	if code, _ := patch(`{"description_max_len":` + strconv.Itoa(DescriptionHardCap+1) + `}`); code != http.StatusBadRequest {
+		t.Errorf("override past the hard cap: status %d, want 400", code)
+	}
+	if code, response := patch(`{"description_max_len":100}`); code != http.StatusOK || response.DescriptionMaxLen != 100 {
+		t.Fatalf("override below global: status %d, description_max_len %d", code, response.DescriptionMaxLen)
+	}
+	if code, _ := patch(description(101)); code != http.StatusBadRequest {
+		t.Errorf("description past the override: status %d, want 400", code)
+	}
+	if code, _ := patch(description(100)); code != http.StatusOK {
+		t.Errorf("description at the override: status %d, want 200", code)
+	}
+	if code, response := patch(`{"description_max_len":null}`); code != http.StatusOK || response.DescriptionMaxLen != DescriptionMaxLen {
+		t.Errorf("reset override: status %d, description_max_len %d, want %d", code, response.DescriptionMaxLen, DescriptionMaxLen)
+	}
+	if code, _ := patch(description(DescriptionMaxLen)); code != http.StatusOK {
+		t.Errorf("description at the global default after the reset: status %d, want 200", code)
+	}
+}
+
+// Leaving deletes the membership, so a rejoin starts over with a new join date everywhere it is shown
+func TestJoinLeaveRejoinDates(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	creator := env.createUser(t, "creator")
+	member := env.createUser(t, "member")
+	subID := env.createSubreddit(t, creator, "revolving")
+	path := "/subreddits/" + subID.String()
+
+	decode := func(rec *httptest.ResponseRecorder, v any) {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// joinDates returns the join date reported by the membership endpoint, the subreddit itself and
+	// GET /me/subreddits, failing unless all three agree
+	joinDates := func() *time.Time {
+		t.Helper()
+
+		var membership MembershipResponse
+		decode(env.do(t, http.MethodGet, path+"/membership", "", member), &membership)
+		var subreddit SubredditResponse
+		decode(env.do(t, http.MethodGet, path, "", member), &subreddit)
+		var mine JoinedSubredditListResponse
+		decode(env.do(t, http.MethodGet, "/me/subreddits", "", member), &mine)
+
+		if membership.IsMember != (membership.JoinedAt != nil) {
+			t.Errorf("membership = %+v, joined_at must come with is_member", membership)
+		}
+		var listed *time.Time
+		for _, item := range mine.Subreddits {
+			if item.ID == subID {
+				listed = &item.JoinedAt
+			}
+		}
+		for name, got := range map[string]*time.Time{"member_since": subreddit.MemberSince, "/me/subreddits": listed} {
+			if (got == nil) != (membership.JoinedAt == nil) || got != nil && !got.Equal(*membership.JoinedAt) {
+				t.Errorf("%s = %v, membership joined_at = %v", name, got, membership.JoinedAt)
+			}
+		}
+		return membership.JoinedAt
+	}
+
+	if joined := joinDates(); joined != nil {
+		t.Fatalf("joined_at %v before joining", joined)
+	}
+
+	if rec := env.do(t, http.MethodPost, path+"/join", "", member); rec.Code != http.StatusOK {
+		t.Fatalf("join: status %d (%s)", rec.Code, rec.Body)
+	}
+	firstJoined := joinDates()
+	if firstJoined == nil {
+		t.Fatal("no join date after joining")
+	}
+
+	if rec := env.do(t, http.MethodPost, path+"/leave", "", member); rec.Code != http.StatusOK {
+		t.Fatalf("leave: status %d (%s)", rec.Code, rec.Body)
+	}
+	if left := joinDates(); left != nil {
+		t.Errorf("joined_at %v after leaving", left)
+	}
+
+	time.Sleep(10 * time.Millisecond) // Keep the two join dates apart
+	if rec := env.do(t, http.MethodPost, path+"/join", "", member); rec.Code != http.StatusOK {
+		t.Fatalf("rejoin: status %d (%s)", rec.Code, rec.Body)
+	}
+	if rejoined := joinDates(); rejoined == nil || !rejoined.After(*firstJoined) {
+		t.Errorf("joined_at after rejoining = %v, want a new date after %v", rejoined, firstJoined)
+	}
+
+	// Anonymous viewers get no member_since
+	var anonymous SubredditResponse
+	decode(env.do(t, http.MethodGet, path, "", uuid.Nil), &anonymous)
+	if anonymous.MemberSince != nil {
+		t.Errorf("anonymous member_since = %v", anonymous.MemberSince)
+	}
+}
+
+// A failed name lookup is an outage, not a free name
+func TestCreateSubredditDuringOutage(t *testing.T) {
+	cfg := config.SubredditConfig{}
+	env := newHandlerEnvOn(t, newTestEnvOn(t, testutil.UnreachablePostgres(t), cfg), cfg)
+
+	rec := env.do(t, http.MethodPost, "/subreddits", `{"name":"outage","display_name":"Outage"}`, uuid.New())
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503 (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestBatchMembershipPartialSuccess(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{MaxMembershipsPerUser: 3})
+	ctx := context.Background()
+	owner := env.createUser(t, "owner") // Creating counts towards the cap as well
+	privateOwner := env.createUser(t, "private_owner")
+	joiner := env.createUser(t, "joiner")
+	own := env.createSubreddit(t, joiner, "own") // Takes one of the three memberships
+	first := env.createSubreddit(t, owner, "first")
+	second := env.createSubreddit(t, owner, "second")
+	third := env.createSubreddit(t, owner, "third")
+	private, err := env.service.CreateSubreddit(ctx, privateOwner, "private", "Private", nil, nil, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown := uuid.New()
+
+	batch := func(path string, ids ...uuid.UUID) []BatchMembershipResult {
+		t.Helper()
+		body, _ := json.Marshal(BatchMembershipRequest{SubredditIDs: ids})
+		rec := env.do(t, http.MethodPost, path, string(body), joiner)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s: status %d (%s)", path, rec.Code, rec.Body)
+		}
+		var response BatchMembershipResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Results
+	}
+	check := func(name string, got []BatchMembershipResult, want []BatchMembershipResult) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d results, want %d: %+v", name, len(got), len(want), got)
+		}
+		for i := range want {
+			if got[i].SubredditID != want[i].SubredditID || got[i].Success != want[i].Success || got[i].Code != want[i].Code {
+				t.Errorf("%s result %d = %+v, want %+v", name, i, got[i], want[i])
+			}
+		}
+	}
+
+	// Each ID is its own transaction: hitting the cap on the last one keeps the earlier joins
+	check(
+		"batch join",
+		batch("/subreddits/batch-join", first, first, private.ID, unknown, second, third),
+		[]BatchMembershipResult{
+			{SubredditID: first, Success: true},
+			{SubredditID: private.ID, Code: "subreddit_private"},
+			{SubredditID: unknown, Code: "not_found"},
+			{SubredditID: second, Success: true},
+			{SubredditID: third, Code: "membership_limit_reached"},
+		},
+	)
+	for id, want := range map[uuid.UUID]bool{first: true, second: true, third: false, private.ID: false} {
+		if member, err := env.service.repo.IsMember(ctx, id, joiner); err != nil || member != want {
+			t.Errorf("member of %s = %t, %v; want %t", id, member, err, want)
+		}
+	}
+
+	check(
+		"batch leave",
+		batch("/subreddits/batch-leave", first, own),
+		[]BatchMembershipResult{
+			{SubredditID: first, Success: true},
+			{SubredditID: own, Code: "creator_cannot_leave"},
+		},
+	)
+	if member, err := env.service.repo.IsMember(ctx, first, joiner); err != nil || member {
+		t.Errorf("member of first after leaving = %t, %v", member, err)
+	}
+
+	// The batch itself is validated up front, nothing is processed when it is rejected
+	tooMany := make([]uuid.UUID, MaxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = third
+	}
+	for name, ids := range map[string][]uuid.UUID{"empty": {}, "too big": tooMany} {
+		body, _ := json.Marshal(BatchMembershipRequest{SubredditIDs: ids})
+		if rec := env.do(t, http.MethodPost, "/subreddits/batch-join", string(body), joiner); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s batch: status %d, want 400", name, rec.Code)
+		}
+	}
+	if rec := env.do(t, http.MethodPost, "/subreddits/batch-join", `{"subreddit_ids":[]}`, uuid.Nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous batch: status %d, want 401", rec.Code)
+	}
+}
+
+func TestSubredditLanguage(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{AllowedLanguages: []string{"en", "uk"}})
+	creator := env.createUser(t, "creator")
+	other := env.createUser(t, "other")
+
+	create := func(userID uuid.UUID, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		return env.do(t, http.MethodPost, "/subreddits", body, userID)
+	}
+	if rec := create(creator, `{"name":"english","display_name":"English","language":" EN "}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create en: status %d (%s)", rec.Code, rec.Body)
+	}
+	rec := create(creator, `{"name":"ukrainian","display_name":"Ukrainian","language":"uk"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create uk: status %d (%s)", rec.Code, rec.Body)
+	}
+	var ukrainian SubredditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &ukrainian); err != nil {
+		t.Fatal(err)
+	}
+	if ukrainian.Language == nil || *ukrainian.Language != "uk" {
+		t.Errorf("language in the response = %v, want uk", ukrainian.Language)
+	}
+	if rec := create(other, `{"name":"elsewhere","display_name":"Elsewhere","language":"en"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("create other en: status %d (%s)", rec.Code, rec.Body)
+	}
+	env.createSubreddit(t, creator, "unspecified")
+
+	for _, body := range []string{
+		`{"name":"french","display_name":"French","language":"fr"}`,
+		`{"name":"wordy","display_name":"Wordy","language":"english"}`,
+	} {
+		if rec := create(creator, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("create with %s: status %d, want 400", body, rec.Code)
+		}
+	}
+
+	tests := []struct {
+		query  string
+		viewer uuid.UUID
+		want   []string
+	}{
+		{"?lang=en", uuid.Nil, []string{"elsewhere", "english"}},
+		{"?lang=UK", uuid.Nil, []string{"ukrainian"}},
+		{"?lang=en&mine=created", creator, []string{"english"}},
+		{"?lang=en&mine=joined", other, []string{"elsewhere"}},
+		{"?lang=uk&mine=joined", other, nil},
+		{"", uuid.Nil, []string{"elsewhere", "english", "ukrainian", "unspecified"}},
+	}
+	for _, tt := range tests {
+		got := listNames(t, env.do(t, http.MethodGet, "/subreddits"+tt.query, "", tt.viewer))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("GET /subreddits%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+	if rec := env.do(t, http.MethodGet, "/subreddits?lang=fr", "", uuid.Nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("lang=fr: status %d, want 400", rec.Code)
+	}
+
+	// A null language clears it, the subreddit drops out of the filtered list
+	path := "/subreddits/" + ukrainian.ID.String()
+	if rec := env.do(t, http.MethodPatch, path, `{"language":"xx"}`, creator); rec.Code != http.StatusBadRequest {
+		t.Errorf("PATCH an unsupported language: status %d, want 400", rec.Code)
+	}
+	if rec := env.do(t, http.MethodPatch, path, `{"language":null}`, creator); rec.Code != http.StatusOK {
+		t.Fatalf("PATCH language null: status %d (%s)", rec.Code, rec.Body)
+	}
+	if got := listNames(t, env.do(t, http.MethodGet, "/subreddits?lang=uk", "", uuid.Nil)); len(got) != 0 {
+		t.Errorf("lang=uk after clearing = %v, want none", got)
+	}
+}
+
+// The detail endpoint reports member_count, it must never load the member list itself
+func TestGetSubredditDoesNotLoadMembers(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	subID := env.createSubreddit(t, creator, "crowded")
+	for i := range 3 {
+		if err := env.service.JoinSubreddit(ctx, subID, env.createUser(t, fmt.Sprintf("member_%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type query struct {
+		table string
+		rows  int64
+	}
+	var queries []query
+	err := env.db.WriteDB().Callback().Query().After("gorm:query").Register(
+		"test:record_queries", func(tx *gorm.DB) {
+			queries = append(queries, query{tx.Statement.Table, tx.RowsAffected})
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.do(t, http.MethodGet, "/subreddits/"+subID.String(), "", creator)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d (%s)", rec.Code, rec.Body)
+	}
+	var response SubredditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.MemberCount != 4 {
+		t.Errorf("member_count = %d, want 4", response.MemberCount)
+	}
+
+	if len(queries) == 0 {
+		t.Fatal("no queries recorded")
+	}
+	for _, q := range queries {
+		// The viewer's own membership row is fine, anything more is the member list
+		if q.table == "users" && q.rows > 1 || q.table == "subreddit_members" && q.rows > 1 {
+			t.Errorf("GET /subreddits/:id read %d rows from %s", q.rows, q.table)
+		}
+	}
+}
+
+func TestWikiPagePermissions(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	member := env.createUser(t, "member")
+	outsider := env.createUser(t, "outsider")
+	public := env.createSubreddit(t, creator, "open")
+	private, err := env.service.CreateSubreddit(ctx, creator, "closed", "Closed", nil, nil, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, subID := range []uuid.UUID{public, private.ID} { // Private ones can't be joined, added directly
+		if _, err := env.service.repo.AddMember(ctx, subID, member); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put := func(subID, userID uuid.UUID, content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(PutWikiPageRequest{Content: content})
+		return env.do(t, http.MethodPut, "/subreddits/"+subID.String()+"/wiki/rules", string(body), userID)
+	}
+	get := func(subID, userID uuid.UUID) *httptest.ResponseRecorder {
+		return env.do(t, http.MethodGet, "/subreddits/"+subID.String()+"/wiki/rules", "", userID)
+	}
+
+	// Only the creator edits, members included
+	for name, userID := range map[string]uuid.UUID{"member": member, "outsider": outsider} {
+		if rec := put(public, userID, "# Hijacked"); rec.Code != http.StatusForbidden {
+			t.Errorf("PUT as %s: status %d, want 403", name, rec.Code)
+		}
+	}
+	if rec := put(public, uuid.Nil, "# Hijacked"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("PUT anonymously: status %d, want 401", rec.Code)
+	}
+	if rec := get(public, uuid.Nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET before any edit: status %d, want 404", rec.Code)
+	}
+
+	content := "# Rules\n\n- be kind <script>alert(1)</script>\n- [no spam](javascript:void)"
+	for _, subID := range []uuid.UUID{public, private.ID} {
+		if rec := put(subID, creator, content); rec.Code != http.StatusOK {
+			t.Fatalf("PUT as creator: status %d (%s)", rec.Code, rec.Body)
+		}
+	}
+
+	rec := get(public, uuid.Nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET public page anonymously: status %d (%s)", rec.Code, rec.Body)
+	}
+	var page WikiPageResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Content != content || page.UpdatedBy != "creator" || page.UpdatedAt.IsZero() {
+		t.Errorf("page = %+v, want the source with the last editor and time", page)
+	}
+	wantHTML := "<h1>Rules</h1>\n<ul>\n<li>be kind &lt;script&gt;alert(1)&lt;/script&gt;</li>\n<li>no spam</li>\n</ul>\n"
+	if page.ContentHTML != wantHTML {
+		t.Errorf("content_html = %q, want %q", page.ContentHTML, wantHTML)
+	}
+
+	// A private community's wiki is only readable by its members
+	tests := []struct {
+		name   string
+		viewer uuid.UUID
+		want   int
+	}{
+		{"anonymous", uuid.Nil, http.StatusNotFound},
+		{"outsider", outsider, http.StatusNotFound},
+		{"member", member, http.StatusOK},
+		{"creator", creator, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := get(private.ID, tt.viewer); rec.Code != tt.want {
+			t.Errorf("GET private page as %s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestSoftDeletedCreatorRendersAsDeleted(t *testing.T) {
+	env := newHandlerEnv(t, config.SubredditConfig{})
+	gone := env.createUser(t, "gone")
+	present := env.createUser(t, "present")
+	orphaned := env.createSubreddit(t, gone, "orphaned")
+	env.createSubreddit(t, present, "tended")
+	if err := env.db.WriteDB().Exec("UPDATE users SET deleted_at = NOW() WHERE id = ?", gone).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := env.do(t, http.MethodGet, "/subreddits/"+orphaned.String(), "", uuid.Nil)
+	var detail SubredditResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET detail: status %d, %v (%s)", rec.Code, err, rec.Body)
+	}
+	if detail.Creator != (user.PublicUserResponse{Username: user.DeletedUsername}) {
+		t.Errorf("detail creator = %+v, want the [deleted] placeholder", detail.Creator)
+	}
+
+	rec = env.do(t, http.MethodGet, "/subreddits", "", uuid.Nil)
+	var list SubredditListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET list: status %d, %v (%s)", rec.Code, err, rec.Body)
+	}
+	creators := make(map[string]user.PublicUserResponse)
+	for _, item := range list.Subreddits {
+		creators[item.Name] = item.Creator
+	}
+	want := map[string]user.PublicUserResponse{
+		"orphaned": {Username: user.DeletedUsername},
+		"tended":   {Username: "present", Email: "present@example.com"},
+	}
+	if !maps.Equal(creators, want) {
+		t.Errorf("list creators = %+v, want %+v", creators, want)
+	}
+}
+
+func TestSubredditListTimeRange(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(start)
+	cfg := config.SubredditConfig{}
+	env := newHandlerEnvOn(t, newTestEnvOn(t, testutil.Postgres(t, clock), cfg), cfg)
+	creator := env.createUser(t, "creator")
+	for _, name := range []string{"first", "second", "third"} {
+		env.createSubreddit(t, creator, name)
+		clock.Advance(time.Hour)
+	}
+	at := func(hours float64) string {
+		return url.QueryEscape(start.Add(time.Duration(hours * float64(time.Hour))).Format(time.RFC3339))
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"first", "second", "third"}},
+		{"since=" + at(1), []string{"second", "third"}}, // Inclusive
+		{"until=" + at(1), []string{"first"}},           // Exclusive
+		{"since=" + at(0.5) + "&until=" + at(1.5), []string{"second"}},
+		{"since=" + at(1) + "&until=" + at(1), nil},
+		{"since=" + url.QueryEscape("2026-01-01T14:00:00+01:00"), []string{"second", "third"}},
+	}
+	for _, tt := range tests {
+		rec := env.do(t, http.MethodGet, "/subreddits?"+tt.query, "", uuid.Nil)
+		if got := listNames(t, rec); !slices.Equal(got, tt.want) {
+			t.Errorf("?%s: %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	invalid := []struct {
+		query, field string
+	}{
+		{"since=" + at(2) + "&until=" + at(1), "since"},
+		{"since=2026-01-01", "since"},
+		{"until=tomorrow", "until"},
+	}
+	for _, tt := range invalid {
+		rec := env.do(t, http.MethodGet, "/subreddits?"+tt.query, "", uuid.Nil)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `"field":"`+tt.field+`"`) {
+			t.Errorf("?%s: status %d (%s), want 400 on %s", tt.query, rec.Code, rec.Body, tt.field)
+		}
+	}
+}
@@ -12,12 +12,21 @@ type Subreddit struct {
 	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Name        string    `gorm:"uniqueIndex;not null;size:21"`
 	DisplayName string    `gorm:"not null;size:255"`
-	Description *string   `gorm:"size:500"`
+	Description *string   `gorm:"size:2000"`
 	IconURL     *string   `gorm:"size:500"`
 
+	// Appearance, colors are stored as lowercase #rrggbb
+	BannerURL    *string `gorm:"size:500"`
+	PrimaryColor *string `gorm:"size:7"`
+	BannerColor  *string `gorm:"size:7"`
+
+	// Overrides DescriptionMaxLen for this community, NULL means the global default
+	DescriptionMaxLen *int
+
 	CreatorID uuid.UUID   `gorm:"type:uuid;not null;index"`
 	Creator   user.User   `gorm:"foreignKey:CreatorID;references:ID"`
-	Members   []user.User `gorm:"many2many:subreddit_members"`
+	Members   []user.User `gorm:"many2many:subreddit_members"` // Unbounded, never preloaded - page through GetMembers
+	Topics    []Topic     `gorm:"many2many:subreddit_topics"`
 
 	// Update on every action(sub/unsub)
 	MemberCount int `gorm:"default:0;not null"`
@@ -26,13 +35,96 @@ type Subreddit struct {
 	IsPublic bool `gorm:"default:true;not null"`
 	IsNSFW   bool `gorm:"default:false;not null"`
 
+	Language *string `gorm:"size:2"` // ISO 639-1, NULL when the community didn't set one
+
+	// Set while a deletion requested by the creator is pending, the community is hidden from listings meanwhile.
+	// RunDeletionPurge soft-deletes the row once it passes.
+	PurgeAt *time.Time
+
+	// Assigned on first share, GET /s/:code redirects to the community. Counters are shown to moderators only.
+	ShareCode  *string `gorm:"size:11"`
+	ShareCount int64   `gorm:"default:0;not null"`
+	ClickCount int64   `gorm:"default:0;not null"`
+
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
+// DescriptionLimit is the max description length in effect for this subreddit
+func (s *Subreddit) DescriptionLimit() int {
+	if s.DescriptionMaxLen != nil {
+		return *s.DescriptionMaxLen
+	}
+	return DescriptionMaxLen
+}
+
 type SubredditMember struct {
 	SubredditID uuid.UUID `gorm:"type:uuid;primaryKey"`
 	UserID      uuid.UUID `gorm:"type:uuid;primaryKey"`
 	CreatedAt   time.Time `gorm:"not null"`
 }
+
+// SubredditLite is the trimmed projection embedded in feed items (posts, comments), served from cache
+type SubredditLite struct {
+	ID       uuid.UUID `json:"id"`
+	Name     string    `json:"name"`
+	IconURL  *string   `json:"icon_url"`
+	IsNSFW   bool      `json:"is_nsfw"`
+	IsPublic bool      `json:"is_public"`
+}
+
+// Member is one row of a subreddit's member list
+type Member struct {
+	UserID   uuid.UUID
+	Username string
+	JoinedAt time.Time
+}
+
+// SubredditEvent is the outbox payload for subreddit creation and membership changes
+type SubredditEvent struct {
+	SubredditID uuid.UUID `json:"subreddit_id"`
+	UserID      uuid.UUID `json:"user_id"`
+}
+
+// DeletionEvent is the outbox payload for deletion state changes, PurgeAt is only set when scheduling
+type DeletionEvent struct {
+	SubredditID uuid.UUID  `json:"subreddit_id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	PurgeAt     *time.Time `json:"purge_at,omitempty"`
+}
+
+// Membership is a joined subreddit together with the viewer's join date
+type Membership struct {
+	Subreddit Subreddit
+	JoinedAt  time.Time
+}
+
+// Topic is an admin-curated category used for browsing communities
+type Topic struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Slug        string    `gorm:"uniqueIndex;not null;size:50"`
+	Name        string    `gorm:"not null;size:100"`
+	IsSensitive bool      `gorm:"default:false;not null"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WikiPage is a markdown page (rules, FAQ) maintained by the subreddit creator
+type WikiPage struct {
+	ID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	SubredditID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:uq_subreddit_wiki_pages_slug"`
+	Slug        string    `gorm:"size:50;not null;uniqueIndex:uq_subreddit_wiki_pages_slug"`
+	Content     string    `gorm:"type:text;not null"`
+
+	UpdatedByID uuid.UUID `gorm:"type:uuid;not null"`
+	UpdatedBy   user.User `gorm:"foreignKey:UpdatedByID;references:ID"`
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (WikiPage) TableName() string {
+	return "subreddit_wiki_pages"
+}
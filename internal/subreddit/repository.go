@@ -3,61 +3,254 @@ package subreddit
 import (
 	"context"
 	"strings"
+	"time"
 
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
-	db *gorm.DB
+	db           *database.DB
+	queryTimeout time.Duration // Applied to every repository call, see utils.WithQueryTimeout
+	// Keeps multi-statement writes whole when the caller didn't open a transaction, joins it otherwise
+	txManager *database.TxManager
 }
 
-func NewRepository(db *gorm.DB) *Repository {
+func NewRepository(db *database.DB, queryTimeout time.Duration) *Repository {
 	return &Repository{
-		db: db,
+		db:           db,
+		queryTimeout: queryTimeout,
+		txManager:    database.NewTxManager(db),
 	}
 }
 
-func (repo *Repository) WithTx(ctx context.Context, fn func(txRepo Repository) error) error {
-	return repo.db.WithContext(ctx).Transaction(
-		func(tx *gorm.DB) error {
-			txRepo := Repository{db: tx}
-			return fn(txRepo)
+// conn joins the transaction carried by ctx (see database.TxManager) or uses the repository handle
+func (repo *Repository) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, repo.db.WriteDB())
+}
+
+// readConn is conn for queries that can be served by the replica, see database.ReadConn
+func (repo *Repository) readConn(ctx context.Context) *gorm.DB {
+	return database.ReadConn(ctx, repo.db)
+}
+
+// orderBy builds a quoted ORDER BY from an already whitelisted sort spec. Ties (same member count, same
+// second) are broken by id, so pages don't overlap or skip rows.
+func orderBy(sort utils.SortSpec) clause.OrderBy {
+	return clause.OrderBy{
+		Columns: []clause.OrderByColumn{
+			{Column: clause.Column{Table: "subreddits", Name: sort.Column}, Desc: sort.Desc},
+			{Column: clause.Column{Table: "subreddits", Name: "id"}, Desc: sort.Desc},
 		},
-	)
+	}
+}
+
+// VisibleSubreddits scopes a subreddits query to the communities whose content viewerID may read: public ones
+// and the private ones they created or joined (viewerID is uuid.Nil for anonymous viewers).
+// Content read paths filter through it, usually via visibleSubredditIDs, instead of checking membership by hand.
+func VisibleSubreddits(viewerID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == uuid.Nil {
+			return db.Where("subreddits.is_public = ?", true)
+		}
+		return db.Where(
+			"(subreddits.is_public = ? OR subreddits.creator_id = ? OR EXISTS ("+
+				"SELECT 1 FROM subreddit_members "+
+				"WHERE subreddit_members.subreddit_id = subreddits.id AND subreddit_members.user_id = ?))",
+			true, viewerID, viewerID,
+		)
+	}
 }
 
-func (repo *Repository) GetList(ctx context.Context) ([]Subreddit, error) {
+// visibleSubredditIDs is a subquery for `subreddit_id IN (?)` filters on content tables
+func visibleSubredditIDs(db *gorm.DB, viewerID uuid.UUID) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).
+		Model(&Subreddit{}).
+		Select("subreddits.id").
+		Scopes(VisibleSubreddits(viewerID))
+}
+
+// applyListFilter adds the optional list filters; columns are qualified for the membership join
+func applyListFilter(query *gorm.DB, filter ListFilter) *gorm.DB {
+	if filter.Language != nil {
+		query = query.Where("subreddits.language = ?", *filter.Language)
+	}
+	if filter.Since != nil {
+		query = query.Where("subreddits.created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("subreddits.created_at < ?", *filter.Until)
+	}
+	return query
+}
+
+func (repo *Repository) GetList(
+	ctx context.Context,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var subreddits []Subreddit
+	var total int64
+
+	query := applyListFilter(
+		repo.readConn(ctx).
+			Model(&Subreddit{}).
+			Where("is_public = ?", true).
+			Where("deleted_at IS NULL").
+			Where("purge_at IS NULL"),
+		filter,
+	).Session(&gorm.Session{}) // Reusable for both the count and the page query
 
-	err := repo.db.WithContext(ctx).
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
 		Preload("Creator").
-		Where("is_public = ?", true).
-		Where("deleted_at IS NULL").
-		Order("created_at DESC").
+		Preload("Topics").
+		Order(orderBy(sort)).
+		Offset(page.Offset()).
+		Limit(page.PageSize).
 		Find(&subreddits).Error
 
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return subreddits, total, nil
+}
+
+// GetCreatedByUser lists subreddits created by the user, private ones included
+func (repo *Repository) GetCreatedByUser(
+	ctx context.Context,
+	userID uuid.UUID,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddits []Subreddit
+	var total int64
+
+	query := applyListFilter(
+		repo.readConn(ctx).
+			Model(&Subreddit{}).
+			Where("creator_id = ?", userID).
+			Where("deleted_at IS NULL"),
+		filter,
+	).Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload("Creator").
+		Preload("Topics").
+		Order(orderBy(sort)).
+		Offset(page.Offset()).
+		Limit(page.PageSize).
+		Find(&subreddits).Error
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return subreddits, total, nil
+}
+
+// GetByID never loads Members, a large community would pull every member into memory on a plain GET.
+// MemberCount carries the number, GetMembers pages through the list itself.
+func (repo *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddit Subreddit
+	err := repo.readConn(ctx).
+		Preload("Creator").
+		Preload("Topics").
+		Where("id = ?", id).
+		First(&subreddit).Error
 	if err != nil {
 		return nil, err
 	}
 
-	return subreddits, nil
+	return &subreddit, nil
 }
 
-func (repo *Repository) GetByID(ctx context.Context, id uuid.UUID, includeMembers bool) (
-	*Subreddit,
-	error,
-) {
+// GetLiteByIDs loads only the SubredditLite columns, no associations
+func (repo *Repository) GetLiteByIDs(ctx context.Context, ids []uuid.UUID) ([]SubredditLite, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var lites []SubredditLite
+	err := repo.conn(ctx).
+		Model(&Subreddit{}).
+		Select("id", "name", "icon_url", "is_nsfw", "is_public").
+		Where("id IN ?", ids).
+		Find(&lites).Error
+	return lites, err
+}
+
+// GetVisibleByID is GetByID through VisibleSubreddits, a private community the viewer can't read is not found.
+// Viewer-facing reads use it (and GetVisibleByName, ExistsVisibleByID), GetByID is for internal checks.
+func (repo *Repository) GetVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (*Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var subreddit Subreddit
-	query := repo.db.WithContext(ctx).
+	err := repo.readConn(ctx).
 		Preload("Creator").
-		Where("id = ?", id)
+		Preload("Topics").
+		Scopes(VisibleSubreddits(viewerID)).
+		Where("subreddits.id = ?", id).
+		First(&subreddit).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &subreddit, nil
+}
+
+// GetVisibleByName is GetByName through VisibleSubreddits
+func (repo *Repository) GetVisibleByName(ctx context.Context, name string, viewerID uuid.UUID) (*Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
 
-	if includeMembers {
-		query = query.Preload("Members")
+	var subreddit Subreddit
+	err := repo.readConn(ctx).
+		Preload("Creator").
+		Preload("Topics").
+		Scopes(VisibleSubreddits(viewerID)).
+		Where("LOWER(subreddits.name) = ?", strings.ToLower(name)).
+		First(&subreddit).Error
+	if err != nil {
+		return nil, err
 	}
-	err := query.First(&subreddit).Error
+
+	return &subreddit, nil
+}
+
+// GetVisibleByShareCode resolves a short link through VisibleSubreddits, only id and name are loaded
+func (repo *Repository) GetVisibleByShareCode(ctx context.Context, code string, viewerID uuid.UUID) (*Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddit Subreddit
+	err := repo.readConn(ctx).
+		Select("subreddits.id", "subreddits.name").
+		Scopes(VisibleSubreddits(viewerID)).
+		Where("subreddits.share_code = ?", code).
+		First(&subreddit).Error
 	if err != nil {
 		return nil, err
 	}
@@ -65,30 +258,80 @@ func (repo *Repository) GetByID(ctx context.Context, id uuid.UUID, includeMember
 	return &subreddit, nil
 }
 
-func (repo *Repository) GetUserSubreddits(ctx context.Context, userID uuid.UUID) (
-	[]Subreddit,
-	int64,
-	error,
-) {
+// EnsureShareCode assigns code unless the subreddit already has one and returns the code it ends up with.
+// gorm.ErrDuplicatedKey means code is taken by another subreddit.
+func (repo *Repository) EnsureShareCode(ctx context.Context, id uuid.UUID, code string) (string, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var stored []string
+	err := repo.conn(ctx).
+		Raw(
+			"UPDATE subreddits SET share_code = COALESCE(share_code, ?) "+
+				"WHERE id = ? AND deleted_at IS NULL RETURNING share_code",
+			code, id,
+		).
+		Scan(&stored).Error
+	if err != nil {
+		return "", err
+	}
+	if len(stored) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return stored[0], nil
+}
+
+// IncrementShareStat bumps share_count or click_count. UpdateColumn leaves updated_at alone,
+// the counters aren't part of the cached public response.
+func (repo *Repository) IncrementShareStat(ctx context.Context, id uuid.UUID, column string) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Model(&Subreddit{}).
+		Where("id = ?", id).
+		UpdateColumn(column, gorm.Expr(column+" + 1")).Error
+}
+
+func (repo *Repository) GetUserSubreddits(
+	ctx context.Context,
+	userID uuid.UUID,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var subreddits []Subreddit
 	var total int64
 
-	err := repo.db.WithContext(ctx).
-		Table("subreddits").
-		Joins("INNER JOIN subreddit_members ON subreddits.id = subreddit_members.subreddit_id").
-		Where("subreddit_members.user_id = ?", userID).
-		Where("subreddits.deleted_at IS NULL").
-		Count(&total).Error
+	err := applyListFilter(
+		repo.readConn(ctx).
+			Table("subreddits").
+			Joins("INNER JOIN subreddit_members ON subreddits.id = subreddit_members.subreddit_id").
+			Where("subreddit_members.user_id = ?", userID).
+			Where("subreddits.deleted_at IS NULL").
+			Where("subreddits.purge_at IS NULL"),
+		filter,
+	).Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
-	// TODO: Add pagination
-	err = repo.db.WithContext(ctx).
-		Preload("Creator").
-		Joins("INNER JOIN subreddit_members ON subreddits.id = subreddit_members.subreddit_id").
-		Where("subreddit_members.user_id = ?", userID).
-		Where("subreddits.deleted_at IS NULL").
-		Order("subreddits.created_at DESC").
+
+	err = applyListFilter(
+		repo.readConn(ctx).
+			Preload("Creator").
+			Preload("Topics").
+			Joins("INNER JOIN subreddit_members ON subreddits.id = subreddit_members.subreddit_id").
+			Where("subreddit_members.user_id = ?", userID).
+			Where("subreddits.deleted_at IS NULL").
+			Where("subreddits.purge_at IS NULL"),
+		filter,
+	).
+		Order(orderBy(sort)).
+		Offset(page.Offset()).
+		Limit(page.PageSize).
 		Find(&subreddits).Error
 
 	if err != nil {
@@ -98,13 +341,81 @@ func (repo *Repository) GetUserSubreddits(ctx context.Context, userID uuid.UUID)
 	return subreddits, total, nil
 }
 
+// CountUserMemberships counts subreddits (excluding deleted ones) the user is currently a member of
+func (repo *Repository) CountUserMemberships(ctx context.Context, userID uuid.UUID) (int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.conn(ctx).
+		Table("subreddit_members").
+		Joins("INNER JOIN subreddits ON subreddits.id = subreddit_members.subreddit_id").
+		Where("subreddit_members.user_id = ?", userID).
+		Where("subreddits.deleted_at IS NULL").
+		Count(&count).Error
+	return count, err
+}
+
+// LockUserMemberships takes the user's row FOR UPDATE, serializing that user's joins until the transaction ends
+func (repo *Repository) LockUserMemberships(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var locked []uuid.UUID
+	err := repo.conn(ctx).
+		Table("users").
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("id = ?", userID).
+		Pluck("id", &locked).Error
+	if err != nil {
+		return err
+	}
+	if len(locked) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repo *Repository) IsMember(ctx context.Context, subredditID, userID uuid.UUID) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.conn(ctx).
+		Model(&SubredditMember{}).
+		Where("subreddit_id = ? AND user_id = ?", subredditID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
 func (repo *Repository) Create(ctx context.Context, subreddit *Subreddit) error {
-	return repo.db.WithContext(ctx).Create(subreddit).Error
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Create(subreddit).Error
+}
+
+// ExistsByID ignores soft-deleted subreddits, unlike ExistsByName
+// ExistsVisibleByID doesn't confirm private communities to viewers who can't read them, see VisibleSubreddits
+func (repo *Repository) ExistsVisibleByID(ctx context.Context, id, viewerID uuid.UUID) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.readConn(ctx).
+		Model(&Subreddit{}).
+		Scopes(VisibleSubreddits(viewerID)).
+		Where("subreddits.id = ?", id).
+		Count(&count).Error
+	return count > 0, err
 }
 
 func (repo *Repository) ExistsByName(ctx context.Context, name string) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var count int64
-	err := repo.db.WithContext(ctx).Model(&Subreddit{}).
+	err := repo.conn(ctx).Model(&Subreddit{}).
 		Unscoped(). // Include soft deleted records to not allow name reusage
 		Where("LOWER(name) = ?", strings.ToLower(name)).
 		Count(&count).Error
@@ -116,7 +427,10 @@ func (repo *Repository) Update(
 	subredditID uuid.UUID,
 	updates map[string]interface{},
 ) error {
-	result := repo.db.WithContext(ctx).
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
 		Model(&Subreddit{}).
 		Where("id = ?", subredditID).
 		Updates(updates)
@@ -132,8 +446,39 @@ func (repo *Repository) Update(
 	return nil
 }
 
+// UpdateReturning applies updates and reads the row back in the same statement (UPDATE ... RETURNING),
+// saving the follow-up SELECT. Associations are not loaded.
+func (repo *Repository) UpdateReturning(
+	ctx context.Context,
+	subredditID uuid.UUID,
+	updates map[string]interface{},
+) (*Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddit Subreddit
+	result := repo.conn(ctx).
+		Model(&subreddit).
+		Clauses(clause.Returning{}).
+		Where("id = ?", subredditID).
+		Updates(updates)
+
+	if err := result.Error; err != nil {
+		return nil, err
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &subreddit, nil
+}
+
 func (repo *Repository) Delete(ctx context.Context, id uuid.UUID) error {
-	result := repo.db.WithContext(ctx).
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
 		Where("id = ?", id).
 		Delete(&Subreddit{})
 
@@ -148,49 +493,408 @@ func (repo *Repository) Delete(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
-func (repo *Repository) AddMember(ctx context.Context, subredditID, userID uuid.UUID) error {
+// ScheduleDeletion sets purge_at unless a deletion is already pending, scheduled reports which one happened
+func (repo *Repository) ScheduleDeletion(ctx context.Context, id uuid.UUID, purgeAt time.Time) (
+	scheduled bool,
+	err error,
+) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
+		Model(&Subreddit{}).
+		Where("id = ? AND purge_at IS NULL", id).
+		Update("purge_at", purgeAt)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelDeletion clears purge_at, cancelled is false when no deletion was pending
+func (repo *Repository) CancelDeletion(ctx context.Context, id uuid.UUID) (cancelled bool, err error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
+		Model(&Subreddit{}).
+		Where("id = ? AND purge_at IS NOT NULL", id).
+		Update("purge_at", nil)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClaimDueForPurge locks subreddits whose grace period ended before now until the surrounding transaction ends.
+// SKIP LOCKED keeps several instances from purging the same rows.
+func (repo *Repository) ClaimDueForPurge(ctx context.Context, now time.Time, limit int) ([]Subreddit, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddits []Subreddit
+	err := repo.conn(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("purge_at <= ?", now).
+		Order("purge_at").
+		Limit(limit).
+		Find(&subreddits).Error
+	return subreddits, err
+}
+
+// GetMembership returns gorm.ErrRecordNotFound when the user is not a member
+func (repo *Repository) GetMembership(ctx context.Context, subredditID, userID uuid.UUID) (
+	*SubredditMember,
+	error,
+) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var member SubredditMember
+	err := repo.conn(ctx).
+		Where("subreddit_id = ? AND user_id = ?", subredditID, userID).
+		Take(&member).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// GetMemberships loads the user's membership rows for the given subreddits (e.g. one page of a list)
+func (repo *Repository) GetMemberships(
+	ctx context.Context,
+	userID uuid.UUID,
+	subredditIDs []uuid.UUID,
+) ([]SubredditMember, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var members []SubredditMember
+	if len(subredditIDs) == 0 {
+		return members, nil
+	}
+
+	err := repo.conn(ctx).
+		Where("user_id = ? AND subreddit_id IN ?", userID, subredditIDs).
+		Find(&members).Error
+	return members, err
+}
+
+// GetMembers lists the subreddit's members, most recently joined first.
+// The list of a private subreddit is empty for viewers who can't see its content.
+func (repo *Repository) GetMembers(
+	ctx context.Context,
+	subredditID, viewerID uuid.UUID,
+	page utils.Pagination,
+) ([]Member, int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var members []Member
+	var total int64
+
+	query := repo.readConn(ctx).
+		Table("subreddit_members").
+		Joins("INNER JOIN users ON users.id = subreddit_members.user_id").
+		Where("subreddit_members.subreddit_id = ?", subredditID).
+		Where("subreddit_members.subreddit_id IN (?)", visibleSubredditIDs(repo.readConn(ctx), viewerID)).
+		Where("users.deleted_at IS NULL").
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Select("users.id AS user_id", "users.username", "subreddit_members.created_at AS joined_at").
+		Order("subreddit_members.created_at DESC").
+		Order("subreddit_members.user_id").
+		Offset(page.Offset()).
+		Limit(page.PageSize).
+		Scan(&members).Error
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return members, total, nil
+}
+
+// AddMember stores the join date in subreddit_members.created_at. Leaving deletes the row,
+// so joining again starts a new membership with a fresh join date. joined is false for an existing member.
+// Joining and leaving bump the subreddit's updated_at along with member_count (Update, not UpdateColumn),
+// as the count is part of the subreddit response and caches key their freshness off updated_at.
+// The membership row and the count change commit together, also when called outside a service transaction.
+func (repo *Repository) AddMember(ctx context.Context, subredditID, userID uuid.UUID) (joined bool, err error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	member := SubredditMember{
 		SubredditID: subredditID,
 		UserID:      userID,
 	}
 
-	result := repo.db.WithContext(ctx).
-		Clauses(clause.OnConflict{DoNothing: true}). // Idempotent (no error if already member)
-		Create(&member)
+	err = repo.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			result := repo.conn(ctx).
+				Clauses(clause.OnConflict{DoNothing: true}). // Idempotent (no error if already member)
+				Create(&member)
+
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			joined = true
+			return repo.conn(ctx).
+				Model(&Subreddit{}).
+				Where("id = ?", subredditID).
+				Update("member_count", gorm.Expr("member_count + 1")).Error
+		},
+	)
+	return joined && err == nil, err
+}
 
-	if result.Error != nil {
-		return result.Error
+// RecountMemberCounts resets member_count to the actual number of members for up to limit subreddits with IDs
+// above afterID, in ID order so the last ID is the cursor of the next batch. Empty once past the last subreddit.
+func (repo *Repository) RecountMemberCounts(
+	ctx context.Context,
+	afterID uuid.UUID,
+	limit int,
+	now time.Time,
+) (RecountBatch, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var ids []uuid.UUID
+	err := repo.conn(ctx).
+		Model(&Subreddit{}).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return RecountBatch{}, err
 	}
-	if result.RowsAffected == 0 {
-		return nil
+	return repo.recountMemberCounts(ctx, ids, now)
+}
+
+// RecountJoinedMemberCounts is RecountMemberCounts over the subreddits userID is a member of, in subreddit ID order
+func (repo *Repository) RecountJoinedMemberCounts(
+	ctx context.Context,
+	userID, afterID uuid.UUID,
+	limit int,
+	now time.Time,
+) (RecountBatch, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var ids []uuid.UUID
+	err := repo.conn(ctx).
+		Model(&SubredditMember{}).
+		Where("user_id = ? AND subreddit_id > ?", userID, afterID).
+		Order("subreddit_id").
+		Limit(limit).
+		Pluck("subreddit_id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return RecountBatch{}, err
+	}
+	return repo.recountMemberCounts(ctx, ids, now)
+}
+
+// recountMemberCounts recounts the subreddits of ids, which must be sorted so the last one is the cursor
+func (repo *Repository) recountMemberCounts(ctx context.Context, ids []uuid.UUID, now time.Time) (RecountBatch, error) {
+	var drifts []int
+	err := repo.conn(ctx).
+		Raw(
+			"UPDATE subreddits SET member_count = counted.n, updated_at = ? "+
+				"FROM (SELECT s.id, s.member_count AS stored, "+
+				"(SELECT count(*) FROM subreddit_members m WHERE m.subreddit_id = s.id) AS n "+
+				"FROM subreddits s WHERE s.id IN ?) counted "+
+				"WHERE subreddits.id = counted.id AND subreddits.member_count <> counted.n "+
+				"RETURNING abs(counted.n - counted.stored)",
+			now, ids,
+		).
+		Scan(&drifts).Error
+	if err != nil {
+		return RecountBatch{}, err
 	}
 
-	return repo.db.WithContext(ctx).
+	batch := RecountBatch{
+		LastID:    ids[len(ids)-1],
+		Scanned:   len(ids),
+		Corrected: len(drifts),
+	}
+	for _, drift := range drifts {
+		batch.MaxDrift = max(batch.MaxDrift, drift)
+	}
+	return batch, nil
+}
+
+// RemoveMember reports left=false when the user wasn't a member. Atomic like AddMember.
+func (repo *Repository) RemoveMember(ctx context.Context, subredditID, userID uuid.UUID) (left bool, err error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	err = repo.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			result := repo.conn(ctx).
+				Where("subreddit_id = ? AND user_id = ?", subredditID, userID).
+				Delete(&SubredditMember{})
+
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error // Already not a member, idempotent behavior
+			}
+
+			left = true
+			return repo.conn(ctx).
+				Model(&Subreddit{}).
+				Where("id = ?", subredditID).
+				Update("member_count", gorm.Expr("member_count - 1")).Error
+		},
+	)
+	return left && err == nil, err
+}
+
+// GetListByTopic lists public subreddits tagged with the topic, most popular first.
+// Joins through the (topic_id, subreddit_id) primary key of subreddit_topics.
+func (repo *Repository) GetListByTopic(ctx context.Context, topicID uuid.UUID, page utils.Pagination) (
+	[]Subreddit,
+	int64,
+	error,
+) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var subreddits []Subreddit
+	var total int64
+
+	query := repo.readConn(ctx).
 		Model(&Subreddit{}).
-		Where("id = ?", subredditID).
-		UpdateColumn(
-			"member_count",
-			gorm.Expr("member_count + 1"),
-		).Error
+		Joins("INNER JOIN subreddit_topics ON subreddit_topics.subreddit_id = subreddits.id").
+		Where("subreddit_topics.topic_id = ?", topicID).
+		Where("subreddits.is_public = ?", true).
+		Where("subreddits.deleted_at IS NULL").
+		Where("subreddits.purge_at IS NULL").
+		Session(&gorm.Session{})
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.
+		Preload(
+			"Creator", func(db *gorm.DB) *gorm.DB {
+				// Listing pages are cached in Redis, never pull credentials along with the creator
+				return db.Select("id", "username", "email", "created_at", "updated_at", "deleted_at")
+			},
+		).
+		Preload("Topics").
+		Order("subreddits.member_count DESC").
+		Order("subreddits.created_at DESC").
+		Offset(page.Offset()).
+		Limit(page.PageSize).
+		Find(&subreddits).Error
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return subreddits, total, nil
 }
 
-func (repo *Repository) RemoveMember(ctx context.Context, subredditID, userID uuid.UUID) error {
-	result := repo.db.WithContext(ctx).
-		Where("subreddit_id = ? AND user_id = ?", subredditID, userID).
-		Delete(&SubredditMember{})
+func (repo *Repository) GetTopicBySlug(ctx context.Context, slug string) (*Topic, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
 
-	if result.Error != nil {
-		return result.Error
+	var topic Topic
+	err := repo.conn(ctx).Where("slug = ?", slug).Take(&topic).Error
+	if err != nil {
+		return nil, err
 	}
+	return &topic, nil
+}
+
+func (repo *Repository) GetTopicsBySlugs(ctx context.Context, slugs []string) ([]Topic, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var topics []Topic
+	err := repo.conn(ctx).Where("slug IN ?", slugs).Find(&topics).Error
+	if err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+func (repo *Repository) CreateTopic(ctx context.Context, topic *Topic) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Create(topic).Error
+}
+
+func (repo *Repository) UpdateTopic(ctx context.Context, slug string, updates map[string]interface{}) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
+		Model(&Topic{}).
+		Where("slug = ?", slug).
+		Updates(updates)
+
+	if err := result.Error; err != nil {
+		return err
+	}
+
 	if result.RowsAffected == 0 {
-		return nil // Already not a member, idempotent behavior
+		return gorm.ErrRecordNotFound
 	}
 
-	return repo.db.WithContext(ctx).
-		Model(&Subreddit{}).
-		Where("id = ?", subredditID).
-		UpdateColumn(
-			"member_count",
-			gorm.Expr("member_count - 1"),
-		).Error
+	return nil
+}
+
+// ReplaceTopics swaps the subreddit's topic assignments for the given set
+func (repo *Repository) ReplaceTopics(ctx context.Context, subredditID uuid.UUID, topics []Topic) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Model(&Subreddit{ID: subredditID}).
+		Association("Topics").
+		Replace(topics)
+}
+
+// GetWikiPage returns gorm.ErrRecordNotFound as well when viewerID can't see the subreddit's content
+func (repo *Repository) GetWikiPage(ctx context.Context, subredditID, viewerID uuid.UUID, slug string) (
+	*WikiPage,
+	error,
+) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var page WikiPage
+	err := repo.readConn(ctx).
+		Preload(
+			"UpdatedBy", func(db *gorm.DB) *gorm.DB {
+				return db.Select("id", "username")
+			},
+		).
+		Where("subreddit_id = ? AND slug = ?", subredditID, slug).
+		Where("subreddit_id IN (?)", visibleSubredditIDs(repo.readConn(ctx), viewerID)).
+		Take(&page).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &page, nil
+}
+
+// UpsertWikiPage creates the page or overwrites content and editor of the existing (subreddit, slug) row
+func (repo *Repository) UpsertWikiPage(ctx context.Context, page *WikiPage) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "subreddit_id"}, {Name: "slug"}},
+				DoUpdates: clause.AssignmentColumns([]string{"content", "updated_by_id", "updated_at"}),
+			},
+		).
+		Create(page).Error
 }
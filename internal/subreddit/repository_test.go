@@ -0,0 +1,250 @@
+package subreddit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB renders SQL without a database, for asserting on generated queries
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(
+		postgres.New(postgres.Config{DSN: "host=localhost"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestOrderByBreaksTiesByID(t *testing.T) {
+	db := dryRunDB(t)
+	tests := []struct {
+		sort utils.SortSpec
+		want string
+	}{
+		{utils.SortSpec{Column: "name"}, `ORDER BY "subreddits"."name","subreddits"."id"`},
+		{
+			utils.SortSpec{Column: "member_count", Desc: true},
+			`ORDER BY "subreddits"."member_count" DESC,"subreddits"."id" DESC`,
+		},
+	}
+
+	for _, tt := range tests {
+		sql := db.ToSQL(
+			func(tx *gorm.DB) *gorm.DB {
+				return tx.Model(&Subreddit{}).Order(orderBy(tt.sort)).Find(&[]Subreddit{})
+			},
+		)
+		if !strings.Contains(sql, tt.want) {
+			t.Errorf("query %q does not contain %q", sql, tt.want)
+		}
+	}
+}
+
+// A statement stuck behind a row lock stands in for a slow query: the per-query timeout has to cut it off
+// long before the request's own deadline, leaving the rest of the budget to the caller
+func TestQueryTimeoutFiresBeforeRequestTimeout(t *testing.T) {
+	db := testutil.Postgres(t, utils.RealClock{})
+	const queryTimeout = 200 * time.Millisecond
+	repo := NewRepository(db, queryTimeout)
+	userRepo := user.NewRepository(db, 0)
+	creator := &user.User{
+		ID:               utils.NewID(),
+		Username:         "creator",
+		Email:            "creator@example.com",
+		EmailNormalized:  "creator@example.com",
+		UsernameSkeleton: utils.Skeleton("creator"),
+	}
+	if err := userRepo.Create(context.Background(), creator); err != nil {
+		t.Fatal(err)
+	}
+	sub := &Subreddit{ID: utils.NewID(), Name: "locked", DisplayName: "Locked", CreatorID: creator.ID}
+	if err := repo.Create(context.Background(), sub); err != nil {
+		t.Fatal(err)
+	}
+
+	locker := db.WriteDB().Begin()
+	if err := locker.Exec("SELECT 1 FROM subreddits WHERE id = ? FOR UPDATE", sub.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := repo.UpdateReturning(ctx, sub.ID, map[string]interface{}{"display_name": "Renamed"})
+	elapsed := time.Since(start)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("UpdateReturning behind a lock: err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed < queryTimeout || elapsed > 5*queryTimeout {
+		t.Errorf("UpdateReturning gave up after %s, want about %s", elapsed, queryTimeout)
+	}
+	if ctx.Err() != nil {
+		t.Errorf("request context expired too: %v", ctx.Err())
+	}
+
+	// Once the lock is gone the same request context still has room for the update
+	if err := locker.Rollback().Error; err != nil {
+		t.Fatal(err)
+	}
+	updated, err := repo.UpdateReturning(ctx, sub.ID, map[string]interface{}{"display_name": "Renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.ID != sub.ID || updated.Name != "locked" || updated.DisplayName != "Renamed" {
+		t.Errorf("UpdateReturning = %+v, want the whole updated row", updated)
+	}
+}
+
+func TestUpdateReturningUnknownID(t *testing.T) {
+	repo := NewRepository(testutil.Postgres(t, utils.RealClock{}), 0)
+
+	_, err := repo.UpdateReturning(context.Background(), utils.NewID(), map[string]interface{}{"display_name": "x"})
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("err = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
+
+// recordQueries makes db append name to hits for every statement it would run
+func recordQueries(t *testing.T, db *gorm.DB, name string, hits *[]string) {
+	t.Helper()
+
+	record := func(*gorm.DB) { *hits = append(*hits, name) }
+	callbacks := db.Callback()
+	for _, err := range []error{
+		callbacks.Query().Before("gorm:query").Register("test:record", record),
+		callbacks.Create().Before("gorm:create").Register("test:record", record),
+		callbacks.Update().Before("gorm:update").Register("test:record", record),
+		callbacks.Delete().Before("gorm:delete").Register("test:record", record),
+		callbacks.Row().Before("gorm:row").Register("test:record", record),
+		callbacks.Raw().Before("gorm:raw").Register("test:record", record),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadsUseReplica(t *testing.T) {
+	var hits []string
+	primary, replica := dryRunDB(t), dryRunDB(t)
+	recordQueries(t, primary, "primary", &hits)
+	recordQueries(t, replica, "replica", &hits)
+	repo := NewRepository(database.NewDB(primary, replica), 0)
+	id := utils.NewID()
+	page := utils.Pagination{Page: 1, PageSize: 10}
+	byName := utils.SortSpec{Column: "name"}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context)
+		want string
+	}{
+		{"GetByID", func(ctx context.Context) { _, _ = repo.GetByID(ctx, id) }, "replica"},
+		{"GetList", func(ctx context.Context) { _, _, _ = repo.GetList(ctx, byName, page, ListFilter{}) }, "replica"},
+		{"GetVisibleByName", func(ctx context.Context) { _, _ = repo.GetVisibleByName(ctx, "golang", id) }, "replica"},
+		{"GetListByTopic", func(ctx context.Context) { _, _, _ = repo.GetListByTopic(ctx, id, page) }, "replica"},
+		{"Create", func(ctx context.Context) { _ = repo.Create(ctx, &Subreddit{ID: id, Name: "golang"}) }, "primary"},
+		{"Update", func(ctx context.Context) { _ = repo.Update(ctx, id, map[string]interface{}{"is_nsfw": true}) }, "primary"},
+		{"IsMember", func(ctx context.Context) { _, _ = repo.IsMember(ctx, id, id) }, "primary"},
+	}
+	for _, tt := range tests {
+		hits = nil
+		tt.call(context.Background())
+		if len(hits) == 0 || slices.ContainsFunc(hits, func(hit string) bool { return hit != tt.want }) {
+			t.Errorf("%s ran on %v, want only the %s", tt.name, hits, tt.want)
+		}
+	}
+
+	// Read-your-writes pins a request's reads to the primary
+	hits = nil
+	_, _ = repo.GetByID(utils.WithPrimaryReads(context.Background()), id)
+	if len(hits) == 0 || slices.Contains(hits, "replica") {
+		t.Errorf("pinned GetByID ran on %v, want only the primary", hits)
+	}
+
+	// Without a replica reads fall back to the primary
+	hits = nil
+	_, _ = NewRepository(database.NewDB(primary, nil), 0).GetByID(context.Background(), id)
+	if len(hits) == 0 || slices.Contains(hits, "replica") {
+		t.Errorf("GetByID without a replica ran on %v, want the primary", hits)
+	}
+}
+
+// AddMember and RemoveMember called outside a service transaction must still roll back the membership row
+// when the member_count update after it fails
+func TestMembershipChangeRollsBackWithCount(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	repo := NewRepository(env.db, 0)
+	creator := env.createUser(t, "creator")
+	member := env.createUser(t, "member")
+	subredditID := env.createSubreddit(t, creator, "golang")
+
+	errCrash := errors.New("simulated crash before the count update")
+	var failCountUpdate atomic.Bool
+	err := env.db.WriteDB().Callback().Update().Before("gorm:update").Register(
+		"test:fail_count_update", func(tx *gorm.DB) {
+			if failCountUpdate.Load() && tx.Statement.Table == "subreddits" {
+				_ = tx.AddError(errCrash)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertState := func(step string, wantMember bool, wantCount int) {
+		t.Helper()
+		isMember, err := repo.IsMember(ctx, subredditID, member)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := repo.GetByID(utils.WithPrimaryReads(ctx), subredditID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if isMember != wantMember || s.MemberCount != wantCount {
+			t.Errorf("%s: member %v, member_count %d; want %v, %d", step, isMember, s.MemberCount, wantMember, wantCount)
+		}
+	}
+
+	failCountUpdate.Store(true)
+	if joined, err := repo.AddMember(ctx, subredditID, member); !errors.Is(err, errCrash) || joined {
+		t.Fatalf("AddMember with a failing count update = %v, %v; want false, the crash", joined, err)
+	}
+	assertState("failed join", false, 1)
+
+	failCountUpdate.Store(false)
+	if joined, err := repo.AddMember(ctx, subredditID, member); err != nil || !joined {
+		t.Fatalf("AddMember = %v, %v", joined, err)
+	}
+	assertState("join", true, 2)
+
+	failCountUpdate.Store(true)
+	if left, err := repo.RemoveMember(ctx, subredditID, member); !errors.Is(err, errCrash) || left {
+		t.Fatalf("RemoveMember with a failing count update = %v, %v; want false, the crash", left, err)
+	}
+	assertState("failed leave", true, 2)
+
+	failCountUpdate.Store(false)
+	if left, err := repo.RemoveMember(ctx, subredditID, member); err != nil || !left {
+		t.Fatalf("RemoveMember = %v, %v", left, err)
+	}
+	assertState("leave", false, 1)
+}
@@ -5,17 +5,78 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(router *gin.Engine, h *Handler) {
+func RegisterRoutes(router *gin.Engine, h *Handler, clock utils.Clock) {
+	requireAuth := utils.JWTAuthMiddleware(&h.config.JWT, clock)
+	optionalAuth := utils.OptionalJWTAuthMiddleware(&h.config.JWT, clock)
+
+	// Paginated listings share one pool, member lists join through memberships and weigh more
+	listings := utils.NewConcurrencyLimiter(
+		"subreddit_listings",
+		h.config.Server.ConcurrencyLimits["subreddit_listings"],
+	)
+	// Hottest anonymous reads, identical concurrent ones share a single service call
+	coalesceByID := utils.NewCoalescer("subreddit_by_id")
+	coalesceByName := utils.NewCoalescer("subreddit_by_name")
+	coalesceByTopic := utils.NewCoalescer("subreddits_by_topic")
+
 	subredditRouter := router.Group("/subreddits")
 	{
-		subredditRouter.GET("", h.GetSubredditList)
-		subredditRouter.GET(":id", h.GetSubreddit)
+		subredditRouter.GET(
+			"",
+			optionalAuth,
+			listings.Limit(1),
+			h.GetSubredditList,
+		)
+		subredditRouter.GET(
+			":id",
+			optionalAuth,
+			coalesceByID.Middleware(),
+			h.GetSubreddit,
+		)
+		subredditRouter.HEAD(":id", optionalAuth, h.HeadSubreddit)
+		subredditRouter.GET(
+			"by-topic/:slug",
+			optionalAuth,
+			coalesceByTopic.Middleware(),
+			listings.Limit(1),
+			h.GetSubredditsByTopic,
+		)
+		subredditRouter.GET(
+			"by-name/:name",
+			optionalAuth,
+			coalesceByName.Middleware(),
+			h.GetSubredditByName,
+		)
+		subredditRouter.GET(
+			":id/members",
+			optionalAuth,
+			listings.Limit(2),
+			h.GetMembers,
+		)
+		subredditRouter.GET(":id/wiki/:slug", optionalAuth, h.GetWikiPage)
+		subredditRouter.PUT(":id/wiki/:slug", requireAuth, h.PutWikiPage)
+		subredditRouter.GET(":id/membership", requireAuth, h.GetMembership)
 
-		subredditRouter.POST("", utils.JWTAuthMiddleware(&h.config.JWT), h.CreateSubreddit)
-		subredditRouter.PATCH(":id", utils.JWTAuthMiddleware(&h.config.JWT), h.UpdateSubreddit)
-		subredditRouter.DELETE(":id", utils.JWTAuthMiddleware(&h.config.JWT), h.DeleteSubreddit)
+		subredditRouter.POST("", requireAuth, h.CreateSubreddit)
+		subredditRouter.PATCH(":id", requireAuth, h.UpdateSubreddit)
+		subredditRouter.DELETE(":id", requireAuth, h.DeleteSubreddit)
+		subredditRouter.DELETE(":id/icon", requireAuth, h.DeleteIcon)
+		subredditRouter.DELETE(":id/banner", requireAuth, h.DeleteBanner)
 
-		subredditRouter.POST(":id/join", utils.JWTAuthMiddleware(&h.config.JWT), h.JoinSubreddit)
-		subredditRouter.POST(":id/leave", utils.JWTAuthMiddleware(&h.config.JWT), h.LeaveSubreddit)
+		subredditRouter.POST(":id/join", requireAuth, h.JoinSubreddit)
+		subredditRouter.POST(":id/leave", requireAuth, h.LeaveSubreddit)
+		subredditRouter.POST(":id/share", requireAuth, h.ShareSubreddit)
+		subredditRouter.POST(":id/cancel-deletion", requireAuth, h.CancelDeletion)
+		subredditRouter.POST("batch-join", requireAuth, h.BatchJoinSubreddits)
+		subredditRouter.POST("batch-leave", requireAuth, h.BatchLeaveSubreddits)
 	}
+
+	// Short share links, kept off /subreddits so they stay short
+	router.GET("/s/:code", optionalAuth, h.ResolveShareLink)
+
+	// Live under /me but are served here, the user package can't depend on subreddit
+	router.GET("/me/subreddits", requireAuth, listings.Limit(1), h.GetMySubreddits)
+	// Writes up to MaxMembershipsPerUser counters, so it gets a small pool of its own
+	recount := utils.NewConcurrencyLimiter("member_recount", h.config.Server.ConcurrencyLimits["member_recount"])
+	router.POST("/me/subreddits/recount", requireAuth, recount.Limit(1), h.RecountMySubreddits)
 }
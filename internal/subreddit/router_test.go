@@ -0,0 +1,205 @@
+package subreddit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// outsiderExpectation is what a route must answer a signed-in outsider of a private subreddit
+type outsiderExpectation int
+
+const (
+	expectNotFound       outsiderExpectation = iota // Reads about the subreddit itself
+	expectWithoutIt                                 // Listings: 200 without the subreddit in them
+	expectNotFoundOrNone                            // Listings scoped to something the test doesn't create
+	expectForbidden
+	expectNoAccess // Writes, only checked for not letting the outsider in
+)
+
+// Every subreddit route must be listed here, so a new one can't skip the check
+var outsiderExpectations = map[string]outsiderExpectation{
+	"GET /subreddits":                      expectWithoutIt,
+	"GET /subreddits/:id":                  expectNotFound,
+	"HEAD /subreddits/:id":                 expectNotFound,
+	"GET /subreddits/by-topic/:slug":       expectNotFoundOrNone,
+	"GET /subreddits/by-name/:name":        expectNotFound,
+	"GET /subreddits/:id/members":          expectNotFound,
+	"GET /subreddits/:id/wiki/:slug":       expectNotFound,
+	"GET /subreddits/:id/membership":       expectNotFound,
+	"GET /s/:code":                         expectNotFound,
+	"GET /me/subreddits":                   expectWithoutIt,
+	"PUT /subreddits/:id/wiki/:slug":       expectNoAccess,
+	"POST /subreddits":                     expectNoAccess,
+	"PATCH /subreddits/:id":                expectNoAccess,
+	"DELETE /subreddits/:id":               expectNoAccess,
+	"DELETE /subreddits/:id/icon":          expectNoAccess,
+	"DELETE /subreddits/:id/banner":        expectNoAccess,
+	"POST /subreddits/:id/join":            expectForbidden,
+	"POST /subreddits/:id/leave":           expectNoAccess,
+	"POST /subreddits/:id/share":           expectNoAccess,
+	"POST /subreddits/:id/cancel-deletion": expectNoAccess,
+	"POST /subreddits/batch-join":          expectNoAccess,
+	"POST /subreddits/batch-leave":         expectNoAccess,
+	"POST /me/subreddits/recount":          expectNoAccess,
+}
+
+// Walks every subreddit route as a signed-in outsider of a private subreddit. Reads must not reveal it (404, or
+// listings without it) and no route may let the outsider in, so a new route can't quietly skip the scope.
+func TestRoutesDontGrantPrivateSubredditAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	env := newTestEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	outsider := env.createUser(t, "outsider")
+	private, err := env.service.CreateSubreddit(ctx, creator, "hideout", "Hideout", nil, nil, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareCode, err := env.service.ShareSubreddit(ctx, private.ID, creator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	engine := gin.New()
+	RegisterRoutes(engine, NewHandler(env.service, cfg), utils.RealClock{})
+	token, err := utils.GenerateJWT(utils.RealClock{}, cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, outsider.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paramValues := strings.NewReplacer(
+		":id", private.ID.String(), ":slug", "rules", ":name", "hideout", ":code", shareCode,
+	)
+	body := `{"subreddit_ids":["` + private.ID.String() + `"],"content":"x","name":"x"}`
+	for _, route := range engine.Routes() {
+		key := route.Method + " " + route.Path
+		expectation, ok := outsiderExpectations[key]
+		if !ok {
+			t.Errorf("%s has no entry in outsiderExpectations", key)
+			continue
+		}
+
+		req := httptest.NewRequest(route.Method, paramValues.Replace(route.Path), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+
+		if _, err := env.service.repo.GetVisibleByID(ctx, private.ID, outsider); err == nil {
+			t.Fatalf("%s (status %d) let the outsider into the private subreddit", key, rec.Code)
+		}
+
+		revealed := strings.Contains(rec.Body.String(), private.ID.String()) ||
+			strings.Contains(rec.Body.String(), "hideout")
+		switch expectation {
+		case expectNotFound:
+			if rec.Code != http.StatusNotFound {
+				t.Errorf("%s: status %d, want %d", key, rec.Code, http.StatusNotFound)
+			}
+		case expectWithoutIt:
+			if rec.Code != http.StatusOK || revealed {
+				t.Errorf("%s: status %d, body %s; want 200 without the private subreddit", key, rec.Code, rec.Body)
+			}
+		case expectNotFoundOrNone:
+			if rec.Code != http.StatusNotFound && (rec.Code != http.StatusOK || revealed) {
+				t.Errorf("%s: status %d, body %s; want 404 or 200 without it", key, rec.Code, rec.Body)
+			}
+		case expectForbidden:
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("%s: status %d, want %d", key, rec.Code, http.StatusForbidden)
+			}
+		}
+	}
+
+	// The scope hides it from outsiders only, its members still read it
+	creatorToken, err := utils.GenerateJWT(utils.RealClock{}, cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, creator.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, target := range []string{
+		"GET /subreddits/" + private.ID.String(),
+		"HEAD /subreddits/" + private.ID.String(),
+		"GET /subreddits/by-name/hideout",
+		"GET /subreddits/" + private.ID.String() + "/members",
+	} {
+		method, path, _ := strings.Cut(target, " ")
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+creatorToken)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s as the creator: status %d, want 200", target, rec.Code)
+		}
+	}
+}
+
+// Runs without a database, so a route missing from outsiderExpectations fails every CI run
+func TestOutsiderExpectationsCoverEveryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	engine := gin.New()
+	RegisterRoutes(engine, NewHandler(nil, cfg), utils.RealClock{})
+
+	var routes []string
+	for _, route := range engine.Routes() {
+		routes = append(routes, route.Method+" "+route.Path)
+		if _, ok := outsiderExpectations[route.Method+" "+route.Path]; !ok {
+			t.Errorf("%s %s has no entry in outsiderExpectations", route.Method, route.Path)
+		}
+	}
+	for key := range outsiderExpectations {
+		if !slices.Contains(routes, key) {
+			t.Errorf("outsiderExpectations lists %s, which isn't a route anymore", key)
+		}
+	}
+}
+
+// Both lookups build the same response, the creator sees the share counters on either
+func TestSubredditLookupsAgreeOnFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	env := newTestEnv(t, config.SubredditConfig{})
+	creator := env.createUser(t, "creator")
+	id := env.createSubreddit(t, creator, "lookups")
+
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	engine := gin.New()
+	RegisterRoutes(engine, NewHandler(env.service, cfg), utils.RealClock{})
+	token, err := utils.GenerateJWT(utils.RealClock{}, cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, creator.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bodies []map[string]any
+	for _, path := range []string{"/subreddits/" + id.String(), "/subreddits/by-name/lookups"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", path, rec.Code)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := body["share_count"]; !ok {
+			t.Errorf("GET %s as the creator: no share_count in %v", path, body)
+		}
+		bodies = append(bodies, body)
+	}
+	if !reflect.DeepEqual(bodies[0], bodies[1]) {
+		t.Errorf("by ID %v\nby name %v\nwant the same response", bodies[0], bodies[1])
+	}
+}
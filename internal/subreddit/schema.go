@@ -4,71 +4,279 @@ import (
 	"time"
 
 	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/google/uuid"
 )
 
+// ListSortFields whitelists the `sort` values accepted by the subreddit list endpoint
+var ListSortFields = utils.SortFields{
+	"created_at":   {Column: "created_at", Desc: true},
+	"name":         {Column: "name"},
+	"member_count": {Column: "member_count", Desc: true},
+}
+
+var DefaultListSort = utils.SortSpec{Column: "created_at", Desc: true}
+
+// Values of the `mine` list filter, only honoured for authenticated requests
+const (
+	MineFilterCreated = "created"
+	MineFilterJoined  = "joined"
+	MineFilterAll     = "all"
+)
+
+// ListFilter narrows subreddit lists, nil fields are not applied
+type ListFilter struct {
+	Language *string
+	Since    *time.Time // Inclusive
+	Until    *time.Time // Exclusive
+}
+
+// RecountBatch sums up one batch of a counter recount: LastID is the cursor, MaxDrift the largest correction
+type RecountBatch struct {
+	LastID    uuid.UUID
+	Scanned   int
+	Corrected int
+	MaxDrift  int
+}
+
+// RecountSummary adds up the batches of a recount, see Service.RecountJoinedMemberCounts
+type RecountSummary struct {
+	Scanned   int `json:"scanned"`
+	Corrected int `json:"corrected"`
+	MaxDrift  int `json:"max_drift"`
+}
+
 type SubredditResponse struct {
-	ID          uuid.UUID               `json:"id"`
-	Name        string                  `json:"name"`
-	DisplayName string                  `json:"display_name"`
-	Description *string                 `json:"description,omitempty"`
-	IconURL     *string                 `json:"icon_url,omitempty"`
-	Creator     user.PublicUserResponse `json:"creator"`
-	MemberCount int                     `json:"member_count"`
-	PostCount   int                     `json:"post_count"`
-	IsPublic    bool                    `json:"is_public"`
-	IsNSFW      bool                    `json:"is_nsfw"`
-	CreatedAt   time.Time               `json:"created_at"`
-	UpdatedAt   time.Time               `json:"updated_at"`
+	ID                uuid.UUID               `json:"id"`
+	Name              string                  `json:"name"`
+	DisplayName       string                  `json:"display_name"`
+	Description       *string                 `json:"description,omitempty"`
+	DescriptionMaxLen int                     `json:"description_max_len"`
+	IconURL           *string                 `json:"icon_url,omitempty"`
+	BannerURL         *string                 `json:"banner_url,omitempty"`
+	PrimaryColor      *string                 `json:"primary_color,omitempty"`
+	BannerColor       *string                 `json:"banner_color,omitempty"`
+	Creator           user.PublicUserResponse `json:"creator"`
+	MemberCount       int                     `json:"member_count"`
+	PostCount         int                     `json:"post_count"`
+	IsPublic          bool                    `json:"is_public"`
+	IsNSFW            bool                    `json:"is_nsfw"`
+	Language          *string                 `json:"language,omitempty"`
+	PurgeAt           *time.Time              `json:"purge_at,omitempty"` // Set while deletion is pending
+	Topics            []TopicResponse         `json:"topics"`
+	MemberSince       *time.Time              `json:"member_since,omitempty"` // Only for an authenticated member
+	ShareCount        *int64                  `json:"share_count,omitempty"`  // Only for moderators
+	ClickCount        *int64                  `json:"click_count,omitempty"`  // Only for moderators
+	CreatedAt         time.Time               `json:"created_at"`
+	UpdatedAt         time.Time               `json:"updated_at"`
+}
+
+// JoinedSubredditResponse is a GET /me/subreddits item
+type JoinedSubredditResponse struct {
+	SubredditResponse
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type JoinedSubredditListResponse struct {
+	Subreddits []JoinedSubredditResponse `json:"subreddits"`
+	Pagination utils.PaginationMeta      `json:"pagination"`
+}
+
+// ShareResponse carries the short link; URL is relative to the API host
+type ShareResponse struct {
+	Code string `json:"code"`
+	URL  string `json:"url"`
+}
+
+type MemberResponse struct {
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type MemberListResponse struct {
+	Members    []MemberResponse     `json:"members"`
+	Pagination utils.PaginationMeta `json:"pagination"`
+}
+
+type PutWikiPageRequest struct {
+	Content string `json:"content"`
+}
+
+// WikiPageResponse carries the markdown source for editing and the sanitized HTML for display
+type WikiPageResponse struct {
+	Slug        string    `json:"slug"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	UpdatedBy   string    `json:"updated_by"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type MembershipResponse struct {
+	IsMember bool       `json:"is_member"`
+	JoinedAt *time.Time `json:"joined_at,omitempty"`
+}
+
+type TopicResponse struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	IsSensitive bool   `json:"is_sensitive"`
 }
 
 type SubredditListResponse struct {
-	Subreddits []SubredditResponse `json:"subreddits"`
+	Subreddits []SubredditResponse  `json:"subreddits"`
+	Pagination utils.PaginationMeta `json:"pagination"`
 }
 
 type CreateSubredditRequest struct {
-	Name        string  `json:"name"`
-	DisplayName string  `json:"display_name"`
-	Description *string `json:"description,omitempty"`
-	IconURL     *string `json:"icon_url,omitempty"`
-	IsPublic    *bool   `json:"is_public,omitempty"`
-	IsNSFW      *bool   `json:"is_nsfw,omitempty"`
+	Name        string   `json:"name"`
+	DisplayName string   `json:"display_name"`
+	Description *string  `json:"description,omitempty"`
+	IconURL     *string  `json:"icon_url,omitempty"`
+	IsPublic    *bool    `json:"is_public,omitempty"`
+	IsNSFW      *bool    `json:"is_nsfw,omitempty"`
+	Topics      []string `json:"topics,omitempty"`
+	Language    *string  `json:"language,omitempty"`
 }
 
+// UpdateSubredditRequest uses utils.Optional for nullable columns, so `"description": null` clears the value
+// while omitting the key leaves it untouched. A new description_max_len only applies to later edits,
+// an existing description over a lowered limit is kept as is.
 type UpdateSubredditRequest struct {
-	DisplayName *string `json:"display_name"`
-	Description *string `json:"description,omitempty"`
-	IconURL     *string `json:"icon_url,omitempty"`
-	IsPublic    *bool   `json:"is_public"`
-	IsNSFW      *bool   `json:"is_nsfw"`
+	DisplayName       *string                `json:"display_name"`
+	Description       utils.Optional[string] `json:"description"`
+	IconURL           utils.Optional[string] `json:"icon_url"`
+	IsPublic          *bool                  `json:"is_public"`
+	IsNSFW            *bool                  `json:"is_nsfw"`
+	Topics            *[]string              `json:"topics"`
+	DescriptionMaxLen utils.Optional[int]    `json:"description_max_len"`
+	BannerURL         utils.Optional[string] `json:"banner_url"`
+	PrimaryColor      utils.Optional[string] `json:"primary_color"`
+	BannerColor       utils.Optional[string] `json:"banner_color"`
+	Language          utils.Optional[string] `json:"language"`
 }
 
+type BatchMembershipRequest struct {
+	SubredditIDs []uuid.UUID `json:"subreddit_ids"`
+}
+
+// BatchMembershipResult reports one ID of a batch join/leave; Code is set on failure
+type BatchMembershipResult struct {
+	SubredditID uuid.UUID `json:"subreddit_id"`
+	Success     bool      `json:"success"`
+	Code        string    `json:"code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type BatchMembershipResponse struct {
+	Results []BatchMembershipResult `json:"results"`
+}
+
+type CreateTopicRequest struct {
+	Slug        string `json:"slug"`
+	Name        string `json:"name"`
+	IsSensitive *bool  `json:"is_sensitive,omitempty"`
+}
+
+type UpdateTopicRequest struct {
+	Name        *string `json:"name"`
+	IsSensitive *bool   `json:"is_sensitive"`
+}
+
+// ToSubredditResponse expects s.Creator to be preloaded. A creator that wasn't loaded, belongs to another
+// user or was soft-deleted is rendered as the "[deleted]" placeholder rather than as empty strings.
 func ToSubredditResponse(s *Subreddit) SubredditResponse {
+	creator := user.ToPublicUserResponse(&user.User{})
+	if s.Creator.ID == s.CreatorID {
+		creator = user.ToPublicUserResponse(&s.Creator)
+	}
+
 	return SubredditResponse{
-		ID:          s.ID,
-		Name:        s.Name,
-		DisplayName: s.DisplayName,
-		Description: s.Description,
-		IconURL:     s.IconURL,
-		Creator: user.PublicUserResponse{
-			Username: s.Creator.Username,
-			Email:    s.Creator.Email,
-		},
-		MemberCount: s.MemberCount,
-		PostCount:   s.PostCount,
-		IsPublic:    s.IsPublic,
-		IsNSFW:      s.IsNSFW,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
+		ID:                s.ID,
+		Name:              s.Name,
+		DisplayName:       s.DisplayName,
+		Description:       s.Description,
+		DescriptionMaxLen: s.DescriptionLimit(),
+		IconURL:           s.IconURL,
+		BannerURL:         s.BannerURL,
+		PrimaryColor:      s.PrimaryColor,
+		BannerColor:       s.BannerColor,
+		Language:          s.Language,
+		PurgeAt:           s.PurgeAt,
+		Creator:           creator,
+		MemberCount:       s.MemberCount,
+		PostCount:         s.PostCount,
+		IsPublic:          s.IsPublic,
+		IsNSFW:            s.IsNSFW,
+		Topics:            ToTopicListResponse(s.Topics),
+		CreatedAt:         s.CreatedAt,
+		UpdatedAt:         s.UpdatedAt,
 	}
 }
 
-func ToSubredditListResponse(subreddits []Subreddit) SubredditListResponse {
+func ToSubredditListResponse(subreddits []Subreddit, meta utils.PaginationMeta) SubredditListResponse {
 	responses := make([]SubredditResponse, len(subreddits))
 	for i := range subreddits {
 		responses[i] = ToSubredditResponse(&subreddits[i])
 	}
 	return SubredditListResponse{
 		Subreddits: responses,
+		Pagination: meta,
+	}
+}
+
+func ToJoinedSubredditListResponse(
+	memberships []Membership,
+	meta utils.PaginationMeta,
+) JoinedSubredditListResponse {
+	responses := make([]JoinedSubredditResponse, len(memberships))
+	for i := range memberships {
+		responses[i] = JoinedSubredditResponse{
+			SubredditResponse: ToSubredditResponse(&memberships[i].Subreddit),
+			JoinedAt:          memberships[i].JoinedAt,
+		}
+	}
+	return JoinedSubredditListResponse{
+		Subreddits: responses,
+		Pagination: meta,
+	}
+}
+
+func ToMemberListResponse(members []Member, meta utils.PaginationMeta) MemberListResponse {
+	responses := make([]MemberResponse, len(members))
+	for i := range members {
+		responses[i] = MemberResponse{
+			Username: members[i].Username,
+			JoinedAt: members[i].JoinedAt,
+		}
+	}
+	return MemberListResponse{
+		Members:    responses,
+		Pagination: meta,
+	}
+}
+
+func ToWikiPageResponse(page *WikiPage) WikiPageResponse {
+	return WikiPageResponse{
+		Slug:        page.Slug,
+		Content:     page.Content,
+		ContentHTML: utils.RenderMarkdown(page.Content),
+		UpdatedBy:   page.UpdatedBy.Username,
+		UpdatedAt:   page.UpdatedAt,
+	}
+}
+
+func ToTopicResponse(t *Topic) TopicResponse {
+	return TopicResponse{
+		Slug:        t.Slug,
+		Name:        t.Name,
+		IsSensitive: t.IsSensitive,
+	}
+}
+
+func ToTopicListResponse(topics []Topic) []TopicResponse {
+	responses := make([]TopicResponse, len(topics))
+	for i := range topics {
+		responses[i] = ToTopicResponse(&topics[i])
 	}
+	return responses
 }
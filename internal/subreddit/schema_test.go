@@ -0,0 +1,67 @@
+package subreddit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestToSubredditResponseCreator(t *testing.T) {
+	creatorID := uuid.New()
+	active := user.User{ID: creatorID, Username: "founder", Email: "founder@example.com"}
+	deleted := active
+	deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	placeholder := user.PublicUserResponse{Username: user.DeletedUsername}
+
+	tests := []struct {
+		name    string
+		creator user.User
+		want    user.PublicUserResponse
+	}{
+		{"active creator", active, user.PublicUserResponse{Username: "founder", Email: "founder@example.com"}},
+		{"soft-deleted creator", deleted, placeholder},
+	}
+	for _, tt := range tests {
+		got := ToSubredditResponse(&Subreddit{ID: uuid.New(), CreatorID: creatorID, Creator: tt.creator}).Creator
+		if got != tt.want {
+			t.Errorf("%s: creator %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToSubredditResponseWithoutLoadedCreator(t *testing.T) {
+	creatorID := uuid.New()
+	unloaded := Subreddit{ID: uuid.New(), Name: "golang", DisplayName: "Go", CreatorID: creatorID, MemberCount: 3}
+	mismatched := unloaded
+	mismatched.Name = "rust"
+	mismatched.Creator = user.User{ID: uuid.New(), Username: "intruder", Email: "intruder@example.com"}
+
+	list := ToSubredditListResponse([]Subreddit{unloaded, mismatched}, utils.PaginationMeta{Total: 2})
+	for _, got := range list.Subreddits {
+		if got.Creator != (user.PublicUserResponse{Username: user.DeletedUsername}) {
+			t.Errorf("%s: creator = %+v, want the [deleted] placeholder", got.Name, got.Creator)
+		}
+	}
+
+	// The rest of the response doesn't depend on the creator
+	body, err := json.Marshal(ToSubredditResponse(&unloaded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	creator, _ := decoded["creator"].(map[string]any)
+	if creator["username"] != user.DeletedUsername || creator["email"] != "" {
+		t.Errorf("creator JSON = %v, want [deleted] without an email", decoded["creator"])
+	}
+	if decoded["name"] != "golang" || decoded["member_count"] != float64(3) {
+		t.Errorf("response = %s, want the subreddit's own fields intact", body)
+	}
+}
@@ -3,75 +3,366 @@ package subreddit
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/flags"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/outbox"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
+// Auditor records subreddit state changes in the mod log. Implemented by admin.Repository,
+// which this package can't import.
+type Auditor interface {
+	RecordSubredditAction(ctx context.Context, actorID, subredditID uuid.UUID, action string) error
+}
+
 type Service struct {
-	repo      *Repository
-	validator *Validator
+	repo         *Repository
+	userService  *user.Service
+	txManager    *database.TxManager
+	outbox       *outbox.Publisher
+	auditor      Auditor
+	validator    *Validator
+	redis        *redis.Client
+	liteCache    *liteCache
+	topicPages   *utils.SWRCache[topicPage]
+	subredditCfg config.SubredditConfig
+	clock        utils.Clock
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(
+	repo *Repository,
+	userService *user.Service,
+	txManager *database.TxManager,
+	publisher *outbox.Publisher,
+	auditor Auditor,
+	redisClient *redis.Client,
+	subredditCfg config.SubredditConfig,
+	clock utils.Clock,
+) *Service {
 	return &Service{
-		repo:      repo,
-		validator: NewValidator(repo),
+		repo:        repo,
+		userService: userService,
+		txManager:   txManager,
+		outbox:      publisher,
+		auditor:     auditor,
+		validator:   NewValidator(repo, subredditCfg.AllowedLanguages, subredditCfg.DisplayNameMaxLen),
+		redis:       redisClient,
+		liteCache:   newLiteCache(redisClient, clock),
+		topicPages: utils.NewSWRCache[topicPage](
+			"topic_page",
+			redisClient,
+			TopicPageCachePrefix,
+			subredditCfg.TopicPageCache.SoftTTL,
+			subredditCfg.TopicPageCache.HardTTL,
+			clock,
+		).WithSoftTTL(
+			func(ctx context.Context) time.Duration {
+				return time.Duration(flags.Int(ctx, flags.TopicPageCacheSoftTTL)) * time.Second
+			},
+		),
+		subredditCfg: subredditCfg,
+		clock:        clock,
 	}
 }
 
+const (
+	TopicPageCachePrefix = "subreddits:by_topic:"
+
+	DeletionPurgeBatchSize = 100
+	MemberRecountBatchSize = 500
+
+	AuditActionDeletionScheduled = "subreddit.deletion_scheduled"
+	AuditActionDeletionCancelled = "subreddit.deletion_cancelled"
+	AuditActionPurge             = "subreddit.purge"
+)
+
 var (
-	ErrNotAuthorized      = errors.New("not authorized to perform this action")
-	ErrCreatorCannotLeave = errors.New("creator cannot leave subreddit, delete it instead")
+	ErrNotAuthorized          = errors.New("not authorized to perform this action")
+	ErrCreatorCannotLeave     = errors.New("creator cannot leave subreddit, delete it instead")
+	ErrMembershipLimitReached = errors.New("subreddit membership limit reached")
+	ErrSubredditPrivate       = errors.New("private subreddits can't be joined")
+	ErrSensitiveTopic         = errors.New("topic requires NSFW content to be enabled")
+	ErrUnavailable            = errors.New("subreddit storage unavailable")
+	ErrDeletionScheduled      = errors.New("subreddit deletion is already scheduled")
+	ErrDeletionNotScheduled   = errors.New("subreddit deletion is not scheduled")
 )
 
-func (s *Service) GetSubredditList(ctx context.Context) ([]Subreddit, error) {
-	return s.repo.GetList(ctx)
+// ParseListFilter validates raw list query values; an unsupported language, a malformed timestamp
+// or a since later than until is a validation error
+func (s *Service) ParseListFilter(language, since, until string) (ListFilter, error) {
+	var filter ListFilter
+	var errs ValidationErrors
+	if language = strings.ToLower(strings.TrimSpace(language)); language != "" {
+		if err := s.validator.ValidateLanguage(&language); err != nil {
+			errs = append(errs, NewValidationError("lang", err.Error()))
+		} else {
+			filter.Language = &language
+		}
+	}
+
+	var err error
+	if filter.Since, err = parseTimeBound("since", since); err != nil {
+		errs = append(errs, NewValidationError("since", err.Error()))
+	}
+	if filter.Until, err = parseTimeBound("until", until); err != nil {
+		errs = append(errs, NewValidationError("until", err.Error()))
+	}
+	if filter.Since != nil && filter.Until != nil && filter.Since.After(*filter.Until) {
+		errs = append(errs, NewValidationError("since", "since must not be later than until"))
+	}
+
+	if len(errs) > 0 {
+		return ListFilter{}, errs
+	}
+	return filter, nil
+}
+
+// parseTimeBound parses an optional RFC 3339 value, nil when blank
+func parseTimeBound(param, raw string) (*time.Time, error) {
+	if raw = strings.TrimSpace(raw); raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", param)
+	}
+	return &parsed, nil
+}
+
+func (s *Service) GetSubredditList(
+	ctx context.Context,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	return s.repo.GetList(ctx, sort, page, filter)
+}
+
+func (s *Service) GetCreatedSubreddits(
+	ctx context.Context,
+	userID uuid.UUID,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	return s.repo.GetCreatedByUser(ctx, userID, sort, page, filter)
+}
+
+// GetJoinedSubreddits also covers created subreddits, since creators are added as members and cannot leave
+func (s *Service) GetJoinedSubreddits(
+	ctx context.Context,
+	userID uuid.UUID,
+	sort utils.SortSpec,
+	page utils.Pagination,
+	filter ListFilter,
+) ([]Subreddit, int64, error) {
+	return s.repo.GetUserSubreddits(ctx, userID, sort, page, filter)
+}
+
+// RecountMemberCounts fixes drifted member counts batch by batch, see Repository.RecountMemberCounts
+func (s *Service) RecountMemberCounts(ctx context.Context, afterID uuid.UUID, limit int) (RecountBatch, error) {
+	return s.repo.RecountMemberCounts(ctx, afterID, limit, s.clock.Now())
+}
+
+// RecountJoinedMemberCounts fixes the member counts of every subreddit userID joined, for users seeing wrong
+// counts after an incident. Memberships are capped by MaxMembershipsPerUser, so this stays a few batches.
+func (s *Service) RecountJoinedMemberCounts(ctx context.Context, userID uuid.UUID) (RecountSummary, error) {
+	var summary RecountSummary
+	cursor := uuid.Nil
+	for {
+		batch, err := s.repo.RecountJoinedMemberCounts(ctx, userID, cursor, MemberRecountBatchSize, s.clock.Now())
+		if err != nil || batch.Scanned == 0 {
+			return summary, err
+		}
+		cursor = batch.LastID
+		summary.Scanned += batch.Scanned
+		summary.Corrected += batch.Corrected
+		summary.MaxDrift = max(summary.MaxDrift, batch.MaxDrift)
+	}
+}
+
+// GetMemberships is GetJoinedSubreddits with the join date of every item
+func (s *Service) GetMemberships(
+	ctx context.Context,
+	userID uuid.UUID,
+	sort utils.SortSpec,
+	page utils.Pagination,
+) ([]Membership, int64, error) {
+	subreddits, total, err := s.repo.GetUserSubreddits(ctx, userID, sort, page, ListFilter{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	subredditIDs := make([]uuid.UUID, len(subreddits))
+	for i := range subreddits {
+		subredditIDs[i] = subreddits[i].ID
+	}
+	members, err := s.repo.GetMemberships(ctx, userID, subredditIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	joinedAt := make(map[uuid.UUID]time.Time, len(members))
+	for _, member := range members {
+		joinedAt[member.SubredditID] = member.CreatedAt
+	}
+
+	memberships := make([]Membership, len(subreddits))
+	for i := range subreddits {
+		memberships[i] = Membership{
+			Subreddit: subreddits[i],
+			JoinedAt:  joinedAt[subreddits[i].ID],
+		}
+	}
+
+	return memberships, total, nil
 }
 
-func (s *Service) GetSubredditById(ctx context.Context, id uuid.UUID) (*Subreddit, error) {
-	return s.repo.GetByID(ctx, id, true)
+// GetMembership returns gorm.ErrRecordNotFound when the user is not a member
+func (s *Service) GetMembership(ctx context.Context, subredditID, userID uuid.UUID) (
+	*SubredditMember,
+	error,
+) {
+	return s.repo.GetMembership(ctx, subredditID, userID)
+}
+
+// SubredditExists is the cheap check behind HEAD /subreddits/:id, nothing is preloaded.
+// viewerID is uuid.Nil for anonymous viewers, private communities they can't read don't exist for them.
+func (s *Service) SubredditExists(ctx context.Context, id, viewerID uuid.UUID) (bool, error) {
+	return s.repo.ExistsVisibleByID(ctx, id, viewerID)
+}
+
+// GetSubredditById returns gorm.ErrRecordNotFound for private communities viewerID can't read
+func (s *Service) GetSubredditById(ctx context.Context, id, viewerID uuid.UUID) (*Subreddit, error) {
+	return s.repo.GetVisibleByID(ctx, id, viewerID)
+}
+
+// GetSubredditForModeration loads any subreddit regardless of visibility, for permission checks that follow
+func (s *Service) GetSubredditForModeration(ctx context.Context, id uuid.UUID) (*Subreddit, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// GetMembers returns gorm.ErrRecordNotFound for an unknown subreddit rather than an empty page.
+// viewerID is uuid.Nil for anonymous viewers.
+func (s *Service) GetMembers(ctx context.Context, subredditID, viewerID uuid.UUID, page utils.Pagination) (
+	[]Member,
+	int64,
+	error,
+) {
+	if _, err := s.repo.GetVisibleByID(ctx, subredditID, viewerID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.GetMembers(ctx, subredditID, viewerID, page)
+}
+
+// GetLiteByIDs returns the trimmed subreddits for feed mappers keyed by ID; unknown or deleted IDs are absent.
+// Hot IDs are served from the local LRU and Redis without touching Postgres.
+func (s *Service) GetLiteByIDs(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]SubredditLite, error) {
+	found, missing := s.liteCache.get(ctx, ids)
+	if len(missing) == 0 {
+		return found, nil
+	}
+
+	lites, err := s.repo.GetLiteByIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	s.liteCache.set(ctx, lites)
+
+	for _, lite := range lites {
+		found[lite.ID] = lite
+	}
+	return found, nil
+}
+
+// ListenForCacheInvalidation keeps this instance's local cache in sync with changes made on other instances
+func (s *Service) ListenForCacheInvalidation(ctx context.Context) {
+	s.liteCache.listen(ctx)
+}
+
+// GetSubredditByName is GetSubredditById by case-insensitive name
+func (s *Service) GetSubredditByName(ctx context.Context, name string, viewerID uuid.UUID) (*Subreddit, error) {
+	return s.repo.GetVisibleByName(ctx, strings.TrimSpace(name), viewerID)
 }
 
 func (s *Service) CreateSubreddit(
 	ctx context.Context, creatorID uuid.UUID, name string, displayName string, description *string,
-	iconURL *string, isPublic bool, isNSFW bool,
+	iconURL *string, isPublic bool, isNSFW bool, topicSlugs []string, language *string,
 ) (*Subreddit, error) {
+	// Trim once at the boundary so the stored values are exactly the ones that were validated
+	name = strings.TrimSpace(name)
+	displayName = utils.NormalizeText(strings.TrimSpace(displayName))
+	description = trimOptional(description)
+	iconURL = trimOptional(iconURL)
+	topicSlugs = normalizeTopicSlugs(topicSlugs)
+	language = lowerOptional(trimOptional(language))
 
-	if errs := s.validator.ValidateCreateSubredditInput(
-		ctx, name, displayName, description, iconURL,
-	); len(errs) > 0 {
+	errs, err := s.validator.ValidateCreateSubredditInput(
+		ctx, name, displayName, description, iconURL, topicSlugs, language,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
 		return nil, errs
 	}
 
+	topics, err := s.validator.ResolveTopics(ctx, topicSlugs)
+	if err != nil {
+		return nil, err
+	}
+
 	subreddit := &Subreddit{
-		ID:          uuid.New(),
+		ID:          utils.NewID(),
 		Name:        name,
 		DisplayName: displayName,
 		Description: description,
 		IconURL:     iconURL,
 		CreatorID:   creatorID,
-		MemberCount: 1,
+		MemberCount: 0, // The creator is counted by AddMember below
 		PostCount:   0,
 		IsPublic:    isPublic,
 		IsNSFW:      isNSFW,
+		Language:    language,
+		Topics:      topics, // Join rows are written together with the subreddit
 	}
 
-	err := s.repo.WithTx(
-		ctx, func(txRepo Repository) error {
-			if err := txRepo.Create(ctx, subreddit); err != nil {
+	err = s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			if err := s.ensureMembershipCapacity(ctx, subreddit.ID, creatorID); err != nil {
 				return err
 			}
-			if err := txRepo.AddMember(ctx, subreddit.ID, creatorID); err != nil {
+			if err := s.repo.Create(ctx, subreddit); err != nil {
 				return err
 			}
+			if _, err := s.repo.AddMember(ctx, subreddit.ID, creatorID); err != nil {
+				return err
+			}
+			subreddit.MemberCount = 1
 
-			return nil
+			return s.outbox.Publish(
+				ctx, outbox.EventSubredditCreated, SubredditEvent{
+					SubredditID: subreddit.ID,
+					UserID:      creatorID,
+				},
+			)
 		},
 	)
 
 	if err != nil {
 		return nil, err
 	}
+	s.invalidateTopicPages(ctx, subreddit.Topics)
 
 	return subreddit, nil
 }
@@ -85,26 +376,49 @@ func (s *Service) UpdateSubreddit(
 	error,
 ) {
 
-	_, err := s.ensureCreator(ctx, subredditID, userID)
+	existing, err := s.ensureCreator(ctx, subredditID, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if errs := s.validator.ValidateUpdateSubredditInput(req); len(errs) > 0 {
+	if req.DisplayName != nil {
+		trimmed := utils.NormalizeText(strings.TrimSpace(*req.DisplayName))
+		req.DisplayName = &trimmed
+	}
+	req.Description.Value = trimOptional(req.Description.Value)
+	req.IconURL.Value = trimOptional(req.IconURL.Value)
+	req.BannerURL.Value = trimOptional(req.BannerURL.Value)
+	req.PrimaryColor.Value = trimOptional(req.PrimaryColor.Value)
+	req.BannerColor.Value = trimOptional(req.BannerColor.Value)
+	req.Language.Value = lowerOptional(trimOptional(req.Language.Value))
+	if req.Topics != nil {
+		normalized := normalizeTopicSlugs(*req.Topics)
+		req.Topics = &normalized
+	}
+
+	if errs := s.validator.ValidateUpdateSubredditInput(req, existing); len(errs) > 0 {
 		return nil, errs
 	}
 
+	var topics []Topic
+	if req.Topics != nil {
+		if topics, err = s.validator.ResolveTopics(ctx, *req.Topics); err != nil {
+			return nil, err
+		}
+	}
+
 	// TODO: Implement better fields mapping
 	updates := make(map[string]interface{})
 
 	if req.DisplayName != nil {
 		updates["display_name"] = *req.DisplayName
 	}
-	if req.Description != nil {
-		updates["description"] = req.Description
+	// Explicit null arrives as Set with nil Value, which gorm writes as NULL
+	if req.Description.Set {
+		updates["description"] = req.Description.Value
 	}
-	if req.IconURL != nil {
-		updates["icon_url"] = req.IconURL
+	if req.IconURL.Set {
+		updates["icon_url"] = req.IconURL.Value
 	}
 	if req.IsPublic != nil {
 		updates["is_public"] = *req.IsPublic
@@ -112,32 +426,276 @@ func (s *Service) UpdateSubreddit(
 	if req.IsNSFW != nil {
 		updates["is_nsfw"] = *req.IsNSFW
 	}
+	if req.DescriptionMaxLen.Set {
+		updates["description_max_len"] = req.DescriptionMaxLen.Value
+	}
+	if req.BannerURL.Set {
+		updates["banner_url"] = req.BannerURL.Value
+	}
+	if req.PrimaryColor.Set {
+		updates["primary_color"] = lowerOptional(req.PrimaryColor.Value)
+	}
+	if req.BannerColor.Set {
+		updates["banner_color"] = lowerOptional(req.BannerColor.Value)
+	}
+	if req.Language.Set {
+		updates["language"] = req.Language.Value
+	}
 
-	err = s.repo.Update(ctx, subredditID, updates)
+	updated := existing
+	err = s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			if len(updates) > 0 {
+				if updated, err = s.repo.UpdateReturning(ctx, subredditID, updates); err != nil {
+					return err
+				}
+			}
+			if req.Topics != nil {
+				return s.repo.ReplaceTopics(ctx, subredditID, topics)
+			}
+			return nil
+		},
+	)
 	if err != nil {
 		return nil, err
 	}
+	s.liteCache.invalidate(ctx, subredditID)
+	s.invalidateTopicPages(ctx, existing.Topics) // Covers untagging
+	if req.Topics != nil {
+		s.invalidateTopicPages(ctx, topics)
+	}
+
+	// RETURNING only gives back the row itself, associations are taken from what we already loaded
+	updated.Creator = existing.Creator
+	updated.Topics = existing.Topics
+	if req.Topics != nil {
+		updated.Topics = topics
+	}
 
-	return s.repo.GetByID(ctx, subredditID, false)
+	return updated, nil
 }
 
+// RemoveIcon clears the icon URL so the community falls back to the default icon
+func (s *Service) RemoveIcon(ctx context.Context, subredditID, userID uuid.UUID) error {
+	_, err := s.ensureCreator(ctx, subredditID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(ctx, subredditID, map[string]interface{}{"icon_url": nil}); err != nil {
+		return err
+	}
+	s.liteCache.invalidate(ctx, subredditID)
+	return nil
+}
+
+// RemoveBanner clears the banner URL, the community falls back to banner_color or the default
+func (s *Service) RemoveBanner(ctx context.Context, subredditID, userID uuid.UUID) error {
+	_, err := s.ensureCreator(ctx, subredditID, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Update(ctx, subredditID, map[string]interface{}{"banner_url": nil})
+}
+
+// DeleteSubreddit only schedules the deletion: the community leaves listings right away, but the creator
+// can cancel it until the returned purge time, after which RunDeletionPurge soft-deletes it
 func (s *Service) DeleteSubreddit(
 	ctx context.Context,
 	subredditID,
 	userID uuid.UUID,
-) error {
-	_, err := s.ensureCreator(ctx, subredditID, userID)
+) (time.Time, error) {
+	subreddit, err := s.ensureCreator(ctx, subredditID, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := s.clock.Now().Add(s.subredditCfg.DeletionGracePeriod)
+	err = s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			scheduled, err := s.repo.ScheduleDeletion(ctx, subredditID, purgeAt)
+			if err != nil {
+				return err
+			}
+			if !scheduled {
+				return ErrDeletionScheduled
+			}
+			if err := s.auditor.RecordSubredditAction(
+				ctx, userID, subredditID, AuditActionDeletionScheduled,
+			); err != nil {
+				return err
+			}
+			return s.outbox.Publish(
+				ctx, outbox.EventSubredditDeletionScheduled, DeletionEvent{
+					SubredditID: subredditID,
+					UserID:      userID,
+					PurgeAt:     &purgeAt,
+				},
+			)
+		},
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.invalidateTopicPages(ctx, subreddit.Topics)
+
+	return purgeAt, nil
+}
+
+// CancelDeletion restores a community whose deletion is still within the grace period
+func (s *Service) CancelDeletion(ctx context.Context, subredditID, userID uuid.UUID) error {
+	subreddit, err := s.ensureCreator(ctx, subredditID, userID)
 	if err != nil {
 		return err
 	}
-	return s.repo.Delete(ctx, subredditID)
+
+	err = s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			cancelled, err := s.repo.CancelDeletion(ctx, subredditID)
+			if err != nil {
+				return err
+			}
+			if !cancelled {
+				return ErrDeletionNotScheduled
+			}
+			if err := s.auditor.RecordSubredditAction(
+				ctx, userID, subredditID, AuditActionDeletionCancelled,
+			); err != nil {
+				return err
+			}
+			return s.outbox.Publish(
+				ctx, outbox.EventSubredditDeletionCancelled, DeletionEvent{
+					SubredditID: subredditID,
+					UserID:      userID,
+				},
+			)
+		},
+	)
+	if err != nil {
+		return err
+	}
+	s.invalidateTopicPages(ctx, subreddit.Topics)
+	return nil
+}
+
+// RunDeletionPurge soft-deletes subreddits past their grace period every purge interval until ctx is done.
+// Scrubbing their content is left to the consumers of EventSubredditDeleted.
+func (s *Service) RunDeletionPurge(ctx context.Context) {
+	if s.subredditCfg.DeletionPurgeInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.subredditCfg.DeletionPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for {
+					purged, err := s.purgeDueBatch(ctx)
+					if err != nil {
+						log.Println("Failed to purge subreddits:", err)
+						break
+					}
+					if purged > 0 {
+						log.Printf("Purged %d subreddits past their deletion grace period", purged)
+					}
+					if purged < DeletionPurgeBatchSize {
+						break
+					}
+				}
+			}
+		}
+	}()
+}
+
+// purgeDueBatch deletes, audits and announces one batch in a single transaction.
+// The creator is recorded as the actor, only they can schedule a deletion.
+func (s *Service) purgeDueBatch(ctx context.Context) (int, error) {
+	var purged []uuid.UUID
+
+	err := s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			due, err := s.repo.ClaimDueForPurge(ctx, s.clock.Now(), DeletionPurgeBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, subreddit := range due {
+				if err := s.repo.Delete(ctx, subreddit.ID); err != nil {
+					return err
+				}
+				if err := s.auditor.RecordSubredditAction(
+					ctx, subreddit.CreatorID, subreddit.ID, AuditActionPurge,
+				); err != nil {
+					return err
+				}
+				if err := s.outbox.Publish(
+					ctx, outbox.EventSubredditDeleted, DeletionEvent{
+						SubredditID: subreddit.ID,
+						UserID:      subreddit.CreatorID,
+					},
+				); err != nil {
+					return err
+				}
+				purged = append(purged, subreddit.ID)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range purged {
+		s.liteCache.invalidate(ctx, id)
+	}
+	return len(purged), nil
+}
+
+// ForceDeleteSubreddit bypasses ensureCreator, callers are responsible for authorizing (admin moderation).
+// It joins the caller's transaction, caches are only invalidated once that commits.
+func (s *Service) ForceDeleteSubreddit(ctx context.Context, actorID, subredditID uuid.UUID) error {
+	return s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			subreddit, err := s.repo.GetByID(ctx, subredditID)
+			if err != nil {
+				return err
+			}
+			if err := s.repo.Delete(ctx, subredditID); err != nil {
+				return err
+			}
+			if err := s.outbox.Publish(
+				ctx, outbox.EventSubredditDeleted, DeletionEvent{
+					SubredditID: subredditID,
+					UserID:      actorID,
+				},
+			); err != nil {
+				return err
+			}
+
+			database.AfterCommit(
+				ctx, func() {
+					// The request context may be cancelled by now, the invalidation must still happen
+					ctx := context.WithoutCancel(ctx)
+					s.liteCache.invalidate(ctx, subredditID)
+					s.invalidateTopicPages(ctx, subreddit.Topics)
+				},
+			)
+			return nil
+		},
+	)
 }
 
 func (s *Service) ensureCreator(ctx context.Context, subredditID, userID uuid.UUID) (
 	*Subreddit,
 	error,
 ) {
-	subreddit, err := s.repo.GetByID(ctx, subredditID, false)
+	subreddit, err := s.repo.GetByID(ctx, subredditID)
 	if err != nil {
 		return nil, err
 	}
@@ -149,22 +707,149 @@ func (s *Service) ensureCreator(ctx context.Context, subredditID, userID uuid.UU
 	return subreddit, nil
 }
 
+// JoinSubreddit adds userID to a public subreddit. A private one can only be "re-joined" by its members:
+// membership is what VisibleSubreddits grants content access on, so anyone else gets ErrSubredditPrivate.
 func (s *Service) JoinSubreddit(ctx context.Context, subredditID, userID uuid.UUID) error {
-	_, err := s.repo.GetByID(ctx, subredditID, false)
+	subreddit, err := s.repo.GetByID(ctx, subredditID)
 	if err != nil {
 		return err
 	}
 
-	// TODO: add request logic to join subreddit if it's private
-	return s.repo.WithTx(
-		ctx, func(txRepo Repository) error {
-			return txRepo.AddMember(ctx, subredditID, userID)
+	// TODO: add join requests (or invites) for private subreddits
+	if !subreddit.IsPublic {
+		isMember, err := s.repo.IsMember(ctx, subredditID, userID)
+		if err != nil {
+			return err
+		}
+		if !isMember {
+			return ErrSubredditPrivate
+		}
+	}
+
+	return s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			if err := s.ensureMembershipCapacity(ctx, subredditID, userID); err != nil {
+				return err
+			}
+			joined, err := s.repo.AddMember(ctx, subredditID, userID)
+			if err != nil || !joined {
+				return err
+			}
+			return s.outbox.Publish(
+				ctx, outbox.EventMemberJoined, SubredditEvent{
+					SubredditID: subredditID,
+					UserID:      userID,
+				},
+			)
 		},
 	)
 }
 
+// ensureMembershipCapacity bounds how many subreddits a user can join (keeps home feed queries cheap).
+// Re-joining is idempotent and admins are exempt. Must run in the transaction that adds the membership:
+// it locks the user's row until commit, so concurrent joins by one user can't all pass the count.
+func (s *Service) ensureMembershipCapacity(ctx context.Context, subredditID, userID uuid.UUID) error {
+	limit := s.subredditCfg.MaxMembershipsPerUser
+	if limit <= 0 {
+		return nil
+	}
+
+	if err := s.repo.LockUserMemberships(ctx, userID); err != nil {
+		return err
+	}
+
+	isMember, err := s.repo.IsMember(ctx, subredditID, userID)
+	if err != nil {
+		return err
+	}
+	if isMember {
+		return nil
+	}
+
+	count, err := s.repo.CountUserMemberships(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if count < int64(limit) {
+		return nil
+	}
+
+	member, err := s.userService.GetUserById(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if member.IsAdmin() {
+		return nil
+	}
+
+	return ErrMembershipLimitReached
+}
+
+// BatchJoin joins each subreddit in its own transaction, so one failure (e.g. the membership cap
+// being hit halfway) doesn't undo the others. Duplicate IDs are processed once.
+func (s *Service) BatchJoin(ctx context.Context, subredditIDs []uuid.UUID, userID uuid.UUID) (
+	[]BatchMembershipResult,
+	error,
+) {
+	return s.runBatch(ctx, subredditIDs, userID, s.JoinSubreddit)
+}
+
+// BatchLeave is BatchJoin for leaving
+func (s *Service) BatchLeave(ctx context.Context, subredditIDs []uuid.UUID, userID uuid.UUID) (
+	[]BatchMembershipResult,
+	error,
+) {
+	return s.runBatch(ctx, subredditIDs, userID, s.LeaveSubreddit)
+}
+
+func (s *Service) runBatch(
+	ctx context.Context,
+	subredditIDs []uuid.UUID,
+	userID uuid.UUID,
+	operation func(ctx context.Context, subredditID, userID uuid.UUID) error,
+) ([]BatchMembershipResult, error) {
+	if err := s.validator.ValidateBatchSize(subredditIDs); err != nil {
+		return nil, ValidationErrors{NewValidationError("subreddit_ids", err.Error())}
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(subredditIDs))
+	results := make([]BatchMembershipResult, 0, len(subredditIDs))
+	for _, subredditID := range subredditIDs {
+		if _, ok := seen[subredditID]; ok {
+			continue
+		}
+		seen[subredditID] = struct{}{}
+
+		result := BatchMembershipResult{SubredditID: subredditID, Success: true}
+		if err := operation(ctx, subredditID, userID); err != nil {
+			result.Success = false
+			result.Code, result.Error = batchFailure(err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// batchFailure maps an operation error to the code/message reported for that ID
+func batchFailure(err error) (string, string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "not_found", "Subreddit not found"
+	case errors.Is(err, ErrMembershipLimitReached):
+		return "membership_limit_reached", err.Error()
+	case errors.Is(err, ErrSubredditPrivate):
+		return "subreddit_private", err.Error()
+	case errors.Is(err, ErrCreatorCannotLeave):
+		return "creator_cannot_leave", err.Error()
+	default:
+		log.Println("Batch membership operation failed:", err)
+		return "internal_error", "Operation failed"
+	}
+}
+
 func (s *Service) LeaveSubreddit(ctx context.Context, subredditID, userID uuid.UUID) error {
-	subreddit, err := s.repo.GetByID(ctx, subredditID, false)
+	subreddit, err := s.repo.GetByID(ctx, subredditID)
 	if err != nil {
 		return err
 	}
@@ -173,9 +858,203 @@ func (s *Service) LeaveSubreddit(ctx context.Context, subredditID, userID uuid.U
 		return ErrCreatorCannotLeave
 	}
 
-	return s.repo.WithTx(
-		ctx, func(txRepo Repository) error {
-			return txRepo.RemoveMember(ctx, subredditID, userID)
+	return s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			left, err := s.repo.RemoveMember(ctx, subredditID, userID)
+			if err != nil || !left {
+				return err
+			}
+			return s.outbox.Publish(
+				ctx, outbox.EventMemberLeft, SubredditEvent{
+					SubredditID: subredditID,
+					UserID:      userID,
+				},
+			)
 		},
 	)
 }
+
+// GetWikiPage serves pages of public subreddits to everyone, of private ones only to members.
+// Pages hidden from the viewer are reported as not found. viewerID is uuid.Nil for anonymous viewers.
+func (s *Service) GetWikiPage(ctx context.Context, subredditID uuid.UUID, slug string, viewerID uuid.UUID) (
+	*WikiPage,
+	error,
+) {
+	return s.repo.GetWikiPage(ctx, subredditID, viewerID, strings.ToLower(strings.TrimSpace(slug)))
+}
+
+// PutWikiPage creates or replaces a wiki page, only the subreddit creator may edit
+func (s *Service) PutWikiPage(ctx context.Context, subredditID, userID uuid.UUID, slug, content string) (
+	*WikiPage,
+	error,
+) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if errs := s.validator.ValidateWikiPageInput(slug, content); len(errs) > 0 {
+		return nil, errs
+	}
+
+	subreddit, err := s.repo.GetByID(ctx, subredditID)
+	if err != nil {
+		return nil, err
+	}
+	if subreddit.CreatorID != userID {
+		return nil, ErrNotAuthorized
+	}
+
+	err = s.repo.UpsertWikiPage(
+		ctx, &WikiPage{
+			ID:          utils.NewID(),
+			SubredditID: subredditID,
+			Slug:        slug,
+			Content:     content,
+			UpdatedByID: userID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetWikiPage(ctx, subredditID, userID, slug) // Reload for the editor and the kept ID on updates
+}
+
+func lowerOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	lowered := strings.ToLower(*value)
+	return &lowered
+}
+
+func trimOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	return &trimmed
+}
+
+type topicPage struct {
+	Subreddits []Subreddit `json:"subreddits"`
+	Total      int64       `json:"total"`
+}
+
+// GetSubredditsByTopic lists public communities for a topic. The first page is the hot one
+// (topic landing pages), so it's served from the stale-while-revalidate topic page cache.
+// Sensitive topics need a viewer with show_nsfw enabled, viewerID is uuid.Nil for anonymous ones.
+func (s *Service) GetSubredditsByTopic(
+	ctx context.Context,
+	slug string,
+	page utils.Pagination,
+	viewerID uuid.UUID,
+) ([]Subreddit, int64, error) {
+	topic, err := s.repo.GetTopicBySlug(ctx, slug)
+	if err != nil {
+		return nil, 0, err
+	}
+	if topic.IsSensitive {
+		if viewerID == uuid.Nil {
+			return nil, 0, ErrSensitiveTopic
+		}
+		settings, err := s.userService.GetSettings(ctx, viewerID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !settings.ShowNSFW {
+			return nil, 0, ErrSensitiveTopic
+		}
+	}
+
+	if page.Page != 1 || page.PageSize != utils.DefaultPageSize {
+		return s.repo.GetListByTopic(ctx, topic.ID, page)
+	}
+
+	cached, err := s.topicPages.Get(
+		ctx, topic.Slug, func(ctx context.Context) (topicPage, error) {
+			subreddits, total, err := s.repo.GetListByTopic(ctx, topic.ID, page)
+			return topicPage{Subreddits: subreddits, Total: total}, err
+		},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cached.Subreddits, cached.Total, nil
+}
+
+func (s *Service) CreateTopic(ctx context.Context, req CreateTopicRequest) (*Topic, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	name := strings.TrimSpace(req.Name)
+
+	var errs ValidationErrors
+	if err := s.validator.ValidateTopicSlugFormat(slug); err != nil {
+		errs = append(errs, NewValidationError("slug", err.Error()))
+	}
+	if err := s.validator.ValidateTopicNameFormat(name); err != nil {
+		errs = append(errs, NewValidationError("name", err.Error()))
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if _, err := s.repo.GetTopicBySlug(ctx, slug); err == nil {
+		return nil, ValidationErrors{NewValidationError("slug", ErrTopicSlugTaken)}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	topic := &Topic{
+		ID:   utils.NewID(),
+		Slug: slug,
+		Name: name,
+	}
+	if req.IsSensitive != nil {
+		topic.IsSensitive = *req.IsSensitive
+	}
+
+	if err := s.repo.CreateTopic(ctx, topic); err != nil {
+		return nil, err
+	}
+	return topic, nil
+}
+
+func (s *Service) UpdateTopic(ctx context.Context, slug string, req UpdateTopicRequest) (*Topic, error) {
+	updates := make(map[string]interface{})
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := s.validator.ValidateTopicNameFormat(name); err != nil {
+			return nil, ValidationErrors{NewValidationError("name", err.Error())}
+		}
+		updates["name"] = name
+	}
+	if req.IsSensitive != nil {
+		updates["is_sensitive"] = *req.IsSensitive
+	}
+
+	if len(updates) > 0 {
+		if err := s.repo.UpdateTopic(ctx, slug, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	topic, err := s.repo.GetTopicBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	s.invalidateTopicPages(ctx, []Topic{*topic}) // Cached subreddits embed the topic name
+	return topic, nil
+}
+
+// invalidateTopicPages drops the cached first page of each topic once a change to its listing has committed
+func (s *Service) invalidateTopicPages(ctx context.Context, topics []Topic) {
+	for _, topic := range topics {
+		s.topicPages.Invalidate(ctx, topic.Slug)
+	}
+}
+
+func normalizeTopicSlugs(slugs []string) []string {
+	normalized := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(slug)))
+	}
+	return normalized
+}
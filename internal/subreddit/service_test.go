@@ -0,0 +1,387 @@
+package subreddit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/outbox"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/user"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+type testEnv struct {
+	service  *Service
+	userRepo *user.Repository
+	db       *database.DB
+}
+
+func newTestEnv(t *testing.T, cfg config.SubredditConfig) testEnv {
+	t.Helper()
+	return newTestEnvOn(t, testutil.Postgres(t, utils.RealClock{}), cfg)
+}
+
+func newTestEnvOn(t *testing.T, db *database.DB, cfg config.SubredditConfig) testEnv {
+	t.Helper()
+
+	txManager := database.NewTxManager(db)
+	userRepo := user.NewRepository(db, 0)
+	userService := user.NewService(
+		userRepo, txManager, nil, config.EmailNormalizationConfig{}, config.RetentionConfig{}, utils.RealClock{},
+	)
+	service := NewService(
+		NewRepository(db, 0),
+		userService,
+		txManager,
+		outbox.NewPublisher(outbox.NewRepository(db, 0)),
+		nil,
+		redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}),
+		cfg,
+		utils.RealClock{},
+	)
+	return testEnv{service: service, userRepo: userRepo, db: db}
+}
+
+func (env testEnv) createUser(t *testing.T, name string) uuid.UUID {
+	t.Helper()
+
+	u := &user.User{
+		ID:               utils.NewID(),
+		Username:         name,
+		Email:            name + "@example.com",
+		EmailNormalized:  name + "@example.com",
+		UsernameSkeleton: utils.Skeleton(name),
+	}
+	if err := env.userRepo.Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func (env testEnv) createSubreddit(t *testing.T, creatorID uuid.UUID, name string) uuid.UUID {
+	t.Helper()
+
+	s, err := env.service.CreateSubreddit(
+		context.Background(), creatorID, name, name, nil, nil, true, false, nil, nil,
+	)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	return s.ID
+}
+
+func TestConcurrentJoinsRespectMembershipCap(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{MaxMembershipsPerUser: 2})
+	ctx := context.Background()
+	joiner := env.createUser(t, "joiner")
+
+	const joins = 6
+	ids := make([]uuid.UUID, joins)
+	for i := range joins {
+		ids[i] = env.createSubreddit(t, env.createUser(t, fmt.Sprintf("creator%d", i)), fmt.Sprintf("community%d", i))
+	}
+
+	errs := make([]error, joins)
+	var wg sync.WaitGroup
+	for i := range joins {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = env.service.JoinSubreddit(ctx, ids[i], joiner)
+		}()
+	}
+	wg.Wait()
+
+	joined := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			joined++
+		case !errors.Is(err, ErrMembershipLimitReached):
+			t.Fatalf("join %d: %v", i, err)
+		}
+	}
+	if joined != 2 {
+		t.Errorf("%d joins succeeded, want 2", joined)
+	}
+}
+
+func TestCreateSubredditCountsCreatorMembership(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{MaxMembershipsPerUser: 1})
+	creator := env.createUser(t, "creator")
+	env.createSubreddit(t, creator, "first")
+
+	_, err := env.service.CreateSubreddit(
+		context.Background(), creator, "second", "second", nil, nil, true, false, nil, nil,
+	)
+	if !errors.Is(err, ErrMembershipLimitReached) {
+		t.Fatalf("creating past the cap: err = %v, want ErrMembershipLimitReached", err)
+	}
+}
+
+func TestJoinPrivateSubredditRejected(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	outsider := env.createUser(t, "outsider")
+	private, err := env.service.CreateSubreddit(ctx, creator, "hideout", "Hideout", nil, nil, false, false, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.service.JoinSubreddit(ctx, private.ID, outsider); !errors.Is(err, ErrSubredditPrivate) {
+		t.Fatalf("outsider join: err = %v, want ErrSubredditPrivate", err)
+	}
+	if err := env.service.JoinSubreddit(ctx, private.ID, creator); err != nil {
+		t.Fatalf("creator re-join: %v", err)
+	}
+
+	results, err := env.service.BatchJoin(ctx, []uuid.UUID{private.ID}, outsider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Success || results[0].Code != "subreddit_private" {
+		t.Fatalf("batch join result = %+v, want subreddit_private", results[0])
+	}
+}
+
+func TestTopicPageInvalidatedOnUntag(t *testing.T) {
+	env := newTestEnv(
+		t, config.SubredditConfig{
+			TopicPageCache: config.CacheTTLConfig{SoftTTL: time.Hour, HardTTL: time.Hour},
+		},
+	)
+	ctx := context.Background()
+	if _, err := env.service.CreateTopic(ctx, CreateTopicRequest{Slug: "go", Name: "Go"}); err != nil {
+		t.Fatal(err)
+	}
+	creator := env.createUser(t, "creator")
+	subreddit, err := env.service.CreateSubreddit(
+		ctx, creator, "gophers", "Gophers", nil, nil, true, false, []string{"go"}, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstPage := utils.Pagination{Page: 1, PageSize: utils.DefaultPageSize}
+	listed := func() int64 {
+		t.Helper()
+		_, total, err := env.service.GetSubredditsByTopic(ctx, "go", firstPage, uuid.Nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return total
+	}
+
+	if got := listed(); got != 1 {
+		t.Fatalf("tagged: %d listed, want 1", got)
+	}
+	untag := []string{}
+	if _, err := env.service.UpdateSubreddit(
+		ctx, subreddit.ID, creator, UpdateSubredditRequest{Topics: &untag},
+	); err != nil {
+		t.Fatal(err)
+	}
+	if got := listed(); got != 0 {
+		t.Errorf("untagged: %d listed, want 0", got)
+	}
+}
+
+func TestRecountJoinedMemberCountsOnlyTouchesOwnSubreddits(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{})
+	ctx := context.Background()
+	member := env.createUser(t, "member")
+	joined := env.createSubreddit(t, member, "joined")
+	other := env.createSubreddit(t, env.createUser(t, "creator"), "other")
+	if err := env.service.JoinSubreddit(ctx, other, member); err != nil {
+		t.Fatal(err)
+	}
+	untouched := env.createSubreddit(t, env.createUser(t, "stranger"), "untouched")
+
+	// Drift every count, as an incident would
+	err := env.service.repo.conn(ctx).
+		Model(&Subreddit{}).
+		Where("id IN ?", []uuid.UUID{joined, other, untouched}).
+		Update("member_count", 40).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := env.service.RecountJoinedMemberCounts(ctx, member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RecountSummary{Scanned: 2, Corrected: 2, MaxDrift: 39}); summary != want {
+		t.Errorf("summary = %+v, want %+v", summary, want)
+	}
+
+	for id, want := range map[uuid.UUID]int{joined: 1, other: 2, untouched: 40} {
+		s, err := env.service.repo.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.MemberCount != want {
+			t.Errorf("subreddit %s: member_count = %d, want %d", s.Name, s.MemberCount, want)
+		}
+	}
+}
+
+// Values are trimmed before validation and stored as validated, so padding can't slip past the name rules
+func TestCreateAndUpdateStoreTrimmedValues(t *testing.T) {
+	env := newTestEnv(t, config.SubredditConfig{AllowedLanguages: []string{"en"}})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+
+	description := "  Gophers unite \n"
+	iconURL := " https://cdn.example/icons/padded.png "
+	language := " EN "
+	created, err := env.service.CreateSubreddit(
+		ctx, creator, "  padded  ", "\tPadded Name ", &description, &iconURL, true, false, nil, &language,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := env.service.repo.GetByID(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "padded" || stored.DisplayName != "Padded Name" || *stored.Description != "Gophers unite" ||
+		*stored.IconURL != "https://cdn.example/icons/padded.png" || *stored.Language != "en" {
+		t.Errorf("stored %q, %q, %q, %q, %q; want every value trimmed",
+			stored.Name, stored.DisplayName, *stored.Description, *stored.IconURL, *stored.Language)
+	}
+
+	// The padded variant of a taken name is the same name
+	_, err = env.service.CreateSubreddit(ctx, creator, " PADDED ", "Again", nil, nil, true, false, nil, nil)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Message != ErrSubredditNameTaken {
+		t.Errorf("padded duplicate: err = %v, want %q", err, ErrSubredditNameTaken)
+	}
+
+	displayName := "  Renamed  "
+	updated, err := env.service.UpdateSubreddit(
+		ctx, created.ID, creator, UpdateSubredditRequest{
+			DisplayName: &displayName,
+			Description: utils.Optional[string]{Value: &description, Set: true},
+		},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != "Renamed" || *updated.Description != "Gophers unite" {
+		t.Errorf("updated %q, %q; want trimmed", updated.DisplayName, *updated.Description)
+	}
+
+	blank := "   "
+	_, err = env.service.UpdateSubreddit(ctx, created.ID, creator, UpdateSubredditRequest{DisplayName: &blank})
+	if !errors.As(err, &errs) || errs[0].Message != ErrDisplayNameRequired {
+		t.Errorf("whitespace-only display name: err = %v, want %q", err, ErrDisplayNameRequired)
+	}
+}
+
+// Membership changes alter member_count, so they have to move updated_at too; no-op joins and leaves must not
+func TestMembershipChangesBumpUpdatedAt(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	env := newTestEnvOn(t, testutil.Postgres(t, clock), config.SubredditConfig{})
+	ctx := context.Background()
+	creator := env.createUser(t, "creator")
+	member := env.createUser(t, "member")
+	subredditID := env.createSubreddit(t, creator, "golang")
+
+	assertState := func(step string, wantCount int, wantUpdatedAt time.Time) {
+		t.Helper()
+		s, err := env.service.GetSubredditForModeration(ctx, subredditID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.MemberCount != wantCount || !s.UpdatedAt.Equal(wantUpdatedAt) {
+			t.Errorf(
+				"%s: member_count %d, updated_at %v; want %d, %v",
+				step, s.MemberCount, s.UpdatedAt, wantCount, wantUpdatedAt,
+			)
+		}
+	}
+	created := clock.Now()
+	assertState("created", 1, created)
+
+	clock.Advance(time.Hour)
+	joined := clock.Now()
+	if err := env.service.JoinSubreddit(ctx, subredditID, member); err != nil {
+		t.Fatal(err)
+	}
+	assertState("join", 2, joined)
+
+	clock.Advance(time.Hour)
+	if err := env.service.JoinSubreddit(ctx, subredditID, member); err != nil {
+		t.Fatal(err)
+	}
+	assertState("repeated join", 2, joined)
+
+	clock.Advance(time.Hour)
+	left := clock.Now()
+	if err := env.service.LeaveSubreddit(ctx, subredditID, member); err != nil {
+		t.Fatal(err)
+	}
+	assertState("leave", 1, left)
+
+	clock.Advance(time.Hour)
+	if err := env.service.LeaveSubreddit(ctx, subredditID, member); err != nil {
+		t.Fatal(err)
+	}
+	assertState("repeated leave", 1, left)
+}
+
+func TestParseListFilterTimeRange(t *testing.T) {
+	service := &Service{validator: NewValidator(nil, nil, 0)}
+	noon := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		since, until string
+		wantSince    *time.Time
+		wantUntil    *time.Time
+		wantFields   []string
+	}{
+		{"unset", "", "", nil, nil, nil},
+		{"since only", "2026-01-01T12:00:00Z", "", &noon, nil, nil},
+		{"until only, with an offset", "", "2026-01-01T14:00:00+02:00", nil, &noon, nil},
+		{"equal bounds", "2026-01-01T12:00:00Z", "2026-01-01T12:00:00Z", &noon, &noon, nil},
+		{"inverted", "2026-01-02T00:00:00Z", "2026-01-01T12:00:00Z", nil, nil, []string{"since"}},
+		{"date without a time", "2026-01-01", "", nil, nil, []string{"since"}},
+		{"unix seconds", "", "1767268800", nil, nil, []string{"until"}},
+		{"both malformed", "yesterday", "now", nil, nil, []string{"since", "until"}},
+	}
+	for _, tt := range tests {
+		filter, err := service.ParseListFilter("", tt.since, tt.until)
+
+		var errs ValidationErrors
+		errors.As(err, &errs)
+		var fields []string
+		for _, e := range errs {
+			fields = append(fields, e.Field)
+		}
+		if !slices.Equal(fields, tt.wantFields) {
+			t.Errorf("%s: error fields %v (%v), want %v", tt.name, fields, err, tt.wantFields)
+			continue
+		}
+		sameTime := func(got, want *time.Time) bool {
+			return got == nil && want == nil || got != nil && want != nil && got.Equal(*want)
+		}
+		if !sameTime(filter.Since, tt.wantSince) || !sameTime(filter.Until, tt.wantUntil) {
+			t.Errorf(
+				"%s: since %v, until %v; want %v, %v",
+				tt.name, filter.Since, filter.Until, tt.wantSince, tt.wantUntil,
+			)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package subreddit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// ShareDedupPrefix keys one marker per subreddit, user and UTC day: {prefix}{subredditID}:{userID}:{date}
+	ShareDedupPrefix = "subreddit:share:"
+	// ShareCodeSequenceKey is the counter short codes are drawn from. If it's ever lost, SET it above the
+	// number of assigned codes before sharing resumes, otherwise new codes collide until it catches up.
+	ShareCodeSequenceKey = "subreddit:share_code_seq"
+	ShareCodeMaxLen      = 11 // base62 of a uint64
+
+	shareDedupTTL     = 48 * time.Hour // Outlives the UTC day the key is for in any timezone
+	shareCodeAttempts = 3
+
+	shareCountColumn = "share_count"
+	clickCountColumn = "click_count"
+)
+
+var ErrSharingUnavailable = errors.New("sharing unavailable")
+
+// ShareSubreddit counts a share by userID at most once per UTC day and returns the community's short code,
+// assigning one on first share. Only communities the user can read can be shared.
+func (s *Service) ShareSubreddit(ctx context.Context, subredditID, userID uuid.UUID) (string, error) {
+	if s.redis == nil {
+		return "", ErrSharingUnavailable
+	}
+
+	subreddit, err := s.repo.GetVisibleByID(ctx, subredditID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	first, err := claimDailyShare(ctx, s.redis, subredditID, userID, s.clock.Now())
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrSharingUnavailable, err)
+	}
+	if first {
+		if err := s.repo.IncrementShareStat(ctx, subredditID, shareCountColumn); err != nil {
+			return "", err
+		}
+	}
+
+	if subreddit.ShareCode != nil {
+		return *subreddit.ShareCode, nil
+	}
+	return s.assignShareCode(ctx, subredditID)
+}
+
+// ResolveShareCode finds the community behind a short code and records the click. Malformed codes are not found
+// without a query, links to private communities viewerID can't read aren't found either.
+func (s *Service) ResolveShareCode(ctx context.Context, code string, viewerID uuid.UUID) (*Subreddit, error) {
+	if !utils.IsBase62(code, ShareCodeMaxLen) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	subreddit, err := s.repo.GetVisibleByShareCode(ctx, code, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.IncrementShareStat(ctx, subreddit.ID, clickCountColumn); err != nil {
+		log.Printf("Failed to record click for share code %s: %v", code, err) // The redirect still works
+	}
+	return subreddit, nil
+}
+
+// assignShareCode draws the next code from the Redis sequence. A concurrent first share may assign one first,
+// EnsureShareCode then returns that one and the drawn value is skipped.
+func (s *Service) assignShareCode(ctx context.Context, subredditID uuid.UUID) (string, error) {
+	var err error
+	for range shareCodeAttempts {
+		var seq int64
+		seq, err = s.redis.Incr(ctx, ShareCodeSequenceKey).Result()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrSharingUnavailable, err)
+		}
+
+		var code string
+		code, err = s.repo.EnsureShareCode(ctx, subredditID, utils.EncodeBase62(uint64(seq)))
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return code, err
+		}
+	}
+	return "", err
+}
+
+// claimDailyShare reports whether this is userID's first share of the subreddit on now's UTC day
+func claimDailyShare(
+	ctx context.Context,
+	client *redis.Client,
+	subredditID, userID uuid.UUID,
+	now time.Time,
+) (bool, error) {
+	key := fmt.Sprintf("%s%s:%s:%s", ShareDedupPrefix, subredditID, userID, now.UTC().Format(time.DateOnly))
+	return client.SetNX(ctx, key, 1, shareDedupTTL).Result()
+}
+
+// ShareRedirectURL is where GET /s/:code sends the visitor. It is always rooted at the frontend URL
+// and the name is a single escaped path segment, so a stored name can't redirect off-site.
+func ShareRedirectURL(frontendURL, name string) string {
+	return strings.TrimRight(frontendURL, "/") + "/r/" + url.PathEscape(name)
+}
@@ -0,0 +1,71 @@
+package subreddit
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestClaimDailyShareOncePerUserPerDay(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	ctx := context.Background()
+	subredditID, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	morning := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+	claims := []struct {
+		name   string
+		userID uuid.UUID
+		at     time.Time
+		want   bool
+	}{
+		{"first share", alice, morning, true},
+		{"same day", alice, morning.Add(15 * time.Hour), false},
+		{"other user", bob, morning, true},
+		{"next UTC day", alice, morning.Add(16 * time.Hour), true},
+		// 23:30 in UTC-5 is already the 17th in UTC
+		{"offset clock", bob, time.Date(2026, 10, 16, 23, 30, 0, 0, time.FixedZone("", -5*3600)), true},
+	}
+
+	for _, claim := range claims {
+		got, err := claimDailyShare(ctx, client, subredditID, claim.userID, claim.at)
+		if err != nil {
+			t.Fatalf("%s: %v", claim.name, err)
+		}
+		if got != claim.want {
+			t.Errorf("%s: claimDailyShare = %v, want %v", claim.name, got, claim.want)
+		}
+	}
+}
+
+func TestShareRedirectURLStaysOnFrontend(t *testing.T) {
+	const frontend = "https://agora.example/"
+	names := []string{"golang", "//evil.example", "../../evil", "a/b?c#d", "@evil.example", `\\evil.example`}
+
+	for _, name := range names {
+		raw := ShareRedirectURL(frontend, name)
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("%q: %v", name, err)
+		}
+		if parsed.Scheme != "https" || parsed.Host != "agora.example" {
+			t.Errorf("%q redirects off-site: %s", name, raw)
+		}
+		if parsed.Path != "/r/"+name || parsed.RawQuery != "" || parsed.Fragment != "" {
+			t.Errorf("%q is not a single /r/ segment: %s", name, raw)
+		}
+	}
+}
+
+func TestResolveShareCodeRejectsMalformedCodes(t *testing.T) {
+	s := &Service{} // No repository: malformed codes must not reach it
+	for _, code := range []string{"", "abc-1", "../x", "123456789012"} {
+		if _, err := s.ResolveShareCode(context.Background(), code, uuid.Nil); err == nil {
+			t.Errorf("ResolveShareCode(%q) succeeded", code)
+		}
+	}
+}
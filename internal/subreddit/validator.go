@@ -6,10 +6,16 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
 )
 
 var (
 	SubredditNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+	TopicSlugRegex     = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	HexColorRegex      = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
 )
 
 const (
@@ -19,23 +25,56 @@ const (
 	ErrSubredditNameInvalid  = "subreddit name can only contain letters, numbers, and underscores"
 	ErrSubredditNameTaken    = "subreddit name already taken"
 
-	ErrDisplayNameRequired      = "display name is required"
-	ErrDisplayNameNoWhitespaces = "display name cannot have leading or trailing whitespace"
-	ErrDisplayNameTooLong       = "display name must be at most %d characters"
-
-	ErrDescriptionTooLong = "description must be at most %d characters"
-	ErrIconURLTooLong     = "icon URL must be at most %d characters"
-
-	NameMinLen        = 3
-	NameMaxLen        = 21
-	DisplayNameMaxLen = 255
-	DescriptionMaxLen = 500
-	IconURLMaxLen     = 500
+	ErrDisplayNameRequired = "display name is required"
+	ErrDisplayNameTooLong  = "display name must be at most %d characters"
+	ErrDisplayNameInvalid  = "display name cannot contain control, zero-width or text direction characters"
+
+	ErrDescriptionTooLong       = "description must be at most %d characters"
+	ErrDescriptionMaxLenInvalid = "description max length must be between 1 and %d"
+	ErrIconURLTooLong           = "icon URL must be at most %d characters"
+	ErrBannerURLTooLong         = "banner URL must be at most %d characters"
+	ErrColorInvalid             = "color must be a hex value in #RRGGBB format"
+	ErrLanguageNotAllowed       = "language must be one of the supported ISO 639-1 codes"
+
+	ErrBatchEmpty  = "subreddit_ids must not be empty"
+	ErrBatchTooBig = "at most %d subreddits can be processed per batch"
+
+	ErrTooManyTopics    = "at most %d topics can be assigned"
+	ErrDuplicateTopic   = "topic %q is listed more than once"
+	ErrTopicNotFound    = "topic %q does not exist"
+	ErrTopicSlugInvalid = "topic slug can only contain lowercase letters, numbers, and single dashes"
+	ErrTopicSlugTooLong = "topic slug must be at most %d characters"
+	ErrTopicNameInvalid = "topic name must be between 1 and %d characters"
+	ErrTopicSlugTaken   = "topic slug already taken"
+
+	ErrWikiSlugInvalid     = "wiki slug can only contain lowercase letters, numbers, and single dashes"
+	ErrWikiSlugTooLong     = "wiki slug must be at most %d characters"
+	ErrWikiContentRequired = "wiki content is required"
+	ErrWikiContentTooLong  = "wiki content must be at most %d characters"
+
+	NameMinLen         = 3
+	NameMaxLen         = 21
+	DisplayNameMaxLen  = 255  // Default and cap, matches the column size
+	DescriptionMaxLen  = 500  // Default, subreddits may override it up to DescriptionHardCap
+	DescriptionHardCap = 2000 // Matches the column size
+	IconURLMaxLen      = 500
+	BannerURLMaxLen    = 500
+	MaxTopics          = 3
+	TopicSlugMaxLen    = 50
+	TopicNameMaxLen    = 100
+	MaxBatchSize       = 50
+	WikiSlugMaxLen     = 50
+	WikiContentMaxLen  = 20000
 )
 
+// Validator limits count runes (code points) like the varchar columns do, not bytes or graphemes
 type Validator struct {
-	repo      *Repository
-	nameRegex *regexp.Regexp
+	repo              *Repository
+	nameRegex         *regexp.Regexp
+	topicSlugRegex    *regexp.Regexp
+	hexColorRegex     *regexp.Regexp
+	languages         map[string]struct{}
+	displayNameMaxLen int
 }
 
 type ValidationError struct {
@@ -45,10 +84,23 @@ type ValidationError struct {
 
 type ValidationErrors []ValidationError
 
-func NewValidator(repo *Repository) *Validator {
+// NewValidator falls back to DisplayNameMaxLen for a displayNameMaxLen outside 1..DisplayNameMaxLen
+func NewValidator(repo *Repository, allowedLanguages []string, displayNameMaxLen int) *Validator {
+	languages := make(map[string]struct{}, len(allowedLanguages))
+	for _, code := range allowedLanguages {
+		languages[strings.ToLower(code)] = struct{}{}
+	}
+	if displayNameMaxLen < 1 || displayNameMaxLen > DisplayNameMaxLen {
+		displayNameMaxLen = DisplayNameMaxLen
+	}
+
 	return &Validator{
-		repo:      repo,
-		nameRegex: SubredditNameRegex,
+		repo:              repo,
+		nameRegex:         SubredditNameRegex,
+		topicSlugRegex:    TopicSlugRegex,
+		hexColorRegex:     HexColorRegex,
+		languages:         languages,
+		displayNameMaxLen: displayNameMaxLen,
 	}
 }
 
@@ -70,11 +122,11 @@ func (v *Validator) ValidateNameFormat(name string) error {
 		return errors.New(ErrSubredditNameRequired)
 	}
 
-	if len(name) < NameMinLen {
+	if utf8.RuneCountInString(name) < NameMinLen {
 		return errors.New(fmt.Sprintf(ErrSubredditNameTooShort, NameMinLen))
 	}
 
-	if len(name) > NameMaxLen {
+	if utf8.RuneCountInString(name) > NameMaxLen {
 		return errors.New(fmt.Sprintf(ErrSubredditNameTooLong, NameMaxLen))
 	}
 
@@ -92,25 +144,38 @@ func (v *Validator) ValidateDisplayNameFormat(displayName string) error {
 		return errors.New(ErrDisplayNameRequired)
 	}
 
-	if displayName != strings.TrimSpace(displayName) {
-		return errors.New(ErrDisplayNameNoWhitespaces)
+	if utf8.RuneCountInString(displayName) > v.displayNameMaxLen {
+		return errors.New(fmt.Sprintf(ErrDisplayNameTooLong, v.displayNameMaxLen))
 	}
 
-	if len(displayName) > DisplayNameMaxLen {
-		return errors.New(fmt.Sprintf(ErrDisplayNameTooLong, DisplayNameMaxLen))
+	if utils.HasUnsafeRunes(displayName) {
+		return errors.New(ErrDisplayNameInvalid)
 	}
 
 	return nil
 }
 
-func (v *Validator) ValidateDescriptionFormat(description *string) error {
+// ValidateDescriptionFormat checks the description against the limit in effect for the subreddit
+func (v *Validator) ValidateDescriptionFormat(description *string, maxLen int) error {
 	if description == nil {
 		return nil // Optional field
 	}
 
 	desc := strings.TrimSpace(*description)
-	if len(desc) > DescriptionMaxLen {
-		return errors.New(fmt.Sprintf(ErrDescriptionTooLong, DescriptionMaxLen))
+	if utf8.RuneCountInString(desc) > maxLen {
+		return errors.New(fmt.Sprintf(ErrDescriptionTooLong, maxLen))
+	}
+
+	return nil
+}
+
+func (v *Validator) ValidateDescriptionMaxLen(maxLen *int) error {
+	if maxLen == nil {
+		return nil // Back to the global default
+	}
+
+	if *maxLen < 1 || *maxLen > DescriptionHardCap {
+		return errors.New(fmt.Sprintf(ErrDescriptionMaxLenInvalid, DescriptionHardCap))
 	}
 
 	return nil
@@ -122,15 +187,149 @@ func (v *Validator) ValidateIconURLFormat(iconURL *string) error {
 	}
 
 	url := strings.TrimSpace(*iconURL)
-	if len(url) > IconURLMaxLen {
+	if utf8.RuneCountInString(url) > IconURLMaxLen {
 		return errors.New(fmt.Sprintf(ErrIconURLTooLong, IconURLMaxLen))
 	}
 
 	return nil
 }
 
+func (v *Validator) ValidateBannerURLFormat(bannerURL *string) error {
+	if bannerURL == nil {
+		return nil // Optional field
+	}
+
+	if utf8.RuneCountInString(strings.TrimSpace(*bannerURL)) > BannerURLMaxLen {
+		return errors.New(fmt.Sprintf(ErrBannerURLTooLong, BannerURLMaxLen))
+	}
+
+	return nil
+}
+
+func (v *Validator) ValidateColorFormat(color *string) error {
+	if color == nil {
+		return nil // Optional field
+	}
+
+	if !v.hexColorRegex.MatchString(*color) {
+		return errors.New(ErrColorInvalid)
+	}
+
+	return nil
+}
+
+func (v *Validator) ValidateBatchSize(ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return errors.New(ErrBatchEmpty)
+	}
+	if len(ids) > MaxBatchSize {
+		return errors.New(fmt.Sprintf(ErrBatchTooBig, MaxBatchSize))
+	}
+	return nil
+}
+
+// ValidateLanguage expects an already lowercased code
+func (v *Validator) ValidateLanguage(language *string) error {
+	if language == nil {
+		return nil // Optional field
+	}
+
+	if _, ok := v.languages[*language]; !ok {
+		return errors.New(ErrLanguageNotAllowed)
+	}
+
+	return nil
+}
+
+// ValidateWikiPageInput expects a lowercased slug; pages share the topic slug format
+func (v *Validator) ValidateWikiPageInput(slug, content string) ValidationErrors {
+	var errs ValidationErrors
+
+	if len(slug) > WikiSlugMaxLen {
+		errs = append(errs, NewValidationError("slug", fmt.Sprintf(ErrWikiSlugTooLong, WikiSlugMaxLen)))
+	} else if !v.topicSlugRegex.MatchString(slug) {
+		errs = append(errs, NewValidationError("slug", ErrWikiSlugInvalid))
+	}
+
+	if strings.TrimSpace(content) == "" {
+		errs = append(errs, NewValidationError("content", ErrWikiContentRequired))
+	} else if utf8.RuneCountInString(content) > WikiContentMaxLen {
+		errs = append(errs, NewValidationError("content", fmt.Sprintf(ErrWikiContentTooLong, WikiContentMaxLen)))
+	}
+
+	return errs
+}
+
+// ValidateTopicSlugs only checks the shape of the list, ResolveTopics checks the topics exist
+func (v *Validator) ValidateTopicSlugs(slugs []string) error {
+	if len(slugs) > MaxTopics {
+		return errors.New(fmt.Sprintf(ErrTooManyTopics, MaxTopics))
+	}
+
+	seen := make(map[string]struct{}, len(slugs))
+	for _, slug := range slugs {
+		if _, ok := seen[slug]; ok {
+			return errors.New(fmt.Sprintf(ErrDuplicateTopic, slug))
+		}
+		seen[slug] = struct{}{}
+	}
+
+	return nil
+}
+
+// ResolveTopics loads the topics for slugs that passed ValidateTopicSlugs, unknown ones are validation errors
+func (v *Validator) ResolveTopics(ctx context.Context, slugs []string) ([]Topic, error) {
+	if len(slugs) == 0 {
+		return []Topic{}, nil
+	}
+
+	topics, err := v.repo.GetTopicsBySlugs(ctx, slugs)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]struct{}, len(topics))
+	for _, topic := range topics {
+		found[topic.Slug] = struct{}{}
+	}
+
+	var errs ValidationErrors
+	for _, slug := range slugs {
+		if _, ok := found[slug]; !ok {
+			errs = append(errs, NewValidationError("topics", fmt.Sprintf(ErrTopicNotFound, slug)))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return topics, nil
+}
+
+func (v *Validator) ValidateTopicSlugFormat(slug string) error {
+	if len(slug) > TopicSlugMaxLen {
+		return errors.New(fmt.Sprintf(ErrTopicSlugTooLong, TopicSlugMaxLen))
+	}
+	if !v.topicSlugRegex.MatchString(slug) {
+		return errors.New(ErrTopicSlugInvalid)
+	}
+	return nil
+}
+
+func (v *Validator) ValidateTopicNameFormat(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > TopicNameMaxLen {
+		return errors.New(fmt.Sprintf(ErrTopicNameInvalid, TopicNameMaxLen))
+	}
+	return nil
+}
+
+// ValidateNameExists returns an ErrUnavailable-wrapped error when the lookup failed, so an outage
+// is never mistaken for a free name
 func (v *Validator) ValidateNameExists(ctx context.Context, name string) error {
-	exists, _ := v.repo.ExistsByName(ctx, strings.ToLower(name))
+	exists, err := v.repo.ExistsByName(ctx, strings.ToLower(name))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
 	if exists {
 		return errors.New(ErrSubredditNameTaken)
 	}
@@ -143,7 +342,9 @@ func (v *Validator) ValidateCreateSubredditInput(
 	displayName string,
 	description *string,
 	iconURL *string,
-) ValidationErrors {
+	topicSlugs []string,
+	language *string,
+) (ValidationErrors, error) {
 	var errs ValidationErrors
 
 	if err := v.ValidateNameFormat(name); err != nil {
@@ -154,7 +355,7 @@ func (v *Validator) ValidateCreateSubredditInput(
 		errs = append(errs, NewValidationError("display_name", err.Error()))
 	}
 
-	if err := v.ValidateDescriptionFormat(description); err != nil {
+	if err := v.ValidateDescriptionFormat(description, DescriptionMaxLen); err != nil {
 		errs = append(errs, NewValidationError("description", err.Error()))
 	}
 
@@ -162,35 +363,92 @@ func (v *Validator) ValidateCreateSubredditInput(
 		errs = append(errs, NewValidationError("icon_url", err.Error()))
 	}
 
+	if err := v.ValidateTopicSlugs(topicSlugs); err != nil {
+		errs = append(errs, NewValidationError("topics", err.Error()))
+	}
+
+	if err := v.ValidateLanguage(language); err != nil {
+		errs = append(errs, NewValidationError("language", err.Error()))
+	}
+
 	if len(errs) == 0 {
 		if err := v.ValidateNameExists(ctx, name); err != nil {
+			if errors.Is(err, ErrUnavailable) {
+				return nil, err
+			}
 			errs = append(errs, NewValidationError("name", err.Error()))
 		}
 	}
 
-	return errs
+	return errs, nil
 }
 
-func (v *Validator) ValidateUpdateSubredditInput(req UpdateSubredditRequest) ValidationErrors {
+// ValidateUpdateSubredditInput takes the current subreddit to resolve its description policy;
+// a limit changed in the same request already applies to the description sent with it
+func (v *Validator) ValidateUpdateSubredditInput(
+	req UpdateSubredditRequest,
+	subreddit *Subreddit,
+) ValidationErrors {
 	var errs ValidationErrors
 
+	descriptionLimit := subreddit.DescriptionLimit()
+	if req.DescriptionMaxLen.Set {
+		if err := v.ValidateDescriptionMaxLen(req.DescriptionMaxLen.Value); err != nil {
+			errs = append(errs, NewValidationError("description_max_len", err.Error()))
+		} else if req.DescriptionMaxLen.Value != nil {
+			descriptionLimit = *req.DescriptionMaxLen.Value
+		} else {
+			descriptionLimit = DescriptionMaxLen
+		}
+	}
+
 	if req.DisplayName != nil {
 		if err := v.ValidateDisplayNameFormat(*req.DisplayName); err != nil {
 			errs = append(errs, NewValidationError("display_name", err.Error()))
 		}
 	}
 
-	if req.Description != nil {
-		if err := v.ValidateDescriptionFormat(req.Description); err != nil {
+	if req.Description.Set {
+		if err := v.ValidateDescriptionFormat(req.Description.Value, descriptionLimit); err != nil {
 			errs = append(errs, NewValidationError("description", err.Error()))
 		}
 	}
 
-	if req.IconURL != nil {
-		if err := v.ValidateIconURLFormat(req.IconURL); err != nil {
+	if req.IconURL.Set {
+		if err := v.ValidateIconURLFormat(req.IconURL.Value); err != nil {
 			errs = append(errs, NewValidationError("icon_url", err.Error()))
 		}
 	}
 
+	if req.Topics != nil {
+		if err := v.ValidateTopicSlugs(*req.Topics); err != nil {
+			errs = append(errs, NewValidationError("topics", err.Error()))
+		}
+	}
+
+	if req.BannerURL.Set {
+		if err := v.ValidateBannerURLFormat(req.BannerURL.Value); err != nil {
+			errs = append(errs, NewValidationError("banner_url", err.Error()))
+		}
+	}
+
+	if req.PrimaryColor.Set {
+		if err := v.ValidateColorFormat(req.PrimaryColor.Value); err != nil {
+			errs = append(errs, NewValidationError("primary_color", err.Error()))
+		}
+	}
+
+	if req.BannerColor.Set {
+		if err := v.ValidateColorFormat(req.BannerColor.Value); err != nil {
+			errs = append(errs, NewValidationError("banner_color", err.Error()))
+		}
+	}
+
+	if req.Language.Set {
+		if err := v.ValidateLanguage(req.Language.Value); err != nil {
+			errs = append(errs, NewValidationError("language", err.Error()))
+		}
+	}
+
 	return errs
 }
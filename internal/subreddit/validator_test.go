@@ -0,0 +1,177 @@
+package subreddit
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+)
+
+func errText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func TestValidateNameFormatCountsRunes(t *testing.T) {
+	validator := NewValidator(nil, nil, 0)
+
+	tests := []struct {
+		name, input, want string
+	}{
+		{"two CJK runes are too short", strings.Repeat("語", 2), fmt.Sprintf(ErrSubredditNameTooShort, NameMinLen)},
+		// 63 bytes but 21 runes: past the length checks, then rejected by the ASCII-only charset
+		{"21 CJK runes pass the length checks", strings.Repeat("語", NameMaxLen), ErrSubredditNameInvalid},
+		{"22 CJK runes are too long", strings.Repeat("語", NameMaxLen+1), fmt.Sprintf(ErrSubredditNameTooLong, NameMaxLen)},
+		{"21 ASCII runes", strings.Repeat("a", NameMaxLen), ""},
+	}
+	for _, tt := range tests {
+		if got := errText(validator.ValidateNameFormat(tt.input)); got != tt.want {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateDisplayNameFormatCountsRunes(t *testing.T) {
+	const emoji = "😀" // 4 bytes, one rune
+
+	validator := NewValidator(nil, nil, 0)
+	if err := validator.ValidateDisplayNameFormat(strings.Repeat(emoji, DisplayNameMaxLen)); err != nil {
+		t.Errorf("%d emoji (%d bytes): %v, want valid", DisplayNameMaxLen, 4*DisplayNameMaxLen, err)
+	}
+	err := validator.ValidateDisplayNameFormat(strings.Repeat(emoji, DisplayNameMaxLen+1))
+	if want := fmt.Sprintf(ErrDisplayNameTooLong, DisplayNameMaxLen); errText(err) != want {
+		t.Errorf("%d emoji: error %v, want %q", DisplayNameMaxLen+1, err, want)
+	}
+
+	configured := NewValidator(nil, nil, 10)
+	if err := configured.ValidateDisplayNameFormat(strings.Repeat(emoji, 10)); err != nil {
+		t.Errorf("10 emoji with a limit of 10: %v, want valid", err)
+	}
+	if err := configured.ValidateDisplayNameFormat(strings.Repeat(emoji, 11)); errText(err) != fmt.Sprintf(ErrDisplayNameTooLong, 10) {
+		t.Errorf("11 emoji with a limit of 10: error %v, want too long", err)
+	}
+}
+
+func TestValidateTextLimitsCountRunes(t *testing.T) {
+	validator := NewValidator(nil, nil, 0)
+
+	description := strings.Repeat("ї", 500)
+	if err := validator.ValidateDescriptionFormat(&description, 500); err != nil {
+		t.Errorf("500 two-byte runes with a limit of 500: %v, want valid", err)
+	}
+	description += "ї"
+	if err := validator.ValidateDescriptionFormat(&description, 500); errText(err) != fmt.Sprintf(ErrDescriptionTooLong, 500) {
+		t.Errorf("501 two-byte runes with a limit of 500: error %v, want too long", err)
+	}
+
+	if err := validator.ValidateTopicNameFormat(strings.Repeat("話", TopicNameMaxLen)); err != nil {
+		t.Errorf("%d CJK runes as a topic name: %v, want valid", TopicNameMaxLen, err)
+	}
+	if err := validator.ValidateTopicNameFormat(strings.Repeat("話", TopicNameMaxLen+1)); err == nil {
+		t.Errorf("%d CJK runes as a topic name: want too long", TopicNameMaxLen+1)
+	}
+}
+
+func TestValidateUpdateDescriptionMaxLen(t *testing.T) {
+	validator := NewValidator(nil, nil, 0)
+	limit := func(n int) *int { return &n }
+	description := func(n int) utils.Optional[string] {
+		text := strings.Repeat("d", n)
+		return utils.Optional[string]{Value: &text, Set: true}
+	}
+	invalidLimit := NewValidationError("description_max_len", fmt.Sprintf(ErrDescriptionMaxLenInvalid, DescriptionHardCap))
+	tooLong := func(n int) ValidationError {
+		return NewValidationError("description", fmt.Sprintf(ErrDescriptionTooLong, n))
+	}
+
+	tests := []struct {
+		name     string
+		override *int // Stored on the subreddit
+		req      UpdateSubredditRequest
+		want     ValidationErrors
+	}{
+		{"global default", nil, UpdateSubredditRequest{Description: description(DescriptionMaxLen)}, nil},
+		{
+			"past the global default", nil,
+			UpdateSubredditRequest{Description: description(DescriptionMaxLen + 1)},
+			ValidationErrors{tooLong(DescriptionMaxLen)},
+		},
+		{"stored override below global", limit(100), UpdateSubredditRequest{Description: description(100)}, nil},
+		{
+			"past a stored override below global", limit(100),
+			UpdateSubredditRequest{Description: description(101)},
+			ValidationErrors{tooLong(100)},
+		},
+		{"stored override above global", limit(1000), UpdateSubredditRequest{Description: description(1000)}, nil},
+		{
+			"override lowered in the same request", nil,
+			UpdateSubredditRequest{DescriptionMaxLen: utils.Optional[int]{Value: limit(50), Set: true}, Description: description(60)},
+			ValidationErrors{tooLong(50)},
+		},
+		{
+			"override reset in the same request", limit(100),
+			UpdateSubredditRequest{DescriptionMaxLen: utils.Optional[int]{Set: true}, Description: description(DescriptionMaxLen)},
+			nil,
+		},
+		{
+			"override at the hard cap", nil,
+			UpdateSubredditRequest{
+				DescriptionMaxLen: utils.Optional[int]{Value: limit(DescriptionHardCap), Set: true},
+				Description:       description(DescriptionHardCap),
+			},
+			nil,
+		},
+		{
+			"override past the hard cap", nil,
+			UpdateSubredditRequest{DescriptionMaxLen: utils.Optional[int]{Value: limit(DescriptionHardCap + 1), Set: true}},
+			ValidationErrors{invalidLimit},
+		},
+		{
+			// A rejected override doesn't loosen the limit for the description sent with it
+			"override past the hard cap with a long description", nil,
+			UpdateSubredditRequest{
+				DescriptionMaxLen: utils.Optional[int]{Value: limit(DescriptionHardCap + 1), Set: true},
+				Description:       description(DescriptionMaxLen + 1),
+			},
+			ValidationErrors{invalidLimit, tooLong(DescriptionMaxLen)},
+		},
+		{
+			"zero override", nil,
+			UpdateSubredditRequest{DescriptionMaxLen: utils.Optional[int]{Value: limit(0), Set: true}},
+			ValidationErrors{invalidLimit},
+		},
+	}
+	for _, tt := range tests {
+		got := validator.ValidateUpdateSubredditInput(tt.req, &Subreddit{DescriptionMaxLen: tt.override})
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: errors %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLanguage(t *testing.T) {
+	validator := NewValidator(nil, []string{"en", "UK"}, 0)
+	code := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		language *string
+		want     string
+	}{
+		{"unset", nil, ""},
+		{"allowed", code("en"), ""},
+		{"allowed, configured in upper case", code("uk"), ""},
+		{"valid ISO 639-1 but not allowed", code("fr"), ErrLanguageNotAllowed},
+		{"not a code", code("english"), ErrLanguageNotAllowed},
+		{"empty", code(""), ErrLanguageNotAllowed},
+	}
+	for _, tt := range tests {
+		if got := errText(validator.ValidateLanguage(tt.language)); got != tt.want {
+			t.Errorf("%s: error %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package testutil
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/email"
+)
+
+// RecordingSender is an email.Sender for handler tests. It refuses what the real transports refuse
+// (see email.Validate) and keeps every accepted message instead of delivering it. Safe for concurrent use.
+type RecordingSender struct {
+	mu       sync.Mutex
+	messages []email.Message
+	err      error
+}
+
+func NewRecordingSender() *RecordingSender {
+	return &RecordingSender{}
+}
+
+// FailWith makes every following Send return err, nil goes back to accepting messages
+func (s *RecordingSender) FailWith(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *RecordingSender) Send(ctx context.Context, msg email.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := email.Validate(msg); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns the accepted messages in the order they were sent
+func (s *RecordingSender) Messages() []email.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]email.Message(nil), s.messages...)
+}
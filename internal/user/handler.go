@@ -8,7 +8,6 @@ import (
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 type Handler struct {
@@ -37,18 +36,114 @@ func (h *Handler) GetRequestUser(c *gin.Context) {
 
 	user, err := h.service.GetUserById(c.Request.Context(), userID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": ErrUserNotFound.Error()})
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrNotFound.Error()})
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
 		return
 	}
 
-	c.JSON(
-		http.StatusOK, PublicUserResponse{
-			Email:    user.Email,
-			Username: user.Username,
-		},
-	)
+	c.JSON(http.StatusOK, ToMeResponse(user))
+}
+
+// HeadUser answers existence checks for a username with 200 or 404 and no body
+func (h *Handler) HeadUser(c *gin.Context) {
+	exists, err := h.service.ExistsByUsername(c.Request.Context(), c.Param("username"))
+	if err != nil {
+		c.Status(http.StatusServiceUnavailable)
+		return
+	}
+	if !exists {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+func (h *Handler) DeleteAvatar(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	if err := h.service.RemoveAvatar(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove avatar"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SyncGoogleAvatar re-imports the Google picture as the avatar
+func (h *Handler) SyncGoogleAvatar(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	user, err := h.service.SyncGoogleAvatar(c.Request.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrNotFound.Error()})
+		case errors.Is(err, ErrNoGoogleAvatar):
+			c.JSON(http.StatusConflict, gin.H{"error": ErrNoGoogleAvatar.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync avatar"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ToMeResponse(user))
+}
+
+func (h *Handler) GetSettings(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	settings, err := h.service.GetSettings(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ToSettingsResponse(settings))
+}
+
+func (h *Handler) UpdateSettings(c *gin.Context) {
+	userID, ok := utils.GetUserIDFromContext(c)
+	if !ok {
+		return // Error response already sent
+	}
+
+	var req UpdateSettingsRequest
+	if err := utils.BindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	settings, err := h.service.UpdateSettings(c.Request.Context(), userID, req)
+	if err != nil {
+		var validationErrs ValidationErrors
+		if errors.As(err, &validationErrs) {
+			c.JSON(
+				http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": validationErrs,
+				},
+			)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ToSettingsResponse(settings))
 }
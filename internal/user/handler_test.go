@@ -0,0 +1,95 @@
+package user
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newTestRouter serves the user routes, do sends requests signed in as userID
+func newTestRouter(t *testing.T, service *Service) func(method, path, body string, userID uuid.UUID) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.Config{JWT: config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	router := gin.New()
+	RegisterRoutes(router, NewHandler(service, cfg), utils.RealClock{})
+
+	return func(method, path, body string, userID uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if userID != uuid.Nil {
+			token, err := utils.GenerateJWT(utils.RealClock{}, cfg.JWT.Secret, utils.TokenTypeAccess, time.Hour, userID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+}
+
+func createTestUser(t *testing.T, service *Service, name string) *User {
+	t.Helper()
+
+	u := &User{
+		ID:               utils.NewID(),
+		Username:         name,
+		Email:            name + "@example.com",
+		EmailNormalized:  name + "@example.com",
+		UsernameSkeleton: utils.Skeleton(name),
+		AuthProvider:     AuthProviderEmail,
+		Role:             RoleUser,
+	}
+	if err := service.repo.Create(context.Background(), u); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestDeleteAvatar(t *testing.T) {
+	service := newTestService(t)
+	do := newTestRouter(t, service)
+	ctx := context.Background()
+	u := createTestUser(t, service, "pictured")
+	avatarURL := "https://cdn.example/avatars/pictured.png"
+	if err := service.repo.SetAvatar(ctx, u.ID, &avatarURL); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec := do(http.MethodDelete, "/me/avatar", "", uuid.Nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous: status %d, want 401", rec.Code)
+	}
+	for range 2 { // Removing an already removed avatar is a no-op
+		if rec := do(http.MethodDelete, "/me/avatar", "", u.ID); rec.Code != http.StatusNoContent {
+			t.Fatalf("status %d (%s), want 204", rec.Code, rec.Body)
+		}
+	}
+
+	stored, err := service.GetUserById(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.AvatarURL != nil {
+		t.Errorf("avatar_url = %q, want NULL", *stored.AvatarURL)
+	}
+	if rec := do(http.MethodGet, "/me", "", u.ID); !strings.Contains(rec.Body.String(), `"avatar_url":null`) {
+		t.Errorf("GET /me after removal = %s, want avatar_url null", rec.Body)
+	}
+
+	if rec := do(http.MethodDelete, "/me/avatar", "", utils.NewID()); rec.Code != http.StatusNotFound {
+		t.Errorf("token of a user that doesn't exist: status %d, want 404", rec.Code)
+	}
+}
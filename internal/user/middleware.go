@@ -0,0 +1,37 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin must be chained after utils.JWTAuthMiddleware, it relies on user_id being set in the context
+func RequireAdmin(service *Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := utils.GetUserIDFromContext(c)
+		if !ok {
+			c.Abort() // Error response already sent
+			return
+		}
+
+		currentUser, err := service.GetUserById(c.Request.Context(), userID)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+			return
+		}
+
+		if !currentUser.IsAdmin() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
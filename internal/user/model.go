@@ -14,6 +14,13 @@ const (
 	AuthProviderGoogle AuthProvider = "google"
 )
 
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
 type User struct {
 	ID           uuid.UUID    `gorm:"type:uuid;primaryKey"`
 	Username     string       `gorm:"size:255;uniqueIndex;not null"`
@@ -21,8 +28,68 @@ type User struct {
 	Password     *string      `gorm:"size:255"`
 	GoogleID     *string      `gorm:"size:255;uniqueIndex"`
 	AvatarURL    *string      `gorm:"size:500"`
-	AuthProvider AuthProvider `gorm:"size:20;not null;default:'email'"`
+	AuthProvider AuthProvider `gorm:"size:20;not null;default:'email'"` // How the account signed up, linking keeps it
+	Role         Role         `gorm:"size:20;not null;default:'user'"`
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ScrubbedAt   *time.Time     // Personal data wiped by the retention job, see Service.RunRetention
+
+	UsernameSkeleton string  `gorm:"size:255;not null;index"`       // utils.Skeleton(Username), lookalikes share it
+	GoogleAvatarURL  *string `gorm:"size:500"`                      // Google picture as of the last Google sign-in
+	EmailNormalized  string  `gorm:"size:255;not null;uniqueIndex"` // utils.EmailNormalizer, one account per mailbox
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// LinkedProviders lists every way the user can sign in, which may be more than the AuthProvider they signed up with
+func (u *User) LinkedProviders() []AuthProvider {
+	providers := []AuthProvider{}
+	if u.Password != nil {
+		providers = append(providers, AuthProviderEmail)
+	}
+	if u.GoogleID != nil {
+		providers = append(providers, AuthProviderGoogle)
+	}
+	return providers
+}
+
+const (
+	FeedSortHot = "hot"
+	FeedSortNew = "new"
+	FeedSortTop = "top"
+
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+	ThemeSystem = "system"
+)
+
+// Settings holds a user's feed and display preferences. The row is only created on the first PATCH,
+// until then DefaultSettings applies (keep both in sync with the column defaults).
+type Settings struct {
+	UserID          uuid.UUID `gorm:"type:uuid;primaryKey"`
+	DefaultFeedSort string    `gorm:"size:10;not null"`
+	ShowNSFW        bool      `gorm:"not null"`
+	EmailOnReply    bool      `gorm:"not null"`
+	EmailOnMention  bool      `gorm:"not null"`
+	ContentLanguage *string   `gorm:"size:2"` // ISO 639-1, NULL shows all languages
+	Theme           string    `gorm:"size:10;not null"`
+	UpdatedAt       time.Time
+}
+
+func (Settings) TableName() string {
+	return "user_settings"
+}
+
+func DefaultSettings(userID uuid.UUID) *Settings {
+	return &Settings{
+		UserID:          userID,
+		DefaultFeedSort: FeedSortHot,
+		ShowNSFW:        false,
+		EmailOnReply:    true,
+		EmailOnMention:  true,
+		Theme:           ThemeSystem,
+	}
 }
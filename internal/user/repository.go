@@ -2,38 +2,101 @@ package user
 
 import (
 	"context"
+	"time"
 
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
 	// INFO: making db unexported so that service layer can't bypass repository and use ORM directly(uncoupling service from gorm)
-	db *gorm.DB
+	db           *database.DB
+	queryTimeout time.Duration // Applied to every repository call, see utils.WithQueryTimeout
 	// TODO: consider adding cache(cache *redis.Client) if needed later
 }
 
-func NewRepository(db *gorm.DB) *Repository {
+func NewRepository(db *database.DB, queryTimeout time.Duration) *Repository {
 	return &Repository{
-		db: db,
+		db:           db,
+		queryTimeout: queryTimeout,
 	}
 }
 
+// conn joins the transaction carried by ctx (see database.TxManager) or uses the repository handle
+func (repo *Repository) conn(ctx context.Context) *gorm.DB {
+	return database.Conn(ctx, repo.db.WriteDB())
+}
+
+// readConn is conn for queries that can be served by the replica, see database.ReadConn
+func (repo *Repository) readConn(ctx context.Context) *gorm.DB {
+	return database.ReadConn(ctx, repo.db)
+}
+
 // Create inserts a new user into DB
 func (repo *Repository) Create(ctx context.Context, user *User) error {
-	return repo.db.WithContext(ctx).Create(user).Error
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Create(user).Error
 }
 
 func (repo *Repository) Update(ctx context.Context, user *User) error {
-	return repo.db.WithContext(ctx).Save(user).Error
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).Save(user).Error
+}
+
+// SetGoogleID links a Google account and records its picture, without touching any other column
+func (repo *Repository) SetGoogleID(ctx context.Context, id uuid.UUID, googleID string, googleAvatarURL *string) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"google_id": googleID, "google_avatar_url": googleAvatarURL})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetAvatar sets avatar_url, NULL makes the client fall back to the default avatar
+func (repo *Repository) SetAvatar(ctx context.Context, id uuid.UUID, avatarURL *string) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	result := repo.conn(ctx).
+		Model(&User{}).
+		Where("id = ?", id).
+		Update("avatar_url", avatarURL)
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
 }
 
 // GetByID retrieves user by ID
 func (repo *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	// TODO: why we're using query result assignment by pointer as destination, instead of user:=...?
 	var currentUser User // INFO: using value in this case instead of pointer to prevent nil pointer dereferencing in orm method
-	err := repo.db.WithContext(ctx).
-		Select("id", "username", "email", "created_at", "updated_at").
+	err := repo.readConn(ctx).
+		Select("id", "username", "email", "role", "created_at", "updated_at").
 		Take(&currentUser, id).Error
 	if err != nil {
 		return nil, err
@@ -43,8 +106,11 @@ func (repo *Repository) GetByID(ctx context.Context, id uuid.UUID) (*User, error
 
 // GetByEmail retrieves user by email
 func (repo *Repository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var currentUser User
-	err := repo.db.WithContext(ctx).Where(
+	err := repo.conn(ctx).Where(
 		"email = ?",
 		email,
 	).First(&currentUser).Error // INFO: using Where() instead of plain First() for better method chaining and readability
@@ -54,10 +120,60 @@ func (repo *Repository) GetByEmail(ctx context.Context, email string) (*User, er
 	return &currentUser, nil
 }
 
+// GetByEmailNormalized retrieves the user registered with any alias of the mailbox, see utils.EmailNormalizer
+func (repo *Repository) GetByEmailNormalized(ctx context.Context, emailNormalized string) (*User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var currentUser User
+	err := repo.conn(ctx).Where("email_normalized = ?", emailNormalized).Take(&currentUser).Error
+	if err != nil {
+		return nil, err
+	}
+	return &currentUser, nil
+}
+
+// ListEmailsAfter pages through every user, soft-deleted ones included, oldest first by (created_at, id).
+// Only the columns email normalization needs are loaded.
+func (repo *Repository) ListEmailsAfter(
+	ctx context.Context,
+	afterCreatedAt time.Time,
+	afterID uuid.UUID,
+	limit int,
+) ([]User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var users []User
+	err := repo.conn(ctx).
+		Unscoped().
+		Select("id", "email", "email_normalized", "created_at").
+		Where("(created_at, id) > (?, ?)", afterCreatedAt, afterID).
+		Order("created_at, id").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// SetEmailNormalized fails with gorm.ErrDuplicatedKey when another account already has the value
+func (repo *Repository) SetEmailNormalized(ctx context.Context, id uuid.UUID, emailNormalized string) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Unscoped().
+		Model(&User{}).
+		Where("id = ?", id).
+		UpdateColumn("email_normalized", emailNormalized).Error
+}
+
 // GetByGoogleID retrieves user by Google ID
 func (repo *Repository) GetByGoogleID(ctx context.Context, googleID string) (*User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var currentUser User
-	err := repo.db.WithContext(ctx).Where("google_id = ?", googleID).Take(&currentUser).Error
+	err := repo.conn(ctx).Where("google_id = ?", googleID).Take(&currentUser).Error
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +182,11 @@ func (repo *Repository) GetByGoogleID(ctx context.Context, googleID string) (*Us
 
 // GetByUsername retrieves user by username
 func (repo *Repository) GetByUsername(ctx context.Context, username string) (*User, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var currentUser User
-	err := repo.db.WithContext(ctx).Where("username = ?", username).First(&currentUser).Error
+	err := repo.readConn(ctx).Where("username = ?", username).First(&currentUser).Error
 	if err != nil {
 		return nil, err
 	}
@@ -78,17 +197,207 @@ func (repo *Repository) GetByUsername(ctx context.Context, username string) (*Us
 
 // ExistsByEmail checks if user with given email exists
 func (repo *Repository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var count int64
-	err := repo.db.WithContext(ctx).Model(&User{}).Where("email = ?", email).Count(&count).Error
+	err := repo.conn(ctx).Model(&User{}).Where("email = ?", email).Count(&count).Error
+	return count > 0, err
+}
+
+// ExistsByEmailNormalized checks if any alias of the mailbox is registered, soft-deleted accounts included
+// as the unique index covers them
+func (repo *Repository) ExistsByEmailNormalized(ctx context.Context, emailNormalized string) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.conn(ctx).
+		Unscoped().
+		Model(&User{}).
+		Where("email_normalized = ?", emailNormalized).
+		Count(&count).Error
 	return count > 0, err
 }
 
 // ExistsByUsername checks if user with given username exists
 func (repo *Repository) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
 	var count int64
-	err := repo.db.WithContext(ctx).Model(&User{}).Where(
+	err := repo.readConn(ctx).Model(&User{}).Where(
 		"username = ?",
 		username,
 	).Count(&count).Error
 	return count > 0, err
 }
+
+func (repo *Repository) ExistsByUsernameSkeleton(ctx context.Context, skeleton string) (bool, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.conn(ctx).Model(&User{}).Where("username_skeleton = ?", skeleton).Count(&count).Error
+	return count > 0, err
+}
+
+// GetSettings returns gorm.ErrRecordNotFound for users who never changed a setting
+func (repo *Repository) GetSettings(ctx context.Context, userID uuid.UUID) (*Settings, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var settings Settings
+	err := repo.conn(ctx).
+		Where("user_id = ?", userID).
+		Take(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpsertSettings creates the settings row on the first update, afterward only the given columns are
+// overwritten, so concurrent PATCHes of different fields don't undo each other. settings is filled with
+// the stored row (INSERT ... ON CONFLICT DO UPDATE ... RETURNING).
+func (repo *Repository) UpsertSettings(ctx context.Context, settings *Settings, columns []string) error {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	return repo.conn(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}},
+				DoUpdates: clause.AssignmentColumns(append(columns, "updated_at")),
+			},
+			clause.Returning{},
+		).
+		Create(settings).Error
+}
+
+// scrubDueQuery matches soft-deleted users deleted before cutoff whose personal data is still there
+func (repo *Repository) scrubDueQuery(db *gorm.DB, cutoff time.Time) *gorm.DB {
+	return db.Unscoped().
+		Model(&User{}).
+		Where("deleted_at < ? AND scrubbed_at IS NULL", cutoff)
+}
+
+// hardDeleteDueQuery matches scrubbed users deleted before cutoff. Users still referenced by subreddits,
+// audit logs or wiki revisions (RESTRICT foreign keys) are left as scrubbed "[deleted]" rows.
+func (repo *Repository) hardDeleteDueQuery(db *gorm.DB, cutoff time.Time) *gorm.DB {
+	return db.Unscoped().
+		Model(&User{}).
+		Where("deleted_at < ? AND scrubbed_at IS NOT NULL", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM subreddits WHERE subreddits.creator_id = users.id)").
+		Where("NOT EXISTS (SELECT 1 FROM audit_logs WHERE audit_logs.actor_id = users.id)").
+		Where("NOT EXISTS (SELECT 1 FROM subreddit_wiki_pages WHERE subreddit_wiki_pages.updated_by_id = users.id)")
+}
+
+// CountScrubDue and CountHardDeleteDue back the retention dry run
+func (repo *Repository) CountScrubDue(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.scrubDueQuery(repo.conn(ctx), cutoff).Count(&count).Error
+	return count, err
+}
+
+func (repo *Repository) CountHardDeleteDue(ctx context.Context, cutoff time.Time) (int64, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := repo.hardDeleteDueQuery(repo.conn(ctx), cutoff).Count(&count).Error
+	return count, err
+}
+
+// ScrubDeletedAfter wipes the personal data of up to limit due users with IDs above afterID and returns
+// their IDs in order, the last one is the keyset cursor for the next batch. The email becomes a tombstone
+// derived from the user ID, keeping the unique index satisfied without retaining the address.
+func (repo *Repository) ScrubDeletedAfter(
+	ctx context.Context,
+	cutoff time.Time,
+	afterID uuid.UUID,
+	limit int,
+	now time.Time,
+) ([]uuid.UUID, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var ids []uuid.UUID
+	err := repo.scrubDueQuery(repo.conn(ctx), cutoff).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	err = repo.conn(ctx).
+		Unscoped().
+		Model(&User{}).
+		Where("id IN ? AND scrubbed_at IS NULL", ids).
+		Updates(
+			map[string]interface{}{
+				"email":             gorm.Expr("'deleted-' || encode(sha256(id::text::bytea), 'hex') || '@invalid'"),
+				"email_normalized":  gorm.Expr("'deleted-' || encode(sha256(id::text::bytea), 'hex') || '@invalid'"),
+				"password":          nil,
+				"google_id":         nil,
+				"avatar_url":        nil,
+				"google_avatar_url": nil,
+				"scrubbed_at":       now,
+			},
+		).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// HardDeleteAfter removes up to limit due users with IDs above afterID, see ScrubDeletedAfter for the cursor.
+// Their memberships go with them (ON DELETE CASCADE), so member_count of those subreddits is decremented
+// first. Run it in a transaction (see database.TxManager) so the counts and the rows change together.
+func (repo *Repository) HardDeleteAfter(
+	ctx context.Context,
+	cutoff time.Time,
+	afterID uuid.UUID,
+	limit int,
+	now time.Time,
+) ([]uuid.UUID, error) {
+	ctx, cancel := utils.WithQueryTimeout(ctx, repo.queryTimeout)
+	defer cancel()
+
+	var ids []uuid.UUID
+	err := repo.hardDeleteDueQuery(repo.conn(ctx), cutoff).
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	// Bumps updated_at like leaving does, caches key their freshness off it
+	err = repo.conn(ctx).
+		Exec(
+			"UPDATE subreddits SET member_count = member_count - m.n, updated_at = ? "+
+				"FROM (SELECT subreddit_id, count(*) AS n FROM subreddit_members WHERE user_id IN ? "+
+				"GROUP BY subreddit_id) m "+
+				"WHERE subreddits.id = m.subreddit_id",
+			now, ids,
+		).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.conn(ctx).
+		Unscoped().
+		Where("id IN ?", ids).
+		Delete(&User{}).Error
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -5,9 +5,20 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func RegisterRoutes(router *gin.Engine, h *Handler) {
+func RegisterRoutes(router *gin.Engine, h *Handler, clock utils.Clock) {
+	requireAuth := utils.JWTAuthMiddleware(&h.config.JWT, clock)
+
 	userRouter := router.Group("/me")
 	{
-		userRouter.GET("", utils.JWTAuthMiddleware(&h.config.JWT), h.GetRequestUser)
+		userRouter.GET("", requireAuth, h.GetRequestUser)
+		userRouter.DELETE("/avatar", requireAuth, h.DeleteAvatar)
+		userRouter.POST("/avatar/sync-google", requireAuth, h.SyncGoogleAvatar)
+		userRouter.GET("/settings", requireAuth, h.GetSettings)
+		userRouter.PATCH("/settings", requireAuth, h.UpdateSettings)
+	}
+
+	usersRouter := router.Group("/users")
+	{
+		usersRouter.HEAD("/:username", h.HeadUser)
 	}
 }
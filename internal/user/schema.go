@@ -1,8 +1,87 @@
 package user
 
+import (
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+)
+
 // TODO: find a more structured and shared way for validating fields in DTOs
 
 type PublicUserResponse struct {
 	Email    string `json:"email" validate:"required,email"`
 	Username string `json:"username" validate:"required,min=3,max=50,alphanum"`
 }
+
+// MeResponse is the signed-in user's own profile
+type MeResponse struct {
+	Email           string         `json:"email"`
+	Username        string         `json:"username"`
+	AvatarURL       *string        `json:"avatar_url"`
+	AuthProvider    AuthProvider   `json:"auth_provider"`
+	LinkedProviders []AuthProvider `json:"linked_providers"`
+}
+
+func ToMeResponse(u *User) MeResponse {
+	return MeResponse{
+		Email:           u.Email,
+		Username:        u.Username,
+		AvatarURL:       u.AvatarURL,
+		AuthProvider:    u.AuthProvider,
+		LinkedProviders: u.LinkedProviders(),
+	}
+}
+
+// DeletedUsername stands in for accounts that no longer exist
+const DeletedUsername = "[deleted]"
+
+// ToPublicUserResponse renders a deleted account as "[deleted]" without an email. Preloads skip
+// soft-deleted users, so a zero-value User (no ID) counts as deleted as well.
+func ToPublicUserResponse(u *User) PublicUserResponse {
+	if u.ID == uuid.Nil || u.DeletedAt.Valid {
+		return PublicUserResponse{Username: DeletedUsername}
+	}
+	return PublicUserResponse{
+		Username: u.Username,
+		Email:    u.Email,
+	}
+}
+
+type SettingsResponse struct {
+	DefaultFeedSort string  `json:"default_feed_sort"`
+	ShowNSFW        bool    `json:"show_nsfw"`
+	EmailOnReply    bool    `json:"email_on_reply"`
+	EmailOnMention  bool    `json:"email_on_mention"`
+	ContentLanguage *string `json:"content_language"`
+	Theme           string  `json:"theme"`
+}
+
+// UpdateSettingsRequest is a partial update, omitted fields keep their current value
+type UpdateSettingsRequest struct {
+	DefaultFeedSort *string                `json:"default_feed_sort"`
+	ShowNSFW        *bool                  `json:"show_nsfw"`
+	EmailOnReply    *bool                  `json:"email_on_reply"`
+	EmailOnMention  *bool                  `json:"email_on_mention"`
+	ContentLanguage utils.Optional[string] `json:"content_language"` // null clears the filter
+	Theme           *string                `json:"theme"`
+}
+
+func ToSettingsResponse(s *Settings) SettingsResponse {
+	return SettingsResponse{
+		DefaultFeedSort: s.DefaultFeedSort,
+		ShowNSFW:        s.ShowNSFW,
+		EmailOnReply:    s.EmailOnReply,
+		EmailOnMention:  s.EmailOnMention,
+		ContentLanguage: s.ContentLanguage,
+		Theme:           s.Theme,
+	}
+}
+
+// RetentionReport is the retention dry run: how many soft-deleted accounts a run would scrub or remove now
+type RetentionReport struct {
+	ScrubCutoff      time.Time  `json:"scrub_cutoff"`
+	ScrubDue         int64      `json:"scrub_due"`
+	HardDeleteCutoff *time.Time `json:"hard_delete_cutoff"` // null while hard deletion is disabled
+	HardDeleteDue    int64      `json:"hard_delete_due"`
+}
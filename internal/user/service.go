@@ -4,22 +4,64 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"time"
 
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
 	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrNotFound            = errors.New("user not found")
+	ErrEmailTaken          = errors.New("email already registered")
+	ErrUsernameTaken       = errors.New("username already taken")
+	ErrUsernameConfusable  = errors.New("username is too similar to an existing one")
+	ErrUnavailable         = errors.New("user storage unavailable")
+	ErrGoogleAccountLinked = errors.New("google account is already linked to another user")
+	ErrSignupNotAllowed    = errors.New("sign-up is not allowed for this email")
+	ErrNoGoogleAvatar      = errors.New("no Google avatar to import, sign in with Google first")
 )
 
+// translateErr maps repository errors onto the package sentinels. The original error stays wrapped,
+// so callers still matching gorm.ErrRecordNotFound keep working.
+func translateErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
+
 type Service struct {
-	repo *Repository
+	repo            *Repository
+	txManager       *database.TxManager
+	validator       *Validator
+	emailNormalizer *utils.EmailNormalizer
+	retentionCfg    config.RetentionConfig
+	clock           utils.Clock
 }
 
-func NewService(repo *Repository) *Service {
+func NewService(
+	repo *Repository,
+	txManager *database.TxManager,
+	allowedLanguages []string,
+	emailNormalization config.EmailNormalizationConfig,
+	retentionCfg config.RetentionConfig,
+	clock utils.Clock,
+) *Service {
 	return &Service{
-		repo: repo,
+		repo:            repo,
+		txManager:       txManager,
+		validator:       NewValidator(allowedLanguages),
+		emailNormalizer: utils.NewEmailNormalizer(emailNormalization),
+		retentionCfg:    retentionCfg,
+		clock:           clock,
 	}
 }
 
@@ -29,11 +71,14 @@ func (s *Service) CreateUser(ctx context.Context, email, username, password stri
 		return nil, fmt.Errorf("password hashing failed: %w", err)
 	}
 	user := &User{
-		ID:           uuid.New(),
-		Email:        email,
-		Username:     username,
-		Password:     &hashedPassword,
-		AuthProvider: AuthProviderEmail,
+		ID:               utils.NewID(),
+		Email:            email,
+		EmailNormalized:  s.emailNormalizer.Normalize(email),
+		Username:         username,
+		UsernameSkeleton: utils.Skeleton(username),
+		Password:         &hashedPassword,
+		AuthProvider:     AuthProviderEmail,
+		Role:             RoleUser,
 	}
 
 	return user, s.repo.Create(ctx, user)
@@ -44,56 +89,430 @@ func (s *Service) CreateUserByGoogle(
 	email, username, googleID, avatarURL string,
 ) (*User, error) {
 	user := &User{
-		ID:           uuid.New(),
-		Email:        email,
-		Username:     username,
-		AuthProvider: AuthProviderGoogle,
-		GoogleID:     &googleID,
-		AvatarURL:    &avatarURL,
+		ID:               utils.NewID(),
+		Email:            email,
+		EmailNormalized:  s.emailNormalizer.Normalize(email),
+		Username:         username,
+		UsernameSkeleton: utils.Skeleton(username),
+		AuthProvider:     AuthProviderGoogle,
+		Role:             RoleUser,
+		GoogleID:         &googleID,
+		AvatarURL:        nilIfEmpty(avatarURL),
+		GoogleAvatarURL:  nilIfEmpty(avatarURL),
 	}
 
 	return user, s.repo.Create(ctx, user)
 }
 
+// LinkGoogleAccount attaches a Google identity to an existing (logged in) user, remembering its picture
+// for SyncGoogleAvatar without touching the user's avatar.
+// Re-linking the same account only refreshes the picture, taking over one linked elsewhere is refused.
+func (s *Service) LinkGoogleAccount(ctx context.Context, userID uuid.UUID, googleID, avatarURL string) error {
+	return s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			linked, err := s.repo.GetByGoogleID(ctx, googleID)
+			switch {
+			case err == nil:
+				if linked.ID != userID {
+					return ErrGoogleAccountLinked
+				}
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err // Not knowing whether it's linked is not the same as it not being linked
+			}
+
+			err = s.repo.SetGoogleID(ctx, userID, googleID, nilIfEmpty(avatarURL))
+			if errors.Is(err, gorm.ErrDuplicatedKey) {
+				return ErrGoogleAccountLinked // Linked to someone else concurrently
+			}
+			return err
+		},
+	)
+}
+
 func (s *Service) GetUserById(ctx context.Context, id uuid.UUID) (*User, error) {
-	return s.repo.GetByID(ctx, id)
+	user, err := s.repo.GetByID(ctx, id)
+	return user, translateErr(err)
 }
 
+// RemoveAvatar clears the stored avatar URL; there is no upload storage yet, so nothing else to delete
+func (s *Service) RemoveAvatar(ctx context.Context, id uuid.UUID) error {
+	return translateErr(s.repo.SetAvatar(ctx, id, nil))
+}
+
+// SyncGoogleAvatar replaces the user's avatar with their Google picture, Google sign-ins never do it on their own
+func (s *Service) SyncGoogleAvatar(ctx context.Context, id uuid.UUID) (*User, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, translateErr(err)
+	}
+	if user.GoogleAvatarURL == nil {
+		return nil, ErrNoGoogleAvatar
+	}
+
+	if err := s.repo.SetAvatar(ctx, id, user.GoogleAvatarURL); err != nil {
+		return nil, translateErr(err)
+	}
+	user.AvatarURL = user.GoogleAvatarURL
+	return user, nil
+}
+
+// GetByEmail finds the account of any alias of the mailbox, like registration's duplicate check does.
+// The exact address is tried first: RenormalizeEmails may have left an alias account with a placeholder
+// email_normalized, and it still has to be reachable by its own address.
 func (s *Service) GetByEmail(ctx context.Context, email string) (*User, error) {
-	return s.repo.GetByEmail(ctx, email)
+	user, err := s.repo.GetByEmail(ctx, email)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, translateErr(err)
+	}
+	user, err = s.repo.GetByEmailNormalized(ctx, s.emailNormalizer.Normalize(email))
+	return user, translateErr(err)
 }
 
 func (s *Service) GetByGoogleID(ctx context.Context, googleID string) (*User, error) {
-	return s.repo.GetByGoogleID(ctx, googleID)
+	user, err := s.repo.GetByGoogleID(ctx, googleID)
+	return user, translateErr(err)
 }
 
 func (s *Service) GetByUsername(ctx context.Context, username string) (*User, error) {
-	return s.repo.GetByUsername(ctx, username)
+	user, err := s.repo.GetByUsername(ctx, username)
+	return user, translateErr(err)
 }
 
+// ExistsByEmail wraps lookup failures in ErrUnavailable, a failed check must never read as "not found"
 func (s *Service) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	return s.repo.ExistsByEmail(ctx, email)
+	exists, err := s.repo.ExistsByEmail(ctx, email)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return exists, nil
 }
 
+// ExistsByUsername wraps lookup failures in ErrUnavailable, a failed check must never read as "not found"
 func (s *Service) ExistsByUsername(ctx context.Context, username string) (bool, error) {
-	return s.repo.ExistsByUsername(ctx, username)
+	exists, err := s.repo.ExistsByUsername(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return exists, nil
+}
+
+// CheckEmailAvailable returns ErrEmailTaken, also for an alias of a registered address ("a.b+x@gmail.com" for
+// "ab@gmail.com"), or an ErrUnavailable-wrapped error when the check itself failed
+func (s *Service) CheckEmailAvailable(ctx context.Context, email string) error {
+	exists, err := s.repo.ExistsByEmailNormalized(ctx, s.emailNormalizer.Normalize(email))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if exists {
+		return ErrEmailTaken
+	}
+	return nil
 }
 
+// CheckUsernameAvailable returns ErrUsernameTaken, or an ErrUnavailable-wrapped error when the check itself failed
+func (s *Service) CheckUsernameAvailable(ctx context.Context, username string) error {
+	exists, err := s.ExistsByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// CheckUsernameNotConfusable returns ErrUsernameConfusable when an existing username looks like this one,
+// e.g. "ADMIN", "adrnin" or "аdmin" (Cyrillic а) against "admin"
+func (s *Service) CheckUsernameNotConfusable(ctx context.Context, username string) error {
+	exists, err := s.repo.ExistsByUsernameSkeleton(ctx, utils.Skeleton(username))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if exists {
+		return ErrUsernameConfusable
+	}
+	return nil
+}
+
+// FindOrCreateByGoogle returns ErrSignupNotAllowed instead of creating an account when allowSignup is false.
+// An existing account is matched by normalized email, the Google account owns the mailbox every alias goes to.
+// Existing accounts only get their Google ID and picture recorded: a chosen avatar and the provider they signed
+// up with are kept, the avatar is only filled in when there's none.
 func (s *Service) FindOrCreateByGoogle(
 	ctx context.Context,
 	email, googleID, avatarURL string,
+	allowSignup bool,
 ) (*User, error) {
-	if user, err := s.repo.GetByGoogleID(ctx, googleID); err == nil {
-		return user, nil
+	user, err := s.findOrCreateByGoogle(ctx, email, googleID, avatarURL, allowSignup)
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		// A concurrent callback created or linked the account first, the lookup finds it now
+		user, err = s.findOrCreateByGoogle(ctx, email, googleID, avatarURL, allowSignup)
 	}
+	return user, err
+}
 
-	if user, err := s.repo.GetByEmail(ctx, email); err == nil {
-		user.GoogleID = &googleID
-		user.AvatarURL = &avatarURL
-		return user, s.repo.Update(ctx, user)
+func (s *Service) findOrCreateByGoogle(
+	ctx context.Context,
+	email, googleID, avatarURL string,
+	allowSignup bool,
+) (*User, error) {
+	var user *User
+	// The transaction keeps the lookup and the write together, but under READ COMMITTED it doesn't stop
+	// a concurrent callback from passing the same lookup: the unique indexes on google_id and email do,
+	// failing the loser with gorm.ErrDuplicatedKey
+	err := s.txManager.RunInTx(
+		ctx, func(ctx context.Context) error {
+			var err error
+			if user, err = s.repo.GetByGoogleID(ctx, googleID); err == nil {
+				if user.GoogleAvatarURL != nil && *user.GoogleAvatarURL == avatarURL ||
+					user.GoogleAvatarURL == nil && avatarURL == "" {
+					return nil
+				}
+				user.GoogleAvatarURL = nilIfEmpty(avatarURL)
+				return s.repo.SetGoogleID(ctx, user.ID, googleID, user.GoogleAvatarURL)
+			}
+
+			if user, err = s.repo.GetByEmailNormalized(ctx, s.emailNormalizer.Normalize(email)); err == nil {
+				user.GoogleID = &googleID
+				user.GoogleAvatarURL = nilIfEmpty(avatarURL)
+				if user.AvatarURL == nil {
+					user.AvatarURL = user.GoogleAvatarURL
+				}
+				return s.repo.Update(ctx, user)
+			}
+
+			if !allowSignup {
+				return ErrSignupNotAllowed
+			}
+			username := GenerateUsernameFromEmail(email)
+			user, err = s.CreateUserByGoogle(ctx, email, username, googleID, avatarURL)
+			return err
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetSettings falls back to DefaultSettings for users without a stored row
+func (s *Service) GetSettings(ctx context.Context, userID uuid.UUID) (*Settings, error) {
+	settings, err := s.repo.GetSettings(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DefaultSettings(userID), nil
 	}
+	return settings, err
+}
+
+// UpdateSettings writes only the fields present in req, the others keep their stored (or default) value
+func (s *Service) UpdateSettings(ctx context.Context, userID uuid.UUID, req UpdateSettingsRequest) (*Settings, error) {
+	req.DefaultFeedSort = lowerOptional(req.DefaultFeedSort)
+	req.Theme = lowerOptional(req.Theme)
+	req.ContentLanguage.Value = lowerOptional(req.ContentLanguage.Value)
 
-	username := GenerateUsernameFromEmail(email)
+	if errs := s.validator.ValidateSettingsInput(req); len(errs) > 0 {
+		return nil, errs
+	}
 
-	return s.CreateUserByGoogle(ctx, email, username, googleID, avatarURL)
+	// The defaults are only inserted for a user without a row, an existing row just gets the sent columns
+	settings := DefaultSettings(userID)
+	var columns []string
+	if req.DefaultFeedSort != nil {
+		settings.DefaultFeedSort = *req.DefaultFeedSort
+		columns = append(columns, "default_feed_sort")
+	}
+	if req.ShowNSFW != nil {
+		settings.ShowNSFW = *req.ShowNSFW
+		columns = append(columns, "show_nsfw")
+	}
+	if req.EmailOnReply != nil {
+		settings.EmailOnReply = *req.EmailOnReply
+		columns = append(columns, "email_on_reply")
+	}
+	if req.EmailOnMention != nil {
+		settings.EmailOnMention = *req.EmailOnMention
+		columns = append(columns, "email_on_mention")
+	}
+	if req.ContentLanguage.Set {
+		settings.ContentLanguage = req.ContentLanguage.Value
+		columns = append(columns, "content_language")
+	}
+	if req.Theme != nil {
+		settings.Theme = *req.Theme
+		columns = append(columns, "theme")
+	}
+
+	if len(columns) == 0 {
+		return s.GetSettings(ctx, userID)
+	}
+	if err := s.repo.UpsertSettings(ctx, settings, columns); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// nilIfEmpty stores a missing Google picture (sent as "") as NULL
+func nilIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func lowerOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	lowered := strings.ToLower(strings.TrimSpace(*value))
+	return &lowered
+}
+
+// RunRetention scrubs and then hard-deletes soft-deleted accounts past their retention windows every interval
+// until ctx is done. Runs walk the users in ID order (keyset), so they're safe to interrupt and simply resume
+// with the rows still due on the next tick.
+func (s *Service) RunRetention(ctx context.Context) {
+	if s.retentionCfg.ScrubAfter <= 0 || s.retentionCfg.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.retentionCfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.applyRetention(ctx)
+			}
+		}
+	}()
+}
+
+// PreviewRetention reports what applyRetention would purge right now without touching anything
+func (s *Service) PreviewRetention(ctx context.Context) (*RetentionReport, error) {
+	now := s.clock.Now()
+	report := &RetentionReport{ScrubCutoff: now.Add(-s.retentionCfg.ScrubAfter)}
+
+	scrubDue, err := s.repo.CountScrubDue(ctx, report.ScrubCutoff)
+	if err != nil {
+		return nil, err
+	}
+	report.ScrubDue = scrubDue
+
+	if s.retentionCfg.HardDeleteAfter > 0 {
+		hardDeleteCutoff := now.Add(-s.retentionCfg.HardDeleteAfter)
+		hardDeleteDue, err := s.repo.CountHardDeleteDue(ctx, hardDeleteCutoff)
+		if err != nil {
+			return nil, err
+		}
+		report.HardDeleteCutoff = &hardDeleteCutoff
+		report.HardDeleteDue = hardDeleteDue
+	}
+
+	return report, nil
+}
+
+func (s *Service) applyRetention(ctx context.Context) {
+	now := s.clock.Now()
+
+	s.purgeInBatches(
+		"scrubbed", func(afterID uuid.UUID, limit int) ([]uuid.UUID, error) {
+			return s.repo.ScrubDeletedAfter(ctx, now.Add(-s.retentionCfg.ScrubAfter), afterID, limit, now)
+		},
+	)
+	if s.retentionCfg.HardDeleteAfter > 0 {
+		s.purgeInBatches(
+			"hard-deleted", func(afterID uuid.UUID, limit int) (ids []uuid.UUID, err error) {
+				err = s.txManager.RunInTx(
+					ctx, func(ctx context.Context) error {
+						ids, err = s.repo.HardDeleteAfter(ctx, now.Add(-s.retentionCfg.HardDeleteAfter), afterID, limit, now)
+						return err
+					},
+				)
+				return ids, err
+			},
+		)
+	}
+}
+
+// purgeInBatches calls purge with the last ID of the previous batch until a batch comes back short
+func (s *Service) purgeInBatches(action string, purge func(afterID uuid.UUID, limit int) ([]uuid.UUID, error)) {
+	limit := max(s.retentionCfg.BatchSize, 1)
+	afterID := uuid.Nil
+
+	for {
+		ids, err := purge(afterID, limit)
+		if err != nil {
+			log.Printf("User retention stopped, the next run resumes it: %v", err)
+			return
+		}
+		if len(ids) > 0 {
+			log.Printf("User retention: %s %d deleted accounts", action, len(ids))
+			afterID = ids[len(ids)-1]
+		}
+		if len(ids) < limit {
+			return
+		}
+	}
+}
+
+// RenormalizeBatchSize is how many users RenormalizeEmails loads at a time
+const RenormalizeBatchSize = 500
+
+// RenormalizeReport counts what RenormalizeEmails changed
+type RenormalizeReport struct {
+	Scanned      int
+	Updated      int
+	Placeholders int // Aliases of an older account's mailbox, kept unique like migration 0022 does
+}
+
+// RenormalizeEmails recomputes email_normalized with the configured providers. Migration 0022 backfilled it with
+// the providers config.yml had then, so run it (`server -renormalize-emails`) after changing them.
+// Users are walked oldest first: when two accounts turn out to share a mailbox the older one gets the address.
+func (s *Service) RenormalizeEmails(ctx context.Context) (RenormalizeReport, error) {
+	var report RenormalizeReport
+	var afterCreatedAt time.Time
+	afterID := uuid.Nil
+
+	for {
+		users, err := s.repo.ListEmailsAfter(ctx, afterCreatedAt, afterID, RenormalizeBatchSize)
+		if err != nil || len(users) == 0 {
+			return report, err
+		}
+		afterCreatedAt, afterID = users[len(users)-1].CreatedAt, users[len(users)-1].ID
+		report.Scanned += len(users)
+
+		for _, u := range users {
+			normalized := s.emailNormalizer.Normalize(u.Email)
+			placeholder := emailNormalizedPlaceholder(normalized, u.ID)
+			if u.EmailNormalized == normalized {
+				continue
+			}
+
+			err := s.repo.SetEmailNormalized(ctx, u.ID, normalized)
+			switch {
+			case err == nil:
+				report.Updated++
+			case !errors.Is(err, gorm.ErrDuplicatedKey):
+				return report, err
+			case u.EmailNormalized != placeholder:
+				if err := s.repo.SetEmailNormalized(ctx, u.ID, placeholder); err != nil {
+					return report, err
+				}
+				report.Placeholders++
+			}
+		}
+	}
+}
+
+// emailNormalizedPlaceholder mirrors migration 0022: left(normalized, 218) || '#' || id
+func emailNormalizedPlaceholder(normalized string, id uuid.UUID) string {
+	if runes := []rune(normalized); len(runes) > 218 {
+		normalized = string(runes[:218])
+	}
+	return normalized + "#" + id.String()
 }
@@ -0,0 +1,305 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/database"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	db := testutil.Postgres(t, utils.RealClock{})
+	return NewService(
+		NewRepository(db, 0),
+		database.NewTxManager(db),
+		nil,
+		config.EmailNormalizationConfig{},
+		config.RetentionConfig{},
+		utils.RealClock{},
+	)
+}
+
+func TestFindOrCreateByGoogleConcurrentCallbacks(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	const callbacks = 8
+	ids := make([]uuid.UUID, callbacks)
+	errs := make([]error, callbacks)
+	var wg sync.WaitGroup
+	for i := range callbacks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := service.FindOrCreateByGoogle(ctx, "racer@example.com", "google-racer", "", true)
+			errs[i] = err
+			if err == nil {
+				ids[i] = user.ID
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range callbacks {
+		if errs[i] != nil {
+			t.Fatalf("callback %d failed: %v", i, errs[i])
+		}
+		if ids[i] != ids[0] {
+			t.Fatalf("callback %d got user %s, callback 0 got %s", i, ids[i], ids[0])
+		}
+	}
+}
+
+func TestLinkGoogleAccountRefusesTakeover(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	owner, err := service.FindOrCreateByGoogle(ctx, "owner@example.com", "google-owner", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := service.FindOrCreateByGoogle(ctx, "other@example.com", "google-other", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := service.LinkGoogleAccount(ctx, other.ID, "google-owner", ""); !errors.Is(err, ErrGoogleAccountLinked) {
+		t.Fatalf("err = %v, want ErrGoogleAccountLinked", err)
+	}
+	if err := service.LinkGoogleAccount(ctx, owner.ID, "google-owner", ""); err != nil {
+		t.Fatalf("re-linking the owner's own account: %v", err)
+	}
+}
+
+func TestUpdateSettingsKeepsConcurrentFieldUpdates(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	u, err := service.FindOrCreateByGoogle(ctx, "settings@example.com", "google-settings", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dark, newest, enabled, language := ThemeDark, FeedSortNew, true, "uk"
+	updates := []UpdateSettingsRequest{
+		{Theme: &dark},
+		{DefaultFeedSort: &newest},
+		{ShowNSFW: &enabled},
+		{ContentLanguage: utils.Optional[string]{Set: true, Value: &language}},
+	}
+	var wg sync.WaitGroup
+	for _, req := range updates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.UpdateSettings(ctx, u.ID, req); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	settings, err := service.GetSettings(ctx, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Theme != ThemeDark || settings.DefaultFeedSort != FeedSortNew || !settings.ShowNSFW ||
+		settings.ContentLanguage == nil || *settings.ContentLanguage != "uk" {
+		t.Fatalf("an update was lost: %+v", settings)
+	}
+	if !settings.EmailOnReply || !settings.EmailOnMention {
+		t.Fatalf("untouched fields lost their defaults: %+v", settings)
+	}
+
+	// Returned settings are the stored row, not the defaults the upsert would have inserted
+	light := ThemeLight
+	updated, err := service.UpdateSettings(ctx, u.ID, UpdateSettingsRequest{Theme: &light})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Theme != ThemeLight || updated.DefaultFeedSort != FeedSortNew || !updated.ShowNSFW {
+		t.Fatalf("UpdateSettings returned %+v", updated)
+	}
+}
+
+func TestRenormalizeEmailsAfterProvidersChange(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	db := testutil.Postgres(t, clock)
+	repo := NewRepository(db, 0)
+	ctx := context.Background()
+
+	// Normalized before gmail was configured, so its aliases were still told apart
+	create := func(name, email string) *User {
+		t.Helper()
+		clock.Advance(time.Minute)
+		u := &User{
+			ID:               utils.NewID(),
+			Username:         name,
+			Email:            email,
+			EmailNormalized:  strings.ToLower(email),
+			UsernameSkeleton: utils.Skeleton(name),
+		}
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	older := create("older", "A.B@gmail.com")
+	alias := create("alias", "ab+shop@gmail.com")
+	other := create("other", "a.b+x@example.com")
+
+	service := NewService(
+		repo,
+		database.NewTxManager(db),
+		nil,
+		config.EmailNormalizationConfig{
+			Enabled: true,
+			Providers: []config.EmailProviderConfig{
+				{Domain: "gmail.com", IgnoreDots: true, TagSeparator: "+"},
+			},
+		},
+		config.RetentionConfig{},
+		clock,
+	)
+
+	report, err := service.RenormalizeEmails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (RenormalizeReport{Scanned: 3, Updated: 1, Placeholders: 1}); report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+
+	for u, want := range map[*User]string{
+		older: "ab@gmail.com",
+		alias: "ab@gmail.com#" + alias.ID.String(),
+		other: "a.b+x@example.com",
+	} {
+		stored, err := repo.GetByID(ctx, u.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.EmailNormalized != want {
+			t.Errorf("%s: email_normalized = %q, want %q", u.Username, stored.EmailNormalized, want)
+		}
+	}
+
+	if report, err := service.RenormalizeEmails(ctx); err != nil || report.Updated+report.Placeholders != 0 {
+		t.Errorf("second run = %+v, %v; want nothing left to change", report, err)
+	}
+}
+
+// Hard deletion cascades the memberships away, the counts of those subreddits have to drop with them
+func TestRetentionHardDeleteDecrementsMemberCounts(t *testing.T) {
+	now := time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(now)
+	db := testutil.Postgres(t, clock)
+	repo := NewRepository(db, 0)
+	ctx := context.Background()
+
+	create := func(name string) *User {
+		t.Helper()
+		u := &User{
+			ID:               utils.NewID(),
+			Username:         name,
+			Email:            name + "@example.com",
+			EmailNormalized:  name + "@example.com",
+			UsernameSkeleton: utils.Skeleton(name),
+		}
+		if err := repo.Create(ctx, u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	creator := create("creator")
+	gone := create("gone")
+
+	sql := db.WriteDB()
+	deletedAt := now.AddDate(-2, 0, 0)
+	if err := sql.Exec(
+		"UPDATE users SET deleted_at = ?, scrubbed_at = ? WHERE id = ?", deletedAt, deletedAt, gone.ID,
+	).Error; err != nil {
+		t.Fatal(err)
+	}
+	subredditID := utils.NewID()
+	if err := sql.Exec(
+		"INSERT INTO subreddits (id, name, display_name, creator_id, member_count) VALUES (?, 'kept', 'Kept', ?, 2)",
+		subredditID, creator.ID,
+	).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := sql.Exec(
+		"INSERT INTO subreddit_members (subreddit_id, user_id) VALUES (?, ?), (?, ?)",
+		subredditID, creator.ID, subredditID, gone.ID,
+	).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewService(
+		repo,
+		database.NewTxManager(db),
+		nil,
+		config.EmailNormalizationConfig{},
+		config.RetentionConfig{ScrubAfter: 30 * 24 * time.Hour, HardDeleteAfter: 365 * 24 * time.Hour, BatchSize: 10},
+		clock,
+	)
+	service.applyRetention(ctx)
+
+	var remaining int64
+	if err := sql.Raw("SELECT count(*) FROM users WHERE id = ?", gone.ID).Scan(&remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 0 {
+		t.Fatal("user past the hard delete window is still there")
+	}
+	var memberCount int
+	if err := sql.Raw("SELECT member_count FROM subreddits WHERE id = ?", subredditID).Scan(&memberCount).Error; err != nil {
+		t.Fatal(err)
+	}
+	if memberCount != 1 {
+		t.Errorf("member_count = %d, want 1 after the member was hard-deleted", memberCount)
+	}
+
+	// A second run finds nothing due and leaves the count alone
+	service.applyRetention(ctx)
+	if err := sql.Raw("SELECT member_count FROM subreddits WHERE id = ?", subredditID).Scan(&memberCount).Error; err != nil {
+		t.Fatal(err)
+	}
+	if memberCount != 1 {
+		t.Errorf("member_count after a second run = %d, want 1", memberCount)
+	}
+}
+
+func TestFindOrCreateByGoogleWithoutSignup(t *testing.T) {
+	service := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := service.FindOrCreateByGoogle(ctx, "stranger@gmail.com", "google-stranger", "", false); !errors.Is(err, ErrSignupNotAllowed) {
+		t.Fatalf("new account: err = %v, want ErrSignupNotAllowed", err)
+	}
+	if _, err := service.GetByEmail(ctx, "stranger@gmail.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("refused sign-up stored a user: %v", err)
+	}
+
+	// Accounts that already exist keep signing in, by email on the first Google login and by Google ID after that
+	existing, err := service.CreateUser(ctx, "member@gmail.com", "member", "Sup3r-secret-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		u, err := service.FindOrCreateByGoogle(ctx, "member@gmail.com", "google-member", "", false)
+		if err != nil || u.ID != existing.ID {
+			t.Fatalf("sign-in %d of an existing account = %v, %v; want user %s", i, u, err, existing.ID)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package user
+
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	ErrFeedSortInvalid    = "default feed sort must be one of hot, new, top"
+	ErrThemeInvalid       = "theme must be one of light, dark, system"
+	ErrLanguageNotAllowed = "content language must be one of the supported ISO 639-1 codes"
+)
+
+type Validator struct {
+	languages map[string]struct{}
+}
+
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []ValidationError
+
+func NewValidator(allowedLanguages []string) *Validator {
+	languages := make(map[string]struct{}, len(allowedLanguages))
+	for _, code := range allowedLanguages {
+		languages[strings.ToLower(code)] = struct{}{}
+	}
+
+	return &Validator{
+		languages: languages,
+	}
+}
+
+func NewValidationError(field, message string) ValidationError {
+	return ValidationError{
+		Field:   field,
+		Message: message,
+	}
+}
+
+func (ve ValidationErrors) Error() string {
+	return "validation failed"
+}
+
+// ValidateSettingsInput checks the fields present in a PATCH, values are expected trimmed and lowercased
+func (v *Validator) ValidateSettingsInput(req UpdateSettingsRequest) ValidationErrors {
+	var errs ValidationErrors
+
+	if req.DefaultFeedSort != nil {
+		switch *req.DefaultFeedSort {
+		case FeedSortHot, FeedSortNew, FeedSortTop:
+		default:
+			errs = append(errs, NewValidationError("default_feed_sort", ErrFeedSortInvalid))
+		}
+	}
+
+	if req.Theme != nil {
+		switch *req.Theme {
+		case ThemeLight, ThemeDark, ThemeSystem:
+		default:
+			errs = append(errs, NewValidationError("theme", ErrThemeInvalid))
+		}
+	}
+
+	if err := v.ValidateLanguage(req.ContentLanguage.Value); err != nil {
+		errs = append(errs, NewValidationError("content_language", err.Error()))
+	}
+
+	return errs
+}
+
+func (v *Validator) ValidateLanguage(language *string) error {
+	if language == nil {
+		return nil // Optional field, null means all languages
+	}
+
+	if _, ok := v.languages[*language]; !ok {
+		return errors.New(ErrLanguageNotAllowed)
+	}
+
+	return nil
+}
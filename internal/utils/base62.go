@@ -0,0 +1,33 @@
+package utils
+
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// EncodeBase62 renders n with digits, lower- then uppercase letters; short codes built from it are case-sensitive
+func EncodeBase62(n uint64) string {
+	if n == 0 {
+		return "0"
+	}
+
+	var buf [11]byte // 62^11 > 2^64
+	i := len(buf)
+	for n > 0 {
+		i--
+		buf[i] = base62Alphabet[n%62]
+		n /= 62
+	}
+	return string(buf[i:])
+}
+
+// IsBase62 reports whether s is a non-empty string of base62 digits at most maxLen long
+func IsBase62(s string, maxLen int) bool {
+	if s == "" || len(s) > maxLen {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
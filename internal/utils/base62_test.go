@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEncodeBase62(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{0, "0"},
+		{9, "9"},
+		{10, "a"},
+		{61, "Z"},
+		{62, "10"},
+		{62*62 - 1, "ZZ"},
+		{math.MaxUint64, "lYGhA16ahyf"},
+	}
+
+	for _, tt := range tests {
+		if got := EncodeBase62(tt.n); got != tt.want {
+			t.Errorf("EncodeBase62(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+		if !IsBase62(tt.want, 11) {
+			t.Errorf("IsBase62(%q) = false", tt.want)
+		}
+	}
+}
+
+func TestIsBase62RejectsOtherInput(t *testing.T) {
+	for _, s := range []string{"", "abc-1", "a/b", "ab%2F", "é", "123456789012"} {
+		if IsBase62(s, 11) {
+			t.Errorf("IsBase62(%q) = true", s)
+		}
+	}
+}
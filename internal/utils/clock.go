@@ -0,0 +1,15 @@
+package utils
+
+import "time"
+
+// Clock is the single source of "now" for time-dependent logic (token expiry, TTLs, DB timestamps),
+// so tests can swap in testutil.FakeClock where deterministic time is needed
+type Clock interface {
+	Now() time.Time
+}
+
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
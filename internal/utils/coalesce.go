@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bytes"
+	"expvar"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// coalescers backs the "coalesce" entry on GET /admin/metrics
+var coalescers sync.Map // name -> *Coalescer
+
+func init() {
+	expvar.Publish(
+		"coalesce", expvar.Func(
+			func() any {
+				stats := make(map[string]map[string]any)
+				coalescers.Range(
+					func(name, value any) bool {
+						stats[name.(string)] = value.(*Coalescer).stats()
+						return true
+					},
+				)
+				return stats
+			},
+		),
+	)
+}
+
+// Coalescer collapses concurrent identical anonymous reads into one: the first request runs the handler,
+// requests arriving while it runs get a copy of its response instead of hitting the service themselves.
+// Requests carrying a user_id bypass it, their responses may be personalized.
+type Coalescer struct {
+	name      string
+	group     singleflight.Group
+	requests  atomic.Int64
+	coalesced atomic.Int64
+}
+
+type coalescedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewCoalescer registers the coalescer's counters under name, so it must be unique
+func NewCoalescer(name string) *Coalescer {
+	coalescer := &Coalescer{name: name}
+	coalescers.Store(name, coalescer)
+	return coalescer
+}
+
+// Middleware must run after OptionalJWTAuthMiddleware, requests are told apart by user_id
+func (co *Coalescer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("user_id") != "" {
+			c.Next()
+			return
+		}
+		co.requests.Add(1)
+
+		// Path holds the route params, Encode sorts the query so parameter order doesn't split keys
+		key := c.Request.Method + " " + c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+		led := false
+		value, _, _ := co.group.Do(
+			key, func() (any, error) {
+				led = true
+				recorder := &recordingWriter{ResponseWriter: c.Writer}
+				c.Writer = recorder
+				c.Next()
+				c.Writer = recorder.ResponseWriter
+
+				return &coalescedResponse{
+					status: c.Writer.Status(),
+					header: c.Writer.Header().Clone(),
+					body:   recorder.body.Bytes(),
+				}, nil
+			},
+		)
+		if led {
+			return
+		}
+
+		response, ok := value.(*coalescedResponse)
+		if !ok {
+			c.Next()
+			return
+		}
+		co.coalesced.Add(1)
+		for name, values := range response.header {
+			c.Writer.Header()[name] = values
+		}
+		c.Data(response.status, response.header.Get("Content-Type"), response.body)
+		c.Abort()
+	}
+}
+
+func (co *Coalescer) stats() map[string]any {
+	requests, coalesced := co.requests.Load(), co.coalesced.Load()
+	ratio := 0.0
+	if requests > 0 {
+		ratio = float64(coalesced) / float64(requests)
+	}
+	return map[string]any{
+		"requests":  requests,
+		"coalesced": coalesced,
+		"ratio":     ratio,
+	}
+}
+
+// recordingWriter keeps a copy of the body the leader writes, for the requests coalesced into it
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// coalescedRouter serves GET /subreddits/:id through co. The handler counts its runs and blocks until
+// release is closed, so concurrent requests pile up behind the first. X-User stands in for a signed-in user.
+func coalescedRouter(co *Coalescer, calls *atomic.Int64, release <-chan struct{}) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET(
+		"/subreddits/:id",
+		func(c *gin.Context) {
+			if userID := c.GetHeader("X-User"); userID != "" {
+				c.Set("user_id", userID)
+			}
+		},
+		co.Middleware(),
+		func(c *gin.Context) {
+			calls.Add(1)
+			<-release
+			c.Header("ETag", `"v1"`)
+			c.JSON(http.StatusOK, gin.H{"id": c.Param("id")})
+		},
+	)
+	return router
+}
+
+// serveConcurrently sends all requests at once and releases the handler once every one of them reached co
+func serveConcurrently(
+	t *testing.T,
+	router *gin.Engine,
+	co *Coalescer,
+	release chan struct{},
+	requests []*http.Request,
+) []*httptest.ResponseRecorder {
+	t.Helper()
+
+	before := co.requests.Load()
+	recorders := make([]*httptest.ResponseRecorder, len(requests))
+	var wg sync.WaitGroup
+	for i, req := range requests {
+		recorders[i] = httptest.NewRecorder()
+		wg.Go(func() { router.ServeHTTP(recorders[i], req) })
+	}
+
+	anonymous := 0
+	for _, req := range requests {
+		if req.Header.Get("X-User") == "" {
+			anonymous++
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for co.requests.Load()-before < int64(anonymous) {
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d anonymous requests arrived", co.requests.Load()-before, anonymous)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond) // Counted requests still have to reach the singleflight group
+	close(release)
+	wg.Wait()
+	return recorders
+}
+
+func sameRequests(n int, target string) []*http.Request {
+	requests := make([]*http.Request, n)
+	for i := range requests {
+		requests[i] = httptest.NewRequest(http.MethodGet, target, nil)
+	}
+	return requests
+}
+
+// The handler (the service and DB behind it) runs once however many identical requests arrive together
+func TestCoalescerLoad(t *testing.T) {
+	for _, concurrency := range []int{1, 10, 100, 1000} {
+		co := NewCoalescer(fmt.Sprintf("test_load_%d", concurrency))
+		var calls atomic.Int64
+		release := make(chan struct{})
+		router := coalescedRouter(co, &calls, release)
+
+		recorders := serveConcurrently(t, router, co, release, sameRequests(concurrency, "/subreddits/42?b=2&a=1"))
+
+		if calls.Load() != 1 {
+			t.Errorf("%d concurrent requests ran the handler %d times, want once", concurrency, calls.Load())
+		}
+		for i, rec := range recorders {
+			if etag := rec.Header().Get("ETag"); rec.Code != http.StatusOK || rec.Body.String() != `{"id":"42"}` ||
+				etag != `"v1"` {
+				t.Fatalf("%d concurrent: response %d = %d %s, ETag %q", concurrency, i, rec.Code, rec.Body, etag)
+			}
+		}
+
+		stats := co.stats()
+		wantRatio := float64(concurrency-1) / float64(concurrency)
+		if stats["requests"] != int64(concurrency) || stats["coalesced"] != int64(concurrency-1) ||
+			stats["ratio"] != wantRatio {
+			t.Errorf("%d concurrent: stats %v, want ratio %.3f", concurrency, stats, wantRatio)
+		}
+	}
+}
+
+func TestCoalescerKeys(t *testing.T) {
+	co := NewCoalescer("test_keys")
+	var calls atomic.Int64
+	release := make(chan struct{})
+	router := coalescedRouter(co, &calls, release)
+
+	requests := append(
+		sameRequests(5, "/subreddits/42?a=1&b=2"),
+		// Same parameters in another order share the key, other IDs or values don't
+		httptest.NewRequest(http.MethodGet, "/subreddits/42?b=2&a=1", nil),
+		httptest.NewRequest(http.MethodGet, "/subreddits/43?a=1&b=2", nil),
+		httptest.NewRequest(http.MethodGet, "/subreddits/42?a=2&b=2", nil),
+	)
+	serveConcurrently(t, router, co, release, requests)
+
+	if calls.Load() != 3 {
+		t.Errorf("handler ran %d times for 3 distinct keys", calls.Load())
+	}
+}
+
+// Responses for signed-in users may be personalized, so they are never shared
+func TestCoalescerBypassesSignedInUsers(t *testing.T) {
+	co := NewCoalescer("test_signed_in")
+	var calls atomic.Int64
+	release := make(chan struct{})
+	router := coalescedRouter(co, &calls, release)
+
+	requests := sameRequests(10, "/subreddits/42")
+	for i, req := range requests {
+		req.Header.Set("X-User", fmt.Sprintf("user-%d", i%2))
+	}
+	go func() {
+		// The bypassing requests aren't counted, wait for them to reach the handler instead
+		for calls.Load() < int64(len(requests)) {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+	}()
+
+	var wg sync.WaitGroup
+	for _, req := range requests {
+		wg.Go(func() { router.ServeHTTP(httptest.NewRecorder(), req) })
+	}
+	wg.Wait()
+
+	if calls.Load() != int64(len(requests)) || co.requests.Load() != 0 {
+		t.Errorf(
+			"handler ran %d times for %d signed-in requests, %d counted",
+			calls.Load(), len(requests), co.requests.Load(),
+		)
+	}
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/semaphore"
+)
+
+// concurrencyStats is published under "concurrency" on GET /admin/metrics, keyed "<name>.in_flight|rejected"
+var concurrencyStats = expvar.NewMap("concurrency")
+
+// ConcurrencyLimiter caps the in-flight requests of a route group, protecting the DB connections they share.
+// Unlike rate limiting it doesn't care who is asking, only how many requests are running at once.
+type ConcurrencyLimiter struct {
+	name string
+	sem  *semaphore.Weighted
+	cfg  config.ConcurrencyLimitConfig
+}
+
+// NewConcurrencyLimiter returns nil (no limit) when the group has no capacity configured
+func NewConcurrencyLimiter(name string, cfg config.ConcurrencyLimitConfig) *ConcurrencyLimiter {
+	if cfg.Capacity <= 0 {
+		return nil
+	}
+	return &ConcurrencyLimiter{
+		name: name,
+		sem:  semaphore.NewWeighted(cfg.Capacity),
+		cfg:  cfg,
+	}
+}
+
+// Limit makes each request of the route hold weight units of the group's capacity while it runs.
+// Requests that can't get them within MaxWait are answered with 503 over_capacity and Retry-After.
+func (l *ConcurrencyLimiter) Limit(weight int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l == nil {
+			c.Next()
+			return
+		}
+		weight := min(max(weight, 1), l.cfg.Capacity)
+
+		if !l.acquire(c.Request.Context(), weight) {
+			concurrencyStats.Add(l.name+".rejected", 1)
+			retryAfter := max(int(math.Ceil(l.cfg.RetryAfter.Seconds())), 1)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(
+				http.StatusServiceUnavailable, APIError{
+					Error: "Server is busy, please retry later",
+					Code:  "over_capacity",
+				},
+			)
+			return
+		}
+
+		concurrencyStats.Add(l.name+".in_flight", weight)
+		defer func() {
+			concurrencyStats.Add(l.name+".in_flight", -weight)
+			l.sem.Release(weight)
+		}()
+		c.Next()
+	}
+}
+
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, weight int64) bool {
+	if l.sem.TryAcquire(weight) {
+		return true
+	}
+	if l.cfg.MaxWait <= 0 {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.cfg.MaxWait)
+	defer cancel()
+	return l.sem.Acquire(ctx, weight) == nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+const DefaultQueryTimeout = 3 * time.Second
+
+// WithQueryTimeout bounds a single repository call so one slow query can't eat the whole request budget.
+// An earlier deadline already on ctx (e.g. the request's) still wins.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+type primaryReadsKey struct{}
+
+// WithPrimaryReads makes read-only repository queries made with ctx skip the replica, for requests
+// that must see their own (or their author's recent) writes
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadsKey{}, true)
+}
+
+func PrimaryReads(ctx context.Context) bool {
+	pinned, _ := ctx.Value(primaryReadsKey{}).(bool)
+	return pinned
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithQueryTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		parent  time.Duration // 0 means no deadline on the parent
+		timeout time.Duration
+		want    time.Duration
+	}{
+		{"configured", 0, time.Second, time.Second},
+		{"unset falls back to the default", 0, 0, DefaultQueryTimeout},
+		{"request deadline is sooner", 100 * time.Millisecond, time.Second, 100 * time.Millisecond},
+		{"request deadline is later", time.Minute, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		parent := context.Background()
+		if tt.parent > 0 {
+			var cancel context.CancelFunc
+			parent, cancel = context.WithTimeout(parent, tt.parent)
+			defer cancel()
+		}
+
+		ctx, cancel := WithQueryTimeout(parent, tt.timeout)
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if !ok {
+			t.Errorf("%s: no deadline", tt.name)
+			continue
+		}
+		if remaining := time.Until(deadline); remaining > tt.want || remaining < tt.want-50*time.Millisecond {
+			t.Errorf("%s: deadline in %s, want %s", tt.name, remaining, tt.want)
+		}
+	}
+}
+
+func TestWithQueryTimeoutExpiresBeforeParent(t *testing.T) {
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelParent()
+
+	ctx, cancel := WithQueryTimeout(parent, 10*time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("query ctx err = %v, want DeadlineExceeded", ctx.Err())
+	}
+	if parent.Err() != nil {
+		t.Errorf("parent err = %v, the request must keep its budget", parent.Err())
+	}
+}
@@ -0,0 +1,251 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JSON field naming conventions. DTOs are tagged in snake_case only, camelCase is derived from the tags
+// when the response is written, so there's a single source of truth for field names.
+//
+// Deprecation path for snake_case: it stays the default while clients migrate by opting in to camelCase.
+// Once none rely on snake_case, the default flips to camelCase and snake_case remains available on request
+// for one release, after which the opt-in is removed together with this layer.
+const (
+	ConventionSnake = "snake_case"
+	ConventionCamel = "camelCase"
+
+	// ConventionHeader selects the convention, alternatively `Accept: application/json; profile="camelCase"`
+	ConventionHeader = "X-API-Convention"
+
+	keepJSONKeysKey = "keep_json_keys"
+)
+
+// VerbatimFields are DTO fields holding maps, raw JSON or `any`: the keys inside them are data (flag values,
+// user input) rather than field names, so their whole value is passed through without renaming in either
+// direction. Every such field has to be listed, the DTO golden test in internal/router enforces it.
+var VerbatimFields = map[string]struct{}{
+	"value":   {}, // admin.FlagResponse, admin.SetFlagRequest
+	"default": {}, // admin.FlagResponse
+}
+
+// KeepJSONKeys opts a route out of the camelCase rewrite, for responses that aren't DTOs such as expvar output
+func KeepJSONKeys(c *gin.Context) {
+	c.Set(keepJSONKeysKey, true)
+	c.Next()
+}
+
+// RequestedConvention is ConventionCamel when asked for by header or Accept profile, ConventionSnake otherwise
+func RequestedConvention(r *http.Request) string {
+	if value := r.Header.Get(ConventionHeader); value != "" {
+		return parseConvention(value)
+	}
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(mediaRange))
+		if err == nil && params["profile"] != "" {
+			return parseConvention(params["profile"])
+		}
+	}
+	return ConventionSnake
+}
+
+func parseConvention(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, ConventionCamel) || strings.EqualFold(value, "camel") {
+		return ConventionCamel
+	}
+	return ConventionSnake
+}
+
+// JSONConvention rewrites the object keys of JSON responses to camelCase for clients that asked for it.
+// Other responses (CSV exports, redirects) pass through untouched. Request bodies are accepted in
+// either convention regardless, see BindJSON.
+func JSONConvention() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", ConventionHeader+", Accept")
+		if RequestedConvention(c.Request) != ConventionCamel {
+			c.Next()
+			return
+		}
+
+		c.Header(ConventionHeader, ConventionCamel)
+		writer := &conventionWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		writer.flush(c.GetBool(keepJSONKeysKey))
+	}
+}
+
+// conventionWriter holds JSON bodies back until the handler is done, so their keys can be rewritten as a whole
+type conventionWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *conventionWriter) buffering() bool {
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+func (w *conventionWriter) Write(data []byte) (int, error) {
+	if !w.buffering() {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.body.Write(data)
+}
+
+func (w *conventionWriter) WriteString(s string) (int, error) {
+	if !w.buffering() {
+		return w.ResponseWriter.WriteString(s)
+	}
+	return w.body.WriteString(s)
+}
+
+func (w *conventionWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *conventionWriter) flush(keepKeys bool) {
+	if w.body.Len() == 0 {
+		return
+	}
+
+	body := w.body.Bytes()
+	if !keepKeys {
+		if converted, err := ConvertJSONKeys(body, SnakeToCamel); err == nil {
+			body = converted
+		}
+	}
+	w.Header().Del("Content-Length")
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// ConvertJSONKeys renames every object key in body with convert, keeping key order and values as they are.
+// Values of VerbatimFields are copied unchanged. Consecutive top-level values stay separated, so trailing
+// data is still detected by the caller.
+func ConvertJSONKeys(body []byte, convert func(string) string) ([]byte, error) {
+	type frame struct {
+		object        bool
+		afterKey      bool
+		count         int
+		verbatim      bool // Keys of this container are data, not field names
+		verbatimValue bool // The value after the current key is a VerbatimFields value
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var out bytes.Buffer
+	var stack []*frame
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			out.WriteByte(byte(delim))
+			continue
+		}
+
+		if len(stack) == 0 {
+			if out.Len() > 0 {
+				out.WriteByte('\n')
+			}
+		} else if top := stack[len(stack)-1]; top.object && !top.afterKey {
+			if top.count > 0 {
+				out.WriteByte(',')
+			}
+			name := token.(string)
+			converted := name
+			if !top.verbatim {
+				converted = convert(name)
+			}
+			key, _ := json.Marshal(converted)
+			out.Write(key)
+			out.WriteByte(':')
+			top.afterKey = true
+			top.verbatimValue = top.verbatim || isVerbatimField(name) || isVerbatimField(converted)
+			continue
+		} else {
+			if !top.object && top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.afterKey = false
+			top.count++
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			out.WriteByte(byte(delim))
+			verbatim := false
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				verbatim = parent.verbatim || parent.object && parent.verbatimValue
+			}
+			stack = append(stack, &frame{object: delim == '{', verbatim: verbatim})
+			continue
+		}
+		value, err := json.Marshal(token)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(value)
+	}
+}
+
+// isVerbatimField checks the snake_case name, responses are converted from it and requests to it
+func isVerbatimField(key string) bool {
+	_, ok := VerbatimFields[key]
+	return ok
+}
+
+// SnakeToCamel turns "access_token" into "accessToken"
+func SnakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+
+	var builder strings.Builder
+	upperNext := false
+	for i, r := range key {
+		if r == '_' && i > 0 {
+			upperNext = true
+			continue
+		}
+		if upperNext {
+			r = unicode.ToUpper(r)
+			upperNext = false
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// CamelToSnake turns "accessToken" (and "userID") into "access_token" ("user_id"), snake_case keys are kept
+func CamelToSnake(key string) string {
+	runes := []rune(key)
+	var builder strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) &&
+				runes[i-1] != '_' {
+				builder.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// conventionCases are testdata/convention/<name>.snake.json bodies, as handlers write them. Each has a
+// <name>.camel.json golden of what camelCase clients receive.
+func conventionCases(t *testing.T) []string {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "convention", "*.snake.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no convention cases found (%v)", err)
+	}
+	return paths
+}
+
+// serveWithConvention runs body through JSONConvention as a handler response, headers are added to the request
+func serveWithConvention(t *testing.T, body []byte, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(JSONConvention())
+	router.GET(
+		"/", func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func compactJSON(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := json.Compact(&out, data); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func TestJSONConventionCamelGolden(t *testing.T) {
+	for _, snakePath := range conventionCases(t) {
+		camelPath := strings.TrimSuffix(snakePath, ".snake.json") + ".camel.json"
+		snake, err := os.ReadFile(snakePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, headers := range []map[string]string{
+			{ConventionHeader: ConventionCamel},
+			{"Accept": `application/json; profile="camelCase"`},
+		} {
+			rec := serveWithConvention(t, snake, headers)
+			if got := rec.Header().Get(ConventionHeader); got != ConventionCamel {
+				t.Errorf("%s: %s response header = %q", snakePath, ConventionHeader, got)
+			}
+			got := rec.Body.Bytes()
+
+			if *updateGolden {
+				var pretty bytes.Buffer
+				if err := json.Indent(&pretty, got, "", "  "); err != nil {
+					t.Fatal(err)
+				}
+				pretty.WriteByte('\n')
+				if err := os.WriteFile(camelPath, pretty.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+
+			golden, err := os.ReadFile(camelPath)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if want := compactJSON(t, golden); !bytes.Equal(got, want) {
+				t.Errorf("%s with %v:\n got %s\nwant %s", snakePath, headers, got, want)
+			}
+		}
+	}
+}
+
+func TestJSONConventionSnakeUntouched(t *testing.T) {
+	for _, snakePath := range conventionCases(t) {
+		snake, err := os.ReadFile(snakePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, headers := range []map[string]string{
+			nil,
+			{ConventionHeader: ConventionSnake},
+			{ConventionHeader: "something-else"},
+			{"Accept": "application/json"},
+		} {
+			rec := serveWithConvention(t, snake, headers)
+			if !bytes.Equal(rec.Body.Bytes(), snake) {
+				t.Errorf("%s with %v was rewritten:\n%s", snakePath, headers, rec.Body)
+			}
+			if vary := rec.Header().Get("Vary"); !strings.Contains(vary, ConventionHeader) {
+				t.Errorf("%s: Vary = %q, caches would mix conventions", snakePath, vary)
+			}
+		}
+	}
+}
+
+func TestJSONConventionKeepJSONKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := []byte(`{"cache_hits":{"subreddit_lite":3}}`)
+
+	router := gin.New()
+	router.Use(JSONConvention())
+	router.GET(
+		"/", KeepJSONKeys, func(c *gin.Context) {
+			c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+		},
+	)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ConventionHeader, ConventionCamel)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Errorf("KeepJSONKeys route was rewritten: %s", rec.Body)
+	}
+}
+
+// Request bodies go the other way (see BindJSON): a camelCase golden must map back onto the snake_case keys
+func TestConvertJSONKeysCamelGoldenRoundTrips(t *testing.T) {
+	for _, snakePath := range conventionCases(t) {
+		if filepath.Base(snakePath) == "edge_cases.snake.json" {
+			continue // Holds deliberately lossy keys (leading, trailing and double underscores)
+		}
+		camelPath := strings.TrimSuffix(snakePath, ".snake.json") + ".camel.json"
+		snake, err := os.ReadFile(snakePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		camel, err := os.ReadFile(camelPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ConvertJSONKeys(camel, CamelToSnake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := ConvertJSONKeys(snake, func(key string) string { return key })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s doesn't map back:\n got %s\nwant %s", camelPath, got, want)
+		}
+	}
+}
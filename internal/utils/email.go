@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+// EmailNormalizer maps the aliases a mailbox provider delivers to the same inbox onto one address,
+// e.g. "A.B+news@googlemail.com" onto "ab@gmail.com". Only configured providers get more than lowercasing,
+// other domains may treat dots and tags as significant.
+type EmailNormalizer struct {
+	enabled   bool
+	providers map[string]config.EmailProviderConfig // By domain, aliases included
+}
+
+func NewEmailNormalizer(cfg config.EmailNormalizationConfig) *EmailNormalizer {
+	providers := make(map[string]config.EmailProviderConfig)
+	for _, provider := range cfg.Providers {
+		provider.Domain = strings.ToLower(strings.TrimSpace(provider.Domain))
+		for _, domain := range append([]string{provider.Domain}, provider.Aliases...) {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				providers[domain] = provider
+			}
+		}
+	}
+	return &EmailNormalizer{enabled: cfg.Enabled, providers: providers}
+}
+
+// Normalize returns the address duplicates are detected by. It's never sent to, the original stays for delivery.
+func (n *EmailNormalizer) Normalize(email string) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if !n.enabled || at < 0 {
+		return email
+	}
+
+	local, domain := email[:at], email[at+1:]
+	provider, ok := n.providers[domain]
+	if !ok {
+		return email
+	}
+	if provider.TagSeparator != "" {
+		local, _, _ = strings.Cut(local, provider.TagSeparator)
+	}
+	if provider.IgnoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local + "@" + provider.Domain
+}
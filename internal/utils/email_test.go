@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+)
+
+func testEmailNormalizer(enabled bool) *EmailNormalizer {
+	return NewEmailNormalizer(
+		config.EmailNormalizationConfig{
+			Enabled: enabled,
+			Providers: []config.EmailProviderConfig{
+				{Domain: "Gmail.com", Aliases: []string{" googlemail.com "}, IgnoreDots: true, TagSeparator: "+"},
+				{Domain: "outlook.com", TagSeparator: "+"},
+				{Domain: "yahoo.com", TagSeparator: "-"},
+			},
+		},
+	)
+}
+
+func TestEmailNormalizerGmail(t *testing.T) {
+	normalizer := testEmailNormalizer(true)
+
+	for _, email := range []string{
+		"ab@gmail.com",
+		"a.b@gmail.com",
+		"A.B+news@gmail.com",
+		"a.b+news+more@gmail.com",
+		" a..b@GMAIL.COM ",
+		"ab@googlemail.com",
+		"a.b+tag@GoogleMail.com",
+	} {
+		if got := normalizer.Normalize(email); got != "ab@gmail.com" {
+			t.Errorf("Normalize(%q) = %q, want ab@gmail.com", email, got)
+		}
+	}
+
+	if got := normalizer.Normalize("ab.c@gmail.com"); got == "ab@gmail.com" {
+		t.Errorf("Normalize(ab.c@gmail.com) = %q, a different mailbox must not collide", got)
+	}
+}
+
+func TestEmailNormalizerNonGmail(t *testing.T) {
+	normalizer := testEmailNormalizer(true)
+
+	tests := []struct {
+		email, want string
+	}{
+		// Configured without ignore_dots: tags go, dots are significant
+		{"A.B+news@Outlook.com", "a.b@outlook.com"},
+		{"a.b@outlook.com", "a.b@outlook.com"},
+		{"a.b-news@yahoo.com", "a.b@yahoo.com"},
+		{"a.b+news@yahoo.com", "a.b+news@yahoo.com"}, // Only the provider's own separator counts
+		// Unknown domains may treat dots and tags as distinct mailboxes, they're only lowercased
+		{"A.B+news@Example.com", "a.b+news@example.com"},
+		{"a.b@gmail.com.example.com", "a.b@gmail.com.example.com"},
+		// The last @ splits, like net/mail does for quoted local parts
+		{`"a@b"+x@gmail.com`, `"a@b"@gmail.com`},
+		{"no-at-sign", "no-at-sign"},
+	}
+	for _, tt := range tests {
+		if got := normalizer.Normalize(tt.email); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
+
+func TestEmailNormalizerDisabled(t *testing.T) {
+	normalizer := testEmailNormalizer(false)
+
+	if got := normalizer.Normalize(" A.B+news@GoogleMail.com "); got != "a.b+news@googlemail.com" {
+		t.Errorf("disabled Normalize = %q, want only trimming and lowercasing", got)
+	}
+}
@@ -0,0 +1,11 @@
+package utils
+
+import "github.com/google/uuid"
+
+// NewID generates primary keys as UUIDv7: the timestamp prefix keeps inserts at the right edge of
+// B-tree indexes instead of scattering them like v4. IDs stay monotonic within a process.
+// Reads accept any UUID version, so existing v4 rows are unaffected.
+// v7 IDs leak their creation time and are guessable, never use them as secrets or unguessable tokens.
+func NewID() uuid.UUID {
+	return uuid.Must(uuid.NewV7())
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestNewIDIsMonotonic(t *testing.T) {
+	previous := NewID()
+	for range 100_000 {
+		id := NewID()
+		if bytes.Compare(id[:], previous[:]) <= 0 {
+			t.Fatalf("NewID() = %s after %s, want strictly increasing", id, previous)
+		}
+		previous = id
+	}
+}
+
+// Concurrent callers still get distinct IDs, each goroutine sees its own IDs increase
+func TestNewIDConcurrentUnique(t *testing.T) {
+	const goroutines, perGoroutine = 8, 5_000
+	results := make([][]uuid.UUID, goroutines)
+	var wg sync.WaitGroup
+	for g := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perGoroutine {
+				results[g] = append(results[g], NewID())
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[uuid.UUID]bool, goroutines*perGoroutine)
+	for g, ids := range results {
+		for i, id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate ID %s", id)
+			}
+			seen[id] = true
+			if i > 0 && bytes.Compare(id[:], ids[i-1][:]) <= 0 {
+				t.Errorf("goroutine %d: %s after %s, want increasing", g, id, ids[i-1])
+			}
+		}
+	}
+}
+
+func TestNewIDVersionAndTime(t *testing.T) {
+	id := NewID()
+
+	if id.Version() != 7 || id.Variant() != uuid.RFC4122 {
+		t.Errorf("NewID() = %s, version %d variant %s; want an RFC 4122 v7", id, id.Version(), id.Variant())
+	}
+	sec, nsec := id.Time().UnixTime()
+	// Bursts of more than 4096 IDs per millisecond borrow from the next one, so only roughly now
+	if drift := time.Since(time.Unix(sec, nsec)).Abs(); drift > time.Second {
+		t.Errorf("NewID() embeds a time %s away from now", drift)
+	}
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrJSONTooDeep      = errors.New("JSON payload is nested too deeply")
+	ErrJSONTrailingData = errors.New("request body must contain a single JSON value")
+)
+
+const (
+	DefaultMaxBodyBytes = 1 << 20
+	DefaultMaxJSONDepth = 32
+
+	disallowUnknownFieldsKey = "json_disallow_unknown_fields"
+)
+
+// JSONGuardMiddleware bounds request body size and JSON nesting depth, rejecting pathological payloads
+// with 4xx before any handler decodes them. The body is buffered once and handed on unchanged.
+// Only JSON bodies are guarded (see isJSONContentType), uploads and other media types pass through untouched.
+func JSONGuardMiddleware(cfgServer *config.ServerConfig) gin.HandlerFunc {
+	maxBodyBytes := cfgServer.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = DefaultMaxBodyBytes
+	}
+	maxDepth := cfgServer.MaxJSONDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxJSONDepth
+	}
+
+	return func(c *gin.Context) {
+		c.Set(disallowUnknownFieldsKey, cfgServer.DisallowUnknownJSONFields)
+
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || !isJSONContentType(c.ContentType()) {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				c.AbortWithStatusJSON(
+					http.StatusRequestEntityTooLarge, APIError{
+						Error: fmt.Sprintf("Request body must be at most %d bytes", maxBodyBytes),
+						Code:  "payload_too_large",
+					},
+				)
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusBadRequest, APIError{Error: "Failed to read request body"})
+			return
+		}
+
+		if len(bytes.TrimSpace(body)) > 0 {
+			if err := checkJSONDepth(body, maxDepth); err != nil {
+				code := "invalid_json"
+				if errors.Is(err, ErrJSONTooDeep) {
+					code = "payload_too_deep"
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, APIError{Error: err.Error(), Code: code})
+				return
+			}
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
+
+// isJSONContentType accepts application/json, +json types like application/merge-patch+json, and a missing
+// Content-Type, which BindJSON decodes as JSON all the same
+func isJSONContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return contentType == "" || contentType == gin.MIMEJSON || strings.HasSuffix(contentType, "+json")
+}
+
+// checkJSONDepth walks the token stream without building values, so nesting is measured before any allocation-heavy decode
+func checkJSONDepth(body []byte, maxDepth int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Recovered from panic while scanning JSON:", r)
+			err = errors.New("malformed JSON payload")
+		}
+	}()
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("malformed JSON payload: %w", err)
+		}
+
+		delim, ok := token.(json.Delim)
+		if !ok {
+			continue
+		}
+		switch delim {
+		case '{', '[':
+			depth++
+			if depth > maxDepth {
+				return ErrJSONTooDeep
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+}
+
+// BindJSON decodes the request body into dst, rejecting unknown fields when the guard middleware is
+// configured to, and refusing bodies with more than one JSON value.
+// Keys may be camelCase as well, they're mapped onto the snake_case tags first (see JSONConvention).
+func BindJSON(c *gin.Context, dst any) error {
+	if c.Request.Body == nil {
+		return io.EOF
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	body, err = ConvertJSONKeys(body, CamelToSnake)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if c.GetBool(disallowUnknownFieldsKey) {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(dst); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return ErrJSONTrailingData
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestJSONGuardMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(JSONGuardMiddleware(&config.ServerConfig{MaxBodyBytes: 64, MaxJSONDepth: 3}))
+	router.POST("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	deep := strings.Repeat("[", 4) + strings.Repeat("]", 4)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"shallow JSON", "application/json", `{"a":[1]}`, http.StatusNoContent},
+		{"deep JSON", "application/json", deep, http.StatusBadRequest},
+		{"deep JSON with charset", "application/json; charset=utf-8", deep, http.StatusBadRequest},
+		{"deep +json", "application/merge-patch+json", deep, http.StatusBadRequest},
+		{"deep JSON without type", "", deep, http.StatusBadRequest},
+		{"large JSON", "application/json", `"` + strings.Repeat("x", 100) + `"`, http.StatusRequestEntityTooLarge},
+		{"multipart", "multipart/form-data; boundary=x", deep + strings.Repeat("x", 100), http.StatusNoContent},
+		{"plain text", "text/plain", deep, http.StatusNoContent},
+		{"image", "image/png", "\x89PNG" + deep, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+				if tt.contentType != "" {
+					request.Header.Set("Content-Type", tt.contentType)
+				}
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, request)
+				if recorder.Code != tt.want {
+					t.Errorf("status = %d, want %d (%s)", recorder.Code, tt.want, recorder.Body)
+				}
+			},
+		)
+	}
+}
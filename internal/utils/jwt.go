@@ -20,26 +20,52 @@ const (
 	TokenTypeAccess             = "access"
 	TokenTypeRefresh            = "refresh"
 	RefreshTokenBlacklistPrefix = "refresh_token_blacklist:"
+	ClaimRememberMe             = "remember"
 )
 
 type TokenPair struct {
 	AccessToken  string
 	RefreshToken string
+	RememberMe   bool // Whether cookies should outlive the browser session
 }
 
-func GenerateJWT(jwtSecret string, tokenType string, tokenLifetime time.Duration, userID string) (string, error) {
+func GenerateJWT(
+	clock Clock,
+	jwtSecret string,
+	tokenType string,
+	tokenLifetime time.Duration,
+	userID string,
+) (string, error) {
+	return GenerateJWTWithClaims(clock, jwtSecret, tokenType, tokenLifetime, userID, nil)
+}
+
+// GenerateJWTWithClaims is GenerateJWT with extra claims; registered ones (sub, iat, exp, type) can't be overridden
+func GenerateJWTWithClaims(
+	clock Clock,
+	jwtSecret string,
+	tokenType string,
+	tokenLifetime time.Duration,
+	userID string,
+	extraClaims jwt.MapClaims,
+) (string, error) {
 	if tokenType != TokenTypeAccess && tokenType != TokenTypeRefresh {
 		return "", ErrInvalidTokenType
 	}
 
-	tokenExpiry := time.Now().Add(tokenLifetime)
+	issuedAt := clock.Now()
+	tokenExpiry := issuedAt.Add(tokenLifetime)
 
 	// TODO: Using default algorithm, can be changed later
-	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":  userID,
-		"exp":  tokenExpiry.Unix(),
-		"type": tokenType,
-	})
+	claims := jwt.MapClaims{}
+	for key, value := range extraClaims {
+		claims[key] = value
+	}
+	claims["sub"] = userID
+	claims["iat"] = issuedAt.Unix()
+	claims["exp"] = tokenExpiry.Unix()
+	claims["type"] = tokenType
+
+	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	token, err := tokenObj.SignedString([]byte(jwtSecret))
 	if err != nil {
 		return "", errors.New(fmt.Sprintln("failed to generate JWT token:", err))
@@ -47,7 +73,13 @@ func GenerateJWT(jwtSecret string, tokenType string, tokenLifetime time.Duration
 	return token, nil
 }
 
-func DecryptJWT(tokenString string, jwtSecret string, expectedTokenType string) (string, jwt.MapClaims, error) {
+// DecryptJWT validates exp against the given clock rather than the wall clock
+func DecryptJWT(
+	clock Clock,
+	tokenString string,
+	jwtSecret string,
+	expectedTokenType string,
+) (string, jwt.MapClaims, error) {
 	if tokenString == "" {
 		return "", nil, ErrInvalidToken
 	}
@@ -57,7 +89,7 @@ func DecryptJWT(tokenString string, jwtSecret string, expectedTokenType string)
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(jwtSecret), nil
-	})
+	}, jwt.WithTimeFunc(clock.Now))
 
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
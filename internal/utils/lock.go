@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Refreshing and releasing only touch the lock while owner still holds it, so a holder whose lock expired
+// can't extend or drop the lock someone else took over since
+var (
+	refreshLockScript = redis.NewScript(
+		`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) end return 0`,
+	)
+	releaseLockScript = redis.NewScript(
+		`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) end return 0`,
+	)
+)
+
+// RedisLock is a lease on key shared by every instance. It expires after ttl unless refreshed, so a crashed
+// holder can't keep it forever; long-running holders refresh it between units of work.
+type RedisLock struct {
+	redis *redis.Client
+	key   string
+	owner string
+	ttl   time.Duration
+}
+
+// TryLock takes the lock for owner, returning nil without an error when someone else holds it
+func TryLock(ctx context.Context, redisClient *redis.Client, key, owner string, ttl time.Duration) (*RedisLock, error) {
+	acquired, err := redisClient.SetNX(ctx, key, owner, ttl).Result()
+	if err != nil || !acquired {
+		return nil, err
+	}
+	return &RedisLock{
+		redis: redisClient,
+		key:   key,
+		owner: owner,
+		ttl:   ttl,
+	}, nil
+}
+
+// Refresh extends the lease, false means it was lost and the work it guards should stop
+func (l *RedisLock) Refresh(ctx context.Context) (bool, error) {
+	refreshed, err := refreshLockScript.Run(ctx, l.redis, []string{l.key}, l.owner, l.ttl.Milliseconds()).Int()
+	return refreshed == 1, err
+}
+
+func (l *RedisLock) Release(ctx context.Context) error {
+	return releaseLockScript.Run(ctx, l.redis, []string{l.key}, l.owner).Err()
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded, process-local cache whose entries also expire after a TTL.
+// Safe for concurrent use.
+type LRU[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	clock    Clock
+	items    map[K]*list.Element
+	order    *list.List // Front is the most recently used entry
+}
+
+type lruEntry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration, clock Clock) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		clock:    clock,
+		items:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	entry := element.Value.(*lruEntry[K, V])
+	if !c.clock.Now().Before(entry.expiresAt) {
+		c.removeElement(element)
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.clock.Now().Add(c.ttl)
+	if element, ok := c.items[key]; ok {
+		entry := element.Value.(*lruEntry[K, V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(
+		&lruEntry[K, V]{
+			key:       key,
+			value:     value,
+			expiresAt: expiresAt,
+		},
+	)
+	if c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *LRU[K, V]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*lruEntry[K, V]).key)
+}
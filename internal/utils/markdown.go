@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownLinkRegex   = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownBoldRegex   = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	markdownItalicRegex = regexp.MustCompile(`\*([^*]+)\*`)
+)
+
+// RenderMarkdown converts a small markdown subset - headings, paragraphs, "-"/"*" lists, fenced code blocks,
+// **bold**, *italic*, `code` and [links](url) - to HTML. The source is HTML-escaped before any markup is added,
+// so raw HTML in user content always renders as text, and links keep only http(s) and relative URLs.
+func RenderMarkdown(src string) string {
+	var out, paragraph strings.Builder
+	inList, inCode := false, false
+
+	flushParagraph := func() {
+		if paragraph.Len() > 0 {
+			out.WriteString("<p>" + renderInline(paragraph.String()) + "</p>\n")
+			paragraph.Reset()
+		}
+	}
+	closeList := func() {
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if inCode {
+			if strings.HasPrefix(trimmed, "```") {
+				out.WriteString("</code></pre>\n")
+				inCode = false
+				continue
+			}
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "```"):
+			flushParagraph()
+			closeList()
+			out.WriteString("<pre><code>")
+			inCode = true
+		case trimmed == "":
+			flushParagraph()
+			closeList()
+		case headingLevel(trimmed) > 0:
+			flushParagraph()
+			closeList()
+			level := headingLevel(trimmed)
+			tag := "h" + string(rune('0'+level))
+			out.WriteString("<" + tag + ">" + renderInline(strings.TrimSpace(trimmed[level:])) + "</" + tag + ">\n")
+		case strings.HasPrefix(trimmed, "- ") || strings.HasPrefix(trimmed, "* "):
+			flushParagraph()
+			if !inList {
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			out.WriteString("<li>" + renderInline(strings.TrimSpace(trimmed[2:])) + "</li>\n")
+		default:
+			closeList()
+			if paragraph.Len() > 0 {
+				paragraph.WriteString(" ")
+			}
+			paragraph.WriteString(trimmed)
+		}
+	}
+
+	if inCode {
+		out.WriteString("</code></pre>\n") // Unterminated fence runs to the end
+	}
+	flushParagraph()
+	closeList()
+
+	return out.String()
+}
+
+// headingLevel returns 1-6 for "# title" .. "###### title", 0 otherwise
+func headingLevel(line string) int {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(line) || line[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// renderInline escapes text and applies inline markup; backtick spans are left as literal code
+func renderInline(text string) string {
+	segments := strings.Split(text, "`")
+
+	var b strings.Builder
+	for i, segment := range segments {
+		escaped := html.EscapeString(segment)
+		if i%2 == 1 && i < len(segments)-1 {
+			b.WriteString("<code>" + escaped + "</code>")
+			continue
+		}
+		if i%2 == 1 {
+			b.WriteString("`") // Unmatched backtick
+		}
+
+		escaped = markdownLinkRegex.ReplaceAllStringFunc(escaped, renderLink)
+		escaped = markdownBoldRegex.ReplaceAllString(escaped, "<strong>$1</strong>")
+		escaped = markdownItalicRegex.ReplaceAllString(escaped, "<em>$1</em>")
+		b.WriteString(escaped)
+	}
+
+	return b.String()
+}
+
+// renderLink gets an already escaped [label](url) match; unsafe targets render as the bare label
+func renderLink(match string) string {
+	parts := markdownLinkRegex.FindStringSubmatch(match)
+	label, target := parts[1], html.UnescapeString(parts[2])
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return label
+	}
+	switch {
+	case parsed.Scheme == "http" || parsed.Scheme == "https":
+	case parsed.Scheme == "" && parsed.Host == "" && strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//"):
+	default:
+		return label
+	}
+
+	// "*" is encoded so the emphasis passes can't reach into the attribute
+	href := html.EscapeString(strings.ReplaceAll(target, "*", "%2A"))
+	return `<a href="` + href + `" rel="nofollow noopener">` + label + "</a>"
+}
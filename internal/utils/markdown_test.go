@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one two</p>\n<p>three</p>\n"},
+		{"heading", "## Rules", "<h2>Rules</h2>\n"},
+		{"not a heading", "#hashtag", "<p>#hashtag</p>\n"},
+		{"list", "- **be** kind\n* no *spam*", "<ul>\n<li><strong>be</strong> kind</li>\n<li>no <em>spam</em></li>\n</ul>\n"},
+		{"inline code keeps markup literal", "run `**go** test`", "<p>run <code>**go** test</code></p>\n"},
+		{"fenced code", "```\n<b>x</b>\n```", "<pre><code>&lt;b&gt;x&lt;/b&gt;\n</code></pre>\n"},
+		{"unterminated fence", "```\ncode", "<pre><code>code\n</code></pre>\n"},
+		{
+			"https link", "[docs](https://go.dev/doc)",
+			`<p><a href="https://go.dev/doc" rel="nofollow noopener">docs</a></p>` + "\n",
+		},
+		{"relative link", "[faq](/wiki/faq)", `<p><a href="/wiki/faq" rel="nofollow noopener">faq</a></p>` + "\n"},
+	}
+	for _, tt := range tests {
+		if got := RenderMarkdown(tt.src); got != tt.want {
+			t.Errorf("%s: RenderMarkdown(%q) = %q, want %q", tt.name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMarkdownSanitizes(t *testing.T) {
+	tests := []struct {
+		name, src string
+		want      string // Must appear in the output
+	}{
+		{"script tag", "<script>alert(1)</script>", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+		{"event handler", `<img src=x onerror="alert(1)">`, "&lt;img src=x onerror=&#34;alert(1)&#34;&gt;"},
+		{"javascript link", "[click](javascript:alert(1))", "click"},
+		{"data link", "[click](data:text/html;base64,PHNjcmlwdD4=)", "click"},
+		{"protocol-relative link", "[click](//evil.example)", "click"},
+		{"quote breaking out of href", `[x](https://a.example/"onmouseover="alert(1))`, `href="https://a.example/&#34;onmouseover=&#34;alert(1"`},
+		{"emphasis inside href", "[x](https://a.example/*b*)", `href="https://a.example/%2Ab%2A"`},
+		{"html in heading", "# <iframe>", "<h1>&lt;iframe&gt;</h1>"},
+		{"html in code span", "`<b>`", "<code>&lt;b&gt;</code>"},
+	}
+	for _, tt := range tests {
+		got := RenderMarkdown(tt.src)
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: RenderMarkdown(%q) = %q, want it to contain %q", tt.name, tt.src, got, tt.want)
+		}
+		for _, unsafe := range []string{"<script", "<img", "<iframe", "javascript:", "data:", "//evil", `" onmouseover`, `"onmouseover`} {
+			if strings.Contains(got, unsafe) {
+				t.Errorf("%s: RenderMarkdown(%q) = %q contains %q", tt.name, tt.src, got, unsafe)
+			}
+		}
+	}
+}
@@ -11,20 +11,24 @@ import (
 	"github.com/google/uuid"
 )
 
-func JWTAuthMiddleware(cfgJWT *config.JWTConfig) gin.HandlerFunc {
+// accessTokenFromRequest reads the access token from its cookie or else from an "Authorization: Bearer" header
+func accessTokenFromRequest(c *gin.Context, cfgJWT *config.JWTConfig) (string, bool) {
+	if tokenString, err := c.Cookie(cfgJWT.AccessTokenCookieKey); err == nil {
+		return tokenString, true
+	}
+	tokenString, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return tokenString, ok && tokenString != ""
+}
+
+func JWTAuthMiddleware(cfgJWT *config.JWTConfig, clock Clock) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString, err := c.Cookie(cfgJWT.AccessTokenCookieKey)
-		if err != nil {
-			authHeader := c.GetHeader("Authorization")
-			if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-				tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-			} else {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization token provided"})
-				c.Abort()
-				return
-			}
+		tokenString, ok := accessTokenFromRequest(c, cfgJWT)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "No authorization token provided"})
+			c.Abort()
+			return
 		}
-		userID, _, err := DecryptJWT(tokenString, cfgJWT.Secret, TokenTypeAccess)
+		userID, _, err := DecryptJWT(clock, tokenString, cfgJWT.Secret, TokenTypeAccess)
 		if err != nil {
 			if errors.Is(err, ErrExpiredToken) {
 				c.JSON(
@@ -51,6 +55,23 @@ func JWTAuthMiddleware(cfgJWT *config.JWTConfig) gin.HandlerFunc {
 	}
 }
 
+// OptionalJWTAuthMiddleware sets user_id for requests carrying a valid access token and lets anonymous ones through,
+// for public endpoints whose response depends on who is asking
+func OptionalJWTAuthMiddleware(cfgJWT *config.JWTConfig, clock Clock) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, ok := accessTokenFromRequest(c, cfgJWT)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if userID, _, err := DecryptJWT(clock, tokenString, cfgJWT.Secret, TokenTypeAccess); err == nil {
+			c.Set("user_id", userID)
+		}
+		c.Next()
+	}
+}
+
 func CORS(cfgCors *config.CorsConfig) gin.HandlerFunc {
 	allowedOriginsSet := make(map[string]struct{}, len(cfgCors.AllowedOrigins))
 	for _, origin := range cfgCors.AllowedOrigins {
@@ -87,8 +108,12 @@ func CORS(cfgCors *config.CorsConfig) gin.HandlerFunc {
 		) // HTTP methods allowed in cross-origin requests
 		c.Header(
 			"Access-Control-Allow-Headers",
-			"Authorization,Content-Type",
+			"Authorization,Content-Type,"+ConventionHeader,
 		) // Request headers allowed in cross-origin requests
+		c.Header(
+			"Access-Control-Expose-Headers",
+			"Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining,X-RateLimit-Reset",
+		) // Response headers scripts may read, for client-side throttling
 
 		if strings.EqualFold(c.Request.Method, http.MethodOptions) {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -99,6 +124,22 @@ func CORS(cfgCors *config.CorsConfig) gin.HandlerFunc {
 	}
 }
 
+const (
+	// CachePolicyNoStore is the default for API JSON, responses may be personalized and must not land in shared caches
+	CachePolicyNoStore = "no-store"
+	// CachePolicyImmutable is for content-addressed objects, whose URL changes whenever the content does
+	CachePolicyImmutable = "public, max-age=31536000, immutable"
+)
+
+// CacheControl declares a route group's Cache-Control policy once. Registered later in the chain
+// (e.g. on a group over the router-wide default) it wins, since it overwrites the header.
+func CacheControl(policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", policy)
+		c.Next()
+	}
+}
+
 // GetUserIDFromContext extracts and parses user ID from gin context
 // Returns the user ID or an error response is sent and false is returned
 func GetUserIDFromContext(c *gin.Context) (uuid.UUID, bool) {
@@ -0,0 +1,52 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/gin-gonic/gin"
+)
+
+func TestJWTMiddlewaresUseInjectedClock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.JWTConfig{Secret: "test-secret", AccessTokenCookieKey: "access_token"}
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	token, err := utils.GenerateJWT(clock, cfg.Secret, utils.TokenTypeAccess, time.Minute, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	whoAmI := func(c *gin.Context) { c.String(http.StatusOK, c.GetString("user_id")) }
+	router.GET("/required", utils.JWTAuthMiddleware(cfg, clock), whoAmI)
+	router.GET("/optional", utils.OptionalJWTAuthMiddleware(cfg, clock), whoAmI)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// The token was issued at the fake time, long expired by the wall clock
+	for _, path := range []string{"/required", "/optional"} {
+		if got := get(path); got.Code != http.StatusOK || got.Body.String() != "user-1" {
+			t.Errorf("%s before expiry = %d %q, want 200 user-1", path, got.Code, got.Body)
+		}
+	}
+
+	clock.Advance(2 * time.Minute)
+	if got := get("/required"); got.Code != http.StatusUnauthorized {
+		t.Errorf("/required after expiry = %d, want 401", got.Code)
+	}
+	if got := get("/optional"); got.Code != http.StatusOK || got.Body.String() != "" {
+		t.Errorf("/optional after expiry = %d %q, want an anonymous 200", got.Code, got.Body)
+	}
+}
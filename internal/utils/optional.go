@@ -0,0 +1,45 @@
+package utils
+
+import "encoding/json"
+
+// Optional distinguishes an omitted JSON field from an explicit null in PATCH payloads.
+// Set is true whenever the key was present, Value is nil when it was sent as null.
+type Optional[T any] struct {
+	Value *T
+	Set   bool
+}
+
+// UnmarshalJSON is only invoked by encoding/json when the key is present, including for `null`
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+
+	if string(data) == "null" {
+		o.Value = nil
+		return nil
+	}
+
+	var value T
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	o.Value = &value
+	return nil
+}
+
+// MarshalJSON writes null or the value; tag the field `omitzero` to leave unset ones out, see IsZero
+func (o Optional[T]) MarshalJSON() ([]byte, error) {
+	if o.Value == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(*o.Value)
+}
+
+// IsZero reports an omitted field, so `omitzero` round-trips absence
+func (o Optional[T]) IsZero() bool {
+	return !o.Set
+}
+
+// IsNull reports whether the field was explicitly sent as null (clear the column)
+func (o Optional[T]) IsNull() bool {
+	return o.Set && o.Value == nil
+}
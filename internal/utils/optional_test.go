@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type optionalPayload struct {
+	Description Optional[string] `json:"description,omitzero"`
+	Limit       Optional[int]    `json:"limit,omitzero"`
+}
+
+func TestOptionalRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantSet   bool
+		wantNull  bool
+		wantValue string
+	}{
+		{name: "absent", body: `{}`},
+		{name: "present null", body: `{"description":null}`, wantSet: true, wantNull: true},
+		{name: "present value", body: `{"description":"hello"}`, wantSet: true, wantValue: "hello"},
+		{name: "present empty", body: `{"description":""}`, wantSet: true, wantValue: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var payload optionalPayload
+				if err := json.Unmarshal([]byte(tt.body), &payload); err != nil {
+					t.Fatalf("unmarshal: %v", err)
+				}
+
+				got := payload.Description
+				if got.Set != tt.wantSet {
+					t.Errorf("Set = %v, want %v", got.Set, tt.wantSet)
+				}
+				if got.IsNull() != tt.wantNull {
+					t.Errorf("IsNull() = %v, want %v", got.IsNull(), tt.wantNull)
+				}
+				if tt.wantSet && !tt.wantNull && (got.Value == nil || *got.Value != tt.wantValue) {
+					t.Errorf("Value = %v, want %q", got.Value, tt.wantValue)
+				}
+
+				encoded, err := json.Marshal(payload)
+				if err != nil {
+					t.Fatalf("marshal: %v", err)
+				}
+				if string(encoded) != tt.body {
+					t.Errorf("round trip = %s, want %s", encoded, tt.body)
+				}
+			},
+		)
+	}
+}
+
+func TestOptionalRejectsWrongType(t *testing.T) {
+	var payload optionalPayload
+	if err := json.Unmarshal([]byte(`{"limit":"ten"}`), &payload); err == nil {
+		t.Fatal("expected an error for a string in an Optional[int]")
+	}
+}
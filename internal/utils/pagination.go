@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrInvalidPagination = errors.New("page and page_size must be positive integers")
+	ErrPageTooDeep       = errors.New("page is too deep for offset pagination, use cursor pagination instead")
+)
+
+const (
+	DefaultPage     = 1
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+	// MaxOffset bounds how many rows Postgres may scan and discard for a single page
+	MaxOffset = 10_000
+)
+
+type Pagination struct {
+	Page     int
+	PageSize int
+}
+
+// PaginationMeta is attached to list responses so clients can render pagers without a second request
+type PaginationMeta struct {
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+	Total    int64 `json:"total"`
+}
+
+// ParsePagination reads raw `page`/`page_size` query values, applying defaults and clamping page_size
+func ParsePagination(pageStr, pageSizeStr string) (Pagination, error) {
+	pagination := Pagination{Page: DefaultPage, PageSize: DefaultPageSize}
+
+	if pageStr != "" {
+		page, err := strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			return Pagination{}, ErrInvalidPagination
+		}
+		pagination.Page = page
+	}
+
+	if pageSizeStr != "" {
+		pageSize, err := strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 {
+			return Pagination{}, ErrInvalidPagination
+		}
+		pagination.PageSize = min(pageSize, MaxPageSize)
+	}
+
+	// Same as Offset() > MaxOffset, but without the multiplication a huge page would overflow
+	if pagination.Page > MaxOffset/pagination.PageSize+1 {
+		return Pagination{}, ErrPageTooDeep
+	}
+
+	return pagination, nil
+}
+
+// PaginationFromQuery parses `page`/`page_size` and writes the 400 response itself on failure,
+// so handlers only need to return when ok is false
+func PaginationFromQuery(c *gin.Context) (Pagination, bool) {
+	pagination, err := ParsePagination(c.Query("page"), c.Query("page_size"))
+	if err == nil {
+		return pagination, true
+	}
+
+	code := "invalid_pagination"
+	if errors.Is(err, ErrPageTooDeep) {
+		code = "page_too_deep"
+	}
+	c.JSON(http.StatusBadRequest, APIError{Error: err.Error(), Code: code})
+	return Pagination{}, false
+}
+
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
+
+func (p Pagination) Meta(total int64) PaginationMeta {
+	return PaginationMeta{
+		Page:     p.Page,
+		PageSize: p.PageSize,
+		Total:    total,
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	maxInt := strconv.Itoa(math.MaxInt)
+	tests := []struct {
+		page, pageSize string
+		want           Pagination
+		wantErr        error
+	}{
+		{"", "", Pagination{Page: DefaultPage, PageSize: DefaultPageSize}, nil},
+		{"2", "500", Pagination{Page: 2, PageSize: MaxPageSize}, nil},
+		{"0", "", Pagination{}, ErrInvalidPagination},
+		{"-1", "", Pagination{}, ErrInvalidPagination},
+		{"", "0", Pagination{}, ErrInvalidPagination},
+		{"x", "", Pagination{}, ErrInvalidPagination},
+		{"99999999999999999999", "", Pagination{}, ErrInvalidPagination}, // Doesn't fit an int
+
+		// Offset exactly MaxOffset is the last allowed page
+		{"501", "20", Pagination{Page: 501, PageSize: 20}, nil},
+		{"502", "20", Pagination{}, ErrPageTooDeep},
+		{"101", "100", Pagination{Page: 101, PageSize: 100}, nil},
+		{"102", "100", Pagination{}, ErrPageTooDeep},
+		{"10001", "1", Pagination{Page: 10001, PageSize: 1}, nil},
+		{"10002", "1", Pagination{}, ErrPageTooDeep},
+		{"102", "117", Pagination{}, ErrPageTooDeep}, // page_size clamps to 100 first
+
+		// (page-1)*page_size would wrap around to a small or negative offset
+		{maxInt, "", Pagination{}, ErrPageTooDeep},
+		{maxInt, maxInt, Pagination{}, ErrPageTooDeep},
+		{strconv.Itoa(math.MaxInt/20 + 2), "20", Pagination{}, ErrPageTooDeep},
+	}
+
+	for _, tt := range tests {
+		got, err := ParsePagination(tt.page, tt.pageSize)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Errorf(
+				"ParsePagination(%q, %q) = %+v, %v; want %+v, %v",
+				tt.page, tt.pageSize, got, err, tt.want, tt.wantErr,
+			)
+		}
+		if err == nil && got.Offset() > MaxOffset {
+			t.Errorf("ParsePagination(%q, %q) allowed offset %d", tt.page, tt.pageSize, got.Offset())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ParseUUIDParam parses the named path parameter as a UUID.
+// Returns the ID or an error response is sent and false is returned
+func ParseUUIDParam(c *gin.Context, name string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(
+			http.StatusBadRequest, APIError{
+				Error: "Invalid " + name + ", expected a UUID",
+				Code:  "invalid_uuid",
+			},
+		)
+		return uuid.Nil, false
+	}
+	return id, true
+}
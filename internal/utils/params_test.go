@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestParseUUIDParam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var parsed uuid.UUID
+	router := gin.New()
+	router.GET(
+		"/subreddits/:id", func(c *gin.Context) {
+			id, ok := ParseUUIDParam(c, "id")
+			if !ok {
+				return // Error response already sent
+			}
+			parsed = id
+			c.Status(http.StatusNoContent)
+		},
+	)
+
+	v4 := uuid.MustParse("9b2c1f4e-8d3a-4e6b-a1c2-3d4e5f607182")
+	v7 := NewID()
+	valid := []struct {
+		param string
+		want  uuid.UUID
+	}{
+		{v4.String(), v4},
+		{v7.String(), v7},
+		{"9B2C1F4E-8D3A-4E6B-A1C2-3D4E5F607182", v4},
+	}
+	for _, tt := range valid {
+		parsed = uuid.Nil
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subreddits/"+tt.param, nil))
+		if rec.Code != http.StatusNoContent || parsed != tt.want {
+			t.Errorf("%s: status %d, parsed %s; want %s", tt.param, rec.Code, parsed, tt.want)
+		}
+	}
+
+	for _, param := range []string{"golang", "123", "9b2c1f4e-8d3a-4e6b-a1c2-3d4e5f60718", "9b2c1f4e-8d3a-4e6b-a1c2-3d4e5f60718g"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/subreddits/"+param, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", param, rec.Code)
+			continue
+		}
+		var body APIError
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Code != "invalid_uuid" ||
+			body.Error != "Invalid id, expected a UUID" {
+			t.Errorf("%s: body %s, want the invalid_uuid APIError", param, rec.Body)
+		}
+	}
+}
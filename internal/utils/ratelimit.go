@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitPrefix = "ratelimit:"
+
+// RateLimit allows cfg.Limit requests per client IP per cfg.Window on the routes it's registered for,
+// counted in Redis over fixed windows so every instance shares the budget. name keeps routes' budgets apart.
+// Clients over it get 429 rate_limited with Retry-After. Every counted response carries X-RateLimit-Limit,
+// X-RateLimit-Remaining and X-RateLimit-Reset (Unix seconds when the window ends), so clients can slow down
+// before hitting it. Without Redis, or when it fails, requests are let through without the headers: the limit
+// guards against abuse and isn't worth an outage.
+func RateLimit(redisClient *redis.Client, name string, cfg config.RateLimitConfig, clock Clock) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if redisClient == nil || cfg.Limit <= 0 || cfg.Window <= 0 {
+			c.Next()
+			return
+		}
+
+		now := clock.Now()
+		windowStart := now.Truncate(cfg.Window)
+		key := fmt.Sprintf("%s%s:%s:%d", rateLimitPrefix, name, c.ClientIP(), windowStart.Unix())
+
+		count, err := incrementWindow(c.Request.Context(), redisClient, key, cfg.Window)
+		if err != nil {
+			log.Printf("Rate limiter %s unavailable, letting the request through: %v", name, err)
+			c.Next()
+			return
+		}
+
+		windowEnd := windowStart.Add(cfg.Window)
+		c.Header("X-RateLimit-Limit", strconv.Itoa(cfg.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(max(int64(cfg.Limit)-count, 0), 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(windowEnd.Unix(), 10))
+
+		if count > int64(cfg.Limit) {
+			retryAfter := max(int(math.Ceil(windowEnd.Sub(now).Seconds())), 1)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(
+				http.StatusTooManyRequests, APIError{
+					Error: "Too many requests, please slow down",
+					Code:  "rate_limited",
+				},
+			)
+			return
+		}
+		c.Next()
+	}
+}
+
+func incrementWindow(ctx context.Context, redisClient *redis.Client, key string, window time.Duration) (int64, error) {
+	pipe := redisClient.Pipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
@@ -0,0 +1,103 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/testutil"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRateLimitedRouter(t *testing.T, cfg config.RateLimitConfig, clock utils.Clock) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	router := gin.New()
+	router.GET("/limited", utils.RateLimit(client, "test", cfg, clock), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func getFrom(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+	req.RemoteAddr = remoteAddr
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRateLimitRemainingDecrements(t *testing.T) {
+	windowStart := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(windowStart.Add(10 * time.Second))
+	router := newRateLimitedRouter(t, config.RateLimitConfig{Limit: 3, Window: time.Minute}, clock)
+	wantReset := strconv.FormatInt(windowStart.Add(time.Minute).Unix(), 10)
+
+	for i, want := range []struct {
+		status    int
+		remaining string
+	}{
+		{http.StatusOK, "2"},
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"}, // Stays at 0 past the limit instead of going negative
+	} {
+		recorder := getFrom(router, "192.0.2.1:1234")
+		if recorder.Code != want.status {
+			t.Errorf("request %d: status %d, want %d", i+1, recorder.Code, want.status)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %q, want %q", i+1, got, want.remaining)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 3", i+1, got)
+		}
+		if got := recorder.Header().Get("X-RateLimit-Reset"); got != wantReset {
+			t.Errorf("request %d: X-RateLimit-Reset = %q, want %q", i+1, got, wantReset)
+		}
+	}
+
+	recorder := getFrom(router, "192.0.2.1:1234")
+	if got := recorder.Header().Get("Retry-After"); got != "50" {
+		t.Errorf("Retry-After = %q, want the 50s left in the window", got)
+	}
+}
+
+func TestRateLimitRemainingPerClientAndWindow(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	router := newRateLimitedRouter(t, config.RateLimitConfig{Limit: 2, Window: time.Minute}, clock)
+
+	getFrom(router, "192.0.2.1:1234")
+	if got := getFrom(router, "192.0.2.2:1234").Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Errorf("other client: X-RateLimit-Remaining = %q, want its own budget of 1", got)
+	}
+	if got := getFrom(router, "192.0.2.1:1234").Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("same client: X-RateLimit-Remaining = %q, want 0", got)
+	}
+
+	clock.Advance(time.Minute)
+	if got := getFrom(router, "192.0.2.1:1234").Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Errorf("next window: X-RateLimit-Remaining = %q, want a fresh budget", got)
+	}
+}
+
+func TestRateLimitWithoutRedisSendsNoHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	cfg := config.RateLimitConfig{Limit: 1, Window: time.Minute}
+	router.GET("/limited", utils.RateLimit(nil, "test", cfg, utils.RealClock{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for range 2 {
+		recorder := getFrom(router, "192.0.2.1:1234")
+		if recorder.Code != http.StatusOK || recorder.Header().Get("X-RateLimit-Remaining") != "" {
+			t.Fatalf("status %d, X-RateLimit-Remaining %q; want the request let through without headers",
+				recorder.Code, recorder.Header().Get("X-RateLimit-Remaining"))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const recentWriterPrefix = "db:recent_writer:"
+
+// ReadYourWrites keeps replica lag out of sight for the user who caused it. Requests that write read from the
+// primary throughout, and a user whose write succeeded keeps reading from it for window afterwards.
+// Users are recognized from the access token here, route-level auth middlewares haven't run yet.
+func ReadYourWrites(
+	redisClient *redis.Client,
+	cfgJWT *config.JWTConfig,
+	clock Clock,
+	window time.Duration,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userID := requestUserID(c, cfgJWT, clock)
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Request = c.Request.WithContext(WithPrimaryReads(ctx))
+			c.Next()
+
+			if userID == "" || redisClient == nil || c.Writer.Status() >= http.StatusBadRequest {
+				return
+			}
+			// The request context may already be past its deadline, the marker must be written regardless
+			markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+			defer cancel()
+			if err := redisClient.Set(markCtx, recentWriterPrefix+userID, 1, window).Err(); err != nil {
+				log.Printf("Failed to mark recent writer %s: %v", userID, err)
+			}
+			return
+		}
+
+		if userID != "" && redisClient != nil {
+			recent, err := redisClient.Exists(ctx, recentWriterPrefix+userID).Result()
+			// Without Redis we can't tell, stale reads are worse than extra primary load
+			if err != nil || recent > 0 {
+				c.Request = c.Request.WithContext(WithPrimaryReads(ctx))
+			}
+		}
+		c.Next()
+	}
+}
+
+func requestUserID(c *gin.Context, cfgJWT *config.JWTConfig, clock Clock) string {
+	tokenString, ok := accessTokenFromRequest(c, cfgJWT)
+	if !ok {
+		return ""
+	}
+	userID, _, err := DecryptJWT(clock, tokenString, cfgJWT.Secret, TokenTypeAccess)
+	if err != nil {
+		return ""
+	}
+	return userID
+}
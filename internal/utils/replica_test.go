@@ -0,0 +1,80 @@
+package utils_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Andriy-Sydorenko/agora_backend/internal/config"
+	"github.com/Andriy-Sydorenko/agora_backend/internal/utils"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestReadYourWritesPinsRecentWriters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.JWTConfig{Secret: "test-secret", AccessTokenCookieKey: "access_token"}
+	clock := utils.RealClock{}
+	redisServer := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: redisServer.Addr(), MaxRetries: -1, DialerRetries: 1})
+
+	router := gin.New()
+	router.Use(utils.ReadYourWrites(client, cfg, clock, time.Minute))
+	reportPinned := func(c *gin.Context) {
+		if utils.PrimaryReads(c.Request.Context()) {
+			c.String(http.StatusOK, "primary")
+			return
+		}
+		c.String(http.StatusOK, "replica")
+	}
+	router.GET("/read", reportPinned)
+	router.POST("/write", reportPinned)
+	router.POST("/reject", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+
+	send := func(method, path, userID string) string {
+		req := httptest.NewRequest(method, path, nil)
+		if userID != "" {
+			token, err := utils.GenerateJWT(clock, cfg.Secret, utils.TokenTypeAccess, time.Hour, userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+
+	if got := send(http.MethodGet, "/read", "writer"); got != "replica" {
+		t.Errorf("read before any write went to the %s", got)
+	}
+	send(http.MethodPost, "/reject", "writer")
+	if got := send(http.MethodGet, "/read", "writer"); got != "replica" {
+		t.Errorf("read after a failed write went to the %s, only successful writes pin", got)
+	}
+	if got := send(http.MethodPost, "/write", "writer"); got != "primary" {
+		t.Errorf("write request read from the %s", got)
+	}
+	if got := send(http.MethodGet, "/read", "writer"); got != "primary" {
+		t.Errorf("writer's read after the write went to the %s", got)
+	}
+	if got := send(http.MethodGet, "/read", "bystander"); got != "replica" {
+		t.Errorf("another user's read went to the %s", got)
+	}
+	if got := send(http.MethodGet, "/read", ""); got != "replica" {
+		t.Errorf("anonymous read went to the %s", got)
+	}
+
+	redisServer.FastForward(2 * time.Minute)
+	if got := send(http.MethodGet, "/read", "writer"); got != "replica" {
+		t.Errorf("writer's read after the window went to the %s", got)
+	}
+
+	// Without Redis nobody can be told apart, so signed-in reads stay on the primary
+	redisServer.Close()
+	if got := send(http.MethodGet, "/read", "writer"); got != "primary" {
+		t.Errorf("read with Redis down went to the %s", got)
+	}
+}
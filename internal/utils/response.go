@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIError is the JSON error body returned by the API.
+// Code is an optional stable identifier clients can branch on instead of parsing the message.
+type APIError struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+}
+
+// NotFoundHandler is registered as gin's NoRoute so unknown paths get JSON like the rest of the API
+func NotFoundHandler(c *gin.Context) {
+	c.JSON(
+		http.StatusNotFound, APIError{
+			Error: "Resource not found",
+			Code:  "not_found",
+		},
+	)
+}
+
+// MethodNotAllowedHandler is registered as gin's NoMethod; gin has already set the Allow header by then
+func MethodNotAllowedHandler(c *gin.Context) {
+	c.JSON(
+		http.StatusMethodNotAllowed, APIError{
+			Error: "Method not allowed",
+			Code:  "method_not_allowed",
+		},
+	)
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrInvalidSortField = errors.New("invalid sort field")
+	ErrInvalidSortOrder = errors.New("invalid sort order, expected asc or desc")
+)
+
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// SortSpec is a validated ordering that repositories can safely turn into an ORDER BY clause
+type SortSpec struct {
+	Column string
+	Desc   bool
+}
+
+// SortField is a DB column clients may sort by, Desc is its order when the request names none
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+// SortFields maps the field names accepted from clients to the actual DB columns
+type SortFields map[string]SortField
+
+// ParseSort resolves raw `sort`/`order` query values against a whitelist.
+// Empty values fall back to the given default (a field without an order gets its own default order),
+// unknown ones are rejected so user input never reaches SQL.
+func ParseSort(field, order string, allowed SortFields, fallback SortSpec) (SortSpec, error) {
+	spec := fallback
+
+	field = strings.ToLower(strings.TrimSpace(field))
+	if field != "" {
+		sortField, ok := allowed[field]
+		if !ok {
+			return SortSpec{}, ErrInvalidSortField
+		}
+		spec = SortSpec{Column: sortField.Column, Desc: sortField.Desc}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case "":
+	case SortOrderAsc:
+		spec.Desc = false
+	case SortOrderDesc:
+		spec.Desc = true
+	default:
+		return SortSpec{}, ErrInvalidSortOrder
+	}
+
+	return spec, nil
+}
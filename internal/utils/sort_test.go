@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+var testSortFields = SortFields{
+	"created_at": {Column: "created_at", Desc: true},
+	"name":       {Column: "name"},
+}
+
+func TestParseSort(t *testing.T) {
+	fallback := SortSpec{Column: "created_at", Desc: true}
+	tests := []struct {
+		name    string
+		field   string
+		order   string
+		want    SortSpec
+		wantErr error
+	}{
+		{name: "defaults", want: fallback},
+		{name: "field default order asc", field: "name", want: SortSpec{Column: "name"}},
+		{name: "field default order desc", field: "created_at", want: SortSpec{Column: "created_at", Desc: true}},
+		{name: "explicit order wins", field: "name", order: "desc", want: SortSpec{Column: "name", Desc: true}},
+		{name: "order only", order: "asc", want: SortSpec{Column: "created_at"}},
+		{name: "case and spaces", field: " Name ", order: " ASC ", want: SortSpec{Column: "name"}},
+		{name: "unknown field", field: "password", wantErr: ErrInvalidSortField},
+		{name: "unknown order", field: "name", order: "sideways", wantErr: ErrInvalidSortOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := ParseSort(tt.field, tt.order, testSortFields, fallback)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Errorf("ParseSort() = %+v, want %+v", got, tt.want)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// swrLoadTimeout bounds a load, which runs detached from the request that triggered it
+const swrLoadTimeout = 5 * time.Second
+
+// cacheStats is published under "cache" on GET /admin/metrics, keyed "<name>.hit|stale|miss"
+var cacheStats = expvar.NewMap("cache")
+
+// SWRCache is a stale-while-revalidate Redis cache. Within SoftTTL entries are served as is, up to HardTTL
+// they're still served while one background load refreshes them, after that callers wait for the load.
+// Concurrent loads of a key are collapsed into one per instance.
+type SWRCache[V any] struct {
+	name    string
+	redis   *redis.Client
+	prefix  string
+	softTTL time.Duration
+	hardTTL time.Duration
+	clock   Clock
+	group   singleflight.Group
+
+	softTTLFunc func(ctx context.Context) time.Duration
+}
+
+type swrEntry[V any] struct {
+	Value      V         `json:"v"`
+	FreshUntil time.Time `json:"f"`
+}
+
+// NewSWRCache keys entries as prefix+key, name only labels the metrics
+func NewSWRCache[V any](
+	name string,
+	redisClient *redis.Client,
+	prefix string,
+	softTTL, hardTTL time.Duration,
+	clock Clock,
+) *SWRCache[V] {
+	return &SWRCache[V]{
+		name:    name,
+		redis:   redisClient,
+		prefix:  prefix,
+		softTTL: softTTL,
+		hardTTL: max(hardTTL, softTTL),
+		clock:   clock,
+	}
+}
+
+// WithSoftTTL reads the soft TTL on every write instead, so it can change at runtime. It's capped at the hard TTL.
+func (c *SWRCache[V]) WithSoftTTL(softTTL func(ctx context.Context) time.Duration) *SWRCache[V] {
+	c.softTTLFunc = softTTL
+	return c
+}
+
+func (c *SWRCache[V]) freshFor(ctx context.Context) time.Duration {
+	if c.softTTLFunc == nil {
+		return c.softTTL
+	}
+	return min(c.softTTLFunc(ctx), c.hardTTL)
+}
+
+// Get returns the cached value for key, calling load when it's missing or stale. Without Redis it always loads.
+func (c *SWRCache[V]) Get(ctx context.Context, key string, load func(ctx context.Context) (V, error)) (V, error) {
+	if c.redis == nil || c.hardTTL <= 0 {
+		return load(ctx)
+	}
+
+	if cached, err := c.redis.Get(ctx, c.prefix+key).Bytes(); err == nil {
+		var entry swrEntry[V]
+		if err := json.Unmarshal(cached, &entry); err == nil {
+			if c.clock.Now().Before(entry.FreshUntil) {
+				cacheStats.Add(c.name+".hit", 1)
+				return entry.Value, nil
+			}
+
+			cacheStats.Add(c.name+".stale", 1)
+			go func() {
+				if _, err := c.refresh(context.WithoutCancel(ctx), key, load); err != nil {
+					log.Printf("Failed to refresh %s cache: %v", c.name, err)
+				}
+			}()
+			return entry.Value, nil
+		}
+	}
+
+	cacheStats.Add(c.name+".miss", 1)
+	return c.refresh(ctx, key, load)
+}
+
+// Invalidate drops key so the next Get loads it. Errors are logged, the entry then expires on its own.
+func (c *SWRCache[V]) Invalidate(ctx context.Context, key string) {
+	if c.redis == nil {
+		return
+	}
+	if err := c.redis.Del(ctx, c.prefix+key).Err(); err != nil {
+		log.Printf("Failed to invalidate %s cache: %v", c.name, err)
+	}
+}
+
+// refresh loads key and caches the result. The load is shared by every caller waiting on key, so it runs
+// detached from the ctx of the caller that started it: that request ending must not fail the others.
+// Each caller still stops waiting once its own ctx is done.
+func (c *SWRCache[V]) refresh(ctx context.Context, key string, load func(ctx context.Context) (V, error)) (V, error) {
+	loaded := c.group.DoChan(
+		key, func() (any, error) {
+			loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), swrLoadTimeout)
+			defer cancel()
+
+			value, err := load(loadCtx)
+			if err != nil {
+				return value, err
+			}
+
+			payload, err := json.Marshal(swrEntry[V]{Value: value, FreshUntil: c.clock.Now().Add(c.freshFor(loadCtx))})
+			if err != nil {
+				return value, nil
+			}
+			if err := c.redis.Set(loadCtx, c.prefix+key, payload, c.hardTTL).Err(); err != nil {
+				log.Printf("Failed to write %s cache: %v", c.name, err)
+			}
+			return value, nil
+		},
+	)
+
+	select {
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	case result := <-loaded:
+		value, _ := result.Val.(V)
+		return value, result.Err
+	}
+}
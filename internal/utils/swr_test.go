@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSWRCacheInvalidate(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	cache := NewSWRCache[int]("test", client, "test:", time.Hour, time.Hour, RealClock{})
+	ctx := context.Background()
+
+	loads := 0
+	load := func(context.Context) (int, error) {
+		loads++
+		return loads, nil
+	}
+
+	for range 2 {
+		if got, err := cache.Get(ctx, "key", load); err != nil || got != 1 {
+			t.Fatalf("Get = %d, %v; want the first load", got, err)
+		}
+	}
+
+	cache.Invalidate(ctx, "key")
+	if got, err := cache.Get(ctx, "key", load); err != nil || got != 2 {
+		t.Fatalf("Get after Invalidate = %d, %v; want a fresh load", got, err)
+	}
+}
+
+// A miss is loaded once for every caller waiting on it. The caller that started the load going away
+// (request cancelled, client gone) must not fail the load for the rest.
+func TestSWRCacheMissOutlivesFirstCaller(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	cache := NewSWRCache[int]("test", client, "test:", time.Hour, time.Hour, RealClock{})
+
+	var loads atomic.Int32
+	started, release := make(chan struct{}), make(chan struct{})
+	loadErr := make(chan error, 1)
+	load := func(ctx context.Context) (int, error) {
+		loads.Add(1)
+		close(started)
+		<-release
+		loadErr <- ctx.Err()
+		return 42, nil
+	}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstDone := make(chan error, 1)
+	go func() {
+		_, err := cache.Get(firstCtx, "key", load)
+		firstDone <- err
+	}()
+	<-started
+
+	const callers = 100
+	results := make([]int, callers)
+	errs := make([]error, callers)
+	var wg, waiting sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		waiting.Add(1)
+		go func() {
+			defer wg.Done()
+			waiting.Done()
+			results[i], errs[i] = cache.Get(context.Background(), "key", load)
+		}()
+	}
+	waiting.Wait()
+	time.Sleep(50 * time.Millisecond) // Lets the callers join the in-flight load
+
+	cancelFirst()
+	if err := <-firstDone; !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled caller got %v, want context.Canceled", err)
+	}
+	close(release)
+	wg.Wait()
+
+	if err := <-loadErr; err != nil {
+		t.Fatalf("load ran on a cancelled context: %v", err)
+	}
+	for i := range callers {
+		if errs[i] != nil || results[i] != 42 {
+			t.Fatalf("caller %d got %d, %v", i, results[i], errs[i])
+		}
+	}
+	if n := loads.Load(); n != 1 {
+		t.Fatalf("loaded %d times, want once", n)
+	}
+	if got, err := cache.Get(context.Background(), "key", load); err != nil || got != 42 {
+		t.Fatalf("Get after the load = %d, %v; want the cached value", got, err)
+	}
+}
+
+func TestSWRCacheMissStopsWaitingOnCallerDeadline(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	cache := NewSWRCache[int]("test", client, "test:", time.Hour, time.Hour, RealClock{})
+
+	release := make(chan struct{})
+	defer close(release)
+	load := func(ctx context.Context) (int, error) {
+		<-release
+		return 1, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Get(ctx, "key", load); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeout puts a deadline on the request context, so DB queries and outgoing calls made with it are
+// cancelled once it passes (repository timeouts only ever shorten it, see WithQueryTimeout).
+// Whatever the handler tries to write after the deadline is dropped and the client gets 503 request_timeout.
+// A handler ignoring its context isn't preempted, it only loses its response.
+func RequestTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		writer := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) || c.Writer.Written() {
+			return
+		}
+		c.JSON(
+			http.StatusServiceUnavailable, APIError{
+				Error: "Request timed out",
+				Code:  "request_timeout",
+			},
+		)
+	}
+}
+
+// timeoutWriter passes writes through until the request deadline, then swallows them
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+}
+
+func (w *timeoutWriter) expired() bool {
+	return w.ctx.Err() != nil && !w.ResponseWriter.Written()
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.Flush()
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveWithTimeout(timeout time.Duration, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestTimeout(timeout))
+	router.GET("/", handler)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func assertRequestTimeout(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+
+	var body APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || body.Code != "request_timeout" {
+		t.Errorf("slow handler = %d %+v, want 503 request_timeout", rec.Code, body)
+	}
+}
+
+func TestRequestTimeoutCancelsSlowHandler(t *testing.T) {
+	var handlerErr error
+	started := time.Now()
+	rec := serveWithTimeout(
+		50*time.Millisecond, func(c *gin.Context) {
+			// Stands in for a DB query running on the request context
+			ctx, cancel := WithQueryTimeout(c.Request.Context(), time.Hour)
+			defer cancel()
+			select {
+			case <-ctx.Done():
+				handlerErr = ctx.Err()
+			case <-time.After(5 * time.Second):
+			}
+			c.JSON(http.StatusOK, gin.H{"late": true})
+		},
+	)
+
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("request took %v, the deadline didn't reach the query", elapsed)
+	}
+	if !errors.Is(handlerErr, context.DeadlineExceeded) {
+		t.Errorf("query ctx err = %v, want DeadlineExceeded from the request deadline", handlerErr)
+	}
+	assertRequestTimeout(t, rec)
+}
+
+// A handler that ignores its context still runs to the end, but its late response is dropped
+func TestRequestTimeoutDropsLateResponse(t *testing.T) {
+	rec := serveWithTimeout(
+		20*time.Millisecond, func(c *gin.Context) {
+			time.Sleep(60 * time.Millisecond)
+			c.JSON(http.StatusOK, gin.H{"late": true})
+		},
+	)
+	assertRequestTimeout(t, rec)
+}
+
+func TestRequestTimeoutLeavesFastHandlers(t *testing.T) {
+	for _, timeout := range []time.Duration{time.Second, 0} {
+		var hasDeadline bool
+		rec := serveWithTimeout(
+			timeout, func(c *gin.Context) {
+				_, hasDeadline = c.Request.Context().Deadline()
+				c.JSON(http.StatusCreated, gin.H{"ok": true})
+			},
+		)
+		if rec.Code != http.StatusCreated || rec.Body.String() != `{"ok":true}` {
+			t.Errorf("timeout %v: fast handler = %d %s, want its own 201", timeout, rec.Code, rec.Body)
+		}
+		if hasDeadline != (timeout > 0) {
+			t.Errorf("timeout %v: request ctx has deadline = %v", timeout, hasDeadline)
+		}
+	}
+}
+
+// A query timeout shorter than what's left of the request still applies
+func TestRequestTimeoutKeepsShorterQueryTimeout(t *testing.T) {
+	var requestDeadline, queryDeadline time.Time
+	serveWithTimeout(
+		time.Minute, func(c *gin.Context) {
+			requestDeadline, _ = c.Request.Context().Deadline()
+			ctx, cancel := WithQueryTimeout(c.Request.Context(), time.Second)
+			defer cancel()
+			queryDeadline, _ = ctx.Deadline()
+			c.Status(http.StatusNoContent)
+		},
+	)
+	if !queryDeadline.Before(requestDeadline.Add(-50 * time.Second)) {
+		t.Errorf("query deadline %v, request deadline %v; want the query's 1s bound", queryDeadline, requestDeadline)
+	}
+}
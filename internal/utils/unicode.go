@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeText returns the NFC form of s, so visually identical input is stored and compared as the same bytes
+func NormalizeText(s string) string {
+	return norm.NFC.String(s)
+}
+
+// HasUnsafeRunes reports control, format (zero-width, ZWJ, bidi overrides and isolates, BOM)
+// and line/paragraph separator codepoints, which can hide or reorder text in single-line names.
+// Emoji ZWJ sequences are rejected too, as the joiner can't be told apart from a hidden one.
+func HasUnsafeRunes(s string) bool {
+	return strings.IndexFunc(
+		s, func(r rune) bool {
+			return unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp)
+		},
+	) >= 0
+}
+
+// confusables is a small subset of the UTS #39 confusables table: Cyrillic and Greek lookalikes of
+// Latin letters plus the ASCII pairs that commonly pass for each other. Keys are lowercase,
+// Skeleton folds case before looking them up.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c',
+	'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i', 'ј': 'j', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x',
+	// Latin and ASCII
+	'ı': 'i', 'ɡ': 'g', '1': 'l', '|': 'l', '0': 'o',
+}
+
+// upperConfusables covers capitals that look like a Latin capital while their lowercase form doesn't
+// look like the Latin lowercase one (Greek Ν is an N, ν is a v). Values are already lowercase.
+var upperConfusables = map[rune]rune{
+	'Β': 'b', 'Ζ': 'z', 'Η': 'h', 'Μ': 'm', 'Ν': 'n', 'Υ': 'y',
+}
+
+// Skeleton maps s to its confusable skeleton (UTS #39, section 4): two strings with the same skeleton
+// look alike, e.g. "аdmin" with a Cyrillic а, "ADMIN" and "adrnin" all reduce to "admin".
+// Unlike the spec the result is also case-folded, so casing variants collide as well. Case is folded
+// before the confusables are mapped, otherwise "I" would become "l" while "i" stays "i".
+// For ASCII input this must stay in sync with the backfill in migration 0024.
+func Skeleton(s string) string {
+	decomposed := norm.NFD.String(s)
+
+	var b strings.Builder
+	b.Grow(len(decomposed))
+	for _, r := range decomposed {
+		if unicode.Is(unicode.Mn, r) || unicode.In(r, unicode.Cc, unicode.Cf) {
+			continue // Combining marks and invisible characters don't change how the name reads
+		}
+		if mapped, ok := upperConfusables[r]; ok {
+			b.WriteRune(mapped)
+			continue
+		}
+		r = unicode.ToLower(r)
+		if mapped, ok := confusables[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+
+	skeleton := strings.ReplaceAll(b.String(), "rn", "m")
+	return strings.ReplaceAll(skeleton, "vv", "w")
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSkeletonFoldsCaseBeforeMapping(t *testing.T) {
+	tests := []struct {
+		name, a, b string
+	}{
+		{"capital I is an i", "Ivan", "ivan"},
+		{"all caps", "ADMIN", "admin"},
+		{"digit one is an l", "1ucky", "lucky"},
+		{"pipe is an l", "|ucky", "lucky"},
+		{"zero is an o", "r00t", "root"},
+		{"rn reads as m", "adrnin", "admin"},
+		{"vv reads as w", "vvizard", "wizard"},
+		{"Cyrillic a", "аdmin", "admin"},
+		{"Cyrillic capitals", "АDМIN", "admin"},
+		{"Cyrillic i", "іvan", "ivan"},
+		{"Greek capitals", "ΝΕΟ", "neo"},
+		{"Greek lowercase nu is a v", "νova", "vova"},
+		{"combining accent", "admín", "admin"},
+		{"precomposed accent", "ádmin", "admin"},
+		{"zero-width joiner", "ad‍min", "admin"},
+		{"zero-width space", "ad​min", "admin"},
+		{"bidi override", "‮admin", "admin"},
+		{"soft hyphen", "ad­min", "admin"},
+	}
+	for _, tt := range tests {
+		if got, want := Skeleton(tt.a), Skeleton(tt.b); got != want {
+			t.Errorf("%s: Skeleton(%q) = %q, Skeleton(%q) = %q", tt.name, tt.a, got, tt.b, want)
+		}
+	}
+}
+
+func TestSkeletonKeepsDistinctNamesApart(t *testing.T) {
+	pairs := [][2]string{
+		{"Ivan", "lvan"}, // Was a collision while I mapped to l before case folding
+		{"bill", "biii"},
+		{"admin", "admins"},
+		{"nova", "vova"},
+	}
+	for _, pair := range pairs {
+		if Skeleton(pair[0]) == Skeleton(pair[1]) {
+			t.Errorf("%q and %q share the skeleton %q", pair[0], pair[1], Skeleton(pair[0]))
+		}
+	}
+}
+
+// The 0024 backfill reimplements Skeleton for ASCII usernames in SQL
+func TestSkeletonMatchesMigrationForASCII(t *testing.T) {
+	sqlSkeleton := func(s string) string {
+		lowered := []rune{}
+		for _, r := range s {
+			if 'A' <= r && r <= 'Z' {
+				r += 'a' - 'A'
+			}
+			switch r {
+			case '1', '|':
+				r = 'l'
+			case '0':
+				r = 'o'
+			}
+			lowered = append(lowered, r)
+		}
+		out := string(lowered)
+		out = strings.ReplaceAll(out, "rn", "m")
+		return strings.ReplaceAll(out, "vv", "w")
+	}
+
+	for _, name := range []string{"Ivan", "ADRNIN", "r00t_1|", "VVizard", "Mixed_Case-123"} {
+		if got, want := Skeleton(name), sqlSkeleton(name); got != want {
+			t.Errorf("Skeleton(%q) = %q, migration gives %q", name, got, want)
+		}
+	}
+}